@@ -5,27 +5,53 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/juice-shop/multi-juicer/balancer/pkg/announcements"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/instances"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/scoring"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
 	"github.com/juice-shop/multi-juicer/balancer/routes"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
 	bundle := bundle.New()
-	scoringService := scoring.NewScoringService(bundle)
-
 	ctx := context.Background()
 
+	instanceManager, err := instances.New(ctx, bundle)
+	if err != nil {
+		log.Fatalf("Failed to start the instance manager: %v", err)
+	}
+	bundle.Instances = instanceManager
+	scoringService := scoring.NewScoringService(bundle)
+	announcementService := announcements.NewAnnouncementService(bundle)
+
 	go StartMetricsServer()
 	scoringService.CalculateAndCacheScoreBoard(ctx)
 	go scoringService.StartingScoringWorker(ctx)
-	StartBalancerServer(bundle, scoringService)
+	if err := announcementService.LoadAnnouncements(ctx); err != nil {
+		bundle.Log.Printf("Failed to load persisted announcements: %v", err)
+	}
+	go announcementService.StartAnnouncementWorker(ctx)
+	if err := bundle.JoinCodes.LoadJoinCodes(ctx); err != nil {
+		bundle.Log.Printf("Failed to load persisted join codes: %v", err)
+		if bundle.Config.JoinCodes.Required {
+			bundle.Log.Printf("Join codes are required. New teams can't be created until the join codes are loaded by the join code watcher")
+		}
+	}
+	go bundle.JoinCodes.StartJoinCodeWorker(ctx)
+	if err := bundle.Waitlist.LoadWaitlist(ctx); err != nil {
+		bundle.Log.Printf("Failed to load persisted waitlist: %v", err)
+	}
+	go bundle.Waitlist.StartWaitlistWorker(ctx)
+	go routes.StartWaitlistProvisioner(ctx, bundle)
+	go teamcookie.StartSessionWorker(ctx, bundle)
+	StartBalancerServer(bundle, scoringService, announcementService)
 }
 
-func StartBalancerServer(bundle *bundle.Bundle, scoringService *scoring.ScoringService) {
+func StartBalancerServer(bundle *bundle.Bundle, scoringService *scoring.ScoringService, announcementService *announcements.AnnouncementService) {
 	router := http.NewServeMux()
-	routes.AddRoutes(router, bundle, scoringService)
+	routes.AddRoutes(router, bundle, scoringService, announcementService)
 
 	bundle.Log.Println("Starting MultiJuicer balancer on :8080")
 	server := &http.Server{
@@ -0,0 +1,151 @@
+package adminoidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"golang.org/x/oauth2"
+)
+
+var ErrNotAllowed = errors.New("identity is not allowed to log in as admin")
+
+// Identity is the subset of the id token claims relevant to decide if a user is allowed to log in as admin
+type Identity struct {
+	Subject string
+	Email   string
+	Groups  []string
+}
+
+// Authenticator handles the OpenID Connect authorization code flow for the admin login
+type Authenticator struct {
+	config       bundle.AdminOIDCConfig
+	verifier     *oidc.IDTokenVerifier
+	oauth2Config oauth2.Config
+}
+
+// NewAuthenticator discovers the endpoints of the configured issuer.
+// The passed context is used for all further requests against the identity provider (key set fetches), so it should not be request scoped.
+func NewAuthenticator(ctx context.Context, config bundle.AdminOIDCConfig) (*Authenticator, error) {
+	provider, err := oidc.NewProvider(ctx, config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover oidc provider '%s': %w", config.IssuerURL, err)
+	}
+
+	scopes := config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"email", "profile"}
+	}
+
+	return &Authenticator{
+		config:   config,
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+		oauth2Config: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       append([]string{oidc.ScopeOpenID}, scopes...),
+		},
+	}, nil
+}
+
+// AuthCodeURL returns the url of the identity provider the admin should be redirected to to log in
+func (a *Authenticator) AuthCodeURL(state, nonce, pkceVerifier string) string {
+	return a.oauth2Config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(pkceVerifier))
+}
+
+// Exchange trades the authorization code for an id token, verifies it and checks if the identity is allowed to log in as admin
+func (a *Authenticator) Exchange(ctx context.Context, code, nonce, pkceVerifier string) (*Identity, error) {
+	token, err := a.oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(pkceVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response did not contain an id_token")
+	}
+	idToken, err := a.verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id token nonce does not match")
+	}
+
+	claims := map[string]any{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode id token claims: %w", err)
+	}
+
+	identity := &Identity{
+		Subject: idToken.Subject,
+		Groups:  getGroupsClaim(claims, a.groupsClaim()),
+	}
+	// only trust the email if the provider doesn't explicitly mark it as unverified
+	if email, ok := claims["email"].(string); ok && claims["email_verified"] != false {
+		identity.Email = strings.ToLower(email)
+	}
+
+	if !a.IsAllowed(identity) {
+		return identity, ErrNotAllowed
+	}
+	return identity, nil
+}
+
+// IsAllowed checks if the identity matches one of the allowed emails or groups
+func (a *Authenticator) IsAllowed(identity *Identity) bool {
+	if identity.Email != "" {
+		for _, allowedEmail := range a.config.AllowedEmails {
+			if strings.EqualFold(allowedEmail, identity.Email) {
+				return true
+			}
+		}
+	}
+	for _, group := range identity.Groups {
+		if slices.Contains(a.config.AllowedGroups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Authenticator) groupsClaim() string {
+	if a.config.GroupsClaim == "" {
+		return "groups"
+	}
+	return a.config.GroupsClaim
+}
+
+// getGroupsClaim supports both providers sending the groups as a list and as a single string
+func getGroupsClaim(claims map[string]any, claimName string) []string {
+	switch value := claims[claimName].(type) {
+	case string:
+		return []string{value}
+	case []any:
+		groups := []string{}
+		for _, group := range value {
+			if groupString, ok := group.(string); ok {
+				groups = append(groups, groupString)
+			}
+		}
+		return groups
+	default:
+		return []string{}
+	}
+}
+
+// GenerateRandomToken creates a random hex string used for the state and nonce of the login flow
+func GenerateRandomToken() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
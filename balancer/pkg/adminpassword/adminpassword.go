@@ -0,0 +1,145 @@
+package adminpassword
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var bcryptPrefixes = []string{"$2a$", "$2b$", "$2y$"}
+
+const argon2idPrefix = "$argon2id$"
+
+// IsHash returns true if the configured password is a bcrypt or argon2id hash instead of a plaintext password
+func IsHash(configured string) bool {
+	return isBcryptHash(configured) || strings.HasPrefix(configured, argon2idPrefix)
+}
+
+func isBcryptHash(configured string) bool {
+	for _, prefix := range bcryptPrefixes {
+		if strings.HasPrefix(configured, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks the provided password against the configured one, which is either a bcrypt hash, a argon2id hash in the PHC string format or a plaintext password.
+// Plaintext passwords are compared in constant time
+func Verify(configured, provided string) bool {
+	if isBcryptHash(configured) {
+		return bcrypt.CompareHashAndPassword([]byte(configured), []byte(provided)) == nil
+	}
+	if strings.HasPrefix(configured, argon2idPrefix) {
+		params, err := parseArgon2idHash(configured)
+		if err != nil {
+			return false
+		}
+		providedHash := argon2.IDKey([]byte(provided), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.hash)))
+		return subtle.ConstantTimeCompare(providedHash, params.hash) == 1
+	}
+	// hashing both values first ensures that the comparison doesn't leak the length of the configured password
+	configuredDigest := sha256.Sum256([]byte(configured))
+	providedDigest := sha256.Sum256([]byte(provided))
+	return subtle.ConstantTimeCompare(configuredDigest[:], providedDigest[:]) == 1
+}
+
+// Validate returns an error if the configured password looks like a hash but can't be used to verify passwords
+func Validate(configured string) error {
+	if isBcryptHash(configured) {
+		if _, err := bcrypt.Cost([]byte(configured)); err != nil {
+			return fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+		return nil
+	}
+	if strings.HasPrefix(configured, argon2idPrefix) {
+		if _, err := parseArgon2idHash(configured); err != nil {
+			return fmt.Errorf("invalid argon2id hash: %w", err)
+		}
+	}
+	return nil
+}
+
+const minPasswordLength = 8
+
+var commonPasswords = map[string]bool{
+	"admin":       true,
+	"password":    true,
+	"passw0rd":    true,
+	"12345678":    true,
+	"123456789":   true,
+	"qwertyui":    true,
+	"changeme":    true,
+	"juiceshop":   true,
+	"multijuicer": true,
+}
+
+// GetWeaknesses returns the reasons why a plaintext password is considered weak. Empty for hashes and strong passwords
+func GetWeaknesses(configured string) []string {
+	if IsHash(configured) {
+		return nil
+	}
+	weaknesses := []string{}
+	if len(configured) < minPasswordLength {
+		weaknesses = append(weaknesses, fmt.Sprintf("shorter than %d characters", minPasswordLength))
+	}
+	if commonPasswords[strings.ToLower(configured)] {
+		weaknesses = append(weaknesses, "commonly used password")
+	}
+	if len(configured) > 0 && strings.Count(configured, configured[:1]) == len(configured) {
+		weaknesses = append(weaknesses, "consists of a single repeated character")
+	}
+	return weaknesses
+}
+
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	hash        []byte
+}
+
+// parses hashes in the format: $argon2id$v=19$m=65536,t=3,p=4$<base64 salt>$<base64 hash>
+func parseArgon2idHash(encoded string) (*argon2idParams, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return nil, errors.New("expected format '$argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<hash>'")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, fmt.Errorf("failed to parse version: %w", err)
+	}
+	if version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	params := &argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters: %w", err)
+	}
+	if params.memory == 0 || params.iterations == 0 || params.parallelism == 0 {
+		return nil, errors.New("memory, iterations and parallelism must be greater than 0")
+	}
+
+	var err error
+	params.salt, err = base64.RawStdEncoding.Strict().DecodeString(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode salt: %w", err)
+	}
+	params.hash, err = base64.RawStdEncoding.Strict().DecodeString(parts[5])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode hash: %w", err)
+	}
+	if len(params.hash) == 0 {
+		return nil, errors.New("hash must not be empty")
+	}
+	return params, nil
+}
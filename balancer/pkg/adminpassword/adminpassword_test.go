@@ -0,0 +1,65 @@
+package adminpassword
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// argon2id hash of "correct-horse-battery-staple" with m=1024,t=1,p=1
+const testArgon2idHash = "$argon2id$v=19$m=1024,t=1,p=1$c29tZXNhbHRzb21lc2FsdA$a1TVs9857fgWSqRvA+jADQ2H3EI/GRhLdJvO1ZHE3l8"
+
+func TestVerify(t *testing.T) {
+	t.Run("compares plaintext passwords", func(t *testing.T) {
+		assert.True(t, Verify("correct-horse-battery-staple", "correct-horse-battery-staple"))
+		assert.False(t, Verify("correct-horse-battery-staple", "correct-horse-battery-stapl"))
+		assert.False(t, Verify("correct-horse-battery-staple", ""))
+	})
+
+	t.Run("verifies bcrypt hashes", func(t *testing.T) {
+		hash, err := bcrypt.GenerateFromPassword([]byte("correct-horse-battery-staple"), bcrypt.MinCost)
+		assert.NoError(t, err)
+
+		assert.True(t, Verify(string(hash), "correct-horse-battery-staple"))
+		assert.False(t, Verify(string(hash), "wrong-password"))
+		// the hash itself must not be accepted as password
+		assert.False(t, Verify(string(hash), string(hash)))
+	})
+
+	t.Run("verifies argon2id hashes", func(t *testing.T) {
+		assert.True(t, Verify(testArgon2idHash, "correct-horse-battery-staple"))
+		assert.False(t, Verify(testArgon2idHash, "wrong-password"))
+		assert.False(t, Verify(testArgon2idHash, testArgon2idHash))
+	})
+
+	t.Run("rejects everything for malformed argon2id hashes", func(t *testing.T) {
+		assert.False(t, Verify("$argon2id$v=19$m=1024,t=1,p=1$invalid", "correct-horse-battery-staple"))
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("accepts plaintext passwords and valid hashes", func(t *testing.T) {
+		hash, _ := bcrypt.GenerateFromPassword([]byte("correct-horse-battery-staple"), bcrypt.MinCost)
+		assert.NoError(t, Validate("correct-horse-battery-staple"))
+		assert.NoError(t, Validate(string(hash)))
+		assert.NoError(t, Validate(testArgon2idHash))
+	})
+
+	t.Run("rejects malformed hashes", func(t *testing.T) {
+		assert.Error(t, Validate("$2a$10$tooshort"))
+		assert.Error(t, Validate("$argon2id$v=19$m=1024,t=1,p=1$c29tZXNhbHQ"))
+		assert.Error(t, Validate("$argon2id$v=16$m=1024,t=1,p=1$c29tZXNhbHQ$aGFzaA"))
+		assert.Error(t, Validate("$argon2id$v=19$m=0,t=1,p=1$c29tZXNhbHQ$aGFzaA"))
+	})
+}
+
+func TestGetWeaknesses(t *testing.T) {
+	assert.Empty(t, GetWeaknesses("correct-horse-battery-staple"))
+	assert.Empty(t, GetWeaknesses("ABCD1234"))
+	assert.Equal(t, []string{"shorter than 8 characters"}, GetWeaknesses("abc123"))
+	assert.Equal(t, []string{"commonly used password"}, GetWeaknesses("Password"))
+	assert.Equal(t, []string{"shorter than 8 characters", "commonly used password"}, GetWeaknesses("admin"))
+	assert.Equal(t, []string{"consists of a single repeated character"}, GetWeaknesses("aaaaaaaaaa"))
+	assert.Empty(t, GetWeaknesses(testArgon2idHash))
+}
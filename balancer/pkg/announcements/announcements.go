@@ -0,0 +1,347 @@
+package announcements
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/retry"
+)
+
+// ConfigMapName is the name of the ConfigMap the announcements get persisted in, so that they survive balancer restarts
+const ConfigMapName = "balancer-announcements"
+
+const configMapDataKey = "announcements.json"
+
+// MaxAnnouncements limits how many announcements are kept. When exceeded the oldest announcements get dropped to stay well below the ConfigMap size limit
+const MaxAnnouncements = 100
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+func (s Severity) IsValid() bool {
+	switch s {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+		return true
+	default:
+		return false
+	}
+}
+
+// Announcement is a message sent by an admin to all teams or a subset of teams
+type Announcement struct {
+	Id       string   `json:"id"`
+	Message  string   `json:"message"`
+	Severity Severity `json:"severity"`
+	// Teams the announcement is targeted at. Empty if the announcement is meant for all teams
+	Teams     []string  `json:"teams,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsVisibleForTeam returns true if the announcement is either targeted at all teams or explicitly at the given team
+func (a *Announcement) IsVisibleForTeam(team string) bool {
+	return len(a.Teams) == 0 || slices.Contains(a.Teams, team)
+}
+
+var ErrAnnouncementNotFound = errors.New("announcement not found")
+
+type AnnouncementService struct {
+	bundle *bundle.Bundle
+
+	announcements      []Announcement
+	announcementsMutex *sync.RWMutex
+
+	lastUpdate time.Time
+	// updated gets closed and replaced whenever the announcements change to wake up all waiting long-polls
+	updated chan struct{}
+}
+
+func NewAnnouncementService(bundle *bundle.Bundle) *AnnouncementService {
+	return &AnnouncementService{
+		bundle:             bundle,
+		announcements:      []Announcement{},
+		announcementsMutex: &sync.RWMutex{},
+		lastUpdate:         time.Now(),
+		updated:            make(chan struct{}),
+	}
+}
+
+// GetAnnouncements returns all announcements, newest first, together with the time of their last update
+func (s *AnnouncementService) GetAnnouncements() ([]Announcement, time.Time) {
+	s.announcementsMutex.RLock()
+	defer s.announcementsMutex.RUnlock()
+	return slices.Clone(s.announcements), s.lastUpdate
+}
+
+// GetAnnouncementsForTeam returns all announcements visible to the team, newest first, together with the time of their last update
+func (s *AnnouncementService) GetAnnouncementsForTeam(team string) ([]Announcement, time.Time) {
+	s.announcementsMutex.RLock()
+	defer s.announcementsMutex.RUnlock()
+	return filterForTeam(s.announcements, team), s.lastUpdate
+}
+
+// WaitForUpdateNewerThan blocks until the announcements changed after lastSeenUpdate.
+// Returns false if there was no update within the max wait time or the context got canceled.
+func (s *AnnouncementService) WaitForUpdateNewerThan(ctx context.Context, lastSeenUpdate time.Time) bool {
+	s.announcementsMutex.RLock()
+	lastUpdate := s.lastUpdate
+	updated := s.updated
+	s.announcementsMutex.RUnlock()
+
+	if lastUpdate.After(lastSeenUpdate) {
+		return true
+	}
+
+	const maxWaitTime = 25 * time.Second
+	timeout := time.NewTimer(maxWaitTime)
+	defer timeout.Stop()
+
+	select {
+	case <-updated:
+		return true
+	case <-timeout.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// CreateAnnouncement persists a new announcement and returns it
+func (s *AnnouncementService) CreateAnnouncement(ctx context.Context, message string, severity Severity, teams []string) (*Announcement, error) {
+	id, err := generateAnnouncementId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate announcement id: %w", err)
+	}
+	announcement := Announcement{
+		Id:        id,
+		Message:   message,
+		Severity:  severity,
+		Teams:     teams,
+		CreatedAt: time.Now(),
+	}
+
+	err = s.updatePersistedAnnouncements(ctx, func(announcements []Announcement) ([]Announcement, error) {
+		announcements = append([]Announcement{announcement}, announcements...)
+		if len(announcements) > MaxAnnouncements {
+			announcements = announcements[:MaxAnnouncements]
+		}
+		return announcements, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &announcement, nil
+}
+
+// DeleteAnnouncement removes the announcement with the given id. Returns ErrAnnouncementNotFound if it doesn't exist
+func (s *AnnouncementService) DeleteAnnouncement(ctx context.Context, id string) error {
+	return s.updatePersistedAnnouncements(ctx, func(announcements []Announcement) ([]Announcement, error) {
+		index := slices.IndexFunc(announcements, func(a Announcement) bool { return a.Id == id })
+		if index == -1 {
+			return nil, ErrAnnouncementNotFound
+		}
+		return slices.Delete(announcements, index, index+1), nil
+	})
+}
+
+// LoadAnnouncements reads the persisted announcements from the ConfigMap into the local cache
+func (s *AnnouncementService) LoadAnnouncements(ctx context.Context) error {
+	configMap, err := s.getConfigMap(ctx)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get announcements config map: %w", err)
+	}
+	announcements, err := decodeAnnouncements(configMap)
+	if err != nil {
+		return err
+	}
+	s.setAnnouncements(announcements)
+	return nil
+}
+
+// StartAnnouncementWorker keeps the local announcement cache in sync with the ConfigMap, so that announcements created via other balancer replicas are picked up as well
+func (s *AnnouncementService) StartAnnouncementWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			s.bundle.Log.Printf("MultiJuicer context canceled. Exiting the announcement watcher.")
+			return
+		default:
+			s.startAnnouncementWatcher(ctx)
+		}
+	}
+}
+
+func (s *AnnouncementService) startAnnouncementWatcher(ctx context.Context) {
+	watcher, err := s.bundle.StateStore.Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", ConfigMapName).String(),
+	})
+	if err != nil {
+		s.bundle.Log.Printf("Failed to start the watcher for the announcements config map: %v", err)
+		time.Sleep(5 * time.Second)
+		return
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				s.bundle.Log.Printf("Watcher for the announcements config map has been closed. Restarting the watcher.")
+				return
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				configMap := event.Object.(*corev1.ConfigMap)
+				announcements, err := decodeAnnouncements(configMap)
+				if err != nil {
+					s.bundle.Log.Printf("Ignoring update of the announcements config map: %v", err)
+					continue
+				}
+				s.setAnnouncements(announcements)
+			case watch.Deleted:
+				s.setAnnouncements([]Announcement{})
+			default:
+			}
+		case <-ctx.Done():
+			s.bundle.Log.Printf("MultiJuicer context canceled. Exiting the announcement watcher.")
+			return
+		}
+	}
+}
+
+func (s *AnnouncementService) setAnnouncements(announcements []Announcement) {
+	s.announcementsMutex.Lock()
+	defer s.announcementsMutex.Unlock()
+	s.announcements = announcements
+	s.lastUpdate = time.Now()
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+func (s *AnnouncementService) getConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	return s.bundle.StateStore.Get(ctx, ConfigMapName, metav1.GetOptions{})
+}
+
+// updatePersistedAnnouncements applies the update function to the currently persisted announcements and writes the result back.
+// Conflicting concurrent writes (e.g. from another balancer replica) are retried.
+func (s *AnnouncementService) updatePersistedAnnouncements(ctx context.Context, update func([]Announcement) ([]Announcement, error)) error {
+	var updatedAnnouncements []Announcement
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+
+		configMap, err := s.getConfigMap(ctx)
+		if apierrors.IsNotFound(err) {
+			announcements, err := update([]Announcement{})
+			if err != nil {
+				return err
+			}
+			configMap, err = encodeAnnouncements(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: ConfigMapName,
+					Labels: map[string]string{
+						"app.kubernetes.io/name":    "balancer",
+						"app.kubernetes.io/part-of": "multi-juicer",
+					},
+				},
+			}, announcements)
+			if err != nil {
+				return err
+			}
+			_, err = s.bundle.StateStore.Create(ctx, configMap, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// created concurrently, treat like a conflict to retry with the now existing config map
+				return apierrors.NewConflict(corev1.Resource("configmaps"), ConfigMapName, err)
+			}
+			updatedAnnouncements = announcements
+			return err
+		} else if err != nil {
+			return fmt.Errorf("failed to get announcements config map: %w", err)
+		}
+
+		announcements, err := decodeAnnouncements(configMap)
+		if err != nil {
+			return err
+		}
+		announcements, err = update(announcements)
+		if err != nil {
+			return err
+		}
+		configMap, err = encodeAnnouncements(configMap, announcements)
+		if err != nil {
+			return err
+		}
+		_, err = s.bundle.StateStore.Update(ctx, configMap, metav1.UpdateOptions{})
+		updatedAnnouncements = announcements
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.setAnnouncements(updatedAnnouncements)
+	return nil
+}
+
+func decodeAnnouncements(configMap *corev1.ConfigMap) ([]Announcement, error) {
+	announcements := []Announcement{}
+	data, ok := configMap.Data[configMapDataKey]
+	if !ok || data == "" {
+		return announcements, nil
+	}
+	if err := json.Unmarshal([]byte(data), &announcements); err != nil {
+		return nil, fmt.Errorf("failed to decode announcements from config map: %w", err)
+	}
+	sort.SliceStable(announcements, func(i, j int) bool {
+		return announcements[i].CreatedAt.After(announcements[j].CreatedAt)
+	})
+	return announcements, nil
+}
+
+func encodeAnnouncements(configMap *corev1.ConfigMap, announcements []Announcement) (*corev1.ConfigMap, error) {
+	encoded, err := json.Marshal(announcements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode announcements: %w", err)
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[configMapDataKey] = string(encoded)
+	return configMap, nil
+}
+
+func filterForTeam(announcements []Announcement, team string) []Announcement {
+	filtered := []Announcement{}
+	for _, announcement := range announcements {
+		if announcement.IsVisibleForTeam(team) {
+			filtered = append(filtered, announcement)
+		}
+	}
+	return filtered
+}
+
+func generateAnnouncementId() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
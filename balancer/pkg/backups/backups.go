@@ -0,0 +1,89 @@
+package backups
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/statestore"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigMapNamePrefix of the ConfigMaps the cleaner backs up the progress of a team in, before it deletes the instance of the team
+const ConfigMapNamePrefix = "juiceshop-backup-"
+
+const configMapDataKey = "backup.json"
+
+// the cleaner labels the backups with app.kubernetes.io/name=juice-shop-backup. The balancer needs to be able to read all ConfigMaps to read the backups (see the role of the balancer), so the label is checked to not treat other ConfigMaps as backup
+const nameLabel = "app.kubernetes.io/name"
+const nameLabelValue = "juice-shop-backup"
+
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Backup of the progress of a team whose instance got deleted by the cleaner
+type Backup struct {
+	Team         string `json:"team"`
+	PasscodeHash string `json:"passcodeHash"`
+	DisplayName  string `json:"displayName,omitempty"`
+	// Challenges solved by the team, in the format of the challenges annotation
+	Challenges       json.RawMessage `json:"challenges"`
+	ChallengesSolved int             `json:"challengesSolved"`
+	// SessionGeneration of the team at the time of the backup. See sessions.GenerationAnnotation
+	SessionGeneration int64 `json:"sessionGeneration"`
+	// RemovedMembers of the team, in the format of the removed members annotation
+	RemovedMembers json.RawMessage `json:"removedMembers,omitempty"`
+	// CreatedAt of the deleted instance
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastRequest *time.Time `json:"lastRequest,omitempty"`
+	BackedUpAt  time.Time  `json:"backedUpAt"`
+}
+
+type Service struct {
+	store statestore.Store
+}
+
+func NewService(store statestore.Store) *Service {
+	return &Service{
+		store: store,
+	}
+}
+
+// Get returns the backup of the team, or ErrBackupNotFound if the team has none
+func (s *Service) Get(ctx context.Context, team string) (*Backup, error) {
+	configMap, err := s.store.Get(ctx, ConfigMapNamePrefix+team, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrBackupNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get backup config map: %w", err)
+	}
+
+	if configMap.Labels[nameLabel] != nameLabelValue {
+		return nil, fmt.Errorf("config map '%s' is not labeled as backup", configMap.Name)
+	}
+	var backup Backup
+	if err := json.Unmarshal([]byte(configMap.Data[configMapDataKey]), &backup); err != nil {
+		return nil, fmt.Errorf("failed to decode backup: %w", err)
+	}
+	if backup.Team != team || backup.PasscodeHash == "" {
+		return nil, fmt.Errorf("backup config map '%s' doesn't contain a valid backup of the team", configMap.Name)
+	}
+	if len(backup.Challenges) == 0 {
+		backup.Challenges = json.RawMessage("[]")
+	}
+	if backup.SessionGeneration < 0 {
+		backup.SessionGeneration = 0
+	}
+	return &backup, nil
+}
+
+// Delete removes the backup of the team once it got restored. Deleting a backup which doesn't exist is not an error
+func (s *Service) Delete(ctx context.Context, team string) error {
+	err := s.store.Delete(ctx, ConfigMapNamePrefix+team, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete backup config map: %w", err)
+	}
+	return nil
+}
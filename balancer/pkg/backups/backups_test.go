@@ -0,0 +1,76 @@
+package backups
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func createBackupConfigMap(team string, data string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapNamePrefix + team,
+			Namespace: "test-namespace",
+			Labels:    map[string]string{"app.kubernetes.io/name": "juice-shop-backup"},
+		},
+		Data: map[string]string{configMapDataKey: data},
+	}
+}
+
+func TestGet(t *testing.T) {
+	t.Run("returns the backup of the team", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createBackupConfigMap("foobar", `{"team":"foobar","passcodeHash":"hash","challenges":[{"key":"scoreBoardChallenge","solvedAt":"2024-10-18T13:54:27.397Z"}],"challengesSolved":1}`))
+		service := NewService(clientset.CoreV1().ConfigMaps("test-namespace"))
+
+		backup, err := service.Get(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.Equal(t, "hash", backup.PasscodeHash)
+		assert.Equal(t, 1, backup.ChallengesSolved)
+		assert.JSONEq(t, `[{"key":"scoreBoardChallenge","solvedAt":"2024-10-18T13:54:27.397Z"}]`, string(backup.Challenges))
+	})
+
+	t.Run("returns ErrBackupNotFound for teams without backup", func(t *testing.T) {
+		service := NewService(fake.NewSimpleClientset().CoreV1().ConfigMaps("test-namespace"))
+
+		_, err := service.Get(context.Background(), "foobar")
+		assert.Equal(t, ErrBackupNotFound, err)
+	})
+
+	t.Run("rejects backups without passcode hash or of other teams", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(
+			createBackupConfigMap("foobar", `{"team":"foobar"}`),
+			createBackupConfigMap("other", `{"team":"foobar","passcodeHash":"hash"}`),
+		)
+		service := NewService(clientset.CoreV1().ConfigMaps("test-namespace"))
+
+		_, err := service.Get(context.Background(), "foobar")
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrBackupNotFound, err)
+		_, err = service.Get(context.Background(), "other")
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects config maps which aren't labeled as backup", func(t *testing.T) {
+		configMap := createBackupConfigMap("foobar", `{"team":"foobar","passcodeHash":"hash"}`)
+		configMap.Labels = nil
+		service := NewService(fake.NewSimpleClientset(configMap).CoreV1().ConfigMaps("test-namespace"))
+
+		_, err := service.Get(context.Background(), "foobar")
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrBackupNotFound, err)
+	})
+}
+
+func TestDelete(t *testing.T) {
+	clientset := fake.NewSimpleClientset(createBackupConfigMap("foobar", `{"team":"foobar","passcodeHash":"hash"}`))
+	service := NewService(clientset.CoreV1().ConfigMaps("test-namespace"))
+
+	assert.NoError(t, service.Delete(context.Background(), "foobar"))
+	assert.NoError(t, service.Delete(context.Background(), "foobar"))
+	_, err := service.Get(context.Background(), "foobar")
+	assert.Equal(t, ErrBackupNotFound, err)
+}
@@ -1,14 +1,29 @@
 package bundle
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"strings"
 	"sync"
-
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/adminpassword"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/backups"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/cleanup"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/clientip"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/joincodes"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/lockout"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/passcode"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/reservations"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/statestore"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamnames"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/waitlist"
 	"golang.org/x/crypto/bcrypt"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
@@ -19,7 +34,12 @@ import (
 // for testing it can be mocked out, see testutil/testUtils.go for helper functions to easily mock out the bundle
 type Bundle struct {
 	RuntimeEnvironment RuntimeEnvironment
-	ClientSet          kubernetes.Interface
+	// only set for the kubernetes backend
+	ClientSet kubernetes.Interface
+	// persists the config maps of the join codes, waitlist, announcements, reservations and backups. A FileStore for the local backend
+	StateStore statestore.Store
+	// runs the juice shop instances of the teams, see pkg/instances for the implementations
+	Instances InstanceManager
 	// generates a random passcode. On the bundle to have a static passcode in tests for easier assertions
 	GeneratePasscode func() string
 	// returns the (cluster internal) url for a team used by the balancer to proxy the request to. On the bundle to allow the tests to proxy requests to a local testing server
@@ -28,6 +48,24 @@ type Bundle struct {
 	StaticAssetsDirectory  string `json:"staticAssetsDirectory"`
 	Config                 *Config
 	Log                    *log.Logger
+	// tracks failed team join and admin login attempts to temporarily block brute force attempts
+	LoginLockouts *lockout.Tracker
+	// ClientIPs resolves the ip of the client behind the configured trusted proxies
+	ClientIPs *clientip.Resolver
+	// tracks revoked team sessions, kept in sync with the annotations of the team deployments
+	TeamSessions *sessions.Store
+	// event join codes required to create new teams, persisted in a config map
+	JoinCodes *joincodes.Service
+	// decides which team names and display names can be used for new teams
+	TeamNamePolicy *teamnames.Policy
+	// reserves instances before they get created to atomically enforce the max instance count
+	InstanceReservations *reservations.Service
+	// teams waiting for an instance because the max instance count has been reached, persisted in a config map
+	Waitlist *waitlist.Service
+	// progress of teams whose instances got deleted by the cleaner, restored once the team rejoins with its passcode
+	ProgressBackups *backups.Service
+	// predicts when the cleaner deletes inactive instances, to warn the teams before
+	CleanupSchedule *cleanup.Schedule
 
 	JuiceShopChallenges []JuiceShopChallenge
 }
@@ -43,20 +81,232 @@ type Settings struct {
 }
 
 type Config struct {
-	JuiceShopConfig JuiceShopConfig `json:"juiceShop"`
-	MaxInstances    int             `json:"maxInstances"`
-	CookieConfig    CookieConfig    `json:"cookie"`
-	Settings        Settings        `json:"settings"`
-	AdminConfig     *AdminConfig
+	JuiceShopConfig JuiceShopConfig  `json:"juiceShop"`
+	MaxInstances    int              `json:"maxInstances"`
+	CookieConfig    CookieConfig     `json:"cookie"`
+	Settings        Settings         `json:"settings"`
+	AdminConfig     *AdminConfig     `json:"admin"`
+	LoginLockout    lockout.Config   `json:"loginLockout"`
+	ClientIP        clientip.Config  `json:"clientIp"`
+	TeamNames       teamnames.Config `json:"teamNames"`
+	Waitlist        waitlist.Config  `json:"waitlist"`
+	JoinCodes       joincodes.Config `json:"joinCodes"`
+	Backend         BackendConfig    `json:"backend"`
+	Cleanup         cleanup.Config   `json:"cleanup"`
+}
+
+const (
+	BackendKubernetes = "kubernetes"
+	BackendLocal      = "local"
+)
+
+// BackendConfig selects where the juice shop instances of the teams are running
+type BackendConfig struct {
+	// Type is either "kubernetes" (default) or "local"
+	Type  string             `json:"type"`
+	Local LocalBackendConfig `json:"local"`
+}
+
+func (c *BackendConfig) GetType() string {
+	if c.Type == "" {
+		return BackendKubernetes
+	}
+	return c.Type
+}
+
+// LocalBackendConfig configures the local backend, which runs the instances as child processes of the balancer instead of in a kubernetes cluster.
+// Meant for development and small events on a single machine
+type LocalBackendConfig struct {
+	// Command starting a juice shop instance, e.g. ["node", "build/app"]. The allocated port is passed in the PORT environment variable, "{port}" and "{team}" in the arguments get replaced as well.
+	// The command should run the server directly, processes started by it in the background aren't stopped together with the instance
+	Command []string `json:"command"`
+	// WorkingDirectory of the started commands. Defaults to the working directory of the balancer
+	WorkingDirectory string `json:"workingDirectory"`
+	// PortRangeStart and PortRangeEnd limit the ports handed out to the instances. Each instance gets the lowest free port of the range
+	PortRangeStart int `json:"portRangeStart"`
+	PortRangeEnd   int `json:"portRangeEnd"`
+	// DataFile stores the instances and their annotations (passcodes, solved challenges, ...) so that teams survive restarts of the balancer
+	DataFile string `json:"dataFile"`
+	// StateFile stores the join codes, waitlist, announcements, instance reservations and progress backups so that they survive restarts of the balancer
+	StateFile string `json:"stateFile"`
+	// WebhookAddress the balancer listens on for the solution webhooks of the instances. Should only be reachable locally
+	WebhookAddress string `json:"webhookAddress"`
+}
+
+const DefaultLocalPortRangeStart = 4000
+const DefaultLocalPortRangeEnd = 4999
+const DefaultLocalDataFile = "multi-juicer-instances.json"
+const DefaultLocalStateFile = "multi-juicer-state.json"
+const DefaultLocalWebhookAddress = "127.0.0.1:8082"
+
+func (c *LocalBackendConfig) GetPortRange() (int, int) {
+	if c.PortRangeStart <= 0 || c.PortRangeEnd < c.PortRangeStart {
+		return DefaultLocalPortRangeStart, DefaultLocalPortRangeEnd
+	}
+	return c.PortRangeStart, c.PortRangeEnd
+}
+
+func (c *LocalBackendConfig) GetDataFile() string {
+	if c.DataFile == "" {
+		return DefaultLocalDataFile
+	}
+	return c.DataFile
+}
+
+func (c *LocalBackendConfig) GetStateFile() string {
+	if c.StateFile == "" {
+		return DefaultLocalStateFile
+	}
+	return c.StateFile
+}
+
+func (c *LocalBackendConfig) GetWebhookAddress() string {
+	if c.WebhookAddress == "" {
+		return DefaultLocalWebhookAddress
+	}
+	return c.WebhookAddress
 }
 
 type AdminConfig struct {
+	// Password of the default admin account. Either plaintext or a bcrypt / argon2id hash
 	Password string `json:"password"`
+	// Accounts are additional named admin accounts. Read from the MULTI_JUICER_CONFIG_ADMIN_ACCOUNTS environment variable as they contain the passwords
+	Accounts []AdminAccount `json:"-"`
+	// OIDC optionally allows admins to log in via an OpenID Connect identity provider in addition to the admin password
+	OIDC AdminOIDCConfig `json:"oidc"`
+}
+
+type AdminOIDCConfig struct {
+	Enabled bool `json:"enabled"`
+	// IssuerURL of the identity provider. Used to discover the authorization, token and key endpoints
+	IssuerURL string `json:"issuerUrl"`
+	ClientID  string `json:"clientId"`
+	// ClientSecret is read from the MULTI_JUICER_CONFIG_ADMIN_OIDC_CLIENT_SECRET environment variable and never from the config file
+	ClientSecret string `json:"-"`
+	// RedirectURL must point to the balancers callback route, e.g. https://multi-juicer.example.com/balancer/api/admin/oidc/callback
+	RedirectURL string `json:"redirectUrl"`
+	// Scopes requested in addition to the "openid" scope. Defaults to "email" and "profile"
+	Scopes []string `json:"scopes"`
+	// GroupsClaim is the name of the id token claim containing the groups of the user. Defaults to "groups"
+	GroupsClaim string `json:"groupsClaim"`
+	// AllowedEmails and AllowedGroups control who is allowed to log in as admin. At least one of them has to be configured
+	AllowedEmails []string `json:"allowedEmails"`
+	AllowedGroups []string `json:"allowedGroups"`
+	// Role granted to admins logged in via oidc. Defaults to "owner"
+	Role AdminRole `json:"role"`
+}
+
+// AdminRole controls what an admin is allowed to do. Each role includes the permissions of the roles below it
+type AdminRole string
+
+const (
+	// AdminRoleViewer can see instances and scores
+	AdminRoleViewer AdminRole = "viewer"
+	// AdminRoleOperator can additionally restart instances and manage announcements
+	AdminRoleOperator AdminRole = "operator"
+	// AdminRoleOwner can additionally delete instances and change settings
+	AdminRoleOwner AdminRole = "owner"
+)
+
+var adminRoleLevels = map[AdminRole]int{
+	AdminRoleViewer:   1,
+	AdminRoleOperator: 2,
+	AdminRoleOwner:    3,
+}
+
+func (r AdminRole) IsValid() bool {
+	_, ok := adminRoleLevels[r]
+	return ok
+}
+
+// Includes returns true if the role has at least the permissions of the required role
+func (r AdminRole) Includes(required AdminRole) bool {
+	return r.IsValid() && adminRoleLevels[r] >= adminRoleLevels[required]
+}
+
+type AdminAccount struct {
+	Name string `json:"name"`
+	// Password is either plaintext or a bcrypt / argon2id hash
+	Password string    `json:"password"`
+	Role     AdminRole `json:"role"`
+}
+
+// the name of the admin account logging in with the MULTI_JUICER_CONFIG_ADMIN_PASSWORD. Always has the owner role
+const DefaultAdminAccountName = "admin"
+
+// prefix of the account names of admins logged in via oidc, followed by their email or subject
+const OIDCAdminAccountPrefix = "oidc:"
+
+// prefix of the identities of all admin accounts but the default one. Can't collide with team names, as they must not contain ':'
+const adminIdentityPrefix = teamnames.AdminTeamName + ":"
+
+// AdminIdentity returns the identity stored in the (signed) team cookie to identify the admin account.
+// The default admin account uses the reserved admin team name for compatibility with existing sessions, all other accounts are prefixed with "admin:"
+func AdminIdentity(accountName string) string {
+	if accountName == DefaultAdminAccountName {
+		return teamnames.AdminTeamName
+	}
+	return adminIdentityPrefix + accountName
+}
+
+// IsAdminIdentity checks if the identity taken from the team cookie belongs to a admin instead of a team
+func IsAdminIdentity(identity string) bool {
+	return identity == teamnames.AdminTeamName || strings.HasPrefix(identity, adminIdentityPrefix)
+}
+
+// GetAdminAccountName returns the name of the admin account the admin identity belongs to
+func GetAdminAccountName(identity string) string {
+	if identity == teamnames.AdminTeamName {
+		return DefaultAdminAccountName
+	}
+	return strings.TrimPrefix(identity, adminIdentityPrefix)
+}
+
+// GetAdminAccount looks up a password based admin account by its name
+func (c *AdminConfig) GetAdminAccount(name string) (*AdminAccount, bool) {
+	for i := range c.Accounts {
+		if c.Accounts[i].Name == name {
+			return &c.Accounts[i], true
+		}
+	}
+	return nil, false
+}
+
+// GetAdminRole returns the current role of the admin account. Returns false if the account doesn't exist (anymore)
+func (c *AdminConfig) GetAdminRole(name string) (AdminRole, bool) {
+	if name == DefaultAdminAccountName {
+		return AdminRoleOwner, true
+	}
+	if strings.HasPrefix(name, OIDCAdminAccountPrefix) {
+		if !c.OIDC.Enabled {
+			return "", false
+		}
+		if c.OIDC.Role == "" {
+			return AdminRoleOwner, true
+		}
+		return c.OIDC.Role, true
+	}
+	account, ok := c.GetAdminAccount(name)
+	if !ok {
+		return "", false
+	}
+	return account.Role, true
 }
 
 type CookieConfig struct {
 	// CookieSigningKey is used to create a hmac signature of the team name to have  readable but cryptographically secure cookie name to identify the team
 	SigningKey string `json:"signingKey"`
+	// SigningKeyID identifies the SigningKey in the cookies, so that cookies signed by previous keys can still be verified after a key rotation
+	SigningKeyID string `json:"signingKeyId"`
+	// VerificationKeys are previous signing keys by their key id. Cookies signed by them are still accepted, but new cookies are always signed with the SigningKey.
+	// Read from the MULTI_JUICER_CONFIG_COOKIE_VERIFICATION_KEYS environment variable
+	VerificationKeys map[string]string `json:"-"`
+	// MaxAgeSeconds controls how long team cookies stay valid after login
+	MaxAgeSeconds int `json:"maxAgeSeconds"`
+	// LegacyCookiesAcceptedUntil ends the transition window in which cookies in the format before the expiring sessions ("<team>.<signature>") are still accepted, so that teams aren't logged out by the upgrade.
+	// They are only accepted if signed with the current SigningKey, so rotating the key ends the transition window as well.
+	// Defaults to one MaxAgeSeconds after the start of the balancer. Set it to a time in the past to reject them right away
+	LegacyCookiesAcceptedUntil time.Time `json:"legacyCookiesAcceptedUntil"`
 
 	// CookieName is the name of the cookie that is used to store the team name
 	Name string `json:"name"`
@@ -65,6 +315,32 @@ type CookieConfig struct {
 	Secure bool `json:"secure"`
 }
 
+const DefaultCookieSigningKeyID = "default"
+const DefaultCookieMaxAgeSeconds = 7 * 24 * 60 * 60
+
+func (c *CookieConfig) GetSigningKeyID() string {
+	if c.SigningKeyID == "" {
+		return DefaultCookieSigningKeyID
+	}
+	return c.SigningKeyID
+}
+
+// GetVerificationKey returns the key used to verify cookies signed with the given key id
+func (c *CookieConfig) GetVerificationKey(keyID string) (string, bool) {
+	if keyID == c.GetSigningKeyID() {
+		return c.SigningKey, true
+	}
+	key, ok := c.VerificationKeys[keyID]
+	return key, ok && key != ""
+}
+
+func (c *CookieConfig) GetMaxAge() time.Duration {
+	if c.MaxAgeSeconds <= 0 {
+		return DefaultCookieMaxAgeSeconds * time.Second
+	}
+	return time.Duration(c.MaxAgeSeconds) * time.Second
+}
+
 type JuiceShopConfig struct {
 	Image            string                        `json:"image"`
 	Tag              string                        `json:"tag"`
@@ -85,6 +361,15 @@ type JuiceShopConfig struct {
 	RuntimeClassName         *string                     `json:"runtimeClassName"`
 
 	JuiceShopPodConfig JuiceShopPodConfig `json:"pod"`
+	// WebhookSigner runs next to every instance to sign its solution webhooks for the progress-watchdog
+	WebhookSigner JuiceShopWebhookSignerConfig `json:"webhookSigner"`
+}
+
+// JuiceShopWebhookSignerConfig configures the sidecar signing the solution webhooks of the instances, it runs the progress-watchdog image.
+// Without image the instances don't send solution webhooks, their progress is then only picked up by the periodic sync of the progress-watchdog
+type JuiceShopWebhookSignerConfig struct {
+	Image     string                      `json:"image"`
+	Resources corev1.ResourceRequirements `json:"resources"`
 }
 
 type JuiceShopPodConfig struct {
@@ -111,18 +396,46 @@ func getJuiceShopUrlForTeam(team string, bundle *Bundle) string {
 }
 
 func New() *Bundle {
-	kubeClientConfig, err := rest.InClusterConfig()
-	if err != nil {
-		panic(err.Error())
+	configFile := os.Getenv("MULTI_JUICER_CONFIG_FILE")
+	if configFile == "" {
+		configFile = "/config/config.json"
 	}
-	clientset, err := kubernetes.NewForConfig(kubeClientConfig)
+	config, err := readConfigFromFile(configFile)
 	if err != nil {
-		panic(err.Error())
+		panic(err)
 	}
 
+	var clientset kubernetes.Interface
+	var stateStore statestore.Store
 	namespace := os.Getenv("NAMESPACE")
-	if namespace == "" {
-		panic(errors.New("environment variable 'NAMESPACE' must be set"))
+	switch config.Backend.GetType() {
+	case BackendKubernetes:
+		kubeClientConfig, err := rest.InClusterConfig()
+		if err != nil {
+			panic(err.Error())
+		}
+		clientset, err = kubernetes.NewForConfig(kubeClientConfig)
+		if err != nil {
+			panic(err.Error())
+		}
+		if namespace == "" {
+			panic(errors.New("environment variable 'NAMESPACE' must be set"))
+		}
+		stateStore = statestore.NewKubernetesStore(clientset, namespace)
+	case BackendLocal:
+		if len(config.Backend.Local.Command) == 0 {
+			panic(errors.New("the local backend requires 'backend.local.command' to be configured"))
+		}
+		// without a cluster the config maps of the join codes, waitlist, etc. are persisted in a file
+		stateStore, err = statestore.NewFileStore(config.Backend.Local.GetStateFile())
+		if err != nil {
+			panic(err)
+		}
+		if namespace == "" {
+			namespace = "local"
+		}
+	default:
+		panic(fmt.Errorf("invalid backend type '%s'. Valid types are '%s' and '%s'", config.Backend.Type, BackendKubernetes, BackendLocal))
 	}
 
 	cookieSigningKey := os.Getenv("MULTI_JUICER_CONFIG_COOKIE_SIGNING_KEY")
@@ -135,16 +448,55 @@ func New() *Bundle {
 		panic(errors.New("environment variable 'MULTI_JUICER_CONFIG_ADMIN_PASSWORD' must be set"))
 	}
 
-	config, err := readConfigFromFile("/config/config.json")
-	if err != nil {
-		panic(err)
+	config.CookieConfig.SigningKey = cookieSigningKey
+	if config.CookieConfig.LegacyCookiesAcceptedUntil.IsZero() {
+		config.CookieConfig.LegacyCookiesAcceptedUntil = time.Now().Add(config.CookieConfig.GetMaxAge())
+	}
+	if verificationKeys := os.Getenv("MULTI_JUICER_CONFIG_COOKIE_VERIFICATION_KEYS"); verificationKeys != "" {
+		if err := json.Unmarshal([]byte(verificationKeys), &config.CookieConfig.VerificationKeys); err != nil {
+			panic(fmt.Errorf("failed to decode 'MULTI_JUICER_CONFIG_COOKIE_VERIFICATION_KEYS': %w", err))
+		}
+		if _, ok := config.CookieConfig.VerificationKeys[config.CookieConfig.GetSigningKeyID()]; ok {
+			panic(fmt.Errorf("cookie verification key id '%s' is already used by the current signing key", config.CookieConfig.GetSigningKeyID()))
+		}
+	}
+	if config.AdminConfig == nil {
+		config.AdminConfig = &AdminConfig{}
+	}
+	config.AdminConfig.Password = adminPasswordKey
+	if err := adminpassword.Validate(config.AdminConfig.Password); err != nil {
+		panic(fmt.Errorf("invalid 'MULTI_JUICER_CONFIG_ADMIN_PASSWORD': %w", err))
 	}
 
-	config.CookieConfig.SigningKey = cookieSigningKey
-	config.AdminConfig = &AdminConfig{Password: adminPasswordKey}
+	if adminAccounts := os.Getenv("MULTI_JUICER_CONFIG_ADMIN_ACCOUNTS"); adminAccounts != "" {
+		if err := json.Unmarshal([]byte(adminAccounts), &config.AdminConfig.Accounts); err != nil {
+			panic(fmt.Errorf("failed to decode 'MULTI_JUICER_CONFIG_ADMIN_ACCOUNTS': %w", err))
+		}
+		if err := validateAdminAccounts(config.AdminConfig.Accounts); err != nil {
+			panic(err)
+		}
+	}
 
-	// read /challenges.json file
-	challengesBytes, err := os.ReadFile("/challenges.json")
+	if config.AdminConfig.OIDC.Enabled {
+		config.AdminConfig.OIDC.ClientSecret = os.Getenv("MULTI_JUICER_CONFIG_ADMIN_OIDC_CLIENT_SECRET")
+		if err := validateAdminOIDCConfig(config.AdminConfig.OIDC); err != nil {
+			panic(err)
+		}
+	}
+
+	if config.TeamNames.BlocklistFile != "" {
+		blockedWords, err := teamnames.ReadBlocklistFile(config.TeamNames.BlocklistFile)
+		if err != nil {
+			panic(err)
+		}
+		config.TeamNames.BlockedWords = append(config.TeamNames.BlockedWords, blockedWords...)
+	}
+
+	challengesFile := os.Getenv("MULTI_JUICER_CHALLENGES_FILE")
+	if challengesFile == "" {
+		challengesFile = "/challenges.json"
+	}
+	challengesBytes, err := os.ReadFile(challengesFile)
 	if err != nil {
 		panic(err)
 	}
@@ -155,19 +507,110 @@ func New() *Bundle {
 		panic(err)
 	}
 
-	return &Bundle{
+	cleanupSchedule, err := cleanup.NewSchedule(config.Cleanup)
+	if err != nil {
+		panic(err)
+	}
+
+	clientIPResolver, err := clientip.NewResolver(config.ClientIP)
+	if err != nil {
+		panic(err)
+	}
+
+	staticAssetsDirectory := os.Getenv("MULTI_JUICER_STATIC_ASSETS_DIRECTORY")
+	if staticAssetsDirectory == "" {
+		staticAssetsDirectory = "/public/"
+	}
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	warnAboutWeakAdminPasswords(logger, config.AdminConfig)
+
+	bundle := &Bundle{
 		ClientSet:             clientset,
-		StaticAssetsDirectory: "/public/",
+		StateStore:            stateStore,
+		StaticAssetsDirectory: staticAssetsDirectory,
 		RuntimeEnvironment: RuntimeEnvironment{
 			Namespace: namespace,
 		},
 		GeneratePasscode:       passcode.GeneratePasscode,
 		GetJuiceShopUrlForTeam: getJuiceShopUrlForTeam,
 		BcryptRounds:           bcrypt.DefaultCost,
-		Log:                    log.New(os.Stdout, "", log.LstdFlags),
+		Log:                    logger,
 		Config:                 config,
+		LoginLockouts:          lockout.NewTracker(config.LoginLockout),
+		ClientIPs:              clientIPResolver,
+		TeamSessions:           sessions.NewStore(),
+		JoinCodes:              joincodes.NewService(stateStore, logger, config.JoinCodes),
+		TeamNamePolicy:         teamnames.NewPolicy(config.TeamNames),
+		Waitlist:               waitlist.NewService(stateStore, logger, config.Waitlist),
+		ProgressBackups:        backups.NewService(stateStore),
+		CleanupSchedule:        cleanupSchedule,
 		JuiceShopChallenges:    challenges,
 	}
+	bundle.InstanceReservations = reservations.NewService(stateStore, bundle.ListInstanceTeams)
+	return bundle
+}
+
+// ListInstanceTeams returns the teams which currently have an instance
+func (b *Bundle) ListInstanceTeams(ctx context.Context) ([]string, error) {
+	instances, err := b.Instances.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	teams := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		teams = append(teams, instance.Team)
+	}
+	return teams, nil
+}
+
+var validAdminAccountNamePattern = regexp.MustCompile("^[a-z0-9][-_.a-z0-9]*$")
+
+func validateAdminAccounts(accounts []AdminAccount) error {
+	names := map[string]bool{}
+	for _, account := range accounts {
+		if !validAdminAccountNamePattern.MatchString(account.Name) || account.Name == DefaultAdminAccountName {
+			return fmt.Errorf("invalid admin account name '%s'. Names must be lowercase alphanumeric and must not be '%s'", account.Name, DefaultAdminAccountName)
+		}
+		if names[account.Name] {
+			return fmt.Errorf("admin account '%s' is configured multiple times", account.Name)
+		}
+		names[account.Name] = true
+		if account.Password == "" {
+			return fmt.Errorf("admin account '%s' has no password configured", account.Name)
+		}
+		if err := adminpassword.Validate(account.Password); err != nil {
+			return fmt.Errorf("admin account '%s' has a invalid password: %w", account.Name, err)
+		}
+		if !account.Role.IsValid() {
+			return fmt.Errorf("admin account '%s' has invalid role '%s'. Valid roles are 'viewer', 'operator' and 'owner'", account.Name, account.Role)
+		}
+	}
+	return nil
+}
+
+func warnAboutWeakAdminPasswords(logger *log.Logger, adminConfig *AdminConfig) {
+	if weaknesses := adminpassword.GetWeaknesses(adminConfig.Password); len(weaknesses) > 0 {
+		logger.Printf("WARNING: The admin password is weak (%s). Consider using a stronger password or configuring it as a bcrypt or argon2id hash", strings.Join(weaknesses, ", "))
+	}
+	for _, account := range adminConfig.Accounts {
+		if weaknesses := adminpassword.GetWeaknesses(account.Password); len(weaknesses) > 0 {
+			logger.Printf("WARNING: The password of admin account '%s' is weak (%s). Consider using a stronger password or configuring it as a bcrypt or argon2id hash", account.Name, strings.Join(weaknesses, ", "))
+		}
+	}
+}
+
+func validateAdminOIDCConfig(oidcConfig AdminOIDCConfig) error {
+	if oidcConfig.IssuerURL == "" || oidcConfig.ClientID == "" || oidcConfig.RedirectURL == "" {
+		return errors.New("admin oidc login requires 'issuerUrl', 'clientId' and 'redirectUrl' to be configured")
+	}
+	if len(oidcConfig.AllowedEmails) == 0 && len(oidcConfig.AllowedGroups) == 0 {
+		return errors.New("admin oidc login requires either 'allowedEmails' or 'allowedGroups' to be configured. Otherwise every user of the identity provider could log in as admin")
+	}
+	if oidcConfig.Role != "" && !oidcConfig.Role.IsValid() {
+		return fmt.Errorf("invalid admin oidc role '%s'. Valid roles are 'viewer', 'operator' and 'owner'", oidcConfig.Role)
+	}
+	return nil
 }
 
 func readConfigFromFile(filePath string) (*Config, error) {
@@ -15,3 +15,53 @@ func TestGetJuiceShopUrlForTeam(t *testing.T) {
 		}))
 	})
 }
+
+func TestValidateAdminOIDCConfig(t *testing.T) {
+	validConfig := AdminOIDCConfig{
+		Enabled:       true,
+		IssuerURL:     "https://idp.example.com",
+		ClientID:      "multi-juicer",
+		RedirectURL:   "https://multi-juicer.example.com/balancer/api/admin/oidc/callback",
+		AllowedEmails: []string{"instructor@example.com"},
+	}
+
+	t.Run("accepts complete config", func(t *testing.T) {
+		assert.NoError(t, validateAdminOIDCConfig(validConfig))
+	})
+
+	t.Run("requires issuer, client id and redirect url", func(t *testing.T) {
+		config := validConfig
+		config.IssuerURL = ""
+		assert.Error(t, validateAdminOIDCConfig(config))
+	})
+
+	t.Run("requires allowed emails or groups to not allow every user of the identity provider", func(t *testing.T) {
+		config := validConfig
+		config.AllowedEmails = nil
+		assert.Error(t, validateAdminOIDCConfig(config))
+
+		config.AllowedGroups = []string{"instructors"}
+		assert.NoError(t, validateAdminOIDCConfig(config))
+	})
+}
+
+func TestAdminIdentity(t *testing.T) {
+	t.Run("the default admin account uses the reserved admin team name", func(t *testing.T) {
+		assert.Equal(t, "admin", AdminIdentity(DefaultAdminAccountName))
+		assert.True(t, IsAdminIdentity("admin"))
+		assert.Equal(t, DefaultAdminAccountName, GetAdminAccountName("admin"))
+	})
+
+	t.Run("other admin accounts are prefixed", func(t *testing.T) {
+		identity := AdminIdentity(OIDCAdminAccountPrefix + "instructor@example.com")
+		assert.Equal(t, "admin:oidc:instructor@example.com", identity)
+		assert.True(t, IsAdminIdentity(identity))
+		assert.Equal(t, "oidc:instructor@example.com", GetAdminAccountName(identity))
+	})
+
+	t.Run("teams are no admins", func(t *testing.T) {
+		assert.False(t, IsAdminIdentity("foobar"))
+		assert.False(t, IsAdminIdentity("administrators"))
+		assert.False(t, IsAdminIdentity(""))
+	})
+}
@@ -0,0 +1,79 @@
+package bundle
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInstanceNotFound      = errors.New("instance not found")
+	ErrInstanceAlreadyExists = errors.New("instance already exists")
+	// ErrInstanceHibernated is returned when trying to restart a hibernated instance. It has no running pod, resuming it starts a fresh one
+	ErrInstanceHibernated = errors.New("instance is hibernated")
+)
+
+// WebhookSecretAnnotation stores the key the solution webhooks of the team get signed with, so that nobody else can mark challenges as solved for the team.
+// Generated when the team gets created. The webhook signer sidecar next to the instance signs the webhooks with it and the progress-watchdog verifies them, see progress-watchdog/internal/webhook-signature.go
+const WebhookSecretAnnotation = "multi-juicer.owasp-juice.shop/webhookSecret"
+
+// GenerateWebhookSecret returns a new random key for the solution webhooks of a team
+func GenerateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// Instance is the backend agnostic view on the juice shop instance of a team
+type Instance struct {
+	Team string
+	// Annotations hold the state of the team, e.g. the passcode hash, the members and the solved challenges
+	Annotations map[string]string
+	// Ready is true once the instance is able to handle requests
+	Ready bool
+	// Hibernated instances got stopped by the cleaner after being inactive, keeping their annotations. They are resumed once the team returns
+	Hibernated bool
+	CreatedAt  time.Time
+}
+
+type InstanceEventType string
+
+const (
+	InstanceAdded    InstanceEventType = "added"
+	InstanceModified InstanceEventType = "modified"
+	InstanceDeleted  InstanceEventType = "deleted"
+)
+
+type InstanceEvent struct {
+	Type     InstanceEventType
+	Instance Instance
+}
+
+// InstanceManager runs the juice shop instances of the teams.
+// The routes only interact with instances through it, so that they don't depend on the backend the instances are running on
+type InstanceManager interface {
+	// Create starts the instance of a new team with the given annotations. Everything created before a failure is cleaned up again.
+	// Returns ErrInstanceAlreadyExists if the team already has an instance
+	Create(ctx context.Context, team string, annotations map[string]string) error
+	// Repair recreates missing parts of an existing instance, e.g. after an interrupted creation. Returns true if something had to be recreated
+	Repair(ctx context.Context, team string) (bool, error)
+	// Delete removes the instance of the team. Deleting a team without an instance is not an error
+	Delete(ctx context.Context, team string) error
+	// Restart restarts the running instance of the team while keeping its annotations. Returns ErrInstanceHibernated if the instance is hibernated
+	Restart(ctx context.Context, team string) error
+	// Resume starts a hibernated instance again. Resuming an instance which isn't hibernated is a no-op
+	Resume(ctx context.Context, team string) error
+	// Get returns the current status of the instance of the team or ErrInstanceNotFound
+	Get(ctx context.Context, team string) (*Instance, error)
+	List(ctx context.Context) ([]Instance, error)
+	// Watch streams changes of all instances until the context is canceled. The channel gets closed once the watch ends, callers are expected to start a new watch then
+	Watch(ctx context.Context) (<-chan InstanceEvent, error)
+	// UpdateAnnotations applies the update to the latest annotations of the instance, retrying on conflicting concurrent updates. Returns the updated instance
+	UpdateAnnotations(ctx context.Context, team string, update func(annotations map[string]string) error) (*Instance, error)
+	// MergeAnnotations sets the given annotations without reading the instance first. Meant for frequent updates where conflicts don't matter, like the last request timestamp
+	MergeAnnotations(ctx context.Context, team string, annotations map[string]string) error
+}
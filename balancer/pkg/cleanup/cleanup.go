@@ -0,0 +1,116 @@
+package cleanup
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// LastRequestAnnotation stores when the team last sent a request to its instance, in unix milliseconds. The cleaner deletes instances based on it
+const LastRequestAnnotation = "multi-juicer.owasp-juice.shop/lastRequest"
+
+// ProtectedAnnotation exempts the instance from the automatic cleanup, e.g. for demo teams of instructors. Set by the admins, the cleaner skips instances with it set to "true"
+const ProtectedAnnotation = "multi-juicer.owasp-juice.shop/protected"
+
+// DefaultInterval matches the default cron of the cleaner, which runs once an hour
+const DefaultInterval = time.Hour
+
+// Config mirrors the configuration of the cleaner, so that the balancer can warn teams before their instance gets deleted because of inactivity
+type Config struct {
+	// Enabled is false if the cleaner isn't deployed. Instances are then never deleted because of inactivity
+	Enabled bool `json:"enabled"`
+	// MaxInactive is the duration after which the cleaner deletes unused instances, e.g. "24h"
+	MaxInactive string `json:"maxInactive"`
+	// Interval in which the cleaner runs, e.g. "5m". Instances which get deleted by the next run are flagged for the admins. Defaults to DefaultInterval
+	Interval string `json:"interval"`
+	// MaxLifetime after which the cleaner deletes instances regardless of their activity, counted from their creation, e.g. "8h". Optional
+	MaxLifetime string `json:"maxLifetime"`
+	// EventEnd after which the cleaner deletes all instances regardless of their activity, as RFC 3339 timestamp. Optional
+	EventEnd string `json:"eventEnd"`
+}
+
+// Schedule predicts when the cleaner deletes the instance of a team
+type Schedule struct {
+	enabled     bool
+	maxInactive time.Duration
+	interval    time.Duration
+	// zero if the policy isn't configured
+	maxLifetime time.Duration
+	eventEnd    time.Time
+}
+
+func NewSchedule(config Config) (*Schedule, error) {
+	if !config.Enabled {
+		return &Schedule{enabled: false}, nil
+	}
+	maxInactive, err := time.ParseDuration(config.MaxInactive)
+	if err != nil || maxInactive <= 0 {
+		return nil, fmt.Errorf("invalid cleanup max inactive duration '%s'. Has to be formatted like \"24h\"", config.MaxInactive)
+	}
+	interval := DefaultInterval
+	if config.Interval != "" {
+		interval, err = time.ParseDuration(config.Interval)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid cleanup interval '%s'. Has to be formatted like \"1h\"", config.Interval)
+		}
+	}
+	var maxLifetime time.Duration
+	if config.MaxLifetime != "" {
+		maxLifetime, err = time.ParseDuration(config.MaxLifetime)
+		if err != nil || maxLifetime <= 0 {
+			return nil, fmt.Errorf("invalid cleanup max lifetime '%s'. Has to be formatted like \"8h\"", config.MaxLifetime)
+		}
+	}
+	var eventEnd time.Time
+	if config.EventEnd != "" {
+		eventEnd, err = time.Parse(time.RFC3339, config.EventEnd)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cleanup event end '%s'. Has to be formatted according to RFC 3339 like \"2024-10-18T18:00:00+02:00\"", config.EventEnd)
+		}
+	}
+	return &Schedule{enabled: true, maxInactive: maxInactive, interval: interval, maxLifetime: maxLifetime, eventEnd: eventEnd}, nil
+}
+
+// Enabled is false if the cleaner isn't deployed
+func (s *Schedule) Enabled() bool {
+	return s.enabled
+}
+
+// DeletionTime returns when the instance becomes due for deletion, like the cleaner by the earliest of the inactivity, max lifetime and event end deadlines.
+// Returns false if the instance never gets deleted, because the cleaner is disabled, the instance is protected or none of the policies applies to it
+func (s *Schedule) DeletionTime(annotations map[string]string, createdAt time.Time) (time.Time, bool) {
+	if !s.enabled || annotations[ProtectedAnnotation] == "true" {
+		return time.Time{}, false
+	}
+	var deletionTime time.Time
+	expireAt := func(deadline time.Time) {
+		if deletionTime.IsZero() || deadline.Before(deletionTime) {
+			deletionTime = deadline
+		}
+	}
+	if lastRequest, err := strconv.ParseInt(annotations[LastRequestAnnotation], 10, 64); err == nil {
+		expireAt(time.UnixMilli(lastRequest).Add(s.maxInactive))
+	}
+	if s.maxLifetime > 0 && !createdAt.IsZero() {
+		expireAt(createdAt.Add(s.maxLifetime))
+	}
+	if !s.eventEnd.IsZero() {
+		expireAt(s.eventEnd)
+	}
+	return deletionTime, !deletionTime.IsZero()
+}
+
+// TimeUntilDeletion returns how long the team has left until its instance becomes due for deletion. Never negative
+func (s *Schedule) TimeUntilDeletion(annotations map[string]string, createdAt time.Time, now time.Time) (time.Duration, bool) {
+	deletionTime, ok := s.DeletionTime(annotations, createdAt)
+	if !ok {
+		return 0, false
+	}
+	return max(deletionTime.Sub(now), 0), true
+}
+
+// DeletedByNextRun returns true if the instance will be deleted within the next cleanup interval, unless the team becomes active again
+func (s *Schedule) DeletedByNextRun(annotations map[string]string, createdAt time.Time, now time.Time) bool {
+	timeUntilDeletion, ok := s.TimeUntilDeletion(annotations, createdAt, now)
+	return ok && timeUntilDeletion <= s.interval
+}
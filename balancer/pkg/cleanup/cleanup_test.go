@@ -0,0 +1,92 @@
+package cleanup
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule(t *testing.T) {
+	now := time.UnixMilli(1_729_259_666_123)
+	lastRequest := func(inactiveFor time.Duration) map[string]string {
+		return map[string]string{LastRequestAnnotation: fmt.Sprintf("%d", now.Add(-inactiveFor).UnixMilli())}
+	}
+	createdAt := now.Add(-72 * time.Hour)
+
+	t.Run("computes the time until inactive instances get deleted", func(t *testing.T) {
+		schedule, err := NewSchedule(Config{Enabled: true, MaxInactive: "24h", Interval: "1h"})
+		assert.NoError(t, err)
+
+		timeUntilDeletion, ok := schedule.TimeUntilDeletion(lastRequest(20*time.Hour), createdAt, now)
+		assert.True(t, ok)
+		assert.Equal(t, 4*time.Hour, timeUntilDeletion)
+		assert.False(t, schedule.DeletedByNextRun(lastRequest(20*time.Hour), createdAt, now))
+
+		timeUntilDeletion, ok = schedule.TimeUntilDeletion(lastRequest(48*time.Hour), createdAt, now)
+		assert.True(t, ok)
+		assert.Equal(t, time.Duration(0), timeUntilDeletion)
+		assert.True(t, schedule.DeletedByNextRun(lastRequest(48*time.Hour), createdAt, now))
+		assert.True(t, schedule.DeletedByNextRun(lastRequest(23*time.Hour), createdAt, now))
+	})
+
+	t.Run("defaults to the hourly interval of the cleaner cron", func(t *testing.T) {
+		schedule, err := NewSchedule(Config{Enabled: true, MaxInactive: "24h"})
+		assert.NoError(t, err)
+
+		assert.True(t, schedule.DeletedByNextRun(lastRequest(23*time.Hour), createdAt, now))
+		assert.False(t, schedule.DeletedByNextRun(lastRequest(22*time.Hour), createdAt, now))
+	})
+
+	t.Run("never deletes instances if the cleaner is disabled, they are protected or their last request is unknown", func(t *testing.T) {
+		disabled, err := NewSchedule(Config{Enabled: false, MaxInactive: "24h"})
+		assert.NoError(t, err)
+		_, ok := disabled.TimeUntilDeletion(lastRequest(48*time.Hour), createdAt, now)
+		assert.False(t, ok)
+
+		schedule, _ := NewSchedule(Config{Enabled: true, MaxInactive: "24h"})
+		protected := lastRequest(48 * time.Hour)
+		protected["multi-juicer.owasp-juice.shop/protected"] = "true"
+		_, ok = schedule.TimeUntilDeletion(protected, createdAt, now)
+		assert.False(t, ok)
+		assert.False(t, schedule.DeletedByNextRun(protected, createdAt, now))
+		_, ok = schedule.TimeUntilDeletion(map[string]string{}, createdAt, now)
+		assert.False(t, ok)
+	})
+
+	t.Run("uses the earliest deadline of the inactivity, max lifetime and event end", func(t *testing.T) {
+		schedule, err := NewSchedule(Config{Enabled: true, MaxInactive: "24h", MaxLifetime: "8h", EventEnd: now.Add(3 * time.Hour).Format(time.RFC3339)})
+		assert.NoError(t, err)
+
+		// created 6 hours ago, so the max lifetime is reached in 2 hours, even though the team is active
+		deletionTime, ok := schedule.DeletionTime(lastRequest(time.Minute), now.Add(-6*time.Hour))
+		assert.True(t, ok)
+		assert.Equal(t, now.Add(2*time.Hour).UnixMilli(), deletionTime.UnixMilli())
+
+		timeUntilDeletion, ok := schedule.TimeUntilDeletion(lastRequest(time.Minute), now.Add(-7*time.Hour), now)
+		assert.True(t, ok)
+		assert.Equal(t, time.Hour, timeUntilDeletion)
+		assert.True(t, schedule.DeletedByNextRun(lastRequest(time.Minute), now.Add(-7*time.Hour), now))
+
+		// the event end applies to new instances as well
+		timeUntilDeletion, ok = schedule.TimeUntilDeletion(lastRequest(time.Minute), now, now)
+		assert.True(t, ok)
+		assert.Equal(t, 3*time.Hour, timeUntilDeletion.Round(time.Second))
+
+		// instances without last request still get deleted by the max lifetime and event end
+		_, ok = schedule.TimeUntilDeletion(map[string]string{}, now, now)
+		assert.True(t, ok)
+	})
+
+	t.Run("rejects invalid durations", func(t *testing.T) {
+		_, err := NewSchedule(Config{Enabled: true, MaxInactive: "1 day"})
+		assert.Error(t, err)
+		_, err = NewSchedule(Config{Enabled: true, MaxInactive: "24h", Interval: "-1h"})
+		assert.Error(t, err)
+		_, err = NewSchedule(Config{Enabled: true, MaxInactive: "24h", MaxLifetime: "1 day"})
+		assert.Error(t, err)
+		_, err = NewSchedule(Config{Enabled: true, MaxInactive: "24h", EventEnd: "2024-10-18 18:00"})
+		assert.Error(t, err)
+	})
+}
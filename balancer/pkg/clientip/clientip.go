@@ -0,0 +1,78 @@
+package clientip
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Config of the proxies in front of the balancer
+type Config struct {
+	// TrustedProxies are the ips or cidr ranges (e.g. "10.0.0.0/8") of the proxies in front of the balancer, e.g. the ingress controller.
+	// The X-Forwarded-For header is only read for requests coming from a trusted proxy. Without trusted proxies the remote address of the connection is used
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+// Resolver determines the ip of the client which sent a request, e.g. to lock out clients guessing passcodes
+type Resolver struct {
+	trustedProxies []netip.Prefix
+}
+
+func NewResolver(config Config) (*Resolver, error) {
+	trustedProxies := make([]netip.Prefix, 0, len(config.TrustedProxies))
+	for _, trustedProxy := range config.TrustedProxies {
+		trustedProxy = strings.TrimSpace(trustedProxy)
+		if prefix, err := netip.ParsePrefix(trustedProxy); err == nil {
+			trustedProxies = append(trustedProxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(trustedProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s'. Has to be an ip or a cidr range like \"10.0.0.0/8\"", trustedProxy)
+		}
+		trustedProxies = append(trustedProxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return &Resolver{trustedProxies: trustedProxies}, nil
+}
+
+// ClientIP returns the ip of the client which sent the request.
+// If the request comes from a trusted proxy, the X-Forwarded-For chain is walked from the right, skipping all trusted proxies. The first untrusted entry is the client.
+// Entries left of it were added by the client itself and can be spoofed, so they are never used
+func (r *Resolver) ClientIP(req *http.Request) string {
+	clientIP, err := parseRemoteAddr(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	forwardedFor := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwardedFor) - 1; i >= 0 && r.isTrusted(clientIP); i-- {
+		forwardedIP, err := netip.ParseAddr(strings.TrimSpace(forwardedFor[i]))
+		if err != nil {
+			// malformed entries can't be trusted, so the last trusted proxy is the best guess
+			break
+		}
+		clientIP = forwardedIP.Unmap()
+	}
+	return clientIP.String()
+}
+
+func (r *Resolver) isTrusted(ip netip.Addr) bool {
+	for _, trustedProxy := range r.trustedProxies {
+		if trustedProxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseRemoteAddr(remoteAddr string) (netip.Addr, error) {
+	if addrPort, err := netip.ParseAddrPort(remoteAddr); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(remoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
@@ -0,0 +1,59 @@
+package clientip
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRequest(remoteAddr string, forwardedFor ...string) *http.Request {
+	req, _ := http.NewRequest("GET", "/balancer/", nil)
+	req.RemoteAddr = remoteAddr
+	for _, value := range forwardedFor {
+		req.Header.Add("X-Forwarded-For", value)
+	}
+	return req
+}
+
+func TestResolver(t *testing.T) {
+	t.Run("uses the remote address without trusted proxies", func(t *testing.T) {
+		resolver, err := NewResolver(Config{})
+		assert.NoError(t, err)
+
+		assert.Equal(t, "10.0.0.1", resolver.ClientIP(newRequest("10.0.0.1:1234")))
+		assert.Equal(t, "10.0.0.1", resolver.ClientIP(newRequest("10.0.0.1:1234", "1.2.3.4")))
+		assert.Equal(t, "2001:db8::1", resolver.ClientIP(newRequest("[2001:db8::1]:1234", "1.2.3.4")))
+	})
+
+	t.Run("ignores the forwarded for header of requests not coming from a trusted proxy", func(t *testing.T) {
+		resolver, err := NewResolver(Config{TrustedProxies: []string{"10.0.0.0/8"}})
+		assert.NoError(t, err)
+
+		assert.Equal(t, "192.0.2.1", resolver.ClientIP(newRequest("192.0.2.1:1234", "1.2.3.4")))
+	})
+
+	t.Run("walks the forwarded for chain from the right past the trusted proxies", func(t *testing.T) {
+		resolver, err := NewResolver(Config{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.10"}})
+		assert.NoError(t, err)
+
+		assert.Equal(t, "203.0.113.7", resolver.ClientIP(newRequest("10.0.0.1:1234", "203.0.113.7")))
+		// entries left of the client are spoofed by the client
+		assert.Equal(t, "203.0.113.7", resolver.ClientIP(newRequest("10.0.0.1:1234", "1.2.3.4, 203.0.113.7, 192.0.2.10")))
+		assert.Equal(t, "203.0.113.7", resolver.ClientIP(newRequest("10.0.0.1:1234", "1.2.3.4, 203.0.113.7", "10.1.2.3")))
+	})
+
+	t.Run("falls back to the last trusted proxy if the chain contains only trusted or malformed entries", func(t *testing.T) {
+		resolver, err := NewResolver(Config{TrustedProxies: []string{"10.0.0.0/8"}})
+		assert.NoError(t, err)
+
+		assert.Equal(t, "10.0.0.1", resolver.ClientIP(newRequest("10.0.0.1:1234")))
+		assert.Equal(t, "10.0.0.2", resolver.ClientIP(newRequest("10.0.0.1:1234", "10.0.0.2")))
+		assert.Equal(t, "10.0.0.2", resolver.ClientIP(newRequest("10.0.0.1:1234", "1.2.3.4, not-an-ip, 10.0.0.2")))
+	})
+
+	t.Run("rejects invalid trusted proxies", func(t *testing.T) {
+		_, err := NewResolver(Config{TrustedProxies: []string{"ingress-nginx"}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,21 @@
+package instances
+
+import (
+	"context"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+)
+
+// New creates and starts the instance manager of the configured backend
+func New(ctx context.Context, b *bundle.Bundle) (bundle.InstanceManager, error) {
+	if b.Config.Backend.GetType() != bundle.BackendLocal {
+		return NewKubernetesInstanceManager(b), nil
+	}
+
+	manager := NewLocalInstanceManager(b)
+	if err := manager.Start(ctx); err != nil {
+		return nil, err
+	}
+	b.GetJuiceShopUrlForTeam = manager.GetJuiceShopUrlForTeam
+	return manager, nil
+}
@@ -0,0 +1,550 @@
+package instances
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/retry"
+)
+
+const instanceLabelSelector = "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer"
+
+// KubernetesInstanceManager runs every team instance as a deployment with a service in the namespace of the balancer.
+// The annotations of the instance are stored as annotations of the deployment
+type KubernetesInstanceManager struct {
+	bundle *bundle.Bundle
+
+	// uid of the balancer kubernetes deployment resource. used to "attach" created juice shop deployments and services to the balancer deployment so that they get deleted when the balancer gets deleted
+	balancerUid      types.UID
+	balancerUidMutex sync.Mutex
+}
+
+func NewKubernetesInstanceManager(bundle *bundle.Bundle) *KubernetesInstanceManager {
+	return &KubernetesInstanceManager{bundle: bundle}
+}
+
+func getResourceName(team string) string {
+	return fmt.Sprintf("juiceshop-%s", team)
+}
+
+func (m *KubernetesInstanceManager) Create(ctx context.Context, team string, annotations map[string]string) error {
+	err := retry.OnError(retry.DefaultBackoff, isTransientError, func() error {
+		return m.createDeployment(ctx, team, annotations)
+	})
+	if apierrors.IsAlreadyExists(err) {
+		return bundle.ErrInstanceAlreadyExists
+	} else if err != nil {
+		return fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	err = retry.OnError(retry.DefaultBackoff, isTransientError, func() error {
+		return m.ensureService(ctx, team)
+	})
+	if err != nil {
+		m.rollbackCreation(team)
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	return nil
+}
+
+// rollbackCreation deletes the deployment and service of a team which couldn't be created completely, so that the team can be created again cleanly.
+// Uses a fresh context, as the resources have to be cleaned up even if the request got canceled
+func (m *KubernetesInstanceManager) rollbackCreation(team string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := m.Delete(ctx, team); err != nil {
+		m.bundle.Log.Printf("Failed to roll back the creation of team '%s': %s", team, err)
+		return
+	}
+	m.bundle.Log.Printf("Rolled back the failed creation of team '%s'", team)
+}
+
+func (m *KubernetesInstanceManager) Repair(ctx context.Context, team string) (bool, error) {
+	if _, err := m.getDeployment(ctx, team); err != nil {
+		return false, err
+	}
+
+	_, err := m.bundle.ClientSet.CoreV1().Services(m.bundle.RuntimeEnvironment.Namespace).Get(ctx, getResourceName(team), metav1.GetOptions{})
+	if err == nil {
+		return false, nil
+	} else if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	if err := m.ensureService(ctx, team); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *KubernetesInstanceManager) Delete(ctx context.Context, team string) error {
+	err := m.bundle.ClientSet.AppsV1().Deployments(m.bundle.RuntimeEnvironment.Namespace).Delete(ctx, getResourceName(team), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete deployment: %w", err)
+	}
+	err = m.bundle.ClientSet.CoreV1().Services(m.bundle.RuntimeEnvironment.Namespace).Delete(ctx, getResourceName(team), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+	return nil
+}
+
+// Restart deletes the pod of the team, the deployment then starts a fresh one
+func (m *KubernetesInstanceManager) Restart(ctx context.Context, team string) error {
+	pods, err := m.bundle.ClientSet.CoreV1().Pods(m.bundle.RuntimeEnvironment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s,team=%s", instanceLabelSelector, team),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+	if len(pods.Items) != 1 {
+		if deployment, err := m.getDeployment(ctx, team); err == nil && isHibernated(deployment) {
+			return bundle.ErrInstanceHibernated
+		}
+		return bundle.ErrInstanceNotFound
+	}
+
+	err = m.bundle.ClientSet.CoreV1().Pods(m.bundle.RuntimeEnvironment.Namespace).Delete(ctx, pods.Items[0].Name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return bundle.ErrInstanceNotFound
+	}
+	return err
+}
+
+// hibernatedAtAnnotation is set by the cleaner when it scales the deployment of an inactive team down to zero replicas
+const hibernatedAtAnnotation = "multi-juicer.owasp-juice.shop/hibernatedAt"
+
+// Resume scales the deployment of a hibernated team back up
+func (m *KubernetesInstanceManager) Resume(ctx context.Context, team string) error {
+	deployment, err := m.getDeployment(ctx, team)
+	if err != nil {
+		return err
+	}
+	if !isHibernated(deployment) {
+		return nil
+	}
+
+	patch := map[string]any{
+		"metadata": map[string]any{
+			// null removes the annotation
+			"annotations": map[string]any{hibernatedAtAnnotation: nil},
+		},
+		"spec": map[string]any{"replicas": 1},
+	}
+	jsonBytes, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("could not encode json to resume the deployment")
+	}
+	_, err = m.bundle.ClientSet.AppsV1().Deployments(m.bundle.RuntimeEnvironment.Namespace).Patch(ctx, getResourceName(team), types.MergePatchType, jsonBytes, metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		return bundle.ErrInstanceNotFound
+	}
+	return err
+}
+
+func isHibernated(deployment *appsv1.Deployment) bool {
+	return deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0
+}
+
+func (m *KubernetesInstanceManager) Get(ctx context.Context, team string) (*bundle.Instance, error) {
+	deployment, err := m.getDeployment(ctx, team)
+	if err != nil {
+		return nil, err
+	}
+	instance := toInstance(deployment)
+	return &instance, nil
+}
+
+func (m *KubernetesInstanceManager) getDeployment(ctx context.Context, team string) (*appsv1.Deployment, error) {
+	deployment, err := m.bundle.ClientSet.AppsV1().Deployments(m.bundle.RuntimeEnvironment.Namespace).Get(ctx, getResourceName(team), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, bundle.ErrInstanceNotFound
+	}
+	return deployment, err
+}
+
+func (m *KubernetesInstanceManager) List(ctx context.Context) ([]bundle.Instance, error) {
+	deployments, err := m.bundle.ClientSet.AppsV1().Deployments(m.bundle.RuntimeEnvironment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: instanceLabelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]bundle.Instance, 0, len(deployments.Items))
+	for i := range deployments.Items {
+		instances = append(instances, toInstance(&deployments.Items[i]))
+	}
+	return instances, nil
+}
+
+func (m *KubernetesInstanceManager) Watch(ctx context.Context) (<-chan bundle.InstanceEvent, error) {
+	watcher, err := m.bundle.ClientSet.AppsV1().Deployments(m.bundle.RuntimeEnvironment.Namespace).Watch(ctx, metav1.ListOptions{
+		LabelSelector: instanceLabelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan bundle.InstanceEvent)
+	go func() {
+		defer close(events)
+		defer watcher.Stop()
+		for {
+			select {
+			case event, ok := <-watcher.ResultChan():
+				if !ok {
+					return
+				}
+				var eventType bundle.InstanceEventType
+				switch event.Type {
+				case watch.Added:
+					eventType = bundle.InstanceAdded
+				case watch.Modified:
+					eventType = bundle.InstanceModified
+				case watch.Deleted:
+					eventType = bundle.InstanceDeleted
+				default:
+					continue
+				}
+				deployment, ok := event.Object.(*appsv1.Deployment)
+				if !ok {
+					continue
+				}
+				select {
+				case events <- bundle.InstanceEvent{Type: eventType, Instance: toInstance(deployment)}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (m *KubernetesInstanceManager) UpdateAnnotations(ctx context.Context, team string, update func(annotations map[string]string) error) (*bundle.Instance, error) {
+	var updatedInstance bundle.Instance
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployment, err := m.getDeployment(ctx, team)
+		if err != nil {
+			return err
+		}
+		if deployment.Annotations == nil {
+			deployment.Annotations = map[string]string{}
+		}
+		if err := update(deployment.Annotations); err != nil {
+			return err
+		}
+		updatedDeployment, err := m.bundle.ClientSet.AppsV1().Deployments(m.bundle.RuntimeEnvironment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		updatedInstance = toInstance(updatedDeployment)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updatedInstance, nil
+}
+
+// annotationsMergePatch a shim of the k8s deployment containing only the annotations
+type annotationsMergePatch struct {
+	Metadata struct {
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+func (m *KubernetesInstanceManager) MergeAnnotations(ctx context.Context, team string, annotations map[string]string) error {
+	var patch annotationsMergePatch
+	patch.Metadata.Annotations = annotations
+	jsonBytes, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("could not encode json to patch the annotations of the deployment")
+	}
+
+	_, err = m.bundle.ClientSet.AppsV1().Deployments(m.bundle.RuntimeEnvironment.Namespace).Patch(ctx, getResourceName(team), types.MergePatchType, jsonBytes, metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		return bundle.ErrInstanceNotFound
+	}
+	return err
+}
+
+func toInstance(deployment *appsv1.Deployment) bundle.Instance {
+	return bundle.Instance{
+		Team:        deployment.Labels["team"],
+		Annotations: deployment.Annotations,
+		Ready:       deployment.Status.ReadyReplicas > 0,
+		Hibernated:  isHibernated(deployment),
+		CreatedAt:   deployment.CreationTimestamp.Time,
+	}
+}
+
+// isTransientError returns true for errors of the kubernetes api which are likely to be gone when retrying
+func isTransientError(err error) bool {
+	return apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsInternalError(err)
+}
+
+func (m *KubernetesInstanceManager) getOwnerReferences(ctx context.Context) ([]metav1.OwnerReference, error) {
+	m.balancerUidMutex.Lock()
+	defer m.balancerUidMutex.Unlock()
+	if m.balancerUid == "" {
+		balancerDeployment, err := m.bundle.ClientSet.AppsV1().Deployments(m.bundle.RuntimeEnvironment.Namespace).Get(
+			ctx,
+			"balancer",
+			metav1.GetOptions{},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get balancer deployment to attach correct owner reference to start juice shop: %w", err)
+		}
+		m.balancerUid = balancerDeployment.ObjectMeta.UID
+	}
+
+	truePointer := true
+	ownerReferences := []metav1.OwnerReference{
+		{
+			APIVersion:         "apps/v1",
+			Kind:               "Deployment",
+			Name:               "balancer",
+			UID:                m.balancerUid,
+			Controller:         &truePointer,
+			BlockOwnerDeletion: &truePointer,
+		},
+	}
+	return ownerReferences, nil
+}
+
+func (m *KubernetesInstanceManager) createDeployment(ctx context.Context, team string, annotations map[string]string) error {
+	ownerReferences, err := m.getOwnerReferences(ctx)
+	if err != nil {
+		return err
+	}
+	config := m.bundle.Config.JuiceShopConfig
+
+	podLabels := map[string]string{}
+	for key, value := range config.JuiceShopPodConfig.Labels {
+		podLabels[key] = value
+	}
+	podLabels["team"] = team
+	podLabels["app.kubernetes.io/version"] = config.Tag
+	podLabels["app.kubernetes.io/name"] = "juice-shop"
+	podLabels["app.kubernetes.io/part-of"] = "multi-juicer"
+
+	podAnnotations := map[string]string{}
+	if config.JuiceShopPodConfig.Annotations != nil {
+		podAnnotations = config.JuiceShopPodConfig.Annotations
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: getResourceName(team),
+			Labels: map[string]string{
+				"team":                        team,
+				"app.kubernetes.io/version":   config.Tag,
+				"app.kubernetes.io/component": "vulnerable-app",
+				"app.kubernetes.io/name":      "juice-shop",
+				"app.kubernetes.io/instance":  fmt.Sprintf("juice-shop-%s", team),
+				"app.kubernetes.io/part-of":   "multi-juicer",
+			},
+			Annotations:     annotations,
+			OwnerReferences: ownerReferences,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"team":                      team,
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      podLabels,
+					Annotations: podAnnotations,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:            "juice-shop",
+							Image:           fmt.Sprintf("%s:%s", config.Image, config.Tag),
+							SecurityContext: &config.ContainerSecurityContext,
+							Resources:       config.Resources,
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: 3000,
+								},
+							},
+							StartupProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									HTTPGet: &corev1.HTTPGetAction{
+										Path: "/rest/admin/application-version",
+										Port: intstr.FromInt(3000),
+									},
+								},
+								PeriodSeconds:    2,
+								FailureThreshold: 150,
+							},
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									HTTPGet: &corev1.HTTPGetAction{
+										Path: "/rest/admin/application-version",
+										Port: intstr.FromInt(3000),
+									},
+								},
+								PeriodSeconds:    5,
+								FailureThreshold: 3,
+							},
+							LivenessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									HTTPGet: &corev1.HTTPGetAction{
+										Path: "/rest/admin/application-version",
+										Port: intstr.FromInt(3000),
+									},
+								},
+								InitialDelaySeconds: 30,
+								PeriodSeconds:       15,
+							},
+							Env: append(
+								config.Env,
+								corev1.EnvVar{
+									Name:  "NODE_ENV",
+									Value: config.NodeEnv,
+								},
+								corev1.EnvVar{
+									Name:  "CTF_KEY",
+									Value: config.CtfKey,
+								},
+							),
+							EnvFrom: config.EnvFrom,
+							VolumeMounts: append(
+								config.VolumeMounts,
+								corev1.VolumeMount{
+									Name:      "juice-shop-config",
+									MountPath: "/juice-shop/config/multi-juicer.yaml",
+									ReadOnly:  true,
+									SubPath:   "multi-juicer.yaml",
+								},
+							),
+						},
+					},
+					Volumes: append(
+						config.Volumes,
+						corev1.Volume{
+							Name: "juice-shop-config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{
+										Name: "juice-shop-config",
+									},
+								},
+							},
+						},
+					),
+					ImagePullSecrets: config.ImagePullSecrets,
+					Tolerations:      config.Tolerations,
+					Affinity:         &config.Affinity,
+					RuntimeClassName: config.RuntimeClassName,
+					SecurityContext:  &config.PodSecurityContext,
+				},
+			},
+		},
+	}
+
+	if config.WebhookSigner.Image != "" {
+		containers := deployment.Spec.Template.Spec.Containers
+		containers[0].Env = append(containers[0].Env, corev1.EnvVar{
+			Name:  "SOLUTIONS_WEBHOOK",
+			Value: fmt.Sprintf("http://%s/", webhookSignerAddress),
+		})
+		deployment.Spec.Template.Spec.Containers = append(containers, m.webhookSignerContainer(team, annotations))
+	}
+
+	_, err = m.bundle.ClientSet.AppsV1().Deployments(m.bundle.RuntimeEnvironment.Namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	return err
+}
+
+// webhookSignerAddress the webhook signer sidecar listens on. Only reachable from within the pod of the instance
+const webhookSignerAddress = "127.0.0.1:8081"
+
+// webhookSignerContainer signs the solution webhooks of the juice shop with the webhook key of the team and forwards them to the progress-watchdog.
+// The key is only passed to the sidecar, so that it isn't part of the environment of the juice shop and never shows up in a url
+func (m *KubernetesInstanceManager) webhookSignerContainer(team string, annotations map[string]string) corev1.Container {
+	config := m.bundle.Config.JuiceShopConfig.WebhookSigner
+	falsePointer := false
+	truePointer := true
+	return corev1.Container{
+		Name:  "webhook-signer",
+		Image: config.Image,
+		// the image only sets CMD, which would be replaced by the args
+		Command: []string{"/progress-watchdog"},
+		Args:    []string{"--webhook-signer"},
+		Env: []corev1.EnvVar{
+			{Name: "WEBHOOK_SIGNER_ADDRESS", Value: webhookSignerAddress},
+			{Name: "WEBHOOK_TARGET_URL", Value: fmt.Sprintf("http://progress-watchdog.%s.svc/team/%s/webhook", m.bundle.RuntimeEnvironment.Namespace, team)},
+			{Name: "WEBHOOK_SIGNING_KEY", Value: annotations[bundle.WebhookSecretAnnotation]},
+		},
+		Resources: config.Resources,
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: &falsePointer,
+			ReadOnlyRootFilesystem:   &truePointer,
+			RunAsNonRoot:             &truePointer,
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		},
+	}
+}
+
+// ensureService creates the service of the team if it doesn't exist yet
+func (m *KubernetesInstanceManager) ensureService(ctx context.Context, team string) error {
+	ownerReferences, err := m.getOwnerReferences(ctx)
+	if err != nil {
+		return err
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: getResourceName(team),
+			Labels: map[string]string{
+				"team":                        team,
+				"app.kubernetes.io/version":   m.bundle.Config.JuiceShopConfig.Tag,
+				"app.kubernetes.io/name":      "juice-shop",
+				"app.kubernetes.io/component": "vulnerable-app",
+				"app.kubernetes.io/instance":  fmt.Sprintf("juice-shop-%s", team),
+				"app.kubernetes.io/part-of":   "multi-juicer",
+			},
+			OwnerReferences: ownerReferences,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				"team":                   team,
+				"app.kubernetes.io/name": "juice-shop",
+			},
+			Ports: []corev1.ServicePort{
+				{
+					Port: 3000,
+				},
+			},
+		},
+	}
+
+	_, err = m.bundle.ClientSet.CoreV1().Services(m.bundle.RuntimeEnvironment.Namespace).Create(ctx, service, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+package instances_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/instances"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	testcore "k8s.io/client-go/testing"
+)
+
+func TestKubernetesInstanceManager(t *testing.T) {
+	balancerDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "balancer",
+			Namespace: "test-namespace",
+			UID:       "34c0bb8a-240b-4f2a-84ae-2eb2258298f9",
+		},
+	}
+	createTeam := func(team string, readyReplicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					"multi-juicer.owasp-juice.shop/challengesSolved": "0",
+				},
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+			Status: appsv1.DeploymentStatus{
+				ReadyReplicas: readyReplicas,
+			},
+		}
+	}
+	newManager := func(clientset *fake.Clientset) *instances.KubernetesInstanceManager {
+		return instances.NewKubernetesInstanceManager(testutil.NewTestBundleWithCustomFakeClient(clientset))
+	}
+
+	t.Run("creates a deployment and service owned by the balancer", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		manager := newManager(clientset)
+
+		err := manager.Create(context.Background(), "foobar", map[string]string{"multi-juicer.owasp-juice.shop/passcode": "hash"})
+		assert.NoError(t, err)
+
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "hash", deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"])
+		assert.Equal(t, "foobar", deployment.Labels["team"])
+		assert.Equal(t, balancerDeployment.UID, deployment.OwnerReferences[0].UID)
+		service, err := clientset.CoreV1().Services("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, balancerDeployment.UID, service.OwnerReferences[0].UID)
+	})
+
+	t.Run("returns ErrInstanceAlreadyExists for existing teams", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam("foobar", 1))
+		manager := newManager(clientset)
+
+		err := manager.Create(context.Background(), "foobar", map[string]string{})
+
+		assert.Equal(t, bundle.ErrInstanceAlreadyExists, err)
+	})
+
+	t.Run("deletes the deployment again if the service can't be created", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		clientset.PrependReactor("create", "services", func(action testcore.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.NewForbidden(schema.GroupResource{Resource: "services"}, "juiceshop-foobar", fmt.Errorf("quota exceeded"))
+		})
+		manager := newManager(clientset)
+
+		err := manager.Create(context.Background(), "foobar", map[string]string{})
+
+		assert.Error(t, err)
+		_, err = clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err))
+	})
+
+	t.Run("repair recreates a missing service", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam("foobar", 1))
+		manager := newManager(clientset)
+
+		repaired, err := manager.Repair(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.True(t, repaired)
+		_, err = clientset.CoreV1().Services("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+
+		repaired, err = manager.Repair(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.False(t, repaired)
+
+		_, err = manager.Repair(context.Background(), "other-team")
+		assert.Equal(t, bundle.ErrInstanceNotFound, err)
+	})
+
+	t.Run("maps deployments to instances", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createTeam("foobar", 1), createTeam("barfoo", 0))
+		manager := newManager(clientset)
+
+		instance, err := manager.Get(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.Equal(t, "foobar", instance.Team)
+		assert.True(t, instance.Ready)
+		assert.Equal(t, "0", instance.Annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+
+		_, err = manager.Get(context.Background(), "other-team")
+		assert.Equal(t, bundle.ErrInstanceNotFound, err)
+
+		list, err := manager.List(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+	})
+
+	t.Run("deleting removes the deployment and service", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createTeam("foobar", 1), &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "juiceshop-foobar", Namespace: "test-namespace"},
+		})
+		manager := newManager(clientset)
+
+		assert.NoError(t, manager.Delete(context.Background(), "foobar"))
+		assert.NoError(t, manager.Delete(context.Background(), "foobar"))
+
+		_, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err))
+		_, err = clientset.CoreV1().Services("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err))
+	})
+
+	t.Run("restarting an instance without a pod returns ErrInstanceNotFound", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createTeam("foobar", 0))
+		manager := newManager(clientset)
+
+		err := manager.Restart(context.Background(), "foobar")
+
+		assert.Equal(t, bundle.ErrInstanceNotFound, err)
+	})
+
+	t.Run("restarting a hibernated instance returns ErrInstanceHibernated", func(t *testing.T) {
+		hibernated := createTeam("foobar", 0)
+		replicas := int32(0)
+		hibernated.Spec.Replicas = &replicas
+		manager := newManager(fake.NewSimpleClientset(hibernated))
+
+		err := manager.Restart(context.Background(), "foobar")
+
+		assert.Equal(t, bundle.ErrInstanceHibernated, err)
+	})
+
+	t.Run("resuming scales hibernated deployments back up", func(t *testing.T) {
+		hibernated := createTeam("foobar", 0)
+		replicas := int32(0)
+		hibernated.Spec.Replicas = &replicas
+		hibernated.Annotations["multi-juicer.owasp-juice.shop/hibernatedAt"] = "1729259667397"
+		clientset := fake.NewSimpleClientset(hibernated, createTeam("barfoo", 1))
+		manager := newManager(clientset)
+
+		instance, err := manager.Get(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.True(t, instance.Hibernated)
+
+		assert.NoError(t, manager.Resume(context.Background(), "foobar"))
+		assert.NoError(t, manager.Resume(context.Background(), "barfoo"))
+		assert.Equal(t, bundle.ErrInstanceNotFound, manager.Resume(context.Background(), "other-team"))
+
+		instance, err = manager.Get(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.False(t, instance.Hibernated)
+		assert.NotContains(t, instance.Annotations, "multi-juicer.owasp-juice.shop/hibernatedAt")
+	})
+
+	t.Run("updates and merges annotations", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createTeam("foobar", 1))
+		manager := newManager(clientset)
+
+		instance, err := manager.UpdateAnnotations(context.Background(), "foobar", func(annotations map[string]string) error {
+			annotations["multi-juicer.owasp-juice.shop/challengesSolved"] = "1"
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "1", instance.Annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+
+		err = manager.MergeAnnotations(context.Background(), "foobar", map[string]string{"multi-juicer.owasp-juice.shop/lastRequest": "1729259667397"})
+		assert.NoError(t, err)
+
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "1", deployment.Annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+		assert.Equal(t, "1729259667397", deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"])
+	})
+
+	t.Run("watch translates deployment events", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset()
+		watcher := watch.NewFake()
+		clientset.PrependWatchReactor("deployments", testcore.DefaultWatchReactor(watcher, nil))
+		manager := newManager(clientset)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events, err := manager.Watch(ctx)
+		assert.NoError(t, err)
+
+		go watcher.Add(createTeam("foobar", 0))
+		select {
+		case event := <-events:
+			assert.Equal(t, bundle.InstanceAdded, event.Type)
+			assert.Equal(t, "foobar", event.Instance.Team)
+			assert.False(t, event.Instance.Ready)
+		case <-time.After(time.Second):
+			t.Fatal("expected an event")
+		}
+
+		go watcher.Delete(createTeam("foobar", 0))
+		select {
+		case event := <-events:
+			assert.Equal(t, bundle.InstanceDeleted, event.Type)
+		case <-time.After(time.Second):
+			t.Fatal("expected an event")
+		}
+
+		cancel()
+		assert.Eventually(t, func() bool {
+			_, ok := <-events
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+	})
+}
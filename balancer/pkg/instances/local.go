@@ -0,0 +1,567 @@
+package instances
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+)
+
+// delay before a crashed or restarted instance gets started again
+const localRestartDelay = time.Second
+
+// interval in which starting instances are checked for readiness
+const localReadinessInterval = 500 * time.Millisecond
+
+// LocalInstanceManager runs every team instance as a child process of the balancer listening on its own local port.
+// The instances and their annotations are persisted in a json file, the solution webhooks of the instances are handled by the manager itself instead of the progress-watchdog
+type LocalInstanceManager struct {
+	bundle     *bundle.Bundle
+	config     bundle.LocalBackendConfig
+	httpClient *http.Client
+	// ctx of the manager, the processes of the instances are stopped once it gets canceled
+	ctx context.Context
+
+	mutex     sync.Mutex
+	instances map[string]*localInstance
+	watchers  []chan bundle.InstanceEvent
+}
+
+// localInstance is also the format the instances are persisted in
+type localInstance struct {
+	Team        string            `json:"team"`
+	Port        int               `json:"port"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	Annotations map[string]string `json:"annotations"`
+
+	ready   bool
+	process *os.Process
+	deleted bool
+}
+
+func NewLocalInstanceManager(bundle *bundle.Bundle) *LocalInstanceManager {
+	return &LocalInstanceManager{
+		bundle:     bundle,
+		config:     bundle.Config.Backend.Local,
+		httpClient: &http.Client{Timeout: 2 * time.Second},
+		ctx:        context.Background(),
+		instances:  map[string]*localInstance{},
+	}
+}
+
+// Start loads the persisted instances, starts their processes and the server receiving the solution webhooks.
+// Everything is stopped again once the context gets canceled
+func (m *LocalInstanceManager) Start(ctx context.Context) error {
+	m.ctx = ctx
+	if err := m.load(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", m.config.GetWebhookAddress())
+	if err != nil {
+		return fmt.Errorf("failed to listen for solution webhooks: %w", err)
+	}
+	router := http.NewServeMux()
+	router.Handle("POST /team/{team}/webhook", m.handleSolutionWebhook())
+	server := &http.Server{Handler: router}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			m.bundle.Log.Printf("Solution webhook server stopped: %s", err)
+		}
+	}()
+	m.bundle.Log.Printf("Listening for solution webhooks of the local instances on %s", listener.Addr())
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, instance := range m.instances {
+		go m.supervise(instance)
+	}
+	return nil
+}
+
+// GetJuiceShopUrlForTeam returns the url of the local process of the team, matching the signature of bundle.GetJuiceShopUrlForTeam
+func (m *LocalInstanceManager) GetJuiceShopUrlForTeam(team string, _ *bundle.Bundle) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	port := 0
+	if instance, ok := m.instances[team]; ok {
+		port = instance.Port
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d", port)
+}
+
+func (m *LocalInstanceManager) Create(ctx context.Context, team string, annotations map[string]string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.instances[team]; ok {
+		return bundle.ErrInstanceAlreadyExists
+	}
+	port, err := m.allocatePort()
+	if err != nil {
+		return err
+	}
+
+	instance := &localInstance{
+		Team:        team,
+		Port:        port,
+		CreatedAt:   time.Now(),
+		Annotations: maps.Clone(annotations),
+	}
+	if instance.Annotations == nil {
+		instance.Annotations = map[string]string{}
+	}
+	m.instances[team] = instance
+	if err := m.persist(); err != nil {
+		delete(m.instances, team)
+		return err
+	}
+	m.notify(bundle.InstanceAdded, instance)
+	go m.supervise(instance)
+	return nil
+}
+
+// Repair has nothing to recreate, as local instances are created in a single step and crashed processes get restarted automatically
+func (m *LocalInstanceManager) Repair(ctx context.Context, team string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.instances[team]; !ok {
+		return false, bundle.ErrInstanceNotFound
+	}
+	return false, nil
+}
+
+func (m *LocalInstanceManager) Delete(ctx context.Context, team string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance, ok := m.instances[team]
+	if !ok {
+		return nil
+	}
+	instance.deleted = true
+	if instance.process != nil {
+		instance.process.Kill()
+	}
+	delete(m.instances, team)
+	m.notify(bundle.InstanceDeleted, instance)
+	return m.persist()
+}
+
+// Restart stops the process of the instance, it gets started again by its supervisor
+func (m *LocalInstanceManager) Restart(ctx context.Context, team string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance, ok := m.instances[team]
+	if !ok || instance.process == nil {
+		return bundle.ErrInstanceNotFound
+	}
+	return instance.process.Kill()
+}
+
+// Resume is a no-op, as local instances never get hibernated
+func (m *LocalInstanceManager) Resume(ctx context.Context, team string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.instances[team]; !ok {
+		return bundle.ErrInstanceNotFound
+	}
+	return nil
+}
+
+func (m *LocalInstanceManager) Get(ctx context.Context, team string) (*bundle.Instance, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance, ok := m.instances[team]
+	if !ok {
+		return nil, bundle.ErrInstanceNotFound
+	}
+	result := instance.toInstance()
+	return &result, nil
+}
+
+func (m *LocalInstanceManager) List(ctx context.Context) ([]bundle.Instance, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.list(), nil
+}
+
+// list has to be called while holding the mutex
+func (m *LocalInstanceManager) list() []bundle.Instance {
+	instances := make([]bundle.Instance, 0, len(m.instances))
+	for _, instance := range m.instances {
+		instances = append(instances, instance.toInstance())
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].Team < instances[j].Team
+	})
+	return instances
+}
+
+// Watch starts with an added event for every existing instance, like a kubernetes watch does.
+// Watchers which don't keep up with the events get closed, so that they start a new watch with the current state
+func (m *LocalInstanceManager) Watch(ctx context.Context) (<-chan bundle.InstanceEvent, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	events := make(chan bundle.InstanceEvent, len(m.instances)+100)
+	for _, instance := range m.list() {
+		events <- bundle.InstanceEvent{Type: bundle.InstanceAdded, Instance: instance}
+	}
+	m.watchers = append(m.watchers, events)
+	go func() {
+		<-ctx.Done()
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		m.removeWatcher(events)
+	}()
+	return events, nil
+}
+
+// removeWatcher closes the events channel of the watcher unless it was already removed. Has to be called while holding the mutex
+func (m *LocalInstanceManager) removeWatcher(events chan bundle.InstanceEvent) {
+	for i, watcher := range m.watchers {
+		if watcher == events {
+			m.watchers = append(m.watchers[:i], m.watchers[i+1:]...)
+			close(events)
+			return
+		}
+	}
+}
+
+// notify has to be called while holding the mutex
+func (m *LocalInstanceManager) notify(eventType bundle.InstanceEventType, instance *localInstance) {
+	event := bundle.InstanceEvent{Type: eventType, Instance: instance.toInstance()}
+	for _, watcher := range append([]chan bundle.InstanceEvent{}, m.watchers...) {
+		select {
+		case watcher <- event:
+		default:
+			m.removeWatcher(watcher)
+		}
+	}
+}
+
+func (m *LocalInstanceManager) UpdateAnnotations(ctx context.Context, team string, update func(annotations map[string]string) error) (*bundle.Instance, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance, ok := m.instances[team]
+	if !ok {
+		return nil, bundle.ErrInstanceNotFound
+	}
+	previousAnnotations := instance.Annotations
+	annotations := maps.Clone(previousAnnotations)
+	if err := update(annotations); err != nil {
+		return nil, err
+	}
+	instance.Annotations = annotations
+	if err := m.persist(); err != nil {
+		instance.Annotations = previousAnnotations
+		return nil, err
+	}
+	m.notify(bundle.InstanceModified, instance)
+	result := instance.toInstance()
+	return &result, nil
+}
+
+func (m *LocalInstanceManager) MergeAnnotations(ctx context.Context, team string, annotations map[string]string) error {
+	_, err := m.UpdateAnnotations(ctx, team, func(existingAnnotations map[string]string) error {
+		maps.Copy(existingAnnotations, annotations)
+		return nil
+	})
+	return err
+}
+
+func (instance *localInstance) toInstance() bundle.Instance {
+	return bundle.Instance{
+		Team:        instance.Team,
+		Annotations: maps.Clone(instance.Annotations),
+		Ready:       instance.ready,
+		CreatedAt:   instance.CreatedAt,
+	}
+}
+
+// allocatePort returns the lowest port of the configured range which is neither used by another instance nor by any other process. Has to be called while holding the mutex
+func (m *LocalInstanceManager) allocatePort() (int, error) {
+	usedPorts := map[int]bool{}
+	for _, instance := range m.instances {
+		usedPorts[instance.Port] = true
+	}
+	start, end := m.config.GetPortRange()
+	for port := start; port <= end; port++ {
+		if usedPorts[port] {
+			continue
+		}
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			continue
+		}
+		listener.Close()
+		return port, nil
+	}
+	return 0, fmt.Errorf("no free port left in the range %d-%d", start, end)
+}
+
+// supervise keeps the process of the instance running until the instance gets deleted or the manager stopped
+func (m *LocalInstanceManager) supervise(instance *localInstance) {
+	for {
+		m.runProcess(instance)
+
+		m.mutex.Lock()
+		deleted := instance.deleted
+		m.mutex.Unlock()
+		if deleted {
+			return
+		}
+		select {
+		case <-time.After(localRestartDelay):
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *LocalInstanceManager) runProcess(instance *localInstance) {
+	cmd := m.newCommand(instance)
+
+	// started while holding the mutex, so that a concurrent delete either prevents the start or sees the process to stop it
+	m.mutex.Lock()
+	if instance.deleted {
+		m.mutex.Unlock()
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		m.mutex.Unlock()
+		m.bundle.Log.Printf("Failed to start instance of team '%s': %s", instance.Team, err)
+		return
+	}
+	instance.process = cmd.Process
+	m.mutex.Unlock()
+
+	exited := make(chan struct{})
+	go m.waitUntilReady(instance, cmd.Process, exited)
+	err := cmd.Wait()
+	close(exited)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance.process = nil
+	if instance.deleted {
+		return
+	}
+	if instance.ready {
+		instance.ready = false
+		m.notify(bundle.InstanceModified, instance)
+	}
+	m.bundle.Log.Printf("Instance of team '%s' stopped (%v), restarting it", instance.Team, err)
+}
+
+func (m *LocalInstanceManager) newCommand(instance *localInstance) *exec.Cmd {
+	port := strconv.Itoa(instance.Port)
+	args := make([]string, len(m.config.Command))
+	for i, arg := range m.config.Command {
+		args[i] = strings.NewReplacer("{port}", port, "{team}", instance.Team).Replace(arg)
+	}
+
+	juiceShopConfig := m.bundle.Config.JuiceShopConfig
+	cmd := exec.CommandContext(m.ctx, args[0], args[1:]...)
+	cmd.Dir = m.config.WorkingDirectory
+	cmd.Stdout = m.bundle.Log.Writer()
+	cmd.Stderr = m.bundle.Log.Writer()
+	cmd.Env = append(os.Environ(),
+		"PORT="+port,
+		"NODE_ENV="+juiceShopConfig.NodeEnv,
+		"CTF_KEY="+juiceShopConfig.CtfKey,
+		"SOLUTIONS_WEBHOOK="+fmt.Sprintf("http://%s/team/%s/webhook?", m.config.GetWebhookAddress(), instance.Team)+url.Values{webhookSecretQueryParameter: {instance.Annotations[bundle.WebhookSecretAnnotation]}}.Encode(),
+	)
+	// env vars referencing secrets or config maps can't be resolved without a cluster
+	for _, env := range juiceShopConfig.Env {
+		if env.ValueFrom == nil {
+			cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+		}
+	}
+	return cmd
+}
+
+// waitUntilReady polls the instance until it responds like the readiness probe of the kubernetes deployments
+func (m *LocalInstanceManager) waitUntilReady(instance *localInstance, process *os.Process, exited <-chan struct{}) {
+	url := fmt.Sprintf("http://127.0.0.1:%d/rest/admin/application-version", instance.Port)
+	ticker := time.NewTicker(localReadinessInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-exited:
+			return
+		}
+		res, err := m.httpClient.Get(url)
+		if err != nil {
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			continue
+		}
+
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if instance.process == process && !instance.deleted {
+			instance.ready = true
+			m.notify(bundle.InstanceModified, instance)
+		}
+		return
+	}
+}
+
+// load reads the persisted instances. A missing data file is not an error, there are just no instances yet
+func (m *LocalInstanceManager) load() error {
+	data, err := os.ReadFile(m.config.GetDataFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read instances data file: %w", err)
+	}
+
+	var instances []*localInstance
+	if err := json.Unmarshal(data, &instances); err != nil {
+		return fmt.Errorf("failed to decode instances data file: %w", err)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, instance := range instances {
+		if instance.Annotations == nil {
+			instance.Annotations = map[string]string{}
+		}
+		m.instances[instance.Team] = instance
+	}
+	return nil
+}
+
+// persist writes all instances to the data file. The file gets replaced atomically so that a crash can't leave a partially written file behind.
+// Has to be called while holding the mutex
+func (m *LocalInstanceManager) persist() error {
+	instances := make([]*localInstance, 0, len(m.instances))
+	for _, instance := range m.instances {
+		instances = append(instances, instance)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].Team < instances[j].Team
+	})
+	data, err := json.MarshalIndent(instances, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode instances: %w", err)
+	}
+
+	dataFile := m.config.GetDataFile()
+	tempFile, err := os.CreateTemp(filepath.Dir(dataFile), filepath.Base(dataFile)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write instances data file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write instances data file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to write instances data file: %w", err)
+	}
+	if err := os.Rename(tempFile.Name(), dataFile); err != nil {
+		return fmt.Errorf("failed to write instances data file: %w", err)
+	}
+	return nil
+}
+
+type solutionWebhook struct {
+	Solution struct {
+		Challenge string `json:"challenge"`
+		IssuedOn  string `json:"issuedOn"`
+	} `json:"solution"`
+}
+
+type challengeStatus struct {
+	Key      string `json:"key"`
+	SolvedAt string `json:"solvedAt"`
+}
+
+var errInvalidWebhookSecret = errors.New("invalid webhook secret")
+
+// webhookSecretQueryParameter of the solution webhook url containing the webhook key of the team.
+// Unlike in kubernetes, where the webhooks get signed by a sidecar, the key is sent as is: the webhook listener only listens locally and the url doesn't pass any proxy which could log it
+const webhookSecretQueryParameter = "secret"
+
+// verifyWebhookSecret rejects webhooks of teams without key as well, local teams got one since the webhooks are verified
+func verifyWebhookSecret(annotations map[string]string, secret string) bool {
+	expected := annotations[bundle.WebhookSecretAnnotation]
+	return expected != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1
+}
+
+// handleSolutionWebhook stores the solved challenges in the annotations of the instance, like the progress-watchdog does for instances running in kubernetes
+func (m *LocalInstanceManager) handleSolutionWebhook() http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, req *http.Request) {
+		team := req.PathValue("team")
+		var webhook solutionWebhook
+		if err := json.NewDecoder(req.Body).Decode(&webhook); err != nil || webhook.Solution.Challenge == "" {
+			http.Error(responseWriter, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		_, err := m.UpdateAnnotations(req.Context(), team, func(annotations map[string]string) error {
+			if !verifyWebhookSecret(annotations, req.URL.Query().Get(webhookSecretQueryParameter)) {
+				return errInvalidWebhookSecret
+			}
+			challenges := []challengeStatus{}
+			if encoded, ok := annotations["multi-juicer.owasp-juice.shop/challenges"]; ok {
+				if err := json.Unmarshal([]byte(encoded), &challenges); err != nil {
+					return fmt.Errorf("failed to decode solved challenges: %w", err)
+				}
+			}
+			for _, challenge := range challenges {
+				if challenge.Key == webhook.Solution.Challenge {
+					return nil
+				}
+			}
+			challenges = append(challenges, challengeStatus{Key: webhook.Solution.Challenge, SolvedAt: webhook.Solution.IssuedOn})
+			sort.Slice(challenges, func(i, j int) bool {
+				return challenges[i].Key < challenges[j].Key
+			})
+			encoded, err := json.Marshal(challenges)
+			if err != nil {
+				return err
+			}
+			annotations["multi-juicer.owasp-juice.shop/challenges"] = string(encoded)
+			annotations["multi-juicer.owasp-juice.shop/challengesSolved"] = strconv.Itoa(len(challenges))
+			return nil
+		})
+		if err == bundle.ErrInstanceNotFound {
+			http.Error(responseWriter, "team not found", http.StatusNotFound)
+			return
+		} else if err == errInvalidWebhookSecret {
+			m.bundle.Log.Printf("Rejected solution webhook for team '%s' with invalid secret from '%s'", team, req.RemoteAddr)
+			http.Error(responseWriter, "invalid webhook secret", http.StatusForbidden)
+			return
+		} else if err != nil {
+			m.bundle.Log.Printf("Failed to persist solved challenge '%s' of team '%s': %s", webhook.Solution.Challenge, team, err)
+			http.Error(responseWriter, "failed to persist solved challenge", http.StatusInternalServerError)
+			return
+		}
+		m.bundle.Log.Printf("Received webhook for team '%s' for challenge '%s'", team, webhook.Solution.Challenge)
+
+		responseWriter.WriteHeader(http.StatusOK)
+		responseWriter.Write([]byte("ok"))
+	})
+}
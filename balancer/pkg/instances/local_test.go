@@ -0,0 +1,231 @@
+package instances_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/instances"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestLocalHelperProcess isn't a real test, it's started by the local instance manager as a fake juice shop instance
+func TestLocalHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	team := os.Args[len(os.Args)-1]
+	router := http.NewServeMux()
+	router.HandleFunc("GET /rest/admin/application-version", func(responseWriter http.ResponseWriter, req *http.Request) {
+		responseWriter.Write([]byte(`{"version":"17.0.0"}`))
+	})
+	router.HandleFunc("GET /", func(responseWriter http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(responseWriter, "juice shop of team %s, node env %s", team, os.Getenv("NODE_ENV"))
+	})
+	http.ListenAndServe("127.0.0.1:"+os.Getenv("PORT"), router)
+	os.Exit(0)
+}
+
+func getFreeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func newLocalTestBundle(t *testing.T, dataFile string) *bundle.Bundle {
+	testBundle := testutil.NewTestBundle()
+	testBundle.Config.JuiceShopConfig.Env = []corev1.EnvVar{{Name: "GO_WANT_HELPER_PROCESS", Value: "1"}}
+	testBundle.Config.Backend = bundle.BackendConfig{
+		Type: bundle.BackendLocal,
+		Local: bundle.LocalBackendConfig{
+			Command:        []string{os.Args[0], "-test.run=^TestLocalHelperProcess$", "--", "{team}"},
+			PortRangeStart: 4700,
+			PortRangeEnd:   4799,
+			DataFile:       dataFile,
+			WebhookAddress: getFreeAddress(t),
+		},
+	}
+	return testBundle
+}
+
+func startLocalManager(t *testing.T, testBundle *bundle.Bundle) *instances.LocalInstanceManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	manager, err := instances.New(ctx, testBundle)
+	assert.NoError(t, err)
+	testBundle.Instances = manager
+	return manager.(*instances.LocalInstanceManager)
+}
+
+func waitForReadiness(t *testing.T, manager *instances.LocalInstanceManager, team string, ready bool) {
+	assert.Eventually(t, func() bool {
+		instance, err := manager.Get(context.Background(), team)
+		return err == nil && instance.Ready == ready
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func getBody(url string) (string, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return string(body), err
+}
+
+func TestLocalInstanceManager(t *testing.T) {
+	t.Run("starts instances as local processes reachable via the juice shop url", func(t *testing.T) {
+		testBundle := newLocalTestBundle(t, filepath.Join(t.TempDir(), "instances.json"))
+		manager := startLocalManager(t, testBundle)
+
+		err := manager.Create(context.Background(), "foobar", map[string]string{"multi-juicer.owasp-juice.shop/passcode": "hash"})
+		assert.NoError(t, err)
+		assert.Equal(t, bundle.ErrInstanceAlreadyExists, manager.Create(context.Background(), "foobar", map[string]string{}))
+		waitForReadiness(t, manager, "foobar", true)
+
+		url := testBundle.GetJuiceShopUrlForTeam("foobar", testBundle)
+		assert.Regexp(t, `^http://127\.0\.0\.1:47\d\d$`, url)
+		body, err := getBody(url)
+		assert.NoError(t, err)
+		assert.Equal(t, "juice shop of team foobar, node env multi-juicer", body)
+
+		instance, err := manager.Get(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.Equal(t, "hash", instance.Annotations["multi-juicer.owasp-juice.shop/passcode"])
+	})
+
+	t.Run("restores persisted instances on start", func(t *testing.T) {
+		dataFile := filepath.Join(t.TempDir(), "instances.json")
+		firstBundle := newLocalTestBundle(t, dataFile)
+		firstManagerCtx, stopFirstManager := context.WithCancel(context.Background())
+		firstManager, err := instances.New(firstManagerCtx, firstBundle)
+		assert.NoError(t, err)
+		assert.NoError(t, firstManager.Create(context.Background(), "foobar", map[string]string{}))
+		assert.NoError(t, firstManager.MergeAnnotations(context.Background(), "foobar", map[string]string{"multi-juicer.owasp-juice.shop/challengesSolved": "3"}))
+		stopFirstManager()
+
+		manager := startLocalManager(t, newLocalTestBundle(t, dataFile))
+
+		instance, err := manager.Get(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.Equal(t, "3", instance.Annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+		waitForReadiness(t, manager, "foobar", true)
+	})
+
+	t.Run("records solved challenges received via the solution webhook", func(t *testing.T) {
+		testBundle := newLocalTestBundle(t, filepath.Join(t.TempDir(), "instances.json"))
+		manager := startLocalManager(t, testBundle)
+		assert.NoError(t, manager.Create(context.Background(), "foobar", map[string]string{"multi-juicer.owasp-juice.shop/webhookSecret": "team-secret"}))
+
+		webhookUrl := fmt.Sprintf("http://%s/team/foobar/webhook?secret=team-secret", testBundle.Config.Backend.Local.WebhookAddress)
+		for i := 0; i < 2; i++ {
+			res, err := http.Post(webhookUrl, "application/json", bytes.NewBufferString(`{"solution":{"challenge":"scoreBoardChallenge","issuedOn":"2024-10-18T13:54:27.397Z"}}`))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+		}
+
+		instance, err := manager.Get(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", instance.Annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+		assert.JSONEq(t, `[{"key":"scoreBoardChallenge","solvedAt":"2024-10-18T13:54:27.397Z"}]`, instance.Annotations["multi-juicer.owasp-juice.shop/challenges"])
+
+		res, err := http.Post(fmt.Sprintf("http://%s/team/other-team/webhook", testBundle.Config.Backend.Local.WebhookAddress), "application/json", bytes.NewBufferString(`{"solution":{"challenge":"scoreBoardChallenge"}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("rejects solution webhooks without the webhook secret of the team", func(t *testing.T) {
+		testBundle := newLocalTestBundle(t, filepath.Join(t.TempDir(), "instances.json"))
+		manager := startLocalManager(t, testBundle)
+		assert.NoError(t, manager.Create(context.Background(), "foobar", map[string]string{"multi-juicer.owasp-juice.shop/webhookSecret": "team-secret"}))
+
+		webhookUrl := fmt.Sprintf("http://%s/team/foobar/webhook", testBundle.Config.Backend.Local.WebhookAddress)
+		for _, url := range []string{webhookUrl, webhookUrl + "?secret=other-secret"} {
+			res, err := http.Post(url, "application/json", bytes.NewBufferString(`{"solution":{"challenge":"scoreBoardChallenge","issuedOn":"2024-10-18T13:54:27.397Z"}}`))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		}
+		instance, err := manager.Get(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.Equal(t, "", instance.Annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+
+		res, err := http.Post(webhookUrl+"?secret=team-secret", "application/json", bytes.NewBufferString(`{"solution":{"challenge":"scoreBoardChallenge","issuedOn":"2024-10-18T13:54:27.397Z"}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		instance, err = manager.Get(context.Background(), "foobar")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", instance.Annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+
+		assert.NoError(t, manager.Create(context.Background(), "legacy-team", map[string]string{}))
+		res, err = http.Post(fmt.Sprintf("http://%s/team/legacy-team/webhook", testBundle.Config.Backend.Local.WebhookAddress), "application/json", bytes.NewBufferString(`{"solution":{"challenge":"scoreBoardChallenge","issuedOn":"2024-10-18T13:54:27.397Z"}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+
+	t.Run("restarts the process of an instance", func(t *testing.T) {
+		manager := startLocalManager(t, newLocalTestBundle(t, filepath.Join(t.TempDir(), "instances.json")))
+		assert.NoError(t, manager.Create(context.Background(), "foobar", map[string]string{}))
+		waitForReadiness(t, manager, "foobar", true)
+
+		assert.NoError(t, manager.Restart(context.Background(), "foobar"))
+		waitForReadiness(t, manager, "foobar", false)
+		waitForReadiness(t, manager, "foobar", true)
+
+		assert.Equal(t, bundle.ErrInstanceNotFound, manager.Restart(context.Background(), "other-team"))
+	})
+
+	t.Run("deleting stops the process of the instance", func(t *testing.T) {
+		testBundle := newLocalTestBundle(t, filepath.Join(t.TempDir(), "instances.json"))
+		manager := startLocalManager(t, testBundle)
+		assert.NoError(t, manager.Create(context.Background(), "foobar", map[string]string{}))
+		waitForReadiness(t, manager, "foobar", true)
+		url := testBundle.GetJuiceShopUrlForTeam("foobar", testBundle)
+
+		assert.NoError(t, manager.Delete(context.Background(), "foobar"))
+		assert.NoError(t, manager.Delete(context.Background(), "foobar"))
+
+		_, err := manager.Get(context.Background(), "foobar")
+		assert.Equal(t, bundle.ErrInstanceNotFound, err)
+		assert.Eventually(t, func() bool {
+			_, err := getBody(url)
+			return err != nil
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("watch starts with the existing instances", func(t *testing.T) {
+		manager := startLocalManager(t, newLocalTestBundle(t, filepath.Join(t.TempDir(), "instances.json")))
+		assert.NoError(t, manager.Create(context.Background(), "foobar", map[string]string{}))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events, err := manager.Watch(ctx)
+		assert.NoError(t, err)
+		event := <-events
+		assert.Equal(t, bundle.InstanceAdded, event.Type)
+		assert.Equal(t, "foobar", event.Instance.Team)
+
+		assert.NoError(t, manager.Delete(context.Background(), "foobar"))
+		assert.Eventually(t, func() bool {
+			event := <-events
+			return event.Type == bundle.InstanceDeleted
+		}, 5*time.Second, 10*time.Millisecond)
+
+		cancel()
+		assert.Eventually(t, func() bool {
+			_, ok := <-events
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,391 @@
+package joincodes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/statestore"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/retry"
+)
+
+// ConfigMapName is the name of the ConfigMap the join codes get persisted in, so that they survive balancer restarts and are shared between balancer replicas
+const ConfigMapName = "balancer-join-codes"
+
+const configMapDataKey = "joinCodes.json"
+
+// MaxJoinCodes limits how many join codes can exist at the same time to stay well below the ConfigMap size limit
+const MaxJoinCodes = 100
+
+const generatedCodeLength = 10
+
+// without easily confused characters like 0/O and 1/I
+const generatedCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var validCodePattern = regexp.MustCompile("^[A-Z0-9][-_A-Z0-9]{3,63}$")
+
+// Config of the join codes
+type Config struct {
+	// Required makes a valid join code mandatory to create new teams, even if no join code exists or the join codes couldn't be loaded.
+	// Otherwise join codes are only required as long as at least one join code exists
+	Required bool `json:"required"`
+}
+
+// JoinCode allows to create new teams. As long as at least one join code exists, new teams can only be created with a valid join code
+type JoinCode struct {
+	Code string `json:"code"`
+	// Quota is the number of teams which can be created with the code. 0 if unlimited
+	Quota int `json:"quota"`
+	// Teams created with the code
+	Teams     []string  `json:"teams"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsExhausted returns true if the quota of the code is used up
+func (c *JoinCode) IsExhausted() bool {
+	return c.Quota > 0 && len(c.Teams) >= c.Quota
+}
+
+var (
+	ErrJoinCodeNotFound     = errors.New("join code not found")
+	ErrNoJoinCodes          = errors.New("no join codes exist")
+	ErrJoinCodeExhausted    = errors.New("join code has been used up")
+	ErrJoinCodeExists       = errors.New("join code already exists")
+	ErrInvalidJoinCode      = errors.New("join code must be 4 to 64 characters long and only contain letters, digits, '-' and '_'")
+	ErrTooManyJoinCodes     = fmt.Errorf("can't create more than %d join codes", MaxJoinCodes)
+	ErrInvalidJoinCodeQuota = errors.New("quota must not be negative")
+)
+
+type Service struct {
+	store  statestore.Store
+	log    *log.Logger
+	config Config
+
+	joinCodes      []JoinCode
+	joinCodesMutex *sync.RWMutex
+}
+
+func NewService(store statestore.Store, logger *log.Logger, config Config) *Service {
+	return &Service{
+		store:          store,
+		log:            logger,
+		config:         config,
+		joinCodes:      []JoinCode{},
+		joinCodesMutex: &sync.RWMutex{},
+	}
+}
+
+// NormalizeCode trims the code and converts it to upper case, so that codes are not case sensitive when typed in by participants
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// IsRequired returns true if a valid join code is mandatory to create new teams.
+// That's the case if join codes are configured as required or at least one join code exists
+func (s *Service) IsRequired() bool {
+	s.joinCodesMutex.RLock()
+	defer s.joinCodesMutex.RUnlock()
+	return s.config.Required || len(s.joinCodes) > 0
+}
+
+// GetJoinCodes returns all join codes, newest first
+func (s *Service) GetJoinCodes() []JoinCode {
+	s.joinCodesMutex.RLock()
+	defer s.joinCodesMutex.RUnlock()
+	return slices.Clone(s.joinCodes)
+}
+
+// CreateJoinCode persists a new join code. A random code is generated if the passed code is empty
+func (s *Service) CreateJoinCode(ctx context.Context, code string, quota int) (*JoinCode, error) {
+	if quota < 0 {
+		return nil, ErrInvalidJoinCodeQuota
+	}
+	code = NormalizeCode(code)
+	if code == "" {
+		generated, err := generateCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate join code: %w", err)
+		}
+		code = generated
+	} else if !validCodePattern.MatchString(code) {
+		return nil, ErrInvalidJoinCode
+	}
+
+	joinCode := JoinCode{
+		Code:      code,
+		Quota:     quota,
+		Teams:     []string{},
+		CreatedAt: time.Now(),
+	}
+	err := s.updatePersistedJoinCodes(ctx, func(joinCodes []JoinCode) ([]JoinCode, error) {
+		if findJoinCode(joinCodes, code) != -1 {
+			return nil, ErrJoinCodeExists
+		}
+		if len(joinCodes) >= MaxJoinCodes {
+			return nil, ErrTooManyJoinCodes
+		}
+		return append([]JoinCode{joinCode}, joinCodes...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &joinCode, nil
+}
+
+// DeleteJoinCode removes the join code. Teams already created with it are not affected. Returns ErrJoinCodeNotFound if it doesn't exist
+func (s *Service) DeleteJoinCode(ctx context.Context, code string) error {
+	code = NormalizeCode(code)
+	return s.updatePersistedJoinCodes(ctx, func(joinCodes []JoinCode) ([]JoinCode, error) {
+		index := findJoinCode(joinCodes, code)
+		if index == -1 {
+			return nil, ErrJoinCodeNotFound
+		}
+		return slices.Delete(joinCodes, index, index+1), nil
+	})
+}
+
+// Redeem records that the team is about to be created with the join code.
+// Returns ErrNoJoinCodes if no join code exists (or none could be loaded), ErrJoinCodeNotFound if the code doesn't exist and ErrJoinCodeExhausted if its quota is used up
+func (s *Service) Redeem(ctx context.Context, code string, team string) error {
+	code = NormalizeCode(code)
+	// check against the local cache first, to not hit the kubernetes api for every wrong guess
+	if len(s.GetJoinCodes()) == 0 {
+		return ErrNoJoinCodes
+	}
+	if !s.exists(code) {
+		return ErrJoinCodeNotFound
+	}
+	return s.updatePersistedJoinCodes(ctx, func(joinCodes []JoinCode) ([]JoinCode, error) {
+		index := findJoinCode(joinCodes, code)
+		if index == -1 {
+			return nil, ErrJoinCodeNotFound
+		}
+		if joinCodes[index].IsExhausted() {
+			return nil, ErrJoinCodeExhausted
+		}
+		if !slices.Contains(joinCodes[index].Teams, team) {
+			joinCodes[index].Teams = append(joinCodes[index].Teams, team)
+		}
+		return joinCodes, nil
+	})
+}
+
+// Release gives back the quota used by the team, e.g. because the creation of the team failed after the code got redeemed
+func (s *Service) Release(ctx context.Context, code string, team string) error {
+	code = NormalizeCode(code)
+	return s.updatePersistedJoinCodes(ctx, func(joinCodes []JoinCode) ([]JoinCode, error) {
+		index := findJoinCode(joinCodes, code)
+		if index == -1 {
+			return joinCodes, nil
+		}
+		joinCodes[index].Teams = slices.DeleteFunc(joinCodes[index].Teams, func(t string) bool { return t == team })
+		return joinCodes, nil
+	})
+}
+
+func (s *Service) exists(code string) bool {
+	s.joinCodesMutex.RLock()
+	defer s.joinCodesMutex.RUnlock()
+	return findJoinCode(s.joinCodes, code) != -1
+}
+
+// LoadJoinCodes reads the persisted join codes from the ConfigMap into the local cache
+func (s *Service) LoadJoinCodes(ctx context.Context) error {
+	configMap, err := s.getConfigMap(ctx)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get join codes config map: %w", err)
+	}
+	joinCodes, err := decodeJoinCodes(configMap)
+	if err != nil {
+		return err
+	}
+	s.setJoinCodes(joinCodes)
+	return nil
+}
+
+// StartJoinCodeWorker keeps the local join code cache in sync with the ConfigMap, so that codes created or redeemed via other balancer replicas are picked up as well
+func (s *Service) StartJoinCodeWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			s.log.Printf("MultiJuicer context canceled. Exiting the join code watcher.")
+			return
+		default:
+			s.startJoinCodeWatcher(ctx)
+		}
+	}
+}
+
+func (s *Service) startJoinCodeWatcher(ctx context.Context) {
+	watcher, err := s.store.Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", ConfigMapName).String(),
+	})
+	if err != nil {
+		s.log.Printf("Failed to start the watcher for the join codes config map: %v", err)
+		time.Sleep(5 * time.Second)
+		return
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				s.log.Printf("Watcher for the join codes config map has been closed. Restarting the watcher.")
+				return
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				configMap := event.Object.(*corev1.ConfigMap)
+				joinCodes, err := decodeJoinCodes(configMap)
+				if err != nil {
+					s.log.Printf("Ignoring update of the join codes config map: %v", err)
+					continue
+				}
+				s.setJoinCodes(joinCodes)
+			case watch.Deleted:
+				s.setJoinCodes([]JoinCode{})
+			default:
+			}
+		case <-ctx.Done():
+			s.log.Printf("MultiJuicer context canceled. Exiting the join code watcher.")
+			return
+		}
+	}
+}
+
+func (s *Service) setJoinCodes(joinCodes []JoinCode) {
+	s.joinCodesMutex.Lock()
+	defer s.joinCodesMutex.Unlock()
+	s.joinCodes = joinCodes
+}
+
+func (s *Service) getConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	return s.store.Get(ctx, ConfigMapName, metav1.GetOptions{})
+}
+
+// updatePersistedJoinCodes applies the update function to the currently persisted join codes and writes the result back.
+// Conflicting concurrent writes (e.g. from another balancer replica) are retried, which ensures that quotas can't be exceeded
+func (s *Service) updatePersistedJoinCodes(ctx context.Context, update func([]JoinCode) ([]JoinCode, error)) error {
+	var updatedJoinCodes []JoinCode
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+
+		configMap, err := s.getConfigMap(ctx)
+		if apierrors.IsNotFound(err) {
+			joinCodes, err := update([]JoinCode{})
+			if err != nil {
+				return err
+			}
+			configMap, err = encodeJoinCodes(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: ConfigMapName,
+					Labels: map[string]string{
+						"app.kubernetes.io/name":    "balancer",
+						"app.kubernetes.io/part-of": "multi-juicer",
+					},
+				},
+			}, joinCodes)
+			if err != nil {
+				return err
+			}
+			_, err = s.store.Create(ctx, configMap, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// created concurrently, treat like a conflict to retry with the now existing config map
+				return apierrors.NewConflict(corev1.Resource("configmaps"), ConfigMapName, err)
+			}
+			updatedJoinCodes = joinCodes
+			return err
+		} else if err != nil {
+			return fmt.Errorf("failed to get join codes config map: %w", err)
+		}
+
+		joinCodes, err := decodeJoinCodes(configMap)
+		if err != nil {
+			return err
+		}
+		joinCodes, err = update(joinCodes)
+		if err != nil {
+			return err
+		}
+		configMap, err = encodeJoinCodes(configMap, joinCodes)
+		if err != nil {
+			return err
+		}
+		_, err = s.store.Update(ctx, configMap, metav1.UpdateOptions{})
+		updatedJoinCodes = joinCodes
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.setJoinCodes(updatedJoinCodes)
+	return nil
+}
+
+func decodeJoinCodes(configMap *corev1.ConfigMap) ([]JoinCode, error) {
+	joinCodes := []JoinCode{}
+	data, ok := configMap.Data[configMapDataKey]
+	if !ok || data == "" {
+		return joinCodes, nil
+	}
+	if err := json.Unmarshal([]byte(data), &joinCodes); err != nil {
+		return nil, fmt.Errorf("failed to decode join codes from config map: %w", err)
+	}
+	sort.SliceStable(joinCodes, func(i, j int) bool {
+		return joinCodes[i].CreatedAt.After(joinCodes[j].CreatedAt)
+	})
+	return joinCodes, nil
+}
+
+func encodeJoinCodes(configMap *corev1.ConfigMap, joinCodes []JoinCode) (*corev1.ConfigMap, error) {
+	encoded, err := json.Marshal(joinCodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode join codes: %w", err)
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[configMapDataKey] = string(encoded)
+	return configMap, nil
+}
+
+// findJoinCode returns the index of the code, comparing in constant time to not leak valid codes via timing differences
+func findJoinCode(joinCodes []JoinCode, code string) int {
+	index := -1
+	for i := range joinCodes {
+		if subtle.ConstantTimeCompare([]byte(joinCodes[i].Code), []byte(code)) == 1 {
+			index = i
+		}
+	}
+	return index
+}
+
+func generateCode() (string, error) {
+	bytes := make([]byte, generatedCodeLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	code := make([]byte, generatedCodeLength)
+	for i, b := range bytes {
+		code[i] = generatedCodeAlphabet[int(b)%len(generatedCodeAlphabet)]
+	}
+	return string(code), nil
+}
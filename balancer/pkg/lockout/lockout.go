@@ -0,0 +1,268 @@
+package lockout
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config controls after how many failed login attempts logins get temporarily blocked and for how long
+type Config struct {
+	// FreeAttempts is the number of failed attempts allowed before logins get locked
+	FreeAttempts int `json:"freeAttempts"`
+	// BaseLockoutSeconds is the lockout duration after the first attempt exceeding the free attempts. Doubles with every further failed attempt
+	BaseLockoutSeconds int `json:"baseLockoutSeconds"`
+	// MaxLockoutSeconds caps the lockout duration
+	MaxLockoutSeconds int `json:"maxLockoutSeconds"`
+	// ResetAfterSeconds is the duration without failed attempts after which the failed attempts are forgotten
+	ResetAfterSeconds int `json:"resetAfterSeconds"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		FreeAttempts:       5,
+		BaseLockoutSeconds: 5,
+		MaxLockoutSeconds:  15 * 60,
+		ResetAfterSeconds:  60 * 60,
+	}
+}
+
+// WithDefaults fills all unset values with the default config
+func (c Config) WithDefaults() Config {
+	defaults := DefaultConfig()
+	if c.FreeAttempts <= 0 {
+		c.FreeAttempts = defaults.FreeAttempts
+	}
+	if c.BaseLockoutSeconds <= 0 {
+		c.BaseLockoutSeconds = defaults.BaseLockoutSeconds
+	}
+	if c.MaxLockoutSeconds <= 0 {
+		c.MaxLockoutSeconds = defaults.MaxLockoutSeconds
+	}
+	if c.ResetAfterSeconds <= 0 {
+		c.ResetAfterSeconds = defaults.ResetAfterSeconds
+	}
+	return c
+}
+
+type KeyType string
+
+const (
+	// KeyTypeTeam tracks the failed logins into a team from all client ips. The lockout of a team only applies to the client ips which failed to log into the team,
+	// so that nobody can lock a team out by guessing wrong passcodes. See RecordTeamFailure
+	KeyTypeTeam     KeyType = "team"
+	KeyTypeClientIP KeyType = "ip"
+)
+
+// Key identifies what failed login attempts are tracked for, e.g. a team or a client ip
+type Key struct {
+	Type  KeyType `json:"type"`
+	Value string  `json:"value"`
+}
+
+func TeamKey(team string) Key {
+	return Key{Type: KeyTypeTeam, Value: team}
+}
+
+func ClientIPKey(ip string) Key {
+	return Key{Type: KeyTypeClientIP, Value: ip}
+}
+
+type entry struct {
+	failedAttempts int
+	lastFailure    time.Time
+	lockedUntil    time.Time
+	// clientIPs which failed to log into the team. Only set for team keys
+	clientIPs map[string]bool
+}
+
+// Lockout is a snapshot of the failed attempts tracked for a key
+type Lockout struct {
+	Key
+	FailedAttempts int `json:"failedAttempts"`
+	// LockedUntil in unix millis. 0 if not currently locked
+	LockedUntil int64 `json:"lockedUntil"`
+	LastFailure int64 `json:"lastFailure"`
+	// ClientIPs the lockout of a team applies to. Omitted for client ip keys
+	ClientIPs []string `json:"clientIps,omitempty"`
+}
+
+// Tracker keeps track of failed login attempts in memory and locks keys with exponentially growing durations.
+// The attempts are tracked by each balancer replica on its own and are lost on restarts, so with multiple replicas clients get the free attempts on every replica they get routed to
+type Tracker struct {
+	config  Config
+	mutex   sync.Mutex
+	entries map[Key]*entry
+	// on the tracker to control the time in tests
+	now func() time.Time
+}
+
+func NewTracker(config Config) *Tracker {
+	return &Tracker{
+		config:  config.WithDefaults(),
+		entries: map[Key]*entry{},
+		now:     time.Now,
+	}
+}
+
+// RetryAfter returns how long the longest lockout of the given keys still lasts. 0 if none of them is locked
+func (t *Tracker) RetryAfter(keys ...Key) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		if entry, ok := t.entries[key]; ok && entry.lockedUntil.After(now) {
+			retryAfter = max(retryAfter, entry.lockedUntil.Sub(now))
+		}
+	}
+	return retryAfter
+}
+
+// TeamRetryAfter returns how long the client ip is still locked out of logging into the team. 0 if it's not locked.
+// The lockout of the team only applies if the client ip failed to log into the team before, the lockout of the client ip always applies
+func (t *Tracker) TeamRetryAfter(team string, clientIP string) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	var retryAfter time.Duration
+	if entry, ok := t.entries[ClientIPKey(clientIP)]; ok && entry.lockedUntil.After(now) {
+		retryAfter = entry.lockedUntil.Sub(now)
+	}
+	if entry, ok := t.entries[TeamKey(team)]; ok && entry.lockedUntil.After(now) && entry.clientIPs[clientIP] {
+		retryAfter = max(retryAfter, entry.lockedUntil.Sub(now))
+	}
+	return retryAfter
+}
+
+// RecordFailure counts a failed attempt for all keys and locks them once they exceed the free attempts
+func (t *Tracker) RecordFailure(keys ...Key) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	t.pruneExpired(now)
+	for _, key := range keys {
+		t.recordFailure(key, now)
+	}
+}
+
+// RecordTeamFailure counts a failed login of the client ip into the team, for both the client ip and the team
+func (t *Tracker) RecordTeamFailure(team string, clientIP string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	t.pruneExpired(now)
+	t.recordFailure(ClientIPKey(clientIP), now)
+	teamEntry := t.recordFailure(TeamKey(team), now)
+	if teamEntry.clientIPs == nil {
+		teamEntry.clientIPs = map[string]bool{}
+	}
+	teamEntry.clientIPs[clientIP] = true
+}
+
+// RecordTeamSuccess lifts the lockout of the team for the client ip. The failed attempts of the team stay, so that other client ips stay locked out.
+// The client ip isn't reset either, as that would allow to circumvent the lockout of the client ip by regularly logging into a own team
+func (t *Tracker) RecordTeamSuccess(team string, clientIP string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if entry, ok := t.entries[TeamKey(team)]; ok {
+		delete(entry.clientIPs, clientIP)
+	}
+}
+
+// must be called with the mutex held
+func (t *Tracker) recordFailure(key Key, now time.Time) *entry {
+	e, ok := t.entries[key]
+	if !ok {
+		e = &entry{}
+		t.entries[key] = e
+	}
+	e.failedAttempts++
+	e.lastFailure = now
+	if exceeding := e.failedAttempts - t.config.FreeAttempts; exceeding > 0 {
+		e.lockedUntil = now.Add(t.lockoutDuration(exceeding))
+	}
+	return e
+}
+
+// RecordSuccess forgets the failed attempts of the keys
+func (t *Tracker) RecordSuccess(keys ...Key) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, key := range keys {
+		delete(t.entries, key)
+	}
+}
+
+// Clear removes the tracked failed attempts and lockout of the key. Returns false if nothing was tracked for it
+func (t *Tracker) Clear(key Key) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	_, ok := t.entries[key]
+	delete(t.entries, key)
+	return ok
+}
+
+func (t *Tracker) ClearAll() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.entries = map[Key]*entry{}
+}
+
+// List returns all keys with tracked failed attempts, currently locked keys first
+func (t *Tracker) List() []Lockout {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	t.pruneExpired(now)
+	lockouts := []Lockout{}
+	for key, e := range t.entries {
+		lockout := Lockout{
+			Key:            key,
+			FailedAttempts: e.failedAttempts,
+			LastFailure:    e.lastFailure.UnixMilli(),
+		}
+		if e.lockedUntil.After(now) {
+			lockout.LockedUntil = e.lockedUntil.UnixMilli()
+		}
+		for clientIP := range e.clientIPs {
+			lockout.ClientIPs = append(lockout.ClientIPs, clientIP)
+		}
+		sort.Strings(lockout.ClientIPs)
+		lockouts = append(lockouts, lockout)
+	}
+	sort.Slice(lockouts, func(i, j int) bool {
+		if lockouts[i].LockedUntil != lockouts[j].LockedUntil {
+			return lockouts[i].LockedUntil > lockouts[j].LockedUntil
+		}
+		return lockouts[i].LastFailure > lockouts[j].LastFailure
+	})
+	return lockouts
+}
+
+func (t *Tracker) lockoutDuration(exceedingAttempts int) time.Duration {
+	base := time.Duration(t.config.BaseLockoutSeconds) * time.Second
+	maxDuration := time.Duration(t.config.MaxLockoutSeconds) * time.Second
+	// compared as float to avoid overflowing the duration for large numbers of attempts
+	duration := float64(base) * math.Pow(2, float64(exceedingAttempts-1))
+	if duration >= float64(maxDuration) {
+		return maxDuration
+	}
+	return time.Duration(duration)
+}
+
+// removes entries which are neither locked nor had a failed attempt within the reset duration. must be called with the mutex held
+func (t *Tracker) pruneExpired(now time.Time) {
+	resetAfter := time.Duration(t.config.ResetAfterSeconds) * time.Second
+	for key, e := range t.entries {
+		if !e.lockedUntil.After(now) && now.Sub(e.lastFailure) > resetAfter {
+			delete(t.entries, key)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTracker() (*Tracker, *time.Time) {
+	now := time.Date(2024, 10, 18, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(Config{FreeAttempts: 3, BaseLockoutSeconds: 10, MaxLockoutSeconds: 60, ResetAfterSeconds: 600})
+	tracker.now = func() time.Time { return now }
+	return tracker, &now
+}
+
+func TestTracker(t *testing.T) {
+	team := TeamKey("foobar")
+	ip := ClientIPKey("10.0.0.1")
+
+	t.Run("doesn't lock before the free attempts are used up", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+		for range 3 {
+			tracker.RecordFailure(team, ip)
+		}
+		assert.Zero(t, tracker.RetryAfter(team, ip))
+	})
+
+	t.Run("lock duration grows exponentially up to the max", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+		for range 3 {
+			tracker.RecordFailure(team)
+		}
+
+		tracker.RecordFailure(team)
+		assert.Equal(t, 10*time.Second, tracker.RetryAfter(team))
+		tracker.RecordFailure(team)
+		assert.Equal(t, 20*time.Second, tracker.RetryAfter(team))
+		tracker.RecordFailure(team)
+		assert.Equal(t, 40*time.Second, tracker.RetryAfter(team))
+		tracker.RecordFailure(team)
+		assert.Equal(t, 60*time.Second, tracker.RetryAfter(team))
+		for range 100 {
+			tracker.RecordFailure(team)
+		}
+		assert.Equal(t, 60*time.Second, tracker.RetryAfter(team))
+	})
+
+	t.Run("returns the longest lockout of the given keys", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+		for range 5 {
+			tracker.RecordFailure(team)
+		}
+		for range 4 {
+			tracker.RecordFailure(ip)
+		}
+		assert.Equal(t, 20*time.Second, tracker.RetryAfter(team, ip))
+		assert.Equal(t, 10*time.Second, tracker.RetryAfter(ip))
+		assert.Zero(t, tracker.RetryAfter(TeamKey("other-team")))
+	})
+
+	t.Run("lockout expires", func(t *testing.T) {
+		tracker, now := newTestTracker()
+		for range 4 {
+			tracker.RecordFailure(team)
+		}
+		*now = now.Add(11 * time.Second)
+		assert.Zero(t, tracker.RetryAfter(team))
+	})
+
+	t.Run("forgets failed attempts after the reset duration", func(t *testing.T) {
+		tracker, now := newTestTracker()
+		for range 4 {
+			tracker.RecordFailure(team)
+		}
+		*now = now.Add(11 * time.Minute)
+		assert.Empty(t, tracker.List())
+
+		tracker.RecordFailure(team)
+		assert.Zero(t, tracker.RetryAfter(team))
+	})
+
+	t.Run("success resets the failed attempts", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+		for range 3 {
+			tracker.RecordFailure(team)
+		}
+		tracker.RecordSuccess(team)
+		tracker.RecordFailure(team)
+		assert.Zero(t, tracker.RetryAfter(team))
+	})
+
+	t.Run("clear removes single keys", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+		for range 4 {
+			tracker.RecordFailure(team, ip)
+		}
+		assert.True(t, tracker.Clear(team))
+		assert.False(t, tracker.Clear(team))
+		assert.Zero(t, tracker.RetryAfter(team))
+		assert.NotZero(t, tracker.RetryAfter(ip))
+
+		tracker.ClearAll()
+		assert.Empty(t, tracker.List())
+	})
+
+	t.Run("the lockout of a team only applies to the client ips which failed to log into it", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+		for range 3 {
+			tracker.RecordTeamFailure("foobar", "10.0.0.1")
+		}
+		tracker.RecordTeamFailure("foobar", "10.0.0.2")
+
+		assert.Equal(t, 10*time.Second, tracker.TeamRetryAfter("foobar", "10.0.0.1"))
+		assert.Equal(t, 10*time.Second, tracker.TeamRetryAfter("foobar", "10.0.0.2"))
+		assert.Zero(t, tracker.TeamRetryAfter("foobar", "10.0.0.3"))
+		assert.Zero(t, tracker.TeamRetryAfter("other-team", "10.0.0.2"))
+
+		tracker.RecordTeamSuccess("foobar", "10.0.0.2")
+		assert.Zero(t, tracker.TeamRetryAfter("foobar", "10.0.0.2"))
+		assert.Equal(t, 10*time.Second, tracker.TeamRetryAfter("foobar", "10.0.0.1"))
+	})
+
+	t.Run("the lockout of a client ip applies to all teams", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+		tracker.RecordTeamFailure("team-a", "10.0.0.1")
+		tracker.RecordTeamFailure("team-b", "10.0.0.1")
+		tracker.RecordTeamFailure("team-c", "10.0.0.1")
+		tracker.RecordTeamFailure("team-d", "10.0.0.1")
+
+		assert.Equal(t, 10*time.Second, tracker.TeamRetryAfter("other-team", "10.0.0.1"))
+		assert.Zero(t, tracker.TeamRetryAfter("team-a", "10.0.0.2"))
+	})
+
+	t.Run("lists locked keys first", func(t *testing.T) {
+		tracker, now := newTestTracker()
+		for range 4 {
+			tracker.RecordFailure(team)
+		}
+		*now = now.Add(time.Second)
+		tracker.RecordFailure(ip)
+
+		lockouts := tracker.List()
+		assert.Len(t, lockouts, 2)
+		assert.Equal(t, team, lockouts[0].Key)
+		assert.Equal(t, now.Add(9*time.Second).UnixMilli(), lockouts[0].LockedUntil)
+		assert.Equal(t, ip, lockouts[1].Key)
+		assert.Equal(t, int64(0), lockouts[1].LockedUntil)
+	})
+}
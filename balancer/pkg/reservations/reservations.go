@@ -0,0 +1,183 @@
+package reservations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/statestore"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// ConfigMapName is the name of the ConfigMap tracking the instances currently being created.
+// Every reservation updates the ConfigMap, so that concurrent reservations (also from other balancer replicas) conflict and get retried with the latest state
+const ConfigMapName = "balancer-instance-reservations"
+
+const configMapDataKey = "reservations.json"
+
+// ReservationTimeout after which reservations are no longer counted, e.g. because the balancer creating the instance crashed before releasing it
+const ReservationTimeout = 2 * time.Minute
+
+// reservationBackoff retries more often than retry.DefaultRetry, as concurrent team creations (e.g. bulk creations) all update the same config map
+var reservationBackoff = wait.Backoff{
+	Steps:    10,
+	Duration: 10 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.5,
+	Cap:      time.Second,
+}
+
+var ErrMaxInstancesReached = errors.New("reached maximum instance count")
+
+// ListTeams returns the teams which currently have an instance
+type ListTeams func(ctx context.Context) ([]string, error)
+
+type Service struct {
+	store     statestore.Store
+	listTeams ListTeams
+	// now is replaceable in tests to simulate expired reservations
+	now func() time.Time
+}
+
+func NewService(store statestore.Store, listTeams ListTeams) *Service {
+	return &Service{
+		store:     store,
+		listTeams: listTeams,
+		now:       time.Now,
+	}
+}
+
+// IsLimitReached returns true if no instance can be created anymore with the given max instance count. A negative max instance count disables the limit
+func IsLimitReached(instanceCount int, maxInstances int) bool {
+	return maxInstances >= 0 && instanceCount >= maxInstances
+}
+
+// Reserve admits the creation of a new instance for the team. Returns ErrMaxInstancesReached if the existing instances plus the pending reservations already reach the max instance count.
+// The reservation has to be released once the instance got created (or its creation failed)
+func (s *Service) Reserve(ctx context.Context, team string, maxInstances int) error {
+	return retry.RetryOnConflict(reservationBackoff, func() error {
+		// the config map has to be read before listing the instances. Instances created concurrently are then either still reserved in the read config map, or already listed
+		configMap, err := s.getConfigMap(ctx)
+		exists := true
+		if apierrors.IsNotFound(err) {
+			exists = false
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: ConfigMapName,
+					Labels: map[string]string{
+						"app.kubernetes.io/name":    "balancer",
+						"app.kubernetes.io/part-of": "multi-juicer",
+					},
+				},
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to get instance reservations config map: %w", err)
+		}
+		reservations, err := decodeReservations(configMap)
+		if err != nil {
+			return err
+		}
+
+		existingTeams, err := s.listTeams(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list instances: %w", err)
+		}
+
+		teams := map[string]bool{}
+		for _, existingTeam := range existingTeams {
+			teams[existingTeam] = true
+		}
+		now := s.now()
+		for reservedTeam, reservedAt := range reservations {
+			if now.Sub(reservedAt) > ReservationTimeout {
+				delete(reservations, reservedTeam)
+				continue
+			}
+			if reservedTeam != team {
+				teams[reservedTeam] = true
+			}
+		}
+		if IsLimitReached(len(teams), maxInstances) {
+			return ErrMaxInstancesReached
+		}
+
+		reservations[team] = now
+		configMap, err = encodeReservations(configMap, reservations)
+		if err != nil {
+			return err
+		}
+		return s.writeConfigMap(ctx, configMap, exists)
+	})
+}
+
+// Release removes the reservation of the team
+func (s *Service) Release(ctx context.Context, team string) error {
+	return retry.RetryOnConflict(reservationBackoff, func() error {
+		configMap, err := s.getConfigMap(ctx)
+		if apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get instance reservations config map: %w", err)
+		}
+		reservations, err := decodeReservations(configMap)
+		if err != nil {
+			return err
+		}
+		if _, ok := reservations[team]; !ok {
+			return nil
+		}
+		delete(reservations, team)
+		configMap, err = encodeReservations(configMap, reservations)
+		if err != nil {
+			return err
+		}
+		return s.writeConfigMap(ctx, configMap, true)
+	})
+}
+
+func (s *Service) getConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	return s.store.Get(ctx, ConfigMapName, metav1.GetOptions{})
+}
+
+func (s *Service) writeConfigMap(ctx context.Context, configMap *corev1.ConfigMap, exists bool) error {
+	if exists {
+		_, err := s.store.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	}
+	_, err := s.store.Create(ctx, configMap, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		// created concurrently, treat like a conflict to retry with the now existing config map
+		return apierrors.NewConflict(corev1.Resource("configmaps"), ConfigMapName, err)
+	}
+	return err
+}
+
+func decodeReservations(configMap *corev1.ConfigMap) (map[string]time.Time, error) {
+	reservations := map[string]time.Time{}
+	data, ok := configMap.Data[configMapDataKey]
+	if !ok || data == "" {
+		return reservations, nil
+	}
+	if err := json.Unmarshal([]byte(data), &reservations); err != nil {
+		return nil, fmt.Errorf("failed to decode instance reservations from config map: %w", err)
+	}
+	return reservations, nil
+}
+
+func encodeReservations(configMap *corev1.ConfigMap, reservations map[string]time.Time) (*corev1.ConfigMap, error) {
+	encoded, err := json.Marshal(reservations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode instance reservations: %w", err)
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[configMapDataKey] = string(encoded)
+	return configMap, nil
+}
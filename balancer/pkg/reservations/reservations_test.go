@@ -0,0 +1,104 @@
+package reservations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func existingTeams(teams ...string) ListTeams {
+	return func(ctx context.Context) ([]string, error) {
+		return teams, nil
+	}
+}
+
+func getReservations(t *testing.T, service *Service) map[string]time.Time {
+	configMap, err := service.getConfigMap(context.Background())
+	assert.NoError(t, err)
+	reservations, err := decodeReservations(configMap)
+	assert.NoError(t, err)
+	return reservations
+}
+
+func TestIsLimitReached(t *testing.T) {
+	assert.False(t, IsLimitReached(0, 1))
+	assert.True(t, IsLimitReached(1, 1))
+	assert.True(t, IsLimitReached(2, 1))
+	assert.True(t, IsLimitReached(0, 0))
+	assert.False(t, IsLimitReached(1000, -1))
+}
+
+func TestReserve(t *testing.T) {
+	t.Run("reserves instances until the max instance count is reached", func(t *testing.T) {
+		service := NewService(fake.NewSimpleClientset().CoreV1().ConfigMaps("test-namespace"), existingTeams("team-1"))
+
+		assert.NoError(t, service.Reserve(context.Background(), "team-2", 3))
+		assert.NoError(t, service.Reserve(context.Background(), "team-3", 3))
+		assert.Equal(t, ErrMaxInstancesReached, service.Reserve(context.Background(), "team-4", 3))
+
+		reservations := getReservations(t, service)
+		assert.Len(t, reservations, 2)
+		assert.Contains(t, reservations, "team-2")
+		assert.Contains(t, reservations, "team-3")
+	})
+
+	t.Run("reserving again for the same team doesn't count its own reservation", func(t *testing.T) {
+		service := NewService(fake.NewSimpleClientset().CoreV1().ConfigMaps("test-namespace"), existingTeams())
+
+		assert.NoError(t, service.Reserve(context.Background(), "team-1", 1))
+		assert.NoError(t, service.Reserve(context.Background(), "team-1", 1))
+		assert.Equal(t, ErrMaxInstancesReached, service.Reserve(context.Background(), "team-2", 1))
+	})
+
+	t.Run("fails if the instances can't be listed", func(t *testing.T) {
+		service := NewService(fake.NewSimpleClientset().CoreV1().ConfigMaps("test-namespace"), func(ctx context.Context) ([]string, error) {
+			return nil, errors.New("backend unavailable")
+		})
+
+		assert.Error(t, service.Reserve(context.Background(), "team-1", 1))
+	})
+
+	t.Run("doesn't limit instances with a negative max instance count", func(t *testing.T) {
+		service := NewService(fake.NewSimpleClientset().CoreV1().ConfigMaps("test-namespace"), existingTeams("team-1", "team-2"))
+
+		assert.NoError(t, service.Reserve(context.Background(), "team-3", -1))
+	})
+
+	t.Run("ignores and prunes expired reservations", func(t *testing.T) {
+		expired, _ := json.Marshal(map[string]time.Time{"crashed-team": time.Now().Add(-ReservationTimeout - time.Minute)})
+		service := NewService(fake.NewSimpleClientset(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: "test-namespace"},
+			Data:       map[string]string{configMapDataKey: string(expired)},
+		}).CoreV1().ConfigMaps("test-namespace"), existingTeams())
+
+		assert.NoError(t, service.Reserve(context.Background(), "team-1", 1))
+
+		reservations := getReservations(t, service)
+		assert.Len(t, reservations, 1)
+		assert.Contains(t, reservations, "team-1")
+	})
+}
+
+func TestRelease(t *testing.T) {
+	t.Run("releasing a reservation frees up the capacity", func(t *testing.T) {
+		service := NewService(fake.NewSimpleClientset().CoreV1().ConfigMaps("test-namespace"), existingTeams())
+
+		assert.NoError(t, service.Reserve(context.Background(), "team-1", 1))
+		assert.NoError(t, service.Release(context.Background(), "team-1"))
+		assert.Empty(t, getReservations(t, service))
+		assert.NoError(t, service.Reserve(context.Background(), "team-2", 1))
+	})
+
+	t.Run("releasing without any reservations is a no-op", func(t *testing.T) {
+		service := NewService(fake.NewSimpleClientset().CoreV1().ConfigMaps("test-namespace"), existingTeams())
+
+		assert.NoError(t, service.Release(context.Background(), "team-1"))
+	})
+}
@@ -8,13 +8,13 @@ import (
 	"time"
 
 	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
-	appsv1 "k8s.io/api/apps/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/watch"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamnames"
 )
 
 type TeamScore struct {
-	Name              string              `json:"name"`
+	Name string `json:"name"`
+	// DisplayName chosen by the team. Same as the name if the team didn't set one
+	DisplayName       string              `json:"displayName"`
 	Score             int                 `json:"score"`
 	Position          int                 `json:"position"`
 	Challenges        []ChallengeProgress `json:"challenges"`
@@ -23,7 +23,7 @@ type TeamScore struct {
 }
 
 func (t *TeamScore) EqualsIgnoringLastUpdate(other *TeamScore) bool {
-	if t.Name != other.Name {
+	if t.Name != other.Name || t.DisplayName != other.DisplayName {
 		return false
 	}
 	if t.Score != other.Score {
@@ -172,27 +172,22 @@ func (s *ScoringService) StartingScoringWorker(ctx context.Context) {
 }
 
 func (s *ScoringService) startScoringWatcher(ctx context.Context) {
-	watcher, err := s.bundle.ClientSet.AppsV1().Deployments(s.bundle.RuntimeEnvironment.Namespace).Watch(ctx, metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
-	})
-
+	events, err := s.bundle.Instances.Watch(ctx)
 	if err != nil {
-		s.bundle.Log.Printf("Failed to start the watcher for JuiceShop deployments: %v", err)
+		s.bundle.Log.Printf("Failed to start the watcher for JuiceShop instances: %v", err)
 		panic(err)
 	}
-	defer watcher.Stop()
 
 	for {
 		select {
-		case event, ok := <-watcher.ResultChan():
+		case event, ok := <-events:
 			if !ok {
-				s.bundle.Log.Printf("Watcher for JuiceShop deployments has been closed. Restarting the watcher.")
+				s.bundle.Log.Printf("Watcher for JuiceShop instances has been closed. Restarting the watcher.")
 				return
 			}
 			switch event.Type {
-			case watch.Added, watch.Modified:
-				deployment := event.Object.(*appsv1.Deployment)
-				score := calculateScore(s.bundle, deployment, cachedChallengesMap)
+			case bundle.InstanceAdded, bundle.InstanceModified:
+				score := calculateScore(s.bundle, &event.Instance, cachedChallengesMap)
 
 				if currentTeamScore, ok := s.currentScores[score.Name]; ok {
 					if currentTeamScore.EqualsIgnoringLastUpdate(score) {
@@ -206,11 +201,9 @@ func (s *ScoringService) startScoringWatcher(ctx context.Context) {
 				s.currentScoresSorted = sortTeamsByScoreAndCalculatePositions(s.currentScores)
 				s.lastUpdate = time.Now()
 				s.currentScoresMutex.Unlock()
-			case watch.Deleted:
-				deployment := event.Object.(*appsv1.Deployment)
-				team := deployment.Labels["team"]
+			case bundle.InstanceDeleted:
 				s.currentScoresMutex.Lock()
-				delete(s.currentScores, team)
+				delete(s.currentScores, event.Instance.Team)
 				s.currentScoresSorted = sortTeamsByScoreAndCalculatePositions(s.currentScores)
 				s.lastUpdate = time.Now()
 				s.currentScoresMutex.Unlock()
@@ -225,14 +218,14 @@ func (s *ScoringService) startScoringWatcher(ctx context.Context) {
 
 func (s *ScoringService) CalculateAndCacheScoreBoard(context context.Context) error {
 	// Get all JuiceShop instances
-	juiceShops, err := getDeployments(context, s.bundle)
+	juiceShops, err := s.bundle.Instances.List(context)
 	if err != nil {
 		return err
 	}
 
 	// Calculate the new scores
 	s.currentScoresMutex.Lock()
-	for _, juiceShop := range juiceShops.Items {
+	for _, juiceShop := range juiceShops {
 		score := calculateScore(s.bundle, &juiceShop, s.challengesMap)
 		s.currentScores[score.Name] = score
 	}
@@ -242,25 +235,17 @@ func (s *ScoringService) CalculateAndCacheScoreBoard(context context.Context) er
 	return nil
 }
 
-func getDeployments(context context.Context, bundle *bundle.Bundle) (*appsv1.DeploymentList, error) {
-	deployments, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).List(context, metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
-	})
-	if err != nil {
-		return nil, err
-	}
-	return deployments, nil
-}
-
-func calculateScore(bundle *bundle.Bundle, teamDeployment *appsv1.Deployment, challengesMap map[string](bundle.JuiceShopChallenge)) *TeamScore {
-	solvedChallengesString := teamDeployment.Annotations["multi-juicer.owasp-juice.shop/challenges"]
-	team := teamDeployment.Labels["team"]
+func calculateScore(bundle *bundle.Bundle, teamInstance *bundle.Instance, challengesMap map[string](bundle.JuiceShopChallenge)) *TeamScore {
+	solvedChallengesString := teamInstance.Annotations["multi-juicer.owasp-juice.shop/challenges"]
+	team := teamInstance.Team
+	displayName := teamnames.GetDisplayName(team, teamInstance.Annotations)
 	if solvedChallengesString == "" {
 		return &TeamScore{
 			Name:              team,
+			DisplayName:       displayName,
 			Score:             0,
 			Challenges:        []ChallengeProgress{},
-			InstanceReadiness: teamDeployment.Status.ReadyReplicas > 0,
+			InstanceReadiness: teamInstance.Ready,
 			LastUpdate:        time.Now(),
 		}
 	}
@@ -269,12 +254,13 @@ func calculateScore(bundle *bundle.Bundle, teamDeployment *appsv1.Deployment, ch
 	err := json.Unmarshal([]byte(solvedChallengesString), &solvedChallenges)
 
 	if err != nil {
-		bundle.Log.Printf("JuiceShop deployment '%s' has an invalid 'multi-juicer.owasp-juice.shop/challenges' annotation. Assuming 0 solved challenges for it as the score can't be calculated.", team)
+		bundle.Log.Printf("JuiceShop instance '%s' has an invalid 'multi-juicer.owasp-juice.shop/challenges' annotation. Assuming 0 solved challenges for it as the score can't be calculated.", team)
 		return &TeamScore{
 			Name:              team,
+			DisplayName:       displayName,
 			Score:             0,
 			Challenges:        []ChallengeProgress{},
-			InstanceReadiness: teamDeployment.Status.ReadyReplicas > 0,
+			InstanceReadiness: teamInstance.Ready,
 			LastUpdate:        time.Now(),
 		}
 	}
@@ -284,7 +270,7 @@ func calculateScore(bundle *bundle.Bundle, teamDeployment *appsv1.Deployment, ch
 	for _, challengeSolved := range solvedChallenges {
 		challenge, ok := challengesMap[challengeSolved.Key]
 		if !ok {
-			bundle.Log.Printf("JuiceShop deployment '%s' has a solved challenge '%s' that is not in the challenges map. The used JuiceShop version might be incompatible with this MultiJuicer version.", team, challengeSolved.Key)
+			bundle.Log.Printf("JuiceShop instance '%s' has a solved challenge '%s' that is not in the challenges map. The used JuiceShop version might be incompatible with this MultiJuicer version.", team, challengeSolved.Key)
 			continue
 		}
 		score += challenge.Difficulty * 10
@@ -293,9 +279,10 @@ func calculateScore(bundle *bundle.Bundle, teamDeployment *appsv1.Deployment, ch
 
 	return &TeamScore{
 		Name:              team,
+		DisplayName:       displayName,
 		Score:             score,
 		Challenges:        solvedChallengeNames,
-		InstanceReadiness: teamDeployment.Status.ReadyReplicas > 0,
+		InstanceReadiness: teamInstance.Ready,
 		LastUpdate:        time.Now(),
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
@@ -68,9 +69,10 @@ func TestScoreingService(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, []*TeamScore{
 			{
-				Name:     "foobar",
-				Score:    50,
-				Position: 1,
+				Name:        "foobar",
+				DisplayName: "foobar",
+				Score:       50,
+				Position:    1,
 				Challenges: []ChallengeProgress{
 					{
 						Key:      "scoreBoardChallenge",
@@ -85,6 +87,7 @@ func TestScoreingService(t *testing.T) {
 			},
 			{
 				Name:              "barfoo",
+				DisplayName:       "barfoo",
 				Score:             0,
 				Position:          2,
 				Challenges:        []ChallengeProgress{},
@@ -111,9 +114,10 @@ func TestScoreingService(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, []*TeamScore{
 			{
-				Name:     "foobar",
-				Score:    50,
-				Position: 1,
+				Name:        "foobar",
+				DisplayName: "foobar",
+				Score:       50,
+				Position:    1,
 				Challenges: []ChallengeProgress{
 					{
 						Key:      "scoreBoardChallenge",
@@ -127,9 +131,10 @@ func TestScoreingService(t *testing.T) {
 				InstanceReadiness: true,
 			},
 			{
-				Name:     "barfoo-1",
-				Score:    10,
-				Position: 2,
+				Name:        "barfoo-1",
+				DisplayName: "barfoo-1",
+				Score:       10,
+				Position:    2,
 				Challenges: []ChallengeProgress{
 					{
 						Key:      "scoreBoardChallenge",
@@ -139,9 +144,10 @@ func TestScoreingService(t *testing.T) {
 				InstanceReadiness: true,
 			},
 			{
-				Name:     "barfoo-2",
-				Score:    10,
-				Position: 2,
+				Name:        "barfoo-2",
+				DisplayName: "barfoo-2",
+				Score:       10,
+				Position:    2,
 				Challenges: []ChallengeProgress{
 					{
 						Key:      "scoreBoardChallenge",
@@ -152,6 +158,7 @@ func TestScoreingService(t *testing.T) {
 			},
 			{
 				Name:              "last",
+				DisplayName:       "last",
 				Score:             0,
 				Position:          4, // should be 4 not 3 as there are two teams with the same score on position 2
 				Challenges:        []ChallengeProgress{},
@@ -176,9 +183,10 @@ func TestScoreingService(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, []*TeamScore{
 			{
-				Name:     "foobar",
-				Score:    40,
-				Position: 1,
+				Name:        "foobar",
+				DisplayName: "foobar",
+				Score:       40,
+				Position:    1,
 				Challenges: []ChallengeProgress{
 					{
 						Key:      "nullByteChallenge",
@@ -189,6 +197,7 @@ func TestScoreingService(t *testing.T) {
 			},
 			{
 				Name:              "barfoo",
+				DisplayName:       "barfoo",
 				Score:             0,
 				Position:          2,
 				Challenges:        []ChallengeProgress{},
@@ -213,6 +222,7 @@ func TestScoreingService(t *testing.T) {
 		assert.Equal(t, []*TeamScore{
 			{
 				Name:              "foobar",
+				DisplayName:       "foobar",
 				Score:             0,
 				Position:          1,
 				Challenges:        []ChallengeProgress{},
@@ -244,6 +254,29 @@ func TestScoreingService(t *testing.T) {
 			return scoringService.GetScores()["foobar"].Score == 50
 		}, 1*time.Second, 10*time.Millisecond)
 	})
+
+	t.Run("watcher removes the scores of deleted instances", func(t *testing.T) {
+		instanceManager := testutil.NewFakeInstanceManager(
+			bundle.Instance{Team: "foobar", Annotations: map[string]string{"multi-juicer.owasp-juice.shop/challenges": `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`}},
+			bundle.Instance{Team: "barfoo"},
+		)
+		scoringService := NewScoringService(testutil.NewTestBundleWithFakeInstanceManager(instanceManager))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		err := scoringService.CalculateAndCacheScoreBoard(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, scoringService.GetScores(), 2)
+		go scoringService.StartingScoringWorker(ctx)
+
+		assert.Eventually(t, func() bool {
+			assert.NoError(t, instanceManager.Delete(ctx, "barfoo"))
+			instanceManager.SetReady("foobar", true)
+			scores := scoringService.GetScores()
+			return len(scores) == 1 && scores["foobar"].InstanceReadiness
+		}, 1*time.Second, 10*time.Millisecond)
+	})
 }
 
 func TestScoreingSorting(t *testing.T) {
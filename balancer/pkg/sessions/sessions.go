@@ -0,0 +1,200 @@
+package sessions
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+)
+
+// GenerationAnnotation stores the current session generation of a team on its deployment.
+// It gets incremented whenever all existing sessions of the team should be invalidated, e.g. after a passcode reset
+const GenerationAnnotation = "multi-juicer.owasp-juice.shop/sessionGeneration"
+
+// MembersAnnotation stores the registered members of a team as json list on its deployment
+const MembersAnnotation = "multi-juicer.owasp-juice.shop/members"
+
+// RemovedMembersAnnotation stores the members removed from the team as json list. Their sessions are no longer accepted
+const RemovedMembersAnnotation = "multi-juicer.owasp-juice.shop/removedMembers"
+
+// MaxRemovedMembers caps the number of removed members kept on the deployment, to stay well below the annotation size limit.
+// Removed members are kept until all cookies issued to them have expired, so the cap is only reached by teams removing lots of members within the cookie lifetime
+const MaxRemovedMembers = 100
+
+// Member is a individual participant of a team who registered with a display name when joining
+type Member struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// JoinedAt in unix millis
+	JoinedAt int64 `json:"joinedAt"`
+}
+
+// RemovedMember is a member removed from the team. Its sessions stay revoked until all cookies issued before the removal have expired
+type RemovedMember struct {
+	ID string `json:"id"`
+	// RemovedAt in unix millis
+	RemovedAt int64 `json:"removedAt"`
+}
+
+type teamState struct {
+	generation     int64
+	members        []Member
+	removedMembers map[string]bool
+}
+
+// Store keeps track of the current session generation, the members and the removed members of each team.
+// Cookies carrying an older generation or belonging to a removed member are no longer accepted
+type Store struct {
+	mutex sync.RWMutex
+	teams map[string]*teamState
+}
+
+func NewStore() *Store {
+	return &Store{
+		teams: map[string]*teamState{},
+	}
+}
+
+// GetGeneration returns the current session generation of the team. 0 if the sessions of the team were never invalidated
+func (s *Store) GetGeneration(team string) int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if state, ok := s.teams[team]; ok {
+		return state.generation
+	}
+	return 0
+}
+
+func (s *Store) SetGeneration(team string, generation int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.getOrCreate(team).generation = generation
+}
+
+// GetMembers returns the registered members of the team
+func (s *Store) GetMembers(team string) []Member {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	state, ok := s.teams[team]
+	if !ok {
+		return []Member{}
+	}
+	return append([]Member{}, state.members...)
+}
+
+// Update replaces the known state of the team with the one stored in the annotations of its deployment
+func (s *Store) Update(team string, annotations map[string]string) {
+	state := newTeamState(annotations)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.teams[team] = state
+}
+
+// UpdateIfUnknown stores the state of the team read from the annotations of its deployment, unless the store already knows the team.
+// Used for state loaded outside of the watcher, which must not overwrite newer state the watcher applied in the meantime
+func (s *Store) UpdateIfUnknown(team string, annotations map[string]string) {
+	s.mutex.RLock()
+	_, known := s.teams[team]
+	s.mutex.RUnlock()
+	if known {
+		return
+	}
+	state := newTeamState(annotations)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, known := s.teams[team]; !known {
+		s.teams[team] = state
+	}
+}
+
+// Knows checks if the store has the state of the team, either from the watcher or loaded via UpdateIfUnknown
+func (s *Store) Knows(team string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, ok := s.teams[team]
+	return ok
+}
+
+func (s *Store) Remove(team string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.teams, team)
+}
+
+// IsRevoked checks if a session of the team with the given generation and member has been invalidated since.
+// Sessions with a newer generation than known are accepted, as the store might not have caught up with the latest deployment changes yet.
+// Sessions of unknown teams are considered revoked, as the store might not have synced yet (e.g. right after a restart). Load the state of unknown teams first, see Knows
+func (s *Store) IsRevoked(team string, generation int64, memberID string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	state, ok := s.teams[team]
+	if !ok {
+		return true
+	}
+	return generation < state.generation || (memberID != "" && state.removedMembers[memberID])
+}
+
+func newTeamState(annotations map[string]string) *teamState {
+	removedMembers := map[string]bool{}
+	for _, removedMember := range ParseRemovedMembers(annotations) {
+		removedMembers[removedMember.ID] = true
+	}
+	return &teamState{
+		generation:     ParseGeneration(annotations),
+		members:        ParseMembers(annotations),
+		removedMembers: removedMembers,
+	}
+}
+
+// must be called with the write lock held
+func (s *Store) getOrCreate(team string) *teamState {
+	state, ok := s.teams[team]
+	if !ok {
+		state = &teamState{removedMembers: map[string]bool{}}
+		s.teams[team] = state
+	}
+	return state
+}
+
+// ParseGeneration reads the session generation from the annotations of a team deployment. 0 if not set
+func ParseGeneration(annotations map[string]string) int64 {
+	generation, err := strconv.ParseInt(annotations[GenerationAnnotation], 10, 64)
+	if err != nil || generation < 0 {
+		return 0
+	}
+	return generation
+}
+
+// ParseMembers reads the registered members from the annotations of a team deployment. Empty if not set or malformed
+func ParseMembers(annotations map[string]string) []Member {
+	members := []Member{}
+	if value, ok := annotations[MembersAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &members); err != nil {
+			return []Member{}
+		}
+	}
+	return members
+}
+
+// ParseRemovedMembers reads the removed members from the annotations of a team deployment.
+// Members removed before the removal time got tracked (stored as plain list of ids) have a RemovedAt of 0
+func ParseRemovedMembers(annotations map[string]string) []RemovedMember {
+	removedMembers := []RemovedMember{}
+	value, ok := annotations[RemovedMembersAnnotation]
+	if !ok {
+		return removedMembers
+	}
+	if err := json.Unmarshal([]byte(value), &removedMembers); err == nil {
+		return removedMembers
+	}
+	removedMemberIDs := []string{}
+	if err := json.Unmarshal([]byte(value), &removedMemberIDs); err != nil {
+		return []RemovedMember{}
+	}
+	removedMembers = []RemovedMember{}
+	for _, memberID := range removedMemberIDs {
+		removedMembers = append(removedMembers, RemovedMember{ID: memberID})
+	}
+	return removedMembers
+}
@@ -56,3 +56,10 @@ func Unsign(input, secret string) (string, error) {
 	}
 	return "", errors.New("signature mismatch")
 }
+
+// DeriveKey derives a separate key for the given purpose from the secret, so that values signed for one purpose (e.g. the oidc login state) can't be passed off as values of another purpose (e.g. a team cookie)
+func DeriveKey(secret, purpose string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(purpose))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,253 @@
+package statestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// watchers get the events buffered, so that writes don't have to wait for slow watchers
+const watchEventBufferSize = 100
+
+// FileStore keeps the ConfigMaps in memory and persists them to a json file, so that the state survives restarts of the balancer.
+// Mirrors the behavior of the kubernetes api the services rely on: NotFound, AlreadyExists and Conflict errors, resource versions for optimistic concurrency and watches
+type FileStore struct {
+	file string
+
+	mutex           sync.Mutex
+	configMaps      map[string]*corev1.ConfigMap
+	resourceVersion int64
+	watchers        map[*fileWatcher]bool
+}
+
+type fileWatcher struct {
+	*watch.ProxyWatcher
+	events   chan watch.Event
+	selector fields.Selector
+	labels   labels.Selector
+}
+
+// NewFileStore loads the ConfigMaps persisted in the file. A missing file is not an error, there is just no state yet
+func NewFileStore(file string) (*FileStore, error) {
+	store := &FileStore{
+		file:       file,
+		configMaps: map[string]*corev1.ConfigMap{},
+		watchers:   map[*fileWatcher]bool{},
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var configMaps []*corev1.ConfigMap
+	if err := json.Unmarshal(data, &configMaps); err != nil {
+		return nil, fmt.Errorf("failed to decode state file: %w", err)
+	}
+	for _, configMap := range configMaps {
+		store.configMaps[configMap.Name] = configMap
+		if resourceVersion, err := strconv.ParseInt(configMap.ResourceVersion, 10, 64); err == nil && resourceVersion > store.resourceVersion {
+			store.resourceVersion = resourceVersion
+		}
+	}
+	return store, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.ConfigMap, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	configMap, ok := s.configMaps[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+	}
+	return configMap.DeepCopy(), nil
+}
+
+func (s *FileStore) Create(ctx context.Context, configMap *corev1.ConfigMap, opts metav1.CreateOptions) (*corev1.ConfigMap, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.configMaps[configMap.Name]; ok {
+		return nil, apierrors.NewAlreadyExists(corev1.Resource("configmaps"), configMap.Name)
+	}
+
+	created := configMap.DeepCopy()
+	created.CreationTimestamp = metav1.Now()
+	if err := s.write(created.Name, created); err != nil {
+		return nil, err
+	}
+	s.notify(watch.Added, created)
+	return created.DeepCopy(), nil
+}
+
+// Update replaces the ConfigMap. Returns a Conflict error if the ConfigMap has been changed since the passed version got read
+func (s *FileStore) Update(ctx context.Context, configMap *corev1.ConfigMap, opts metav1.UpdateOptions) (*corev1.ConfigMap, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	existing, ok := s.configMaps[configMap.Name]
+	if !ok {
+		return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), configMap.Name)
+	}
+	if configMap.ResourceVersion != "" && configMap.ResourceVersion != existing.ResourceVersion {
+		return nil, apierrors.NewConflict(corev1.Resource("configmaps"), configMap.Name, errors.New("the config map has been modified"))
+	}
+
+	updated := configMap.DeepCopy()
+	updated.CreationTimestamp = existing.CreationTimestamp
+	if err := s.write(updated.Name, updated); err != nil {
+		return nil, err
+	}
+	s.notify(watch.Modified, updated)
+	return updated.DeepCopy(), nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	existing, ok := s.configMaps[name]
+	if !ok {
+		return apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+	}
+	if err := s.write(name, nil); err != nil {
+		return err
+	}
+	s.notify(watch.Deleted, existing)
+	return nil
+}
+
+// Watch sends the changes of the ConfigMaps matching the field and label selectors of the options.
+// Like the kubernetes api, the currently existing ConfigMaps are sent as added first
+func (s *FileStore) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	fieldSelector, err := fields.ParseSelector(opts.FieldSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid field selector: %v", err))
+	}
+	labelSelector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid label selector: %v", err))
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// the existing ConfigMaps are sent before the watcher is returned, so they have to fit into the buffer
+	events := make(chan watch.Event, len(s.configMaps)+watchEventBufferSize)
+	watcher := &fileWatcher{
+		ProxyWatcher: watch.NewProxyWatcher(events),
+		events:       events,
+		selector:     fieldSelector,
+		labels:       labelSelector,
+	}
+	s.watchers[watcher] = true
+	for _, name := range s.sortedNames() {
+		s.send(watcher, watch.Added, s.configMaps[name])
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			watcher.Stop()
+		case <-watcher.StopChan():
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		delete(s.watchers, watcher)
+		close(events)
+	}()
+	return watcher, nil
+}
+
+// write sets (or deletes if nil) the ConfigMap and persists all ConfigMaps. The change is rolled back if it can't be persisted.
+// Has to be called while holding the mutex
+func (s *FileStore) write(name string, configMap *corev1.ConfigMap) error {
+	previous, existed := s.configMaps[name]
+	if configMap == nil {
+		delete(s.configMaps, name)
+	} else {
+		s.resourceVersion++
+		configMap.ResourceVersion = strconv.FormatInt(s.resourceVersion, 10)
+		s.configMaps[name] = configMap
+	}
+
+	if err := s.persist(); err != nil {
+		if existed {
+			s.configMaps[name] = previous
+		} else {
+			delete(s.configMaps, name)
+		}
+		return apierrors.NewInternalError(err)
+	}
+	return nil
+}
+
+// persist writes all ConfigMaps to the file. The file gets replaced atomically so that a crash can't leave a partially written file behind.
+// Has to be called while holding the mutex
+func (s *FileStore) persist() error {
+	configMaps := make([]*corev1.ConfigMap, 0, len(s.configMaps))
+	for _, name := range s.sortedNames() {
+		configMaps = append(configMaps, s.configMaps[name])
+	}
+	data, err := json.MarshalIndent(configMaps, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tempFile.Name(), s.file); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// Has to be called while holding the mutex
+func (s *FileStore) sortedNames() []string {
+	names := make([]string, 0, len(s.configMaps))
+	for name := range s.configMaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has to be called while holding the mutex
+func (s *FileStore) notify(eventType watch.EventType, configMap *corev1.ConfigMap) {
+	for watcher := range s.watchers {
+		s.send(watcher, eventType, configMap)
+	}
+}
+
+// send delivers the event if the ConfigMap matches the selectors of the watcher. Blocks while the buffer of the watcher is full, unless the watcher gets stopped.
+// Has to be called while holding the mutex
+func (s *FileStore) send(watcher *fileWatcher, eventType watch.EventType, configMap *corev1.ConfigMap) {
+	objectFields := fields.Set{"metadata.name": configMap.Name, "metadata.namespace": configMap.Namespace}
+	if !watcher.selector.Matches(objectFields) || !watcher.labels.Matches(labels.Set(configMap.Labels)) {
+		return
+	}
+	select {
+	case watcher.events <- watch.Event{Type: eventType, Object: configMap.DeepCopy()}:
+	case <-watcher.StopChan():
+	}
+}
@@ -0,0 +1,135 @@
+package statestore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func newConfigMap(name string, value string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Data:       map[string]string{"value": value},
+	}
+}
+
+func nextEvent(t *testing.T, watcher watch.Interface) watch.Event {
+	t.Helper()
+	select {
+	case event := <-watcher.ResultChan():
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("expected a watch event")
+		return watch.Event{}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("persists the config maps across restarts", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "state.json")
+		store, err := NewFileStore(file)
+		assert.NoError(t, err)
+
+		_, err = store.Create(ctx, newConfigMap("balancer-join-codes", "a"), metav1.CreateOptions{})
+		assert.NoError(t, err)
+		_, err = store.Create(ctx, newConfigMap("balancer-waitlist", "b"), metav1.CreateOptions{})
+		assert.NoError(t, err)
+		assert.NoError(t, store.Delete(ctx, "balancer-waitlist", metav1.DeleteOptions{}))
+
+		restarted, err := NewFileStore(file)
+		assert.NoError(t, err)
+		configMap, err := restarted.Get(ctx, "balancer-join-codes", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "a", configMap.Data["value"])
+		_, err = restarted.Get(ctx, "balancer-waitlist", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+
+		// resource versions continue after the restart, so that versions read before can't match again
+		updated, err := restarted.Update(ctx, configMap, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		assert.NotEqual(t, configMap.ResourceVersion, updated.ResourceVersion)
+	})
+
+	t.Run("returns the errors of the kubernetes api", func(t *testing.T) {
+		store, err := NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+		assert.NoError(t, err)
+
+		_, err = store.Get(ctx, "missing", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+		_, err = store.Update(ctx, newConfigMap("missing", "a"), metav1.UpdateOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+		assert.True(t, apierrors.IsNotFound(store.Delete(ctx, "missing", metav1.DeleteOptions{})))
+
+		_, err = store.Create(ctx, newConfigMap("existing", "a"), metav1.CreateOptions{})
+		assert.NoError(t, err)
+		_, err = store.Create(ctx, newConfigMap("existing", "b"), metav1.CreateOptions{})
+		assert.True(t, apierrors.IsAlreadyExists(err))
+	})
+
+	t.Run("rejects updates of outdated versions", func(t *testing.T) {
+		store, err := NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+		assert.NoError(t, err)
+		_, err = store.Create(ctx, newConfigMap("reservations", "a"), metav1.CreateOptions{})
+		assert.NoError(t, err)
+
+		first, _ := store.Get(ctx, "reservations", metav1.GetOptions{})
+		second, _ := store.Get(ctx, "reservations", metav1.GetOptions{})
+		first.Data["value"] = "b"
+		_, err = store.Update(ctx, first, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		second.Data["value"] = "c"
+		_, err = store.Update(ctx, second, metav1.UpdateOptions{})
+		assert.True(t, apierrors.IsConflict(err))
+
+		configMap, _ := store.Get(ctx, "reservations", metav1.GetOptions{})
+		assert.Equal(t, "b", configMap.Data["value"])
+	})
+
+	t.Run("watches the changes of the selected config map", func(t *testing.T) {
+		store, err := NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+		assert.NoError(t, err)
+		_, err = store.Create(ctx, newConfigMap("balancer-announcements", "a"), metav1.CreateOptions{})
+		assert.NoError(t, err)
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		watcher, err := store.Watch(watchCtx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", "balancer-announcements").String(),
+		})
+		assert.NoError(t, err)
+
+		event := nextEvent(t, watcher)
+		assert.Equal(t, watch.Added, event.Type)
+		assert.Equal(t, "a", event.Object.(*corev1.ConfigMap).Data["value"])
+
+		_, err = store.Create(ctx, newConfigMap("balancer-waitlist", "b"), metav1.CreateOptions{})
+		assert.NoError(t, err)
+		configMap, _ := store.Get(ctx, "balancer-announcements", metav1.GetOptions{})
+		configMap.Data["value"] = "c"
+		_, err = store.Update(ctx, configMap, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		assert.NoError(t, store.Delete(ctx, "balancer-announcements", metav1.DeleteOptions{}))
+
+		event = nextEvent(t, watcher)
+		assert.Equal(t, watch.Modified, event.Type)
+		assert.Equal(t, "c", event.Object.(*corev1.ConfigMap).Data["value"])
+		assert.Equal(t, watch.Deleted, nextEvent(t, watcher).Type)
+
+		cancel()
+		select {
+		case _, ok := <-watcher.ResultChan():
+			assert.False(t, ok, "expected the watcher to be closed")
+		case <-time.After(time.Second):
+			t.Fatal("expected the watcher to be closed once the context got canceled")
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package statestore
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Store persists the state of the balancer which isn't part of a team instance (join codes, waitlist, announcements, instance reservations and progress backups) as ConfigMaps.
+// It is the subset of the kubernetes ConfigMap api used by the balancer, so that the ConfigMaps of a namespace can be used directly. The local backend uses a FileStore instead
+type Store interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.ConfigMap, error)
+	Create(ctx context.Context, configMap *corev1.ConfigMap, opts metav1.CreateOptions) (*corev1.ConfigMap, error)
+	Update(ctx context.Context, configMap *corev1.ConfigMap, opts metav1.UpdateOptions) (*corev1.ConfigMap, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// NewKubernetesStore stores the state in ConfigMaps of the namespace, so that it is shared between all balancer replicas
+func NewKubernetesStore(clientSet kubernetes.Interface, namespace string) Store {
+	return clientSet.CoreV1().ConfigMaps(namespace)
+}
@@ -0,0 +1,34 @@
+package teamcookie
+
+import (
+	"fmt"
+	"net/http"
+
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+)
+
+// Admin is the identity of a logged in admin
+type Admin struct {
+	// Name of the admin account. "admin" for the default admin account
+	Name string
+	Role b.AdminRole
+}
+
+// GetAdminFromRequest returns the admin identity of the request with the current role of the account.
+// Returns an error if the request doesn't belong to a admin or the admin account doesn't exist anymore
+func GetAdminFromRequest(bundle *b.Bundle, req *http.Request) (*Admin, error) {
+	team, err := GetTeamFromRequest(bundle, req)
+	if err != nil {
+		return nil, err
+	}
+	if !b.IsAdminIdentity(team) {
+		return nil, fmt.Errorf("team '%s' is not a admin", team)
+	}
+
+	accountName := b.GetAdminAccountName(team)
+	role, ok := bundle.Config.AdminConfig.GetAdminRole(accountName)
+	if !ok {
+		return nil, fmt.Errorf("admin account '%s' does not exist", accountName)
+	}
+	return &Admin{Name: accountName, Role: role}, nil
+}
@@ -0,0 +1,153 @@
+package teamcookie
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+)
+
+const MaxMemberNameLength = 32
+const MaxMembersPerTeam = 50
+
+var ErrMemberNotFound = errors.New("member not found")
+var ErrTooManyMembers = errors.New("team has reached the maximum number of members")
+
+// NormalizeMemberName trims the display name of a member and checks that it is between 1 and MaxMemberNameLength printable characters long
+func NormalizeMemberName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	length := utf8.RuneCountInString(name)
+	if length == 0 || length > MaxMemberNameLength {
+		return "", false
+	}
+	for _, char := range name {
+		if !unicode.IsPrint(char) {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// NewMember creates a member with a random id. The name must already be normalized
+func NewMember(name string) (sessions.Member, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return sessions.Member{}, err
+	}
+	return sessions.Member{
+		ID:       hex.EncodeToString(idBytes),
+		Name:     name,
+		JoinedAt: time.Now().UnixMilli(),
+	}, nil
+}
+
+// EncodeMembers encodes the members to be stored in the members annotation of the team deployment
+func EncodeMembers(members []sessions.Member) (string, error) {
+	membersBytes, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	return string(membersBytes), nil
+}
+
+// AddMember registers a new member with the given (normalized) name for the team. Returns the new member and the current session generation of the team
+func AddMember(ctx context.Context, bundle *bundle.Bundle, team string, name string) (*sessions.Member, int64, error) {
+	member, err := NewMember(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	var generation int64
+	err = updateTeamAnnotations(ctx, bundle, team, func(annotations map[string]string) error {
+		members := sessions.ParseMembers(annotations)
+		if len(members) >= MaxMembersPerTeam {
+			return ErrTooManyMembers
+		}
+		encodedMembers, err := EncodeMembers(append(members, member))
+		if err != nil {
+			return err
+		}
+		annotations[sessions.MembersAnnotation] = encodedMembers
+		generation = sessions.ParseGeneration(annotations)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &member, generation, nil
+}
+
+// RemoveMember removes the member from the team and invalidates its sessions. Returns the session generation of the team after the removal.
+// Removing a member only ends its current sessions, it can still join again with the passcode of the team. To lock it out, updateAnnotations can reset the passcode in the same update.
+// If updateAnnotations is set, all sessions of the team get revoked, like on a passcode reset
+func RemoveMember(ctx context.Context, bundle *bundle.Bundle, team string, memberID string, updateAnnotations func(annotations map[string]string)) (int64, error) {
+	now := time.Now()
+	var generation int64
+	err := updateTeamAnnotations(ctx, bundle, team, func(annotations map[string]string) error {
+		members := sessions.ParseMembers(annotations)
+		remainingMembers := []sessions.Member{}
+		for _, member := range members {
+			if member.ID != memberID {
+				remainingMembers = append(remainingMembers, member)
+			}
+		}
+		if len(remainingMembers) == len(members) {
+			return ErrMemberNotFound
+		}
+
+		generation = sessions.ParseGeneration(annotations)
+		removedMembers := append(
+			pruneRemovedMembers(sessions.ParseRemovedMembers(annotations), now, bundle.Config.CookieConfig.GetMaxAge()),
+			sessions.RemovedMember{ID: memberID, RemovedAt: now.UnixMilli()},
+		)
+		if updateAnnotations != nil || len(removedMembers) > sessions.MaxRemovedMembers {
+			// a new session generation revokes the sessions of all removed members as well, so they don't have to be tracked anymore.
+			// Dropping the oldest removed members instead would make their cookies valid again
+			generation++
+			annotations[sessions.GenerationAnnotation] = strconv.FormatInt(generation, 10)
+			removedMembers = []sessions.RemovedMember{}
+			if updateAnnotations != nil {
+				updateAnnotations(annotations)
+			}
+		}
+
+		encodedRemovedMembers, err := json.Marshal(removedMembers)
+		if err != nil {
+			return err
+		}
+		encodedMembers, err := EncodeMembers(remainingMembers)
+		if err != nil {
+			return err
+		}
+		annotations[sessions.MembersAnnotation] = encodedMembers
+		annotations[sessions.RemovedMembersAnnotation] = string(encodedRemovedMembers)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return generation, nil
+}
+
+// pruneRemovedMembers drops the removed members whose cookies have all expired, as they can't sign in anymore anyway.
+// Members removed before the removal time got tracked are kept for another cookie lifetime
+func pruneRemovedMembers(removedMembers []sessions.RemovedMember, now time.Time, cookieMaxAge time.Duration) []sessions.RemovedMember {
+	remainingRemovedMembers := []sessions.RemovedMember{}
+	for _, removedMember := range removedMembers {
+		if removedMember.RemovedAt == 0 {
+			removedMember.RemovedAt = now.UnixMilli()
+		}
+		if now.Sub(time.UnixMilli(removedMember.RemovedAt)) < cookieMaxAge {
+			remainingRemovedMembers = append(remainingRemovedMembers, removedMember)
+		}
+	}
+	return remainingRemovedMembers
+}
@@ -0,0 +1,161 @@
+package teamcookie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/signutil"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamnames"
+)
+
+// Session is the content of the signed team cookie
+type Session struct {
+	Team      string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// Generation of the team sessions at the time the cookie was issued. See sessions.Store
+	Generation int64
+	// MemberID identifies the registered team member the session belongs to. Empty for anonymous sessions
+	MemberID string
+	// KeyID identifies the key the cookie was signed with
+	KeyID string
+}
+
+const cookieFieldSeparator = "|"
+
+// SignSession encodes the session as readable cookie value in the format "<team>|<issuedAt>|<expiresAt>|<generation>|<memberId>|<keyId>.<signature>"
+func SignSession(session Session, signingKey string) (string, error) {
+	for _, field := range []string{session.Team, session.MemberID, session.KeyID} {
+		if strings.Contains(field, cookieFieldSeparator) {
+			return "", fmt.Errorf("team, member id and key id must not contain '%s'", cookieFieldSeparator)
+		}
+	}
+	value := strings.Join([]string{
+		session.Team,
+		strconv.FormatInt(session.IssuedAt.UnixMilli(), 10),
+		strconv.FormatInt(session.ExpiresAt.UnixMilli(), 10),
+		strconv.FormatInt(session.Generation, 10),
+		session.MemberID,
+		session.KeyID,
+	}, cookieFieldSeparator)
+	return signutil.Sign(value, signingKey)
+}
+
+// CreateCookieValue creates a new session for the team (member) with the current session generation of the team and signs it with the current signing key
+func CreateCookieValue(bundle *b.Bundle, team string, generation int64, memberID string) (string, error) {
+	now := time.Now()
+	return SignSession(Session{
+		Team:       team,
+		IssuedAt:   now,
+		ExpiresAt:  now.Add(bundle.Config.CookieConfig.GetMaxAge()),
+		Generation: generation,
+		MemberID:   memberID,
+		KeyID:      bundle.Config.CookieConfig.GetSigningKeyID(),
+	}, bundle.Config.CookieConfig.SigningKey)
+}
+
+// ParseCookieValue verifies the signature of the cookie value with the key referenced in it and checks that the session is neither expired nor revoked
+func ParseCookieValue(ctx context.Context, bundle *b.Bundle, cookieValue string) (*Session, error) {
+	lastDotIndex := strings.LastIndex(cookieValue, ".")
+	if lastDotIndex == -1 {
+		return nil, errors.New("invalid cookie format")
+	}
+	if !strings.Contains(cookieValue[:lastDotIndex], cookieFieldSeparator) {
+		return parseLegacyCookieValue(ctx, bundle, cookieValue)
+	}
+	// the fields are only trusted after the signature has been verified, the key id is just used to select the key to verify it with
+	fields := strings.Split(cookieValue[:lastDotIndex], cookieFieldSeparator)
+	if len(fields) != 6 {
+		return nil, errors.New("invalid cookie format")
+	}
+	key, ok := bundle.Config.CookieConfig.GetVerificationKey(fields[5])
+	if !ok {
+		return nil, fmt.Errorf("cookie is signed by unknown key '%s'", fields[5])
+	}
+	if _, err := signutil.Unsign(cookieValue, key); err != nil {
+		return nil, fmt.Errorf("cookie is signed by an invalid key")
+	}
+
+	issuedAt, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid cookie issued at time")
+	}
+	expiresAt, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid cookie expiry time")
+	}
+	generation, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid cookie session generation")
+	}
+	session := &Session{
+		Team:       fields[0],
+		IssuedAt:   time.UnixMilli(issuedAt),
+		ExpiresAt:  time.UnixMilli(expiresAt),
+		Generation: generation,
+		MemberID:   fields[4],
+		KeyID:      fields[5],
+	}
+
+	if !time.Now().Before(session.ExpiresAt) {
+		return nil, errors.New("cookie has expired")
+	}
+	if err := checkRevoked(ctx, bundle, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// parseLegacyCookieValue accepts cookies in the format before the expiring sessions ("<team>.<signature>") until the end of the transition window.
+// They don't contain a key id, so only cookies signed with the current key are accepted. They are treated like sessions of the first generation without member
+func parseLegacyCookieValue(ctx context.Context, bundle *b.Bundle, cookieValue string) (*Session, error) {
+	cookieConfig := bundle.Config.CookieConfig
+	if !time.Now().Before(cookieConfig.LegacyCookiesAcceptedUntil) {
+		return nil, errors.New("cookie in the legacy format is no longer accepted")
+	}
+
+	team, err := signutil.Unsign(cookieValue, cookieConfig.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("cookie is signed by an invalid key")
+	}
+	// legacy cookies only ever contained team names, anything else has been signed for another purpose
+	if !teamnames.MatchesPattern(team) {
+		return nil, errors.New("invalid cookie format")
+	}
+	session := &Session{
+		Team:      team,
+		ExpiresAt: cookieConfig.LegacyCookiesAcceptedUntil,
+		KeyID:     cookieConfig.GetSigningKeyID(),
+	}
+	if err := checkRevoked(ctx, bundle, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// checkRevoked checks the session against the session store. Teams the store doesn't know yet (e.g. as its watcher hasn't synced after a restart) are loaded from their instance first,
+// so that revoked sessions aren't accepted again. Sessions of teams without instance are accepted, the routes handle the missing instance.
+// Admin sessions have no instance holding their session state
+func checkRevoked(ctx context.Context, bundle *b.Bundle, session *Session) error {
+	if b.IsAdminIdentity(session.Team) {
+		return nil
+	}
+	if !bundle.TeamSessions.Knows(session.Team) {
+		instance, err := bundle.Instances.Get(ctx, session.Team)
+		if err == b.ErrInstanceNotFound {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to check if the session has been revoked: %w", err)
+		}
+		bundle.TeamSessions.UpdateIfUnknown(session.Team, instance.Annotations)
+	}
+	if bundle.TeamSessions.IsRevoked(session.Team, session.Generation, session.MemberID) {
+		return errors.New("session has been revoked")
+	}
+	return nil
+}
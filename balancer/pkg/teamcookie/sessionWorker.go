@@ -0,0 +1,81 @@
+package teamcookie
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+)
+
+// StartSessionWorker keeps the team session store of the bundle in sync with the team instances, so that revocations done via other balancer replicas are picked up as well
+func StartSessionWorker(ctx context.Context, bundle *b.Bundle) {
+	for {
+		select {
+		case <-ctx.Done():
+			bundle.Log.Printf("MultiJuicer context canceled. Exiting the session watcher.")
+			return
+		default:
+			startSessionWatcher(ctx, bundle)
+		}
+	}
+}
+
+func startSessionWatcher(ctx context.Context, bundle *b.Bundle) {
+	events, err := bundle.Instances.Watch(ctx)
+	if err != nil {
+		bundle.Log.Printf("Failed to start the watcher for the team sessions: %v", err)
+		time.Sleep(5 * time.Second)
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				bundle.Log.Printf("Watcher for the team sessions has been closed. Restarting the watcher.")
+				return
+			}
+			switch event.Type {
+			case b.InstanceAdded, b.InstanceModified:
+				bundle.TeamSessions.Update(event.Instance.Team, event.Instance.Annotations)
+			case b.InstanceDeleted:
+				bundle.TeamSessions.Remove(event.Instance.Team)
+			default:
+			}
+		case <-ctx.Done():
+			bundle.Log.Printf("MultiJuicer context canceled. Exiting the session watcher.")
+			return
+		}
+	}
+}
+
+// RevokeSessions increments the session generation of the team, which invalidates all cookies issued to the team so far.
+// updateAnnotations can be used to apply further changes to the team in the same update, e.g. a new passcode. Returns the new session generation
+func RevokeSessions(ctx context.Context, bundle *b.Bundle, team string, updateAnnotations func(annotations map[string]string)) (int64, error) {
+	var generation int64
+	err := updateTeamAnnotations(ctx, bundle, team, func(annotations map[string]string) error {
+		generation = sessions.ParseGeneration(annotations) + 1
+		annotations[sessions.GenerationAnnotation] = strconv.FormatInt(generation, 10)
+		if updateAnnotations != nil {
+			updateAnnotations(annotations)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return generation, nil
+}
+
+// updateTeamAnnotations applies the update to the latest annotations of the team instance, retrying on conflicting updates.
+// The session store is updated directly so that changes apply immediately and not only once the watcher picked them up
+func updateTeamAnnotations(ctx context.Context, bundle *b.Bundle, team string, update func(annotations map[string]string) error) error {
+	instance, err := bundle.Instances.UpdateAnnotations(ctx, team, update)
+	if err != nil {
+		return err
+	}
+	bundle.TeamSessions.Update(team, instance.Annotations)
+	return nil
+}
@@ -0,0 +1,198 @@
+package teamcookie
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/signutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// instancesStub only implements Get, which is used to load the sessions of teams unknown to the session store
+type instancesStub struct {
+	bundle.InstanceManager
+	annotations map[string]map[string]string
+	err         error
+}
+
+func (s *instancesStub) Get(ctx context.Context, team string) (*bundle.Instance, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	annotations, ok := s.annotations[team]
+	if !ok {
+		return nil, bundle.ErrInstanceNotFound
+	}
+	return &bundle.Instance{Team: team, Annotations: annotations}, nil
+}
+
+func newSessionTestBundle() *bundle.Bundle {
+	return &bundle.Bundle{
+		Instances: &instancesStub{annotations: map[string]map[string]string{}},
+		Config: &bundle.Config{
+			CookieConfig: bundle.CookieConfig{
+				SigningKey:   "current-key",
+				SigningKeyID: "2",
+				VerificationKeys: map[string]string{
+					"1": "previous-key",
+				},
+			},
+		},
+		TeamSessions: sessions.NewStore(),
+	}
+}
+
+func TestSessionCookies(t *testing.T) {
+	t.Run("created cookies can be parsed again", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+
+		cookieValue, err := CreateCookieValue(bundle, "foobar", 0, "")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(cookieValue, "foobar|"))
+
+		session, err := ParseCookieValue(context.Background(), bundle, cookieValue)
+		assert.NoError(t, err)
+		assert.Equal(t, "foobar", session.Team)
+		assert.Equal(t, "2", session.KeyID)
+		assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), session.ExpiresAt, time.Minute)
+	})
+
+	t.Run("accepts cookies signed by previous keys", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+		cookieValue, _ := SignSession(Session{Team: "foobar", IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour), KeyID: "1"}, "previous-key")
+
+		session, err := ParseCookieValue(context.Background(), bundle, cookieValue)
+		assert.NoError(t, err)
+		assert.Equal(t, "foobar", session.Team)
+	})
+
+	t.Run("rejects cookies signed by unknown or mismatching keys", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+
+		unknownKeyCookie, _ := SignSession(Session{Team: "foobar", IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour), KeyID: "0"}, "removed-key")
+		_, err := ParseCookieValue(context.Background(), bundle, unknownKeyCookie)
+		assert.Error(t, err)
+
+		mismatchingKeyCookie, _ := SignSession(Session{Team: "foobar", IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour), KeyID: "2"}, "previous-key")
+		_, err = ParseCookieValue(context.Background(), bundle, mismatchingKeyCookie)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects tampered cookies", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+		cookieValue, _ := SignSession(Session{Team: "foobar", IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour), KeyID: "2"}, "current-key")
+
+		_, err := ParseCookieValue(context.Background(), bundle, strings.Replace(cookieValue, "foobar", "barfoo", 1))
+		assert.Error(t, err)
+	})
+
+	t.Run("accepts cookies in the old format without expiry during the transition window", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+		bundle.Config.CookieConfig.LegacyCookiesAcceptedUntil = time.Now().Add(time.Hour)
+		legacyCookie, _ := signutil.Sign("foobar", "current-key")
+		rotatedLegacyCookie, _ := signutil.Sign("barfoo", "previous-key")
+
+		session, err := ParseCookieValue(context.Background(), bundle, legacyCookie)
+		assert.NoError(t, err)
+		assert.Equal(t, "foobar", session.Team)
+		assert.Equal(t, int64(0), session.Generation)
+		assert.Equal(t, bundle.Config.CookieConfig.LegacyCookiesAcceptedUntil, session.ExpiresAt)
+		assert.Equal(t, "2", session.KeyID)
+
+		// legacy cookies don't reference their key, so they are only accepted with the current key
+		_, err = ParseCookieValue(context.Background(), bundle, rotatedLegacyCookie)
+		assert.Error(t, err)
+		for _, value := range []string{"state:nonce:verifier", "Foobar", "-foobar", "admin:someone"} {
+			invalidTeamCookie, _ := signutil.Sign(value, "current-key")
+			_, err = ParseCookieValue(context.Background(), bundle, invalidTeamCookie)
+			assert.Error(t, err, value)
+		}
+
+		_, err = ParseCookieValue(context.Background(), bundle, strings.Replace(legacyCookie, "foobar", "barfoo", 1))
+		assert.Error(t, err)
+
+		bundle.TeamSessions.SetGeneration("foobar", 1)
+		_, err = ParseCookieValue(context.Background(), bundle, legacyCookie)
+		assert.EqualError(t, err, "session has been revoked")
+	})
+
+	t.Run("rejects cookies in the old format without expiry after the transition window", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+		bundle.Config.CookieConfig.LegacyCookiesAcceptedUntil = time.Now().Add(-time.Hour)
+		legacyCookie, _ := signutil.Sign("foobar", "current-key")
+
+		_, err := ParseCookieValue(context.Background(), bundle, legacyCookie)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects expired cookies", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+		cookieValue, _ := SignSession(Session{Team: "foobar", IssuedAt: time.Now().Add(-2 * time.Hour), ExpiresAt: time.Now().Add(-time.Hour), KeyID: "2"}, "current-key")
+
+		_, err := ParseCookieValue(context.Background(), bundle, cookieValue)
+		assert.EqualError(t, err, "cookie has expired")
+	})
+
+	t.Run("rejects cookies of previous session generations of the team", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+		oldCookie, _ := CreateCookieValue(bundle, "foobar", 1, "")
+		otherTeamCookie, _ := CreateCookieValue(bundle, "other-team", 1, "")
+
+		bundle.TeamSessions.SetGeneration("foobar", 2)
+
+		_, err := ParseCookieValue(context.Background(), bundle, oldCookie)
+		assert.EqualError(t, err, "session has been revoked")
+		_, err = ParseCookieValue(context.Background(), bundle, otherTeamCookie)
+		assert.NoError(t, err)
+
+		currentCookie, _ := CreateCookieValue(bundle, "foobar", 2, "")
+		_, err = ParseCookieValue(context.Background(), bundle, currentCookie)
+		assert.NoError(t, err)
+	})
+
+	t.Run("loads the sessions of teams unknown to the store from their instance, e.g. before the store synced after a restart", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+		bundle.Instances.(*instancesStub).annotations["foobar"] = map[string]string{
+			sessions.GenerationAnnotation:     "2",
+			sessions.RemovedMembersAnnotation: `[{"id":"removed-member","removedAt":0}]`,
+		}
+		oldCookie, _ := CreateCookieValue(bundle, "foobar", 1, "")
+		removedMemberCookie, _ := CreateCookieValue(bundle, "foobar", 2, "removed-member")
+		currentCookie, _ := CreateCookieValue(bundle, "foobar", 2, "")
+		missingTeamCookie, _ := CreateCookieValue(bundle, "missing-team", 0, "")
+
+		_, err := ParseCookieValue(context.Background(), bundle, oldCookie)
+		assert.EqualError(t, err, "session has been revoked")
+		_, err = ParseCookieValue(context.Background(), bundle, removedMemberCookie)
+		assert.EqualError(t, err, "session has been revoked")
+		_, err = ParseCookieValue(context.Background(), bundle, currentCookie)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), bundle.TeamSessions.GetGeneration("foobar"))
+		// nothing could have been revoked for teams without instance, the routes handle the missing instance
+		_, err = ParseCookieValue(context.Background(), bundle, missingTeamCookie)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects cookies of unknown teams if their instance can't be loaded", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+		bundle.Instances.(*instancesStub).err = errors.New("api unavailable")
+		cookieValue, _ := CreateCookieValue(bundle, "foobar", 0, "")
+
+		_, err := ParseCookieValue(context.Background(), bundle, cookieValue)
+		assert.Error(t, err)
+	})
+
+	t.Run("accepts cookies of newer session generations than known, as the store might not be up to date yet", func(t *testing.T) {
+		bundle := newSessionTestBundle()
+		cookieValue, _ := CreateCookieValue(bundle, "foobar", 3, "")
+
+		session, err := ParseCookieValue(context.Background(), bundle, cookieValue)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), session.Generation)
+	})
+}
@@ -5,18 +5,22 @@ import (
 	"net/http"
 
 	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
-	"github.com/juice-shop/multi-juicer/balancer/pkg/signutil"
 )
 
 func GetTeamFromRequest(bundle *bundle.Bundle, req *http.Request) (string, error) {
-	balancerCookie, err := req.Cookie(bundle.Config.CookieConfig.Name)
+	session, err := GetSessionFromRequest(bundle, req)
 	if err != nil {
-		return "", fmt.Errorf("request is missing team cookie")
+		return "", err
 	}
-	cookieTeamname, err := signutil.Unsign(balancerCookie.Value, bundle.Config.CookieConfig.SigningKey)
+
+	return session.Team, nil
+}
+
+// GetSessionFromRequest returns the verified session of the team cookie including the member of the team
+func GetSessionFromRequest(bundle *bundle.Bundle, req *http.Request) (*Session, error) {
+	balancerCookie, err := req.Cookie(bundle.Config.CookieConfig.Name)
 	if err != nil {
-		return "", fmt.Errorf("cookie is signed by an invalid key")
+		return nil, fmt.Errorf("request is missing team cookie")
 	}
-
-	return cookieTeamname, nil
+	return ParseCookieValue(req.Context(), bundle, balancerCookie.Value)
 }
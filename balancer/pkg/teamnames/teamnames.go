@@ -0,0 +1,154 @@
+package teamnames
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DisplayNameAnnotation stores the free-form display name of a team on its deployment. The team name itself stays the dns safe resource name
+const DisplayNameAnnotation = "multi-juicer.owasp-juice.shop/displayName"
+
+// PatternString matches dns safe team names. Also used to match team names in the paths of the frontend routes
+const PatternString = "[a-z0-9]([-a-z0-9])+[a-z0-9]"
+
+var pattern = regexp.MustCompile("^" + PatternString + "$")
+
+// MatchesPattern checks if the name is a dns safe team name, without checking the length or the policy
+func MatchesPattern(name string) bool {
+	return pattern.MatchString(name)
+}
+
+// MaxDisplayNameLength is the max number of characters (not bytes) of a display name
+const MaxDisplayNameLength = 32
+
+var (
+	ErrReservedTeamName   = errors.New("team name is reserved")
+	ErrBlockedName        = errors.New("name contains a blocked word")
+	ErrInvalidDisplayName = fmt.Errorf("display name must be between 1 and %d characters long and must not contain control characters", MaxDisplayNameLength)
+)
+
+type Config struct {
+	// ReservedNames can't be used as team names, e.g. names of the organizers
+	ReservedNames []string `json:"reservedNames"`
+	// BlockedWords must not be contained in team names or display names (case insensitive)
+	BlockedWords []string `json:"blockedWords"`
+	// BlocklistFile optionally points to a file with additional blocked words, one per line. Lines starting with '#' are ignored
+	BlocklistFile string `json:"blocklistFile"`
+}
+
+// Policy decides which team names and display names are allowed for new teams.
+// Existing teams aren't affected when the policy changes, as the policy is only checked when teams get created
+type Policy struct {
+	reservedNames map[string]bool
+	blockedWords  []string
+}
+
+// AdminTeamName is always reserved, as joining it is used to log in as admin. Admins are shown under this name as well
+const AdminTeamName = "admin"
+
+var alwaysReservedNames = []string{AdminTeamName}
+
+func NewPolicy(config Config) *Policy {
+	policy := &Policy{reservedNames: map[string]bool{}}
+	for _, name := range append(alwaysReservedNames, config.ReservedNames...) {
+		policy.reservedNames[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for _, word := range config.BlockedWords {
+		if normalized := normalizeForComparison(word); normalized != "" {
+			policy.blockedWords = append(policy.blockedWords, normalized)
+		}
+	}
+	return policy
+}
+
+// ReadBlocklistFile reads the blocked words from the file, one word per line
+func ReadBlocklistFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open team name blocklist file: %w", err)
+	}
+	defer file.Close()
+
+	words := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read team name blocklist file: %w", err)
+	}
+	return words, nil
+}
+
+// CheckTeamName returns an error if the (syntactically valid) team name is reserved or contains a blocked word
+func (p *Policy) CheckTeamName(team string) error {
+	if p.reservedNames[team] {
+		return ErrReservedTeamName
+	}
+	if p.containsBlockedWord(team) {
+		return ErrBlockedName
+	}
+	return nil
+}
+
+// CheckDisplayName returns an error if the normalized display name contains a blocked word
+func (p *Policy) CheckDisplayName(displayName string) error {
+	if p.containsBlockedWord(displayName) {
+		return ErrBlockedName
+	}
+	return nil
+}
+
+// blocked words are matched ignoring case and everything but letters and digits, so that "b-a-d" or "B A D" match the blocked word "bad" as well
+func (p *Policy) containsBlockedWord(name string) bool {
+	normalized := normalizeForComparison(name)
+	for _, word := range p.blockedWords {
+		if strings.Contains(normalized, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeForComparison(value string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// NormalizeDisplayName trims the display name and returns false if it is empty, too long or contains control or other non printable characters
+func NormalizeDisplayName(displayName string) (string, bool) {
+	displayName = strings.TrimSpace(displayName)
+	if displayName == "" || !utf8.ValidString(displayName) || utf8.RuneCountInString(displayName) > MaxDisplayNameLength {
+		return "", false
+	}
+	for _, r := range displayName {
+		// zero width joiners are required for many emojis
+		if !unicode.IsPrint(r) && r != '\u200d' {
+			return "", false
+		}
+	}
+	return displayName, true
+}
+
+// GetDisplayName returns the display name stored in the annotations of a team deployment. Falls back to the team name if no display name is set
+func GetDisplayName(team string, annotations map[string]string) string {
+	if displayName, ok := annotations[DisplayNameAnnotation]; ok && displayName != "" {
+		return displayName
+	}
+	return team
+}
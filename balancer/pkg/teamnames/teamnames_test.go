@@ -0,0 +1,79 @@
+package teamnames
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := NewPolicy(Config{
+		ReservedNames: []string{"orga", " Staff "},
+		BlockedWords:  []string{"Badword", "  "},
+	})
+
+	t.Run("allows regular team names", func(t *testing.T) {
+		assert.NoError(t, policy.CheckTeamName("team-42"))
+		assert.NoError(t, policy.CheckTeamName("organizers"))
+	})
+
+	t.Run("rejects reserved team names", func(t *testing.T) {
+		assert.Equal(t, ErrReservedTeamName, policy.CheckTeamName("admin"))
+		assert.Equal(t, ErrReservedTeamName, policy.CheckTeamName("orga"))
+		assert.Equal(t, ErrReservedTeamName, policy.CheckTeamName("staff"))
+	})
+
+	t.Run("rejects names containing blocked words ignoring case and separators", func(t *testing.T) {
+		assert.Equal(t, ErrBlockedName, policy.CheckTeamName("the-badword-team"))
+		assert.Equal(t, ErrBlockedName, policy.CheckTeamName("bad-word"))
+		assert.Equal(t, ErrBlockedName, policy.CheckDisplayName("B.A.D W.O.R.D 🍊"))
+		assert.NoError(t, policy.CheckDisplayName("Los Hackers 🍊"))
+	})
+}
+
+func TestNormalizeDisplayName(t *testing.T) {
+	t.Run("accepts unicode display names", func(t *testing.T) {
+		for input, expected := range map[string]string{
+			"  Los Hackers 🍊  ":                 "Los Hackers 🍊",
+			"Équipe Überflieger":                "Équipe Überflieger",
+			"\U0001F469\u200d\U0001F4BB Coders": "\U0001F469\u200d\U0001F4BB Coders",
+		} {
+			displayName, ok := NormalizeDisplayName(input)
+			assert.True(t, ok, input)
+			assert.Equal(t, expected, displayName)
+		}
+	})
+
+	t.Run("rejects empty, too long and display names with control characters", func(t *testing.T) {
+		for _, input := range []string{
+			"",
+			"   ",
+			"this display name is way too long for the score board",
+			"line\nbreak",
+			"right-to-left\u202eoverride",
+			"invalid \xff utf8",
+		} {
+			_, ok := NormalizeDisplayName(input)
+			assert.False(t, ok, input)
+		}
+	})
+}
+
+func TestReadBlocklistFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("# offensive words\nfoo\n\n  bar  \n"), 0o600))
+
+	words, err := ReadBlocklistFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, words)
+
+	_, err = ReadBlocklistFile(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestGetDisplayName(t *testing.T) {
+	assert.Equal(t, "Los Hackers 🍊", GetDisplayName("los-hackers", map[string]string{DisplayNameAnnotation: "Los Hackers 🍊"}))
+	assert.Equal(t, "los-hackers", GetDisplayName("los-hackers", map[string]string{}))
+}
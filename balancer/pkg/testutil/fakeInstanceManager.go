@@ -0,0 +1,209 @@
+package testutil
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+)
+
+// FakeInstanceManager is an in-memory bundle.InstanceManager for tests which don't care about the resources of a specific backend
+type FakeInstanceManager struct {
+	mutex     sync.Mutex
+	instances map[string]bundle.Instance
+	watchers  []chan bundle.InstanceEvent
+	// Restarts counts the restarts per team
+	Restarts map[string]int
+	// Resumes counts the resumes of hibernated instances per team
+	Resumes map[string]int
+	// CreateError is returned by Create if set, to test error handling
+	CreateError error
+}
+
+func NewFakeInstanceManager(instances ...bundle.Instance) *FakeInstanceManager {
+	manager := &FakeInstanceManager{
+		instances: map[string]bundle.Instance{},
+		Restarts:  map[string]int{},
+		Resumes:   map[string]int{},
+	}
+	for _, instance := range instances {
+		if instance.Annotations == nil {
+			instance.Annotations = map[string]string{}
+		}
+		manager.instances[instance.Team] = instance
+	}
+	return manager
+}
+
+func (m *FakeInstanceManager) Create(ctx context.Context, team string, annotations map[string]string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.CreateError != nil {
+		return m.CreateError
+	}
+	if _, ok := m.instances[team]; ok {
+		return bundle.ErrInstanceAlreadyExists
+	}
+	instance := bundle.Instance{Team: team, Annotations: maps.Clone(annotations), CreatedAt: time.Now()}
+	if instance.Annotations == nil {
+		instance.Annotations = map[string]string{}
+	}
+	m.instances[team] = instance
+	m.notify(bundle.InstanceAdded, instance)
+	return nil
+}
+
+func (m *FakeInstanceManager) Repair(ctx context.Context, team string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.instances[team]; !ok {
+		return false, bundle.ErrInstanceNotFound
+	}
+	return false, nil
+}
+
+func (m *FakeInstanceManager) Delete(ctx context.Context, team string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance, ok := m.instances[team]
+	if !ok {
+		return nil
+	}
+	delete(m.instances, team)
+	m.notify(bundle.InstanceDeleted, instance)
+	return nil
+}
+
+func (m *FakeInstanceManager) Restart(ctx context.Context, team string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance, ok := m.instances[team]
+	if !ok {
+		return bundle.ErrInstanceNotFound
+	}
+	if instance.Hibernated {
+		return bundle.ErrInstanceHibernated
+	}
+	m.Restarts[team]++
+	return nil
+}
+
+func (m *FakeInstanceManager) Resume(ctx context.Context, team string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance, ok := m.instances[team]
+	if !ok {
+		return bundle.ErrInstanceNotFound
+	}
+	if !instance.Hibernated {
+		return nil
+	}
+	m.Resumes[team]++
+	instance.Hibernated = false
+	m.instances[team] = instance
+	m.notify(bundle.InstanceModified, instance)
+	return nil
+}
+
+func (m *FakeInstanceManager) Get(ctx context.Context, team string) (*bundle.Instance, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance, ok := m.instances[team]
+	if !ok {
+		return nil, bundle.ErrInstanceNotFound
+	}
+	instance = copyInstance(instance)
+	return &instance, nil
+}
+
+func (m *FakeInstanceManager) List(ctx context.Context) ([]bundle.Instance, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instances := make([]bundle.Instance, 0, len(m.instances))
+	for _, instance := range m.instances {
+		instances = append(instances, copyInstance(instance))
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].Team < instances[j].Team
+	})
+	return instances, nil
+}
+
+// Watch streams the changes done through the manager, e.g. via SetReady. The channel gets closed once the context is canceled
+func (m *FakeInstanceManager) Watch(ctx context.Context) (<-chan bundle.InstanceEvent, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	// buffered so that changes done by the tests don't block until the watcher picked them up
+	events := make(chan bundle.InstanceEvent, 100)
+	m.watchers = append(m.watchers, events)
+	go func() {
+		<-ctx.Done()
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		for i, watcher := range m.watchers {
+			if watcher == events {
+				m.watchers = append(m.watchers[:i], m.watchers[i+1:]...)
+				break
+			}
+		}
+		close(events)
+	}()
+	return events, nil
+}
+
+func (m *FakeInstanceManager) UpdateAnnotations(ctx context.Context, team string, update func(annotations map[string]string) error) (*bundle.Instance, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance, ok := m.instances[team]
+	if !ok {
+		return nil, bundle.ErrInstanceNotFound
+	}
+	annotations := maps.Clone(instance.Annotations)
+	if err := update(annotations); err != nil {
+		return nil, err
+	}
+	instance.Annotations = annotations
+	m.instances[team] = instance
+	m.notify(bundle.InstanceModified, instance)
+	updatedInstance := copyInstance(instance)
+	return &updatedInstance, nil
+}
+
+func (m *FakeInstanceManager) MergeAnnotations(ctx context.Context, team string, annotations map[string]string) error {
+	_, err := m.UpdateAnnotations(ctx, team, func(existingAnnotations map[string]string) error {
+		maps.Copy(existingAnnotations, annotations)
+		return nil
+	})
+	return err
+}
+
+// SetReady changes the readiness of the instance, like a backend would once the instance has started
+func (m *FakeInstanceManager) SetReady(team string, ready bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	instance, ok := m.instances[team]
+	if !ok {
+		return
+	}
+	instance.Ready = ready
+	m.instances[team] = instance
+	m.notify(bundle.InstanceModified, instance)
+}
+
+// notify has to be called while holding the mutex
+func (m *FakeInstanceManager) notify(eventType bundle.InstanceEventType, instance bundle.Instance) {
+	for _, watcher := range m.watchers {
+		select {
+		case watcher <- bundle.InstanceEvent{Type: eventType, Instance: copyInstance(instance)}:
+		default:
+		}
+	}
+}
+
+func copyInstance(instance bundle.Instance) bundle.Instance {
+	instance.Annotations = maps.Clone(instance.Annotations)
+	return instance
+}
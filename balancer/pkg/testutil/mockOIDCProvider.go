@@ -0,0 +1,185 @@
+package testutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+)
+
+// MockOIDCProvider is a minimal OpenID Connect provider used to test the admin oidc login without a real identity provider.
+// The authorize endpoint logs in the user immediately with the configured Claims and redirects back to the redirect_uri.
+type MockOIDCProvider struct {
+	Server   *httptest.Server
+	ClientID string
+	// Claims added to the id tokens issued by the provider, e.g. "email" or "groups"
+	Claims map[string]any
+
+	signer jose.Signer
+	key    *rsa.PrivateKey
+
+	mutex sync.Mutex
+	// nonces of the issued authorization codes
+	codes map[string]string
+}
+
+func NewMockOIDCProvider(clientID string) *MockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test-key"),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	provider := &MockOIDCProvider{
+		ClientID: clientID,
+		Claims:   map[string]any{},
+		signer:   signer,
+		key:      key,
+		codes:    map[string]string{},
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /.well-known/openid-configuration", provider.handleDiscovery)
+	router.HandleFunc("GET /authorize", provider.handleAuthorize)
+	router.HandleFunc("POST /token", provider.handleToken)
+	router.HandleFunc("GET /keys", provider.handleKeys)
+	provider.Server = httptest.NewServer(router)
+	return provider
+}
+
+func (p *MockOIDCProvider) Close() {
+	p.Server.Close()
+}
+
+func (p *MockOIDCProvider) IssuerURL() string {
+	return p.Server.URL
+}
+
+func (p *MockOIDCProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, map[string]any{
+		"issuer":                                p.IssuerURL(),
+		"authorization_endpoint":                p.IssuerURL() + "/authorize",
+		"token_endpoint":                        p.IssuerURL() + "/token",
+		"jwks_uri":                              p.IssuerURL() + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *MockOIDCProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != p.ClientID {
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	}
+	code := randomHex()
+	p.mutex.Lock()
+	p.codes[code] = query.Get("nonce")
+	p.mutex.Unlock()
+
+	redirectUrl, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	redirectQuery := redirectUrl.Query()
+	redirectQuery.Set("code", code)
+	redirectQuery.Set("state", query.Get("state"))
+	redirectUrl.RawQuery = redirectQuery.Encode()
+	http.Redirect(w, r, redirectUrl.String(), http.StatusFound)
+}
+
+func (p *MockOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	code := r.PostForm.Get("code")
+	p.mutex.Lock()
+	nonce, ok := p.codes[code]
+	delete(p.codes, code)
+	claims := map[string]any{}
+	for key, value := range p.Claims {
+		claims[key] = value
+	}
+	p.mutex.Unlock()
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	now := time.Now()
+	claims["iss"] = p.IssuerURL()
+	claims["aud"] = p.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	claims["nonce"] = nonce
+	if _, ok := claims["sub"]; !ok {
+		claims["sub"] = "test-user"
+	}
+
+	idToken, err := jwt.Signed(p.signer).Claims(claims).Serialize()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to sign id token: %s", err), http.StatusInternalServerError)
+		return
+	}
+	writeJson(w, map[string]any{
+		"access_token": randomHex(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (p *MockOIDCProvider) handleKeys(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{Key: &p.key.PublicKey, KeyID: "test-key", Algorithm: string(jose.RS256), Use: "sig"},
+		},
+	})
+}
+
+func writeJson(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomHex() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// NewTestAdminOIDCConfig returns a admin oidc config pointing to the mock provider, allowing "instructor@example.com" and the "instructors" group
+func NewTestAdminOIDCConfig(provider *MockOIDCProvider) bundle.AdminOIDCConfig {
+	return bundle.AdminOIDCConfig{
+		Enabled:       true,
+		IssuerURL:     provider.IssuerURL(),
+		ClientID:      provider.ClientID,
+		ClientSecret:  "test-client-secret",
+		RedirectURL:   "http://localhost:8080/balancer/api/admin/oidc/callback",
+		AllowedEmails: []string{"instructor@example.com"},
+		AllowedGroups: []string{"instructors"},
+	}
+}
@@ -3,9 +3,21 @@ package testutil
 import (
 	"log"
 	"os"
+	"time"
 
+	"github.com/juice-shop/multi-juicer/balancer/pkg/backups"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
-	"github.com/juice-shop/multi-juicer/balancer/pkg/signutil"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/cleanup"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/clientip"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/instances"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/joincodes"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/lockout"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/reservations"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/statestore"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamnames"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/waitlist"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/client-go/kubernetes"
@@ -19,9 +31,19 @@ func NewTestBundle() *bundle.Bundle {
 
 var testSigningKey = "test-signing-key"
 
+// NewTestBundleWithFakeInstanceManager creates a bundle which manages the team instances in memory instead of via the kubernetes api
+func NewTestBundleWithFakeInstanceManager(instanceManager *FakeInstanceManager) *bundle.Bundle {
+	testBundle := NewTestBundle()
+	testBundle.Instances = instanceManager
+	return testBundle
+}
+
 func NewTestBundleWithCustomFakeClient(clientset kubernetes.Interface) *bundle.Bundle {
-	return &bundle.Bundle{
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	stateStore := statestore.NewKubernetesStore(clientset, "test-namespace")
+	testBundle := &bundle.Bundle{
 		ClientSet:             clientset,
+		StateStore:            stateStore,
 		StaticAssetsDirectory: "../ui/build/",
 		RuntimeEnvironment: bundle.RuntimeEnvironment{
 			Namespace: "test-namespace",
@@ -44,8 +66,20 @@ func NewTestBundleWithCustomFakeClient(clientset kubernetes.Interface) *bundle.B
 				Difficulty: 4,
 			},
 		},
-		BcryptRounds: 2,
-		Log:          log.New(os.Stdout, "", log.LstdFlags),
+		BcryptRounds:    2,
+		Log:             logger,
+		LoginLockouts:   lockout.NewTracker(lockout.DefaultConfig()),
+		ClientIPs:       &clientip.Resolver{},
+		TeamSessions:    sessions.NewStore(),
+		JoinCodes:       joincodes.NewService(stateStore, logger, joincodes.Config{}),
+		Waitlist:        waitlist.NewService(stateStore, logger, waitlist.Config{}),
+		ProgressBackups: backups.NewService(stateStore),
+		// the cleaner is disabled by default, tests of the cleanup warnings configure their own schedule
+		CleanupSchedule: &cleanup.Schedule{},
+		TeamNamePolicy: teamnames.NewPolicy(teamnames.Config{
+			ReservedNames: []string{"orga"},
+			BlockedWords:  []string{"badword"},
+		}),
 		Config: &bundle.Config{
 			MaxInstances: 100,
 			Settings: bundle.Settings{
@@ -57,6 +91,9 @@ func NewTestBundleWithCustomFakeClient(clientset kubernetes.Interface) *bundle.B
 				Image:           "bkimminich/juice-shop",
 				Tag:             "latest",
 				NodeEnv:         "multi-juicer",
+				WebhookSigner: bundle.JuiceShopWebhookSignerConfig{
+					Image: "ghcr.io/juice-shop/multi-juicer/progress-watchdog:latest",
+				},
 				Resources: corev1.ResourceRequirements{
 					Requests: corev1.ResourceList{
 						corev1.ResourceCPU:    resource.MustParse("200m"),
@@ -75,13 +112,32 @@ func NewTestBundleWithCustomFakeClient(clientset kubernetes.Interface) *bundle.B
 			},
 			AdminConfig: &bundle.AdminConfig{
 				Password: "mock-admin-password",
+				Accounts: []bundle.AdminAccount{
+					{Name: "viewer", Password: "mock-viewer-password", Role: bundle.AdminRoleViewer},
+					{Name: "operator", Password: "mock-operator-password", Role: bundle.AdminRoleOperator},
+				},
 			},
 		},
 	}
+	testBundle.Instances = instances.NewKubernetesInstanceManager(testBundle)
+	testBundle.InstanceReservations = reservations.NewService(stateStore, testBundle.ListInstanceTeams)
+	return testBundle
 }
 
 func SignTestTeamname(team string) string {
-	signed, err := signutil.Sign(team, testSigningKey)
+	return SignTestTeamMember(team, "")
+}
+
+// SignTestTeamMember creates a signed team cookie value for a registered member of the team
+func SignTestTeamMember(team string, memberID string) string {
+	now := time.Now()
+	signed, err := teamcookie.SignSession(teamcookie.Session{
+		Team:      team,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(bundle.DefaultCookieMaxAgeSeconds * time.Second),
+		MemberID:  memberID,
+		KeyID:     bundle.DefaultCookieSigningKeyID,
+	}, testSigningKey)
 	if err != nil {
 		panic(err)
 	}
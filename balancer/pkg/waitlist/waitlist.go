@@ -0,0 +1,334 @@
+package waitlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/statestore"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/retry"
+)
+
+// ConfigMapName is the name of the ConfigMap the waitlist gets persisted in, so that it survives balancer restarts and is shared between balancer replicas
+const ConfigMapName = "balancer-waitlist"
+
+const configMapDataKey = "waitlist.json"
+
+// DefaultMaxEntries limits how many teams can wait at the same time to stay well below the ConfigMap size limit
+const DefaultMaxEntries = 500
+
+type Config struct {
+	// Enabled lets new teams join a waitlist instead of being rejected once the max instance count is reached
+	Enabled bool `json:"enabled"`
+	// MaxEntries is the maximum number of teams waiting at the same time. Defaults to DefaultMaxEntries
+	MaxEntries int `json:"maxEntries"`
+}
+
+func (c Config) GetMaxEntries() int {
+	if c.MaxEntries <= 0 || c.MaxEntries > DefaultMaxEntries {
+		return DefaultMaxEntries
+	}
+	return c.MaxEntries
+}
+
+// Entry is a team waiting for its instance to be created
+type Entry struct {
+	Team        string `json:"team"`
+	DisplayName string `json:"displayName,omitempty"`
+	// PasscodeHash of the passcode handed out when the team joined the waitlist. Used for the instance once it gets created
+	PasscodeHash string `json:"passcodeHash"`
+	// Members registered while the team was waiting
+	Members []sessions.Member `json:"members,omitempty"`
+	// JoinCode the team was created with, if any. Released again if the team gets removed from the waitlist
+	JoinCode string    `json:"joinCode,omitempty"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+var (
+	ErrAlreadyWaiting  = errors.New("team is already on the waitlist")
+	ErrNotWaiting      = errors.New("team is not on the waitlist")
+	ErrWaitlistFull    = errors.New("waitlist is full")
+	ErrInvalidPosition = errors.New("position must be at least 1")
+	ErrTooManyMembers  = errors.New("team has reached the maximum number of members")
+)
+
+type Service struct {
+	store      statestore.Store
+	log        *log.Logger
+	maxEntries int
+
+	entries      []Entry
+	entriesMutex *sync.RWMutex
+}
+
+func NewService(store statestore.Store, logger *log.Logger, config Config) *Service {
+	return &Service{
+		store:        store,
+		log:          logger,
+		maxEntries:   config.GetMaxEntries(),
+		entries:      []Entry{},
+		entriesMutex: &sync.RWMutex{},
+	}
+}
+
+// Len returns the number of teams currently waiting
+func (s *Service) Len() int {
+	s.entriesMutex.RLock()
+	defer s.entriesMutex.RUnlock()
+	return len(s.entries)
+}
+
+// GetEntries returns all waiting teams in the order they will get their instances
+func (s *Service) GetEntries() []Entry {
+	s.entriesMutex.RLock()
+	defer s.entriesMutex.RUnlock()
+	return slices.Clone(s.entries)
+}
+
+// Get returns the waitlist entry of the team and its position, starting at 1
+func (s *Service) Get(team string) (Entry, int, bool) {
+	s.entriesMutex.RLock()
+	defer s.entriesMutex.RUnlock()
+	index := findEntry(s.entries, team)
+	if index == -1 {
+		return Entry{}, 0, false
+	}
+	return s.entries[index], index + 1, true
+}
+
+// Enqueue adds the team to the end of the waitlist and returns its position
+func (s *Service) Enqueue(ctx context.Context, entry Entry) (int, error) {
+	position := 0
+	err := s.updatePersistedEntries(ctx, func(entries []Entry) ([]Entry, error) {
+		if findEntry(entries, entry.Team) != -1 {
+			return nil, ErrAlreadyWaiting
+		}
+		if len(entries) >= s.maxEntries {
+			return nil, ErrWaitlistFull
+		}
+		position = len(entries) + 1
+		return append(entries, entry), nil
+	})
+	return position, err
+}
+
+// Remove takes the team off the waitlist and returns its entry. Returns ErrNotWaiting if the team isn't on the waitlist (anymore)
+func (s *Service) Remove(ctx context.Context, team string) (Entry, error) {
+	var removed Entry
+	err := s.updatePersistedEntries(ctx, func(entries []Entry) ([]Entry, error) {
+		index := findEntry(entries, team)
+		if index == -1 {
+			return nil, ErrNotWaiting
+		}
+		removed = entries[index]
+		return slices.Delete(entries, index, index+1), nil
+	})
+	return removed, err
+}
+
+// Move places the team at the given position, starting at 1. Positions after the end of the waitlist move the team to the end
+func (s *Service) Move(ctx context.Context, team string, position int) error {
+	if position < 1 {
+		return ErrInvalidPosition
+	}
+	return s.updatePersistedEntries(ctx, func(entries []Entry) ([]Entry, error) {
+		index := findEntry(entries, team)
+		if index == -1 {
+			return nil, ErrNotWaiting
+		}
+		entry := entries[index]
+		entries = slices.Delete(entries, index, index+1)
+		return slices.Insert(entries, min(position-1, len(entries)), entry), nil
+	})
+}
+
+// AddMember registers a member for a team which is still waiting for its instance
+func (s *Service) AddMember(ctx context.Context, team string, member sessions.Member, maxMembers int) error {
+	return s.updatePersistedEntries(ctx, func(entries []Entry) ([]Entry, error) {
+		index := findEntry(entries, team)
+		if index == -1 {
+			return nil, ErrNotWaiting
+		}
+		if len(entries[index].Members) >= maxMembers {
+			return nil, ErrTooManyMembers
+		}
+		entries[index].Members = append(entries[index].Members, member)
+		return entries, nil
+	})
+}
+
+// LoadWaitlist reads the persisted waitlist from the ConfigMap into the local cache
+func (s *Service) LoadWaitlist(ctx context.Context) error {
+	configMap, err := s.getConfigMap(ctx)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get waitlist config map: %w", err)
+	}
+	entries, err := decodeEntries(configMap)
+	if err != nil {
+		return err
+	}
+	s.setEntries(entries)
+	return nil
+}
+
+// StartWaitlistWorker keeps the local waitlist cache in sync with the ConfigMap, so that changes made via other balancer replicas are picked up as well
+func (s *Service) StartWaitlistWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			s.log.Printf("MultiJuicer context canceled. Exiting the waitlist watcher.")
+			return
+		default:
+			s.startWaitlistWatcher(ctx)
+		}
+	}
+}
+
+func (s *Service) startWaitlistWatcher(ctx context.Context) {
+	watcher, err := s.store.Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", ConfigMapName).String(),
+	})
+	if err != nil {
+		s.log.Printf("Failed to start the watcher for the waitlist config map: %v", err)
+		time.Sleep(5 * time.Second)
+		return
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				s.log.Printf("Watcher for the waitlist config map has been closed. Restarting the watcher.")
+				return
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				configMap := event.Object.(*corev1.ConfigMap)
+				entries, err := decodeEntries(configMap)
+				if err != nil {
+					s.log.Printf("Ignoring update of the waitlist config map: %v", err)
+					continue
+				}
+				s.setEntries(entries)
+			case watch.Deleted:
+				s.setEntries([]Entry{})
+			default:
+			}
+		case <-ctx.Done():
+			s.log.Printf("MultiJuicer context canceled. Exiting the waitlist watcher.")
+			return
+		}
+	}
+}
+
+func (s *Service) setEntries(entries []Entry) {
+	s.entriesMutex.Lock()
+	defer s.entriesMutex.Unlock()
+	s.entries = entries
+}
+
+func (s *Service) getConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	return s.store.Get(ctx, ConfigMapName, metav1.GetOptions{})
+}
+
+// updatePersistedEntries applies the update function to the currently persisted waitlist and writes the result back.
+// Conflicting concurrent writes (e.g. from another balancer replica) are retried, so that no team gets lost or added twice
+func (s *Service) updatePersistedEntries(ctx context.Context, update func([]Entry) ([]Entry, error)) error {
+	var updatedEntries []Entry
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+
+		configMap, err := s.getConfigMap(ctx)
+		if apierrors.IsNotFound(err) {
+			entries, err := update([]Entry{})
+			if err != nil {
+				return err
+			}
+			configMap, err = encodeEntries(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: ConfigMapName,
+					Labels: map[string]string{
+						"app.kubernetes.io/name":    "balancer",
+						"app.kubernetes.io/part-of": "multi-juicer",
+					},
+				},
+			}, entries)
+			if err != nil {
+				return err
+			}
+			_, err = s.store.Create(ctx, configMap, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// created concurrently, treat like a conflict to retry with the now existing config map
+				return apierrors.NewConflict(corev1.Resource("configmaps"), ConfigMapName, err)
+			}
+			updatedEntries = entries
+			return err
+		} else if err != nil {
+			return fmt.Errorf("failed to get waitlist config map: %w", err)
+		}
+
+		entries, err := decodeEntries(configMap)
+		if err != nil {
+			return err
+		}
+		entries, err = update(entries)
+		if err != nil {
+			return err
+		}
+		configMap, err = encodeEntries(configMap, entries)
+		if err != nil {
+			return err
+		}
+		_, err = s.store.Update(ctx, configMap, metav1.UpdateOptions{})
+		updatedEntries = entries
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.setEntries(updatedEntries)
+	return nil
+}
+
+// the order of the persisted entries is the order of the waitlist, so they must not be sorted when decoded
+func decodeEntries(configMap *corev1.ConfigMap) ([]Entry, error) {
+	entries := []Entry{}
+	data, ok := configMap.Data[configMapDataKey]
+	if !ok || data == "" {
+		return entries, nil
+	}
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode waitlist from config map: %w", err)
+	}
+	return entries, nil
+}
+
+func encodeEntries(configMap *corev1.ConfigMap, entries []Entry) (*corev1.ConfigMap, error) {
+	encoded, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode waitlist: %w", err)
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[configMapDataKey] = string(encoded)
+	return configMap, nil
+}
+
+func findEntry(entries []Entry, team string) int {
+	return slices.IndexFunc(entries, func(entry Entry) bool { return entry.Team == team })
+}
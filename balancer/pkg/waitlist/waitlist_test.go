@@ -0,0 +1,135 @@
+package waitlist
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestService(config Config) *Service {
+	return NewService(fake.NewSimpleClientset().CoreV1().ConfigMaps("test-namespace"), log.New(os.Stdout, "", log.LstdFlags), config)
+}
+
+func getTeams(service *Service) []string {
+	teams := []string{}
+	for _, entry := range service.GetEntries() {
+		teams = append(teams, entry.Team)
+	}
+	return teams
+}
+
+func enqueueTeams(t *testing.T, service *Service, teams ...string) {
+	for _, team := range teams {
+		_, err := service.Enqueue(context.Background(), Entry{Team: team, PasscodeHash: "hash", JoinedAt: time.Now()})
+		assert.NoError(t, err)
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	t.Run("adds teams to the end of the waitlist", func(t *testing.T) {
+		service := newTestService(Config{})
+
+		position, err := service.Enqueue(context.Background(), Entry{Team: "team-a"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, position)
+		position, err = service.Enqueue(context.Background(), Entry{Team: "team-b"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, position)
+
+		assert.Equal(t, []string{"team-a", "team-b"}, getTeams(service))
+		_, position, ok := service.Get("team-b")
+		assert.True(t, ok)
+		assert.Equal(t, 2, position)
+	})
+
+	t.Run("persists the waitlist so that it can be loaded by other balancers", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset()
+		service := NewService(clientset.CoreV1().ConfigMaps("test-namespace"), log.New(os.Stdout, "", log.LstdFlags), Config{})
+		enqueueTeams(t, service, "team-b", "team-a")
+
+		otherService := NewService(clientset.CoreV1().ConfigMaps("test-namespace"), log.New(os.Stdout, "", log.LstdFlags), Config{})
+		assert.NoError(t, otherService.LoadWaitlist(context.Background()))
+		assert.Equal(t, []string{"team-b", "team-a"}, getTeams(otherService))
+	})
+
+	t.Run("rejects teams which are already waiting", func(t *testing.T) {
+		service := newTestService(Config{})
+		enqueueTeams(t, service, "team-a")
+
+		_, err := service.Enqueue(context.Background(), Entry{Team: "team-a"})
+		assert.Equal(t, ErrAlreadyWaiting, err)
+	})
+
+	t.Run("rejects teams once the waitlist is full", func(t *testing.T) {
+		service := newTestService(Config{MaxEntries: 2})
+		enqueueTeams(t, service, "team-a", "team-b")
+
+		_, err := service.Enqueue(context.Background(), Entry{Team: "team-c"})
+		assert.Equal(t, ErrWaitlistFull, err)
+		assert.Equal(t, 2, service.Len())
+	})
+}
+
+func TestMove(t *testing.T) {
+	t.Run("moves teams to the given position", func(t *testing.T) {
+		service := newTestService(Config{})
+		enqueueTeams(t, service, "team-a", "team-b", "team-c")
+
+		assert.NoError(t, service.Move(context.Background(), "team-c", 1))
+		assert.Equal(t, []string{"team-c", "team-a", "team-b"}, getTeams(service))
+
+		assert.NoError(t, service.Move(context.Background(), "team-c", 2))
+		assert.Equal(t, []string{"team-a", "team-c", "team-b"}, getTeams(service))
+	})
+
+	t.Run("moves teams to the end for positions after the end of the waitlist", func(t *testing.T) {
+		service := newTestService(Config{})
+		enqueueTeams(t, service, "team-a", "team-b", "team-c")
+
+		assert.NoError(t, service.Move(context.Background(), "team-a", 42))
+		assert.Equal(t, []string{"team-b", "team-c", "team-a"}, getTeams(service))
+	})
+
+	t.Run("rejects invalid positions and unknown teams", func(t *testing.T) {
+		service := newTestService(Config{})
+		enqueueTeams(t, service, "team-a")
+
+		assert.Equal(t, ErrInvalidPosition, service.Move(context.Background(), "team-a", 0))
+		assert.Equal(t, ErrNotWaiting, service.Move(context.Background(), "team-b", 1))
+	})
+}
+
+func TestRemove(t *testing.T) {
+	service := newTestService(Config{})
+	_, err := service.Enqueue(context.Background(), Entry{Team: "team-a", JoinCode: "EVENT-CODE"})
+	assert.NoError(t, err)
+	enqueueTeams(t, service, "team-b")
+
+	removed, err := service.Remove(context.Background(), "team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "EVENT-CODE", removed.JoinCode)
+	assert.Equal(t, []string{"team-b"}, getTeams(service))
+
+	_, err = service.Remove(context.Background(), "team-a")
+	assert.Equal(t, ErrNotWaiting, err)
+}
+
+func TestAddMember(t *testing.T) {
+	service := newTestService(Config{})
+	enqueueTeams(t, service, "team-a")
+
+	assert.NoError(t, service.AddMember(context.Background(), "team-a", sessions.Member{ID: "member-1", Name: "Alice"}, 2))
+	assert.NoError(t, service.AddMember(context.Background(), "team-a", sessions.Member{ID: "member-2", Name: "Bob"}, 2))
+	assert.Equal(t, ErrTooManyMembers, service.AddMember(context.Background(), "team-a", sessions.Member{ID: "member-3", Name: "Eve"}, 2))
+	assert.Equal(t, ErrNotWaiting, service.AddMember(context.Background(), "team-b", sessions.Member{ID: "member-4", Name: "Mallory"}, 2))
+
+	entry, _, ok := service.Get("team-a")
+	assert.True(t, ok)
+	assert.Len(t, entry.Members, 2)
+}
@@ -0,0 +1,229 @@
+package routes
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/reservations"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+)
+
+// maxBulkCreateTeams limits the number of teams which can be created with a single request
+const maxBulkCreateTeams = 500
+
+// maxConcurrentTeamCreations limits how many teams get created in parallel, to not overwhelm the kubernetes api
+const maxConcurrentTeamCreations = 5
+
+const maxBulkCreateBodySize = 1 << 20
+
+const (
+	bulkCreateStatusCreated      = "created"
+	bulkCreateStatusExists       = "exists"
+	bulkCreateStatusInvalid      = "invalid"
+	bulkCreateStatusLimitReached = "limit-reached"
+	bulkCreateStatusFailed       = "failed"
+)
+
+type AdminBulkCreateTeamsRequest struct {
+	Teams []string `json:"teams"`
+}
+
+type AdminBulkCreateTeamResult struct {
+	Team   string `json:"team"`
+	Status string `json:"status"`
+	// Passcode is only set for newly created teams. Passcodes of already existing teams can't be retrieved
+	Passcode string `json:"passcode,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+type AdminBulkCreateTeamsResponse struct {
+	Results []AdminBulkCreateTeamResult `json:"results"`
+	Created int                         `json:"created"`
+	Failed  int                         `json:"failed"`
+}
+
+// handleAdminBulkCreateTeams creates multiple teams at once, e.g. for trainings where the list of teams is known beforehand.
+// The team names are passed either as json (`{"teams": ["team-a", "team-b"]}`) or as csv with the team name in the first column.
+// Responds with a report of the result for each team, or with a csv sheet of the generated passcodes when called with `?format=csv`
+func handleAdminBulkCreateTeams(bundle *b.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			teams, err := readBulkCreateTeamNames(responseWriter, req)
+			if err != nil {
+				http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if len(teams) == 0 {
+				http.Error(responseWriter, "no teams passed", http.StatusBadRequest)
+				return
+			}
+			if len(teams) > maxBulkCreateTeams {
+				http.Error(responseWriter, fmt.Sprintf("can't create more than %d teams at once", maxBulkCreateTeams), http.StatusBadRequest)
+				return
+			}
+
+			results := make([]AdminBulkCreateTeamResult, len(teams))
+			semaphore := make(chan struct{}, maxConcurrentTeamCreations)
+			var waitGroup sync.WaitGroup
+			for i, team := range teams {
+				results[i] = AdminBulkCreateTeamResult{Team: team}
+				if !isValidTeamName(team) {
+					results[i].Status = bulkCreateStatusInvalid
+					results[i].Message = "invalid team name"
+					continue
+				}
+				if err := bundle.TeamNamePolicy.CheckTeamName(team); err != nil {
+					results[i].Status = bulkCreateStatusInvalid
+					results[i].Message = err.Error()
+					continue
+				}
+
+				waitGroup.Add(1)
+				go func(result *AdminBulkCreateTeamResult) {
+					defer waitGroup.Done()
+					semaphore <- struct{}{}
+					defer func() { <-semaphore }()
+
+					_, err := bundle.Instances.Get(req.Context(), result.Team)
+					if err == nil {
+						result.Status = bulkCreateStatusExists
+						result.Message = "team already exists"
+						return
+					} else if err != b.ErrInstanceNotFound {
+						bundle.Log.Printf("Failed to get instance of team '%s': %s", result.Team, err)
+						result.Status = bulkCreateStatusFailed
+						result.Message = "failed to get instance"
+						return
+					}
+
+					err = bundle.InstanceReservations.Reserve(req.Context(), result.Team, bundle.Config.MaxInstances)
+					if err == reservations.ErrMaxInstancesReached {
+						result.Status = bulkCreateStatusLimitReached
+						result.Message = "reached maximum instance count"
+						return
+					} else if err != nil {
+						bundle.Log.Printf("Failed to reserve instance for team '%s': %s", result.Team, err)
+						result.Status = bulkCreateStatusFailed
+						result.Message = "failed to check max instance limit"
+						return
+					}
+					defer releaseInstanceReservation(bundle, result.Team)
+
+					passcode, err := provisionTeam(req.Context(), bundle, result.Team, "", []sessions.Member{})
+					if err == errTeamAlreadyCreated {
+						result.Status = bulkCreateStatusExists
+						result.Message = "team already exists"
+						return
+					} else if err != nil {
+						result.Status = bulkCreateStatusFailed
+						result.Message = err.Error()
+						return
+					}
+					result.Status = bulkCreateStatusCreated
+					result.Passcode = passcode
+				}(&results[i])
+			}
+			waitGroup.Wait()
+
+			response := AdminBulkCreateTeamsResponse{Results: results}
+			for _, result := range results {
+				switch result.Status {
+				case bulkCreateStatusCreated:
+					response.Created++
+				case bulkCreateStatusExists:
+				default:
+					response.Failed++
+				}
+			}
+			bundle.Log.Printf("Bulk created %d teams, %d teams failed", response.Created, response.Failed)
+
+			if req.URL.Query().Get("format") == "csv" {
+				writePasscodeSheet(bundle, responseWriter, results)
+				return
+			}
+
+			responseBytes, err := json.Marshal(response)
+			if err != nil {
+				bundle.Log.Printf("Failed to marshal response: %s", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.Header().Set("Cache-Control", "no-store")
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write(responseBytes)
+		},
+	)
+}
+
+// readBulkCreateTeamNames reads the deduplicated team names either from a csv or a json request body
+func readBulkCreateTeamNames(responseWriter http.ResponseWriter, req *http.Request) ([]string, error) {
+	if req.Body == nil {
+		return nil, fmt.Errorf("no teams passed")
+	}
+	body := http.MaxBytesReader(responseWriter, req.Body, maxBulkCreateBodySize)
+
+	var teams []string
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		reader := csv.NewReader(body)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("invalid csv: %w", err)
+			}
+			team := strings.TrimSpace(record[0])
+			// skip the optional header row
+			if len(teams) == 0 && (strings.EqualFold(team, "team") || strings.EqualFold(team, "name")) {
+				continue
+			}
+			teams = append(teams, team)
+		}
+	} else {
+		var requestBody AdminBulkCreateTeamsRequest
+		if err := json.NewDecoder(body).Decode(&requestBody); err != nil {
+			return nil, fmt.Errorf("invalid json")
+		}
+		teams = requestBody.Teams
+	}
+
+	seen := map[string]bool{}
+	uniqueTeams := []string{}
+	for _, team := range teams {
+		team = strings.TrimSpace(team)
+		if team == "" || seen[team] {
+			continue
+		}
+		seen[team] = true
+		uniqueTeams = append(uniqueTeams, team)
+	}
+	return uniqueTeams, nil
+}
+
+func writePasscodeSheet(bundle *b.Bundle, responseWriter http.ResponseWriter, results []AdminBulkCreateTeamResult) {
+	responseWriter.Header().Set("Content-Type", "text/csv")
+	responseWriter.Header().Set("Content-Disposition", `attachment; filename="multi-juicer-passcodes.csv"`)
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(responseWriter)
+	writer.Write([]string{"team", "passcode", "status", "message"})
+	for _, result := range results {
+		writer.Write([]string{result.Team, result.Passcode, result.Status, result.Message})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		bundle.Log.Printf("Failed to write passcode sheet: %s", err)
+	}
+}
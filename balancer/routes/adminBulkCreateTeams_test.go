@@ -0,0 +1,169 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdminBulkCreateTeamsHandler(t *testing.T) {
+	balancerDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "balancer",
+			Namespace: "test-namespace",
+			UID:       "34c0bb8a-240b-4f2a-84ae-2eb2258298f9",
+		},
+	}
+	createTeam := func(team string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+		}
+	}
+
+	t.Run("bulk creating teams requires the operator role", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string][]string{"teams": {"team-a"}})
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/teams", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:viewer")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Len(t, clientset.Actions(), 0)
+	})
+
+	t.Run("creates all teams passed as json and reports the result per team", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string][]string{"teams": {"team-a", "existing", "Invalid Name!", "team-b", "team-a", "orga"}})
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/teams", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam("existing"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response AdminBulkCreateTeamsResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, 2, response.Created)
+		assert.Equal(t, 2, response.Failed)
+		assert.Len(t, response.Results, 5)
+
+		assert.Equal(t, "team-a", response.Results[0].Team)
+		assert.Equal(t, "created", response.Results[0].Status)
+		assert.Regexp(t, "^[0-9]{8}$", response.Results[0].Passcode)
+		assert.Equal(t, AdminBulkCreateTeamResult{Team: "existing", Status: "exists", Message: "team already exists"}, response.Results[1])
+		assert.Equal(t, AdminBulkCreateTeamResult{Team: "Invalid Name!", Status: "invalid", Message: "invalid team name"}, response.Results[2])
+		assert.Equal(t, "team-b", response.Results[3].Team)
+		assert.Equal(t, "created", response.Results[3].Status)
+		assert.Equal(t, AdminBulkCreateTeamResult{Team: "orga", Status: "invalid", Message: "team name is reserved"}, response.Results[4])
+
+		for _, result := range []AdminBulkCreateTeamResult{response.Results[0], response.Results[3]} {
+			deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", result.Team), metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"]), []byte(result.Passcode)))
+			_, err = clientset.CoreV1().Services("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", result.Team), metav1.GetOptions{})
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("accepts csv and returns a passcode sheet", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/teams?format=csv", strings.NewReader("team,trainer\nteam-a,alice\nteam-b,bob\n"))
+		req.Header.Set("Content-Type", "text/csv")
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="multi-juicer-passcodes.csv"`, rr.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 3)
+		assert.Equal(t, []string{"team", "passcode", "status", "message"}, records[0])
+		assert.Equal(t, "team-a", records[1][0])
+		assert.Regexp(t, "^[0-9]{8}$", records[1][1])
+		assert.Equal(t, "created", records[1][2])
+		assert.Equal(t, "team-b", records[2][0])
+		assert.Equal(t, "created", records[2][2])
+	})
+
+	t.Run("stops creating teams once the max instance limit is reached", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string][]string{"teams": {"team-a", "team-b", "team-c"}})
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/teams", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam("existing"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.MaxInstances = 3
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response AdminBulkCreateTeamsResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, 2, response.Created)
+		assert.Equal(t, 1, response.Failed)
+
+		limitReached := 0
+		for _, result := range response.Results {
+			if result.Status == "limit-reached" {
+				limitReached++
+			}
+		}
+		assert.Equal(t, 1, limitReached)
+	})
+
+	t.Run("rejects requests without teams", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/teams", strings.NewReader(`{"teams":[]}`))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/joincodes"
+)
+
+type AdminJoinCodesResponse struct {
+	JoinCodes []joincodes.JoinCode `json:"joinCodes"`
+}
+
+type CreateJoinCodeRequest struct {
+	// Code to create. A random code gets generated if empty
+	Code string `json:"code"`
+	// Quota is the number of teams which can be created with the code. 0 if unlimited
+	Quota int `json:"quota"`
+}
+
+func handleAdminListJoinCodes(bundle *bundle.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			responseBytes, err := json.Marshal(AdminJoinCodesResponse{JoinCodes: bundle.JoinCodes.GetJoinCodes()})
+			if err != nil {
+				bundle.Log.Printf("Failed to marshal response: %s", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write(responseBytes)
+		},
+	)
+}
+
+func handleAdminCreateJoinCode(bundle *bundle.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			if req.Body == nil {
+				http.Error(responseWriter, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			var requestBody CreateJoinCodeRequest
+			if err := json.NewDecoder(req.Body).Decode(&requestBody); err != nil {
+				http.Error(responseWriter, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			defer req.Body.Close()
+
+			joinCode, err := bundle.JoinCodes.CreateJoinCode(req.Context(), requestBody.Code, requestBody.Quota)
+			if errors.Is(err, joincodes.ErrInvalidJoinCode) || errors.Is(err, joincodes.ErrInvalidJoinCodeQuota) || errors.Is(err, joincodes.ErrTooManyJoinCodes) {
+				http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+				return
+			} else if errors.Is(err, joincodes.ErrJoinCodeExists) {
+				http.Error(responseWriter, err.Error(), http.StatusConflict)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Failed to create join code: %s", err)
+				http.Error(responseWriter, "failed to create join code", http.StatusInternalServerError)
+				return
+			}
+			bundle.Log.Printf("Created join code with a quota of %d teams", joinCode.Quota)
+
+			responseBytes, err := json.Marshal(joinCode)
+			if err != nil {
+				bundle.Log.Printf("Failed to marshal response: %s", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusCreated)
+			responseWriter.Write(responseBytes)
+		},
+	)
+}
+
+func handleAdminDeleteJoinCode(bundle *bundle.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			err := bundle.JoinCodes.DeleteJoinCode(req.Context(), req.PathValue("code"))
+			if errors.Is(err, joincodes.ErrJoinCodeNotFound) {
+				http.Error(responseWriter, "join code not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Failed to delete join code: %s", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			bundle.Log.Printf("Deleted join code")
+
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write([]byte{})
+		},
+	)
+}
@@ -0,0 +1,265 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/joincodes"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/reservations"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	testcore "k8s.io/client-go/testing"
+)
+
+func TestAdminJoinCodesHandler(t *testing.T) {
+	balancerDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "balancer",
+			Namespace: "test-namespace",
+			UID:       "34c0bb8a-240b-4f2a-84ae-2eb2258298f9",
+		},
+	}
+
+	joinTeam := func(server *http.ServeMux, team string, body map[string]string) *httptest.ResponseRecorder {
+		jsonPayload, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("creating join codes requires the operator role", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]any{"code": "owasp-day", "quota": 10})
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/join-codes", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:viewer")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset()
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Empty(t, clientset.Actions())
+	})
+
+	t.Run("admins can create, list and delete join codes", func(t *testing.T) {
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset()
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		jsonPayload, _ := json.Marshal(map[string]any{"code": "owasp-day", "quota": 10})
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/join-codes", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:operator")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var created joincodes.JoinCode
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		assert.Equal(t, "OWASP-DAY", created.Code)
+		assert.Equal(t, 10, created.Quota)
+
+		configMap, err := clientset.CoreV1().ConfigMaps("test-namespace").Get(context.Background(), joincodes.ConfigMapName, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Contains(t, configMap.Data["joinCodes.json"], `"code":"OWASP-DAY"`)
+
+		req, _ = http.NewRequest("POST", "/balancer/api/admin/join-codes", bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:operator")))
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var generated joincodes.JoinCode
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &generated))
+		assert.Regexp(t, "^[A-Z2-9]{10}$", generated.Code)
+		assert.Equal(t, 0, generated.Quota)
+
+		req, _ = http.NewRequest("GET", "/balancer/api/admin/join-codes", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:viewer")))
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var listed AdminJoinCodesResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &listed))
+		assert.Len(t, listed.JoinCodes, 2)
+
+		req, _ = http.NewRequest("DELETE", "/balancer/api/admin/join-codes/owasp-day", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:operator")))
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Len(t, bundle.JoinCodes.GetJoinCodes(), 1)
+
+		req, _ = http.NewRequest("DELETE", "/balancer/api/admin/join-codes/owasp-day", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:operator")))
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("rejects duplicate and invalid join codes", func(t *testing.T) {
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		AddRoutes(server, bundle, nil, nil)
+		_, err := bundle.JoinCodes.CreateJoinCode(context.Background(), "OWASP-DAY", 0)
+		assert.NoError(t, err)
+
+		for payload, expectedStatus := range map[string]int{
+			`{"code":"owasp-day"}`:             http.StatusConflict,
+			`{"code":"abc"}`:                   http.StatusBadRequest,
+			`{"code":"with space"}`:            http.StatusBadRequest,
+			`{"code":"valid-code","quota":-1}`: http.StatusBadRequest,
+		} {
+			req, _ := http.NewRequest("POST", "/balancer/api/admin/join-codes", bytes.NewReader([]byte(payload)))
+			req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			assert.Equal(t, expectedStatus, rr.Code, payload)
+		}
+	})
+
+	t.Run("new teams can only be created with a valid join code once join codes exist", func(t *testing.T) {
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+		_, err := bundle.JoinCodes.CreateJoinCode(context.Background(), "OWASP-DAY", 1)
+		assert.NoError(t, err)
+
+		rr := joinTeam(server, "team-a", map[string]string{})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.JSONEq(t, `{"message":"A join code is required to create a new team"}`, rr.Body.String())
+
+		rr = joinTeam(server, "team-a", map[string]string{"joinCode": "wrong-code"})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.JSONEq(t, `{"message":"Invalid join code"}`, rr.Body.String())
+
+		rr = joinTeam(server, "team-a", map[string]string{"joinCode": " owasp-day "})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, []string{"team-a"}, bundle.JoinCodes.GetJoinCodes()[0].Teams)
+
+		rr = joinTeam(server, "team-b", map[string]string{"joinCode": "OWASP-DAY"})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.JSONEq(t, `{"message":"The join code has been used up"}`, rr.Body.String())
+
+		_, err = clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-team-b", metav1.GetOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("requests without a valid join code don't reserve instances", func(t *testing.T) {
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+		_, err := bundle.JoinCodes.CreateJoinCode(context.Background(), "OWASP-DAY", 0)
+		assert.NoError(t, err)
+
+		rr := joinTeam(server, "team-a", map[string]string{"joinCode": "wrong-code"})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		_, err = clientset.CoreV1().ConfigMaps("test-namespace").Get(context.Background(), reservations.ConfigMapName, metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err), "expected no instance to be reserved")
+	})
+
+	t.Run("gives back the join code if the max instance count is reached", func(t *testing.T) {
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.MaxInstances = 0
+		AddRoutes(server, bundle, nil, nil)
+		_, err := bundle.JoinCodes.CreateJoinCode(context.Background(), "OWASP-DAY", 1)
+		assert.NoError(t, err)
+
+		rr := joinTeam(server, "team-a", map[string]string{"joinCode": "OWASP-DAY"})
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Empty(t, bundle.JoinCodes.GetJoinCodes()[0].Teams)
+	})
+
+	t.Run("required join codes keep team creation closed while no join code exists", func(t *testing.T) {
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.JoinCodes = joincodes.Config{Required: true}
+		bundle.JoinCodes = joincodes.NewService(clientset.CoreV1().ConfigMaps("test-namespace"), bundle.Log, bundle.Config.JoinCodes)
+		AddRoutes(server, bundle, nil, nil)
+
+		rr := joinTeam(server, "team-a", map[string]string{})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.JSONEq(t, `{"message":"A join code is required to create a new team"}`, rr.Body.String())
+
+		rr = joinTeam(server, "team-a", map[string]string{"joinCode": "OWASP-DAY"})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.JSONEq(t, `{"message":"No join codes are available yet. Ask the organizers for one"}`, rr.Body.String())
+
+		// deleting the last join code doesn't open up team creation again
+		_, err := bundle.JoinCodes.CreateJoinCode(context.Background(), "OWASP-DAY", 0)
+		assert.NoError(t, err)
+		assert.NoError(t, bundle.JoinCodes.DeleteJoinCode(context.Background(), "OWASP-DAY"))
+
+		rr = joinTeam(server, "team-a", map[string]string{})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		_, err = clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-team-a", metav1.GetOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("required join codes keep team creation closed if the join codes can't be loaded", func(t *testing.T) {
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		clientset.PrependReactor("get", "configmaps", func(action testcore.Action) (bool, runtime.Object, error) {
+			if action.(testcore.GetAction).GetName() != joincodes.ConfigMapName {
+				return false, nil, nil
+			}
+			return true, nil, errors.New("kubernetes api unavailable")
+		})
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.JoinCodes = joincodes.Config{Required: true}
+		bundle.JoinCodes = joincodes.NewService(clientset.CoreV1().ConfigMaps("test-namespace"), bundle.Log, bundle.Config.JoinCodes)
+		AddRoutes(server, bundle, nil, nil)
+
+		assert.Error(t, bundle.JoinCodes.LoadJoinCodes(context.Background()))
+
+		rr := joinTeam(server, "team-a", map[string]string{"joinCode": "OWASP-DAY"})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		_, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-team-a", metav1.GetOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("locks out clients repeatedly guessing join codes", func(t *testing.T) {
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+		_, err := bundle.JoinCodes.CreateJoinCode(context.Background(), "OWASP-DAY", 0)
+		assert.NoError(t, err)
+
+		// the first failed attempts are free, the lockout only kicks in afterwards
+		for i := 0; i < 6; i++ {
+			rr := joinTeam(server, "team-a", map[string]string{"joinCode": fmt.Sprintf("guess-%d", i)})
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+		}
+
+		rr := joinTeam(server, "team-a", map[string]string{"joinCode": "OWASP-DAY"})
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	})
+}
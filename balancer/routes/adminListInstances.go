@@ -7,8 +7,8 @@ import (
 	"time"
 
 	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
-	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamnames"
 )
 
 type AdminListInstancesResponse struct {
@@ -17,33 +17,37 @@ type AdminListInstancesResponse struct {
 
 type AdminListJuiceShopInstance struct {
 	Team        string `json:"team"`
+	DisplayName string `json:"displayName"`
 	Ready       bool   `json:"ready"`
-	CreatedAt   int64  `json:"createdAt"`
-	LastConnect int64  `json:"lastConnect"`
+	// Hibernated instances got stopped because of inactivity, they are resumed once the team returns
+	Hibernated bool `json:"hibernated"`
+	// Protected instances are exempt from the automatic cleanup
+	Protected   bool  `json:"protected"`
+	CreatedAt   int64 `json:"createdAt"`
+	LastConnect int64 `json:"lastConnect"`
+	// CleanupAt is the time the instance becomes due for deletion because of inactivity. Omitted if the instance never gets deleted
+	CleanupAt int64 `json:"cleanupAt,omitempty"`
+	// CleanupDue instances get deleted by the next run of the cleaner, unless the team uses them again
+	CleanupDue bool `json:"cleanupDue"`
+	// Members registered for the team
+	Members []sessions.Member `json:"members"`
 }
 
 func handleAdminListInstances(bundle *bundle.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
-			team, err := teamcookie.GetTeamFromRequest(bundle, req)
-			if err != nil || team != "admin" {
-				http.Error(responseWriter, "", http.StatusUnauthorized)
-				return
-			}
-
-			deployments, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).List(req.Context(), metav1.ListOptions{
-				LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
-			})
+			teamInstances, err := bundle.Instances.List(req.Context())
 			if err != nil {
-				bundle.Log.Printf("Failed to list deployments: %s", err)
+				bundle.Log.Printf("Failed to list instances: %s", err)
 				http.Error(responseWriter, "unable to get instances", http.StatusInternalServerError)
 				return
 			}
 
+			now := time.Now()
 			instances := []AdminListJuiceShopInstance{}
-			for _, teamDeployment := range deployments.Items {
+			for _, teamInstance := range teamInstances {
 
-				lastConnectAnnotation := teamDeployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"]
+				lastConnectAnnotation := teamInstance.Annotations["multi-juicer.owasp-juice.shop/lastRequest"]
 				lastConnection := time.UnixMilli(0)
 
 				if lastConnectAnnotation != "" {
@@ -54,11 +58,22 @@ func handleAdminListInstances(bundle *bundle.Bundle) http.Handler {
 					lastConnection = time.UnixMilli(millis)
 				}
 
+				var cleanupAt int64
+				if deletionTime, ok := bundle.CleanupSchedule.DeletionTime(teamInstance.Annotations, teamInstance.CreatedAt); ok {
+					cleanupAt = deletionTime.UnixMilli()
+				}
+
 				instances = append(instances, AdminListJuiceShopInstance{
-					Team:        teamDeployment.Labels["team"],
-					Ready:       teamDeployment.Status.ReadyReplicas == 1,
-					CreatedAt:   teamDeployment.CreationTimestamp.UnixMilli(),
+					Team:        teamInstance.Team,
+					DisplayName: teamnames.GetDisplayName(teamInstance.Team, teamInstance.Annotations),
+					Ready:       teamInstance.Ready,
+					Hibernated:  teamInstance.Hibernated,
+					Protected:   isProtected(teamInstance.Annotations),
+					CreatedAt:   teamInstance.CreatedAt.UnixMilli(),
 					LastConnect: lastConnection.UnixMilli(),
+					CleanupAt:   cleanupAt,
+					CleanupDue:  bundle.CleanupSchedule.DeletedByNextRun(teamInstance.Annotations, teamInstance.CreatedAt, now),
+					Members:     sessions.ParseMembers(teamInstance.Annotations),
 				})
 			}
 
@@ -8,6 +8,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/cleanup"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
@@ -52,7 +55,7 @@ func TestAdminListInstanceshandler(t *testing.T) {
 
 		clientset := fake.NewSimpleClientset()
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -67,12 +70,14 @@ func TestAdminListInstanceshandler(t *testing.T) {
 
 		server := http.NewServeMux()
 
+		teamWithMembers := createTeam("foobar", time.UnixMilli(1_700_000_000_000), time.UnixMilli(1_729_259_666_123), 1)
+		teamWithMembers.Annotations["multi-juicer.owasp-juice.shop/members"] = `[{"id":"a1b2c3d4e5f60718","name":"Alice","joinedAt":1729259000000}]`
 		clientset := fake.NewSimpleClientset(
-			createTeam("foobar", time.UnixMilli(1_700_000_000_000), time.UnixMilli(1_729_259_666_123), 1),
+			teamWithMembers,
 			createTeam("test-team", time.UnixMilli(1_600_000_000_000), time.UnixMilli(1_729_259_333_123), 0),
 		)
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusOK, rr.Code)
@@ -84,16 +89,77 @@ func TestAdminListInstanceshandler(t *testing.T) {
 		assert.Equal(t, []AdminListJuiceShopInstance{
 			{
 				Team:        "foobar",
+				DisplayName: "foobar",
 				Ready:       true,
 				CreatedAt:   1_700_000_000_000,
 				LastConnect: 1_729_259_666_123,
+				Members:     []sessions.Member{{ID: "a1b2c3d4e5f60718", Name: "Alice", JoinedAt: 1_729_259_000_000}},
 			},
 			{
 				Team:        "test-team",
+				DisplayName: "test-team",
 				Ready:       false,
 				CreatedAt:   1_600_000_000_000,
 				LastConnect: 1_729_259_333_123,
+				Members:     []sessions.Member{},
 			},
 		}, response.Instances)
 	})
+
+	t.Run("lists the instances independent of the backend", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/admin/all", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundleWithFakeInstanceManager(testutil.NewFakeInstanceManager(bundle.Instance{
+			Team:        "foobar",
+			Annotations: map[string]string{"multi-juicer.owasp-juice.shop/displayName": "Los Hackers", "multi-juicer.owasp-juice.shop/lastRequest": "1729259666123", "multi-juicer.owasp-juice.shop/protected": "true"},
+			Ready:       false,
+			Hibernated:  true,
+			CreatedAt:   time.UnixMilli(1_700_000_000_000),
+		}))
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"instances":[{"team":"foobar","displayName":"Los Hackers","ready":false,"hibernated":true,"protected":true,"createdAt":1700000000000,"lastConnect":1729259666123,"cleanupDue":false,"members":[]}]}`, rr.Body.String())
+	})
+
+	t.Run("flags instances which get deleted by the next cleanup", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/admin/all", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		now := time.Now()
+		inactiveTeam := createTeam("inactive-team", now.Add(-48*time.Hour), now.Add(-(23*time.Hour + 30*time.Minute)), 1)
+		protectedTeam := createTeam("protected-team", now.Add(-48*time.Hour), now.Add(-47*time.Hour), 1)
+		protectedTeam.Annotations["multi-juicer.owasp-juice.shop/protected"] = "true"
+		clientset := fake.NewSimpleClientset(
+			inactiveTeam,
+			createTeam("active-team", now.Add(-48*time.Hour), now.Add(-time.Hour), 1),
+			protectedTeam,
+		)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.CleanupSchedule, _ = cleanup.NewSchedule(cleanup.Config{Enabled: true, MaxInactive: "24h", Interval: "1h"})
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response AdminListInstancesResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		instances := map[string]AdminListJuiceShopInstance{}
+		for _, instance := range response.Instances {
+			instances[instance.Team] = instance
+		}
+		assert.True(t, instances["inactive-team"].CleanupDue)
+		assert.Equal(t, now.Add(30*time.Minute).UnixMilli(), instances["inactive-team"].CleanupAt)
+		assert.False(t, instances["active-team"].CleanupDue)
+		assert.NotZero(t, instances["active-team"].CleanupAt)
+		assert.False(t, instances["protected-team"].CleanupDue)
+		assert.Zero(t, instances["protected-team"].CleanupAt)
+	})
 }
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/lockout"
+)
+
+// AdminLockoutsResponse lists the failed login attempts tracked for teams and client ips.
+// The lockout of a team only applies to the clientIps listed with it, other clients can still log into the team.
+// The lockouts are tracked in memory by each balancer replica on its own, so with multiple replicas the list only contains the lockouts of the replica answering the request, and clearing lockouts only clears them on that replica
+type AdminLockoutsResponse struct {
+	Lockouts []lockout.Lockout `json:"lockouts"`
+}
+
+func handleAdminListLockouts(bundle *bundle.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			responseBytes, err := json.Marshal(AdminLockoutsResponse{Lockouts: bundle.LoginLockouts.List()})
+			if err != nil {
+				bundle.Log.Printf("Failed to marshal response: %s", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write(responseBytes)
+		},
+	)
+}
+
+func handleAdminClearLockouts(bundle *bundle.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			bundle.LoginLockouts.ClearAll()
+			bundle.Log.Printf("Cleared all login lockouts")
+
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write([]byte{})
+		},
+	)
+}
+
+// handleAdminClearLockout clears the lockout of a single team (for all client ips) or client ip
+func handleAdminClearLockout(bundle *bundle.Bundle, keyType lockout.KeyType) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			key := lockout.Key{Type: keyType, Value: req.PathValue("value")}
+			if !bundle.LoginLockouts.Clear(key) {
+				http.Error(responseWriter, "no lockout found", http.StatusNotFound)
+				return
+			}
+			bundle.Log.Printf("Cleared login lockout of %s '%s'", key.Type, key.Value)
+
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write([]byte{})
+		},
+	)
+}
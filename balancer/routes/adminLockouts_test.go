@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/lockout"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminLockoutsHandler(t *testing.T) {
+	lockTeamAndIP := func(tracker *lockout.Tracker) {
+		for range 6 {
+			tracker.RecordTeamFailure("foobar", "10.0.0.1")
+		}
+	}
+
+	t.Run("listing lockouts requires admin login", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/admin/lockouts", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("foobar")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("lists the tracked lockouts", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/admin/lockouts", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		lockTeamAndIP(bundle.LoginLockouts)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"type":"team","value":"foobar","failedAttempts":6`)
+		assert.Contains(t, rr.Body.String(), `"clientIps":["10.0.0.1"]`)
+		assert.Contains(t, rr.Body.String(), `"type":"ip","value":"10.0.0.1","failedAttempts":6`)
+	})
+
+	t.Run("clearing lockouts requires the operator role", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/balancer/api/admin/lockouts/teams/foobar", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:viewer")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		lockTeamAndIP(bundle.LoginLockouts)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Greater(t, bundle.LoginLockouts.RetryAfter(lockout.TeamKey("foobar")).Seconds(), 0.0)
+	})
+
+	t.Run("clears the lockout of a single team", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/balancer/api/admin/lockouts/teams/foobar", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:operator")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		lockTeamAndIP(bundle.LoginLockouts)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Zero(t, bundle.LoginLockouts.RetryAfter(lockout.TeamKey("foobar")))
+		assert.NotZero(t, bundle.LoginLockouts.RetryAfter(lockout.ClientIPKey("10.0.0.1")))
+	})
+
+	t.Run("returns 404 when clearing a ip without lockout", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/balancer/api/admin/lockouts/ips/10.0.0.2", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		lockTeamAndIP(bundle.LoginLockouts)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("clears all lockouts", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/balancer/api/admin/lockouts", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		lockTeamAndIP(bundle.LoginLockouts)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, bundle.LoginLockouts.List())
+	})
+}
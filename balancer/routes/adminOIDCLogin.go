@@ -0,0 +1,171 @@
+package routes
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/adminoidc"
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/signutil"
+	"golang.org/x/oauth2"
+)
+
+// lazily discovers the oidc provider on first use, so that a unreachable identity provider doesn't prevent the balancer from starting
+type adminOIDCAuthenticatorProvider struct {
+	bundle        *b.Bundle
+	mutex         sync.Mutex
+	authenticator *adminoidc.Authenticator
+}
+
+func (p *adminOIDCAuthenticatorProvider) get() (*adminoidc.Authenticator, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.authenticator == nil {
+		authenticator, err := adminoidc.NewAuthenticator(context.Background(), p.bundle.Config.AdminConfig.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		p.authenticator = authenticator
+	}
+	return p.authenticator, nil
+}
+
+func getOIDCStateCookieName(bundle *b.Bundle) string {
+	return bundle.Config.CookieConfig.Name + "-oidc"
+}
+
+const oidcStateCookiePath = "/balancer/api/admin/oidc"
+
+// getOIDCStateSigningKey is derived from the cookie signing key, so that state cookies can't be used as team cookies
+func getOIDCStateSigningKey(bundle *b.Bundle) string {
+	return signutil.DeriveKey(bundle.Config.CookieConfig.SigningKey, "oidc-state")
+}
+
+func handleAdminOIDCLogin(bundle *b.Bundle, authenticatorProvider *adminOIDCAuthenticatorProvider) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			if !bundle.Config.AdminConfig.OIDC.Enabled {
+				http.NotFound(responseWriter, req)
+				return
+			}
+
+			authenticator, err := authenticatorProvider.get()
+			if err != nil {
+				bundle.Log.Printf("Failed to initialize admin oidc login: %s", err)
+				http.Error(responseWriter, "identity provider unavailable", http.StatusBadGateway)
+				return
+			}
+
+			state, err := adminoidc.GenerateRandomToken()
+			if err != nil {
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			nonce, err := adminoidc.GenerateRandomToken()
+			if err != nil {
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			pkceVerifier := oauth2.GenerateVerifier()
+
+			stateCookieValue, err := signutil.Sign(strings.Join([]string{state, nonce, pkceVerifier}, ":"), getOIDCStateSigningKey(bundle))
+			if err != nil {
+				http.Error(responseWriter, "failed to sign oidc state cookie", http.StatusInternalServerError)
+				return
+			}
+			http.SetCookie(responseWriter, &http.Cookie{
+				Name:     getOIDCStateCookieName(bundle),
+				Value:    stateCookieValue,
+				HttpOnly: true,
+				Path:     oidcStateCookiePath,
+				MaxAge:   10 * 60,
+				// needs to be lax as the callback is a cross site navigation coming from the identity provider
+				SameSite: http.SameSiteLaxMode,
+				Secure:   bundle.Config.CookieConfig.Secure,
+			})
+
+			http.Redirect(responseWriter, req, authenticator.AuthCodeURL(state, nonce, pkceVerifier), http.StatusFound)
+		},
+	)
+}
+
+func handleAdminOIDCCallback(bundle *b.Bundle, authenticatorProvider *adminOIDCAuthenticatorProvider) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			if !bundle.Config.AdminConfig.OIDC.Enabled {
+				http.NotFound(responseWriter, req)
+				return
+			}
+
+			// the state cookie is single use, so it gets cleared no matter the outcome
+			http.SetCookie(responseWriter, &http.Cookie{Name: getOIDCStateCookieName(bundle), Path: oidcStateCookiePath, MaxAge: -1})
+
+			if providerError := req.URL.Query().Get("error"); providerError != "" {
+				bundle.Log.Printf("Admin oidc login failed. Identity provider returned error: %s", providerError)
+				failedLoginCounter.WithLabelValues("admin").Inc()
+				writeUnauthorizedResponse(responseWriter)
+				return
+			}
+
+			state, nonce, pkceVerifier, err := getOIDCStateFromCookie(bundle, req)
+			if err != nil || subtle.ConstantTimeCompare([]byte(state), []byte(req.URL.Query().Get("state"))) != 1 {
+				http.Error(responseWriter, "invalid or expired login state, please try again", http.StatusBadRequest)
+				return
+			}
+
+			authenticator, err := authenticatorProvider.get()
+			if err != nil {
+				bundle.Log.Printf("Failed to initialize admin oidc login: %s", err)
+				http.Error(responseWriter, "identity provider unavailable", http.StatusBadGateway)
+				return
+			}
+
+			identity, err := authenticator.Exchange(req.Context(), req.URL.Query().Get("code"), nonce, pkceVerifier)
+			if errors.Is(err, adminoidc.ErrNotAllowed) {
+				bundle.Log.Printf("Rejected admin oidc login of '%s' (%s) as it doesn't match the allowed emails or groups", identity.Subject, identity.Email)
+				failedLoginCounter.WithLabelValues("admin").Inc()
+				http.Error(responseWriter, "you are not allowed to log in as admin", http.StatusForbidden)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Admin oidc login failed: %s", err)
+				failedLoginCounter.WithLabelValues("admin").Inc()
+				writeUnauthorizedResponse(responseWriter)
+				return
+			}
+
+			accountName := identity.Email
+			if accountName == "" {
+				accountName = identity.Subject
+			}
+			err = setSignedTeamCookie(bundle, b.AdminIdentity(b.OIDCAdminAccountPrefix+accountName), 0, "", responseWriter)
+			if err != nil {
+				http.Error(responseWriter, "failed to sign team cookie", http.StatusInternalServerError)
+				return
+			}
+
+			bundle.Log.Printf("Admin '%s' (%s) logged in via oidc", identity.Subject, identity.Email)
+			loginCounter.WithLabelValues("login", "admin").Inc()
+			http.Redirect(responseWriter, req, "/balancer/admin", http.StatusFound)
+		},
+	)
+}
+
+func getOIDCStateFromCookie(bundle *b.Bundle, req *http.Request) (string, string, string, error) {
+	stateCookie, err := req.Cookie(getOIDCStateCookieName(bundle))
+	if err != nil {
+		return "", "", "", err
+	}
+	value, err := signutil.Unsign(stateCookie.Value, getOIDCStateSigningKey(bundle))
+	if err != nil {
+		return "", "", "", err
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return "", "", "", errors.New("malformed oidc state cookie")
+	}
+	return parts[0], parts[1], parts[2], nil
+}
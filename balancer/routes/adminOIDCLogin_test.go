@@ -0,0 +1,153 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminOIDCLoginHandler(t *testing.T) {
+	noRedirectClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	setupOIDC := func(t *testing.T, claims map[string]any) (*http.ServeMux, *bundle.Bundle) {
+		provider := testutil.NewMockOIDCProvider("multi-juicer")
+		t.Cleanup(provider.Close)
+		provider.Claims = claims
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		bundle.Config.AdminConfig.OIDC = testutil.NewTestAdminOIDCConfig(provider)
+		AddRoutes(server, bundle, nil, nil)
+		return server, bundle
+	}
+
+	// runs the full login flow: balancer login -> identity provider -> balancer callback
+	login := func(t *testing.T, server *http.ServeMux) *httptest.ResponseRecorder {
+		loginReq, _ := http.NewRequest("GET", "/balancer/api/admin/oidc/login", nil)
+		loginRR := httptest.NewRecorder()
+		server.ServeHTTP(loginRR, loginReq)
+		assert.Equal(t, http.StatusFound, loginRR.Code)
+
+		providerRes, err := noRedirectClient.Get(loginRR.Header().Get("Location"))
+		assert.NoError(t, err)
+		defer providerRes.Body.Close()
+		assert.Equal(t, http.StatusFound, providerRes.StatusCode)
+
+		callbackUrl, err := url.Parse(providerRes.Header.Get("Location"))
+		assert.NoError(t, err)
+		assert.Equal(t, "/balancer/api/admin/oidc/callback", callbackUrl.Path)
+
+		callbackReq, _ := http.NewRequest("GET", callbackUrl.RequestURI(), nil)
+		for _, cookie := range loginRR.Result().Cookies() {
+			callbackReq.AddCookie(cookie)
+		}
+		callbackRR := httptest.NewRecorder()
+		server.ServeHTTP(callbackRR, callbackReq)
+		return callbackRR
+	}
+
+	t.Run("returns 404 if oidc isn't enabled", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/admin/oidc/login", nil)
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("redirects to the identity provider with state, nonce and pkce challenge", func(t *testing.T) {
+		server, _ := setupOIDC(t, map[string]any{})
+
+		req, _ := http.NewRequest("GET", "/balancer/api/admin/oidc/login", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusFound, rr.Code)
+		location, err := url.Parse(rr.Header().Get("Location"))
+		assert.NoError(t, err)
+		assert.Equal(t, "/authorize", location.Path)
+		assert.Equal(t, "multi-juicer", location.Query().Get("client_id"))
+		assert.NotEmpty(t, location.Query().Get("state"))
+		assert.NotEmpty(t, location.Query().Get("nonce"))
+		assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+		assert.Regexp(t, regexp.MustCompile(`team-oidc=.*; Path=/balancer/api/admin/oidc; Max-Age=600; HttpOnly; SameSite=Lax`), rr.Header().Get("Set-Cookie"))
+	})
+
+	t.Run("state cookies can't be used as team cookies", func(t *testing.T) {
+		server, bundle := setupOIDC(t, map[string]any{})
+		bundle.Config.CookieConfig.LegacyCookiesAcceptedUntil = time.Now().Add(time.Hour)
+
+		req, _ := http.NewRequest("GET", "/balancer/api/admin/oidc/login", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		cookies := rr.Result().Cookies()
+		assert.Len(t, cookies, 1)
+		_, err := teamcookie.ParseCookieValue(context.Background(), bundle, cookies[0].Value)
+		assert.Error(t, err)
+	})
+
+	t.Run("logs in admins with an allowed email", func(t *testing.T) {
+		server, _ := setupOIDC(t, map[string]any{"email": "Instructor@Example.com", "email_verified": true})
+
+		rr := login(t, server)
+
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, "/balancer/admin", rr.Header().Get("Location"))
+		assert.Regexp(t, regexp.MustCompile(`team=admin:oidc:instructor@example\.com\|\d+\|\d+\|0\|\|default\..*; Path=/; Max-Age=604800; HttpOnly; SameSite=Strict`), rr.Header().Values("Set-Cookie"))
+	})
+
+	t.Run("logs in admins with an allowed group", func(t *testing.T) {
+		server, _ := setupOIDC(t, map[string]any{"email": "someone@example.com", "groups": []string{"staff", "instructors"}})
+
+		rr := login(t, server)
+
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Regexp(t, regexp.MustCompile(`team=admin:oidc:someone@example\.com\|\d+\|\d+\|0\|\|default\..*; Path=/; Max-Age=604800; HttpOnly; SameSite=Strict`), rr.Header().Values("Set-Cookie"))
+	})
+
+	t.Run("rejects identities not matching the allowed emails or groups", func(t *testing.T) {
+		server, _ := setupOIDC(t, map[string]any{"email": "participant@example.com", "groups": []string{"participants"}})
+
+		rr := login(t, server)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.NotRegexp(t, regexp.MustCompile(`team=admin`), rr.Header().Values("Set-Cookie"))
+	})
+
+	t.Run("rejects unverified emails", func(t *testing.T) {
+		server, _ := setupOIDC(t, map[string]any{"email": "instructor@example.com", "email_verified": false})
+
+		rr := login(t, server)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("rejects callbacks without matching state cookie", func(t *testing.T) {
+		server, _ := setupOIDC(t, map[string]any{"email": "instructor@example.com"})
+
+		req, _ := http.NewRequest("GET", "/balancer/api/admin/oidc/callback?code=foo&state=bar", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.NotRegexp(t, regexp.MustCompile(`team=admin`), rr.Header().Values("Set-Cookie"))
+	})
+}
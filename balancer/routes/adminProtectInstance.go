@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/cleanup"
+)
+
+func isProtected(annotations map[string]string) bool {
+	return annotations[cleanup.ProtectedAnnotation] == "true"
+}
+
+// handleAdminSetInstanceProtection sets or clears the protection of the instance from the automatic cleanup
+func handleAdminSetInstanceProtection(bundle *b.Bundle, protected bool) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team := req.PathValue("team")
+			if !isValidTeamName(team) {
+				http.Error(responseWriter, "invalid team name", http.StatusBadRequest)
+				return
+			}
+
+			_, err := bundle.Instances.UpdateAnnotations(req.Context(), team, func(annotations map[string]string) error {
+				if protected {
+					annotations[cleanup.ProtectedAnnotation] = "true"
+				} else {
+					delete(annotations, cleanup.ProtectedAnnotation)
+				}
+				return nil
+			})
+			if err == b.ErrInstanceNotFound {
+				http.Error(responseWriter, "team not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Failed to update the protection of team '%s': %s", team, err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			if protected {
+				bundle.Log.Printf("Protected team '%s' from the automatic cleanup", team)
+			} else {
+				bundle.Log.Printf("Removed the protection of team '%s' from the automatic cleanup", team)
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write([]byte(fmt.Sprintf(`{"protected":%t}`, protected)))
+		},
+	)
+}
@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminSetInstanceProtectionHandler(t *testing.T) {
+	newServer := func() (*http.ServeMux, *testutil.FakeInstanceManager) {
+		instances := testutil.NewFakeInstanceManager(bundle.Instance{Team: "foobar"})
+		server := http.NewServeMux()
+		AddRoutes(server, testutil.NewTestBundleWithFakeInstanceManager(instances), nil, nil)
+		return server, instances
+	}
+
+	t.Run("protects and unprotects instances", func(t *testing.T) {
+		server, instances := newServer()
+
+		req, _ := http.NewRequest("PUT", "/balancer/api/admin/teams/foobar/protected", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"protected":true}`, rr.Body.String())
+		instance, _ := instances.Get(context.Background(), "foobar")
+		assert.Equal(t, "true", instance.Annotations["multi-juicer.owasp-juice.shop/protected"])
+
+		req, _ = http.NewRequest("DELETE", "/balancer/api/admin/teams/foobar/protected", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"protected":false}`, rr.Body.String())
+		instance, _ = instances.Get(context.Background(), "foobar")
+		assert.NotContains(t, instance.Annotations, "multi-juicer.owasp-juice.shop/protected")
+	})
+
+	t.Run("returns 404 for teams without an instance", func(t *testing.T) {
+		server, _ := newServer()
+
+		req, _ := http.NewRequest("PUT", "/balancer/api/admin/teams/other-team/protected", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("requires the operator role", func(t *testing.T) {
+		server, instances := newServer()
+
+		req, _ := http.NewRequest("PUT", "/balancer/api/admin/teams/foobar/protected", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:viewer")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		instance, _ := instances.Get(context.Background(), "foobar")
+		assert.NotContains(t, instance.Annotations, "multi-juicer.owasp-juice.shop/protected")
+
+		req, _ = http.NewRequest("PUT", "/balancer/api/admin/teams/foobar/protected", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("some-team")))
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+)
+
+type AdminRepairInstanceResponse struct {
+	Message  string `json:"message"`
+	Repaired bool   `json:"repaired"`
+}
+
+// handleAdminRepairInstance recreates missing parts of the instance of an existing team, e.g. a service which got lost during an interrupted team creation
+func handleAdminRepairInstance(bundle *b.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team := req.PathValue("team")
+			if !isValidTeamName(team) {
+				http.Error(responseWriter, "invalid team name", http.StatusBadRequest)
+				return
+			}
+
+			repaired, err := bundle.Instances.Repair(req.Context(), team)
+			if err == b.ErrInstanceNotFound {
+				http.Error(responseWriter, "team not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Failed to repair instance of team '%s': %s", team, err)
+				http.Error(responseWriter, "failed to repair instance", http.StatusInternalServerError)
+				return
+			}
+
+			response := AdminRepairInstanceResponse{Message: "Instance is complete", Repaired: repaired}
+			if repaired {
+				bundle.Log.Printf("Recreated missing parts of the instance of team '%s'", team)
+				response.Message = "Recreated missing parts of the instance"
+			}
+			responseBody, _ := json.Marshal(response)
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write(responseBody)
+		},
+	)
+}
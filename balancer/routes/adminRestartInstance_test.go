@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
@@ -40,7 +41,7 @@ func TestAdminRestartInstanceHandler(t *testing.T) {
 
 		clientset := fake.NewSimpleClientset(createPodForTeam("foobar"))
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -57,7 +58,7 @@ func TestAdminRestartInstanceHandler(t *testing.T) {
 
 		clientset := fake.NewSimpleClientset(createPodForTeam("foobar"))
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -73,7 +74,7 @@ func TestAdminRestartInstanceHandler(t *testing.T) {
 
 		clientset := fake.NewSimpleClientset(createPodForTeam("foobar"), createPodForTeam("other-team"))
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -93,4 +94,34 @@ func TestAdminRestartInstanceHandler(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Len(t, pods.Items, 1)
 	})
+
+	t.Run("returns a 404 for teams without a running instance", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/teams/other-team/restart", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		instanceManager := testutil.NewFakeInstanceManager(bundle.Instance{Team: "foobar"})
+		AddRoutes(server, testutil.NewTestBundleWithFakeInstanceManager(instanceManager), nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Empty(t, instanceManager.Restarts)
+	})
+
+	t.Run("returns a 409 for hibernated instances", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/teams/foobar/restart", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		instanceManager := testutil.NewFakeInstanceManager(bundle.Instance{Team: "foobar", Hibernated: true})
+		AddRoutes(server, testutil.NewTestBundleWithFakeInstanceManager(instanceManager), nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Empty(t, instanceManager.Restarts)
+	})
 }
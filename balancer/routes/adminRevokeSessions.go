@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
+)
+
+// handleAdminRevokeSessions invalidates all cookies issued to the team so far. Members have to join the team again with the passcode
+func handleAdminRevokeSessions(bundle *b.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team := req.PathValue("team")
+			if !isValidTeamName(team) {
+				http.Error(responseWriter, "invalid team name", http.StatusBadRequest)
+				return
+			}
+
+			_, err := teamcookie.RevokeSessions(req.Context(), bundle, team, nil)
+			if err == b.ErrInstanceNotFound {
+				http.Error(responseWriter, "team not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Failed to revoke sessions of team '%s': %s", team, err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			bundle.Log.Printf("Revoked all sessions of team '%s'", team)
+
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write([]byte{})
+		},
+	)
+}
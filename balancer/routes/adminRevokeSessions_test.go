@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdminRevokeSessionsHandler(t *testing.T) {
+	createDeploymentForTeam := func(team string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+		}
+	}
+
+	t.Run("revoking sessions requires the operator role", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/teams/foobar/revoke-sessions", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:viewer")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(createDeploymentForTeam("foobar"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Len(t, clientset.Actions(), 0)
+	})
+
+	t.Run("returns 404 for unknown teams", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/teams/foobar/revoke-sessions", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("revokes existing sessions of the team", func(t *testing.T) {
+		teamCookie := testutil.SignTestTeamname("foobar")
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(createDeploymentForTeam("foobar"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/teams/foobar/revoke-sessions", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:operator")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "1", deployment.Annotations[sessions.GenerationAnnotation])
+
+		statusReq, _ := http.NewRequest("GET", "/balancer/api/teams/status", nil)
+		statusReq.Header.Set("Cookie", fmt.Sprintf("team=%s", teamCookie))
+		statusRR := httptest.NewRecorder()
+		server.ServeHTTP(statusRR, statusReq)
+
+		assert.Equal(t, http.StatusUnauthorized, statusRR.Code)
+	})
+}
@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/waitlist"
+)
+
+type AdminWaitlistEntry struct {
+	Team        string    `json:"team"`
+	DisplayName string    `json:"displayName"`
+	Position    int       `json:"position"`
+	MemberCount int       `json:"memberCount"`
+	JoinedAt    time.Time `json:"joinedAt"`
+}
+
+type AdminWaitlistResponse struct {
+	Enabled bool                 `json:"enabled"`
+	Entries []AdminWaitlistEntry `json:"entries"`
+}
+
+type MoveWaitlistEntryRequest struct {
+	// Position to move the team to, starting at 1
+	Position int `json:"position"`
+}
+
+func handleAdminListWaitlist(bundle *bundle.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			entries := bundle.Waitlist.GetEntries()
+			response := AdminWaitlistResponse{
+				Enabled: bundle.Config.Waitlist.Enabled,
+				Entries: make([]AdminWaitlistEntry, len(entries)),
+			}
+			for i, entry := range entries {
+				displayName := entry.DisplayName
+				if displayName == "" {
+					displayName = entry.Team
+				}
+				response.Entries[i] = AdminWaitlistEntry{
+					Team:        entry.Team,
+					DisplayName: displayName,
+					Position:    i + 1,
+					MemberCount: len(entry.Members),
+					JoinedAt:    entry.JoinedAt,
+				}
+			}
+
+			responseBytes, err := json.Marshal(response)
+			if err != nil {
+				bundle.Log.Printf("Failed to marshal response: %s", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write(responseBytes)
+		},
+	)
+}
+
+func handleAdminMoveWaitlistEntry(bundle *bundle.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team := req.PathValue("team")
+			if req.Body == nil {
+				http.Error(responseWriter, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			var requestBody MoveWaitlistEntryRequest
+			if err := json.NewDecoder(req.Body).Decode(&requestBody); err != nil {
+				http.Error(responseWriter, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			defer req.Body.Close()
+
+			err := bundle.Waitlist.Move(req.Context(), team, requestBody.Position)
+			if errors.Is(err, waitlist.ErrInvalidPosition) {
+				http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+				return
+			} else if errors.Is(err, waitlist.ErrNotWaiting) {
+				http.Error(responseWriter, err.Error(), http.StatusNotFound)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Failed to move team '%s' on the waitlist: %s", team, err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			bundle.Log.Printf("Moved team '%s' to position %d of the waitlist", team, requestBody.Position)
+
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write([]byte{})
+		},
+	)
+}
+
+func handleAdminRemoveWaitlistEntry(bundle *bundle.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team := req.PathValue("team")
+
+			entry, err := bundle.Waitlist.Remove(req.Context(), team)
+			if errors.Is(err, waitlist.ErrNotWaiting) {
+				http.Error(responseWriter, err.Error(), http.StatusNotFound)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Failed to remove team '%s' from the waitlist: %s", team, err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			// the team will never be created, so it shouldn't use up the quota of its join code
+			releaseJoinCode(req.Context(), bundle, entry.JoinCode, team)
+			bundle.Log.Printf("Removed team '%s' from the waitlist", team)
+
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write([]byte{})
+		},
+	)
+}
@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/waitlist"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdminWaitlistHandler(t *testing.T) {
+	joinedAt := time.Date(2024, 11, 1, 19, 55, 48, 0, time.UTC)
+	newBundleWithWaitingTeams := func(teams ...string) *b.Bundle {
+		bundle := testutil.NewTestBundleWithCustomFakeClient(fake.NewSimpleClientset())
+		bundle.Config.Waitlist.Enabled = true
+		for _, team := range teams {
+			_, err := bundle.Waitlist.Enqueue(context.Background(), waitlist.Entry{Team: team, PasscodeHash: "hash", JoinedAt: joinedAt})
+			assert.NoError(t, err)
+		}
+		return bundle
+	}
+	getWaitingTeams := func(bundle *b.Bundle) []string {
+		teams := []string{}
+		for _, entry := range bundle.Waitlist.GetEntries() {
+			teams = append(teams, entry.Team)
+		}
+		return teams
+	}
+
+	t.Run("lists the waiting teams in order", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/admin/waitlist", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:viewer")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := newBundleWithWaitingTeams("team-a")
+		_, err := bundle.Waitlist.Enqueue(context.Background(), waitlist.Entry{Team: "team-b", DisplayName: "Los Hackers", PasscodeHash: "hash", JoinedAt: joinedAt})
+		assert.NoError(t, err)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{
+			"enabled": true,
+			"entries": [
+				{"team":"team-a","displayName":"team-a","position":1,"memberCount":0,"joinedAt":"2024-11-01T19:55:48Z"},
+				{"team":"team-b","displayName":"Los Hackers","position":2,"memberCount":0,"joinedAt":"2024-11-01T19:55:48Z"}
+			]
+		}`, rr.Body.String())
+		assert.NotContains(t, rr.Body.String(), "hash")
+	})
+
+	t.Run("reordering and removing waiting teams requires the operator role", func(t *testing.T) {
+		server := http.NewServeMux()
+		bundle := newBundleWithWaitingTeams("team-a", "team-b")
+		AddRoutes(server, bundle, nil, nil)
+
+		jsonPayload, _ := json.Marshal(map[string]int{"position": 1})
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/waitlist/team-b/move", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:viewer")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		req, _ = http.NewRequest("DELETE", "/balancer/api/admin/waitlist/team-b", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:viewer")))
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		assert.Equal(t, []string{"team-a", "team-b"}, getWaitingTeams(bundle))
+	})
+
+	t.Run("moves waiting teams to another position", func(t *testing.T) {
+		server := http.NewServeMux()
+		bundle := newBundleWithWaitingTeams("team-a", "team-b", "team-c")
+		AddRoutes(server, bundle, nil, nil)
+
+		jsonPayload, _ := json.Marshal(map[string]int{"position": 1})
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/waitlist/team-c/move", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:operator")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, []string{"team-c", "team-a", "team-b"}, getWaitingTeams(bundle))
+	})
+
+	t.Run("rejects invalid positions and teams which are not waiting", func(t *testing.T) {
+		server := http.NewServeMux()
+		bundle := newBundleWithWaitingTeams("team-a")
+		AddRoutes(server, bundle, nil, nil)
+
+		jsonPayload, _ := json.Marshal(map[string]int{"position": 0})
+		req, _ := http.NewRequest("POST", "/balancer/api/admin/waitlist/team-a/move", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		jsonPayload, _ = json.Marshal(map[string]int{"position": 1})
+		req, _ = http.NewRequest("POST", "/balancer/api/admin/waitlist/team-b/move", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+
+		req, _ = http.NewRequest("DELETE", "/balancer/api/admin/waitlist/team-b", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("removing a waiting team releases its join code", func(t *testing.T) {
+		server := http.NewServeMux()
+		bundle := newBundleWithWaitingTeams()
+		joinCode, err := bundle.JoinCodes.CreateJoinCode(context.Background(), "OWASP-DAY", 1)
+		assert.NoError(t, err)
+		assert.NoError(t, bundle.JoinCodes.Redeem(context.Background(), joinCode.Code, "team-a"))
+		_, err = bundle.Waitlist.Enqueue(context.Background(), waitlist.Entry{Team: "team-a", PasscodeHash: "hash", JoinCode: joinCode.Code})
+		assert.NoError(t, err)
+		AddRoutes(server, bundle, nil, nil)
+
+		req, _ := http.NewRequest("DELETE", "/balancer/api/admin/waitlist/team-a", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin:operator")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 0, bundle.Waitlist.Len())
+		assert.Empty(t, bundle.JoinCodes.GetJoinCodes()[0].Teams)
+	})
+}
@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/announcements"
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
+)
+
+const maxAnnouncementMessageLength = 1000
+
+type AnnouncementsResponse struct {
+	Announcements []announcements.Announcement `json:"announcements"`
+	// LastUpdate is the time the announcements last changed. Clients pass it back as wait-for-update-after to long-poll for the next change
+	LastUpdate time.Time `json:"lastUpdate"`
+}
+
+type CreateAnnouncementRequest struct {
+	Message  string                 `json:"message"`
+	Severity announcements.Severity `json:"severity"`
+	// Teams the announcement should be shown to. All teams if empty
+	Teams []string `json:"teams"`
+}
+
+func handleAnnouncementsGet(bundle *b.Bundle, announcementService *announcements.AnnouncementService) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			team, err := teamcookie.GetTeamFromRequest(bundle, req)
+			if err != nil {
+				http.Error(responseWriter, "", http.StatusUnauthorized)
+				return
+			}
+
+			if req.URL.Query().Get("wait-for-update-after") != "" {
+				lastSeenUpdate, err := time.Parse(time.RFC3339Nano, req.URL.Query().Get("wait-for-update-after"))
+				if err != nil {
+					http.Error(responseWriter, "Invalid time format", http.StatusBadRequest)
+					return
+				}
+				if !announcementService.WaitForUpdateNewerThan(req.Context(), lastSeenUpdate) {
+					responseWriter.WriteHeader(http.StatusNoContent)
+					responseWriter.Write([]byte{})
+					return
+				}
+			}
+
+			var teamAnnouncements []announcements.Announcement
+			var lastUpdate time.Time
+			if b.IsAdminIdentity(team) {
+				teamAnnouncements, lastUpdate = announcementService.GetAnnouncements()
+			} else {
+				teamAnnouncements, lastUpdate = announcementService.GetAnnouncementsForTeam(team)
+			}
+
+			responseBytes, err := json.Marshal(AnnouncementsResponse{Announcements: teamAnnouncements, LastUpdate: lastUpdate})
+			if err != nil {
+				bundle.Log.Printf("Failed to marshal response: %s", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write(responseBytes)
+		},
+	)
+}
+
+func handleAnnouncementsPost(bundle *b.Bundle, announcementService *announcements.AnnouncementService) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			if req.Body == nil {
+				http.Error(responseWriter, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			var requestBody CreateAnnouncementRequest
+			if err := json.NewDecoder(req.Body).Decode(&requestBody); err != nil {
+				http.Error(responseWriter, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			defer req.Body.Close()
+
+			if requestBody.Message == "" || len(requestBody.Message) > maxAnnouncementMessageLength {
+				http.Error(responseWriter, fmt.Sprintf("message must be between 1 and %d characters long", maxAnnouncementMessageLength), http.StatusBadRequest)
+				return
+			}
+			if requestBody.Severity == "" {
+				requestBody.Severity = announcements.SeverityInfo
+			}
+			if !requestBody.Severity.IsValid() {
+				http.Error(responseWriter, fmt.Sprintf("invalid severity: %s", requestBody.Severity), http.StatusBadRequest)
+				return
+			}
+			for _, targetTeam := range requestBody.Teams {
+				if !isValidTeamName(targetTeam) {
+					http.Error(responseWriter, fmt.Sprintf("invalid team name: %s", targetTeam), http.StatusBadRequest)
+					return
+				}
+			}
+
+			announcement, err := announcementService.CreateAnnouncement(req.Context(), requestBody.Message, requestBody.Severity, requestBody.Teams)
+			if err != nil {
+				bundle.Log.Printf("Failed to create announcement: %s", err)
+				http.Error(responseWriter, "failed to create announcement", http.StatusInternalServerError)
+				return
+			}
+			bundle.Log.Printf("Created announcement '%s' with severity '%s'", announcement.Id, announcement.Severity)
+
+			responseBytes, err := json.Marshal(announcement)
+			if err != nil {
+				bundle.Log.Printf("Failed to marshal response: %s", err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusCreated)
+			responseWriter.Write(responseBytes)
+		},
+	)
+}
+
+func handleAnnouncementsDelete(bundle *b.Bundle, announcementService *announcements.AnnouncementService) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			id := req.PathValue("id")
+			err := announcementService.DeleteAnnouncement(req.Context(), id)
+			if errors.Is(err, announcements.ErrAnnouncementNotFound) {
+				http.Error(responseWriter, "announcement not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Failed to delete announcement '%s': %s", id, err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write([]byte{})
+		},
+	)
+}
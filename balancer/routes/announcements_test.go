@@ -0,0 +1,241 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/announcements"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAnnouncementsHandler(t *testing.T) {
+	createAnnouncementsConfigMap := func(announcementsJson string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      announcements.ConfigMapName,
+				Namespace: "test-namespace",
+			},
+			Data: map[string]string{
+				"announcements.json": announcementsJson,
+			},
+		}
+	}
+
+	t.Run("creating announcements requires admin login", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]string{"message": "hint released"})
+		req, _ := http.NewRequest("POST", "/balancer/api/announcements", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("foobar")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset()
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, announcements.NewAnnouncementService(bundle))
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		// only the session of the team got looked up
+		for _, action := range clientset.Actions() {
+			assert.Equal(t, "get", action.GetVerb())
+			assert.Equal(t, "deployments", action.GetResource().Resource)
+		}
+	})
+
+	t.Run("admins can create announcements which get persisted in a config map", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]any{"message": "event ends in 10 minutes", "severity": "warning"})
+		req, _ := http.NewRequest("POST", "/balancer/api/announcements", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset()
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, announcements.NewAnnouncementService(bundle))
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		configMap, err := clientset.CoreV1().ConfigMaps("test-namespace").Get(context.Background(), announcements.ConfigMapName, metav1.GetOptions{})
+		assert.NoError(t, err)
+
+		var persisted []announcements.Announcement
+		assert.NoError(t, json.Unmarshal([]byte(configMap.Data["announcements.json"]), &persisted))
+		assert.Len(t, persisted, 1)
+		assert.Equal(t, "event ends in 10 minutes", persisted[0].Message)
+		assert.Equal(t, announcements.SeverityWarning, persisted[0].Severity)
+	})
+
+	t.Run("rejects announcements with invalid severity", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]any{"message": "hint released", "severity": "panic"})
+		req, _ := http.NewRequest("POST", "/balancer/api/announcements", bytes.NewReader(jsonPayload))
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset()
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, announcements.NewAnnouncementService(bundle))
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Empty(t, clientset.Actions())
+	})
+
+	t.Run("teams only see announcements targeted at them or all teams", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/announcements", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("foobar")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(createAnnouncementsConfigMap(`[
+			{"id":"1","message":"for everyone","severity":"info","createdAt":"2024-11-01T19:55:48Z"},
+			{"id":"2","message":"for foobar","severity":"info","teams":["foobar"],"createdAt":"2024-11-01T19:56:48Z"},
+			{"id":"3","message":"for barfoo","severity":"info","teams":["barfoo"],"createdAt":"2024-11-01T19:57:48Z"}
+		]`))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		announcementService := announcements.NewAnnouncementService(bundle)
+		assert.NoError(t, announcementService.LoadAnnouncements(context.Background()))
+		AddRoutes(server, bundle, nil, announcementService)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response struct {
+			Announcements json.RawMessage `json:"announcements"`
+			LastUpdate    time.Time       `json:"lastUpdate"`
+		}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.JSONEq(t, `[
+			{"id":"2","message":"for foobar","severity":"info","teams":["foobar"],"createdAt":"2024-11-01T19:56:48Z"},
+			{"id":"1","message":"for everyone","severity":"info","createdAt":"2024-11-01T19:55:48Z"}
+		]`, string(response.Announcements))
+		assert.False(t, response.LastUpdate.IsZero())
+	})
+
+	t.Run("long-polls with the returned lastUpdate get woken up by the next announcement", func(t *testing.T) {
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset()
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		announcementService := announcements.NewAnnouncementService(bundle)
+		AddRoutes(server, bundle, nil, announcementService)
+
+		req, _ := http.NewRequest("GET", "/balancer/api/announcements", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("foobar")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var initialResponse AnnouncementsResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &initialResponse))
+
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			req, _ := http.NewRequest("GET", "/balancer/api/announcements?wait-for-update-after="+url.QueryEscape(initialResponse.LastUpdate.Format(time.RFC3339Nano)), nil)
+			req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("foobar")))
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+			done <- rr
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("long-poll returned before any announcement was created")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		_, err := announcementService.CreateAnnouncement(context.Background(), "hint released", announcements.SeverityInfo, nil)
+		assert.NoError(t, err)
+
+		select {
+		case rr := <-done:
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var response AnnouncementsResponse
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Len(t, response.Announcements, 1)
+			assert.Equal(t, "hint released", response.Announcements[0].Message)
+			assert.True(t, response.LastUpdate.After(initialResponse.LastUpdate))
+		case <-time.After(time.Second):
+			t.Fatal("long-poll didn't return after an announcement was created")
+		}
+	})
+
+	t.Run("long-polls return no content when the request gets canceled before an update", func(t *testing.T) {
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		announcementService := announcements.NewAnnouncementService(bundle)
+		AddRoutes(server, bundle, nil, announcementService)
+		_, lastUpdate := announcementService.GetAnnouncements()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, "GET", "/balancer/api/announcements?wait-for-update-after="+url.QueryEscape(lastUpdate.Format(time.RFC3339Nano)), nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("foobar")))
+		rr := httptest.NewRecorder()
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+	})
+
+	t.Run("fetching announcements requires a team cookie", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/announcements", nil)
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		AddRoutes(server, bundle, nil, announcements.NewAnnouncementService(bundle))
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("admins can delete announcements", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/balancer/api/announcements/1", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(createAnnouncementsConfigMap(`[
+			{"id":"1","message":"for everyone","severity":"info","createdAt":"2024-11-01T19:55:48Z"},
+			{"id":"2","message":"also for everyone","severity":"info","createdAt":"2024-11-01T19:56:48Z"}
+		]`))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		announcementService := announcements.NewAnnouncementService(bundle)
+		AddRoutes(server, bundle, nil, announcementService)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		remainingAnnouncements, _ := announcementService.GetAnnouncements()
+		assert.Len(t, remainingAnnouncements, 1)
+		assert.Equal(t, "2", remainingAnnouncements[0].Id)
+	})
+
+	t.Run("deleting unknown announcements returns 404", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/balancer/api/announcements/does-not-exist", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname("admin")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		bundle := testutil.NewTestBundle()
+		AddRoutes(server, bundle, nil, announcements.NewAnnouncementService(bundle))
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -19,6 +19,7 @@ type SolvedChallenge struct {
 
 type IndividualScore struct {
 	Name             string            `json:"name"`
+	DisplayName      string            `json:"displayName"`
 	Score            int               `json:"score"`
 	SolvedChallenges []SolvedChallenge `json:"solvedChallenges"`
 	Position         int               `json:"position"`
@@ -35,7 +36,7 @@ func handleIndividualScore(bundle *b.Bundle, scoringService *scoring.ScoringServ
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 			user, _ := teamcookie.GetTeamFromRequest(bundle, req)
-			if !bundle.GetScoreOverviewVisibleForUsers() && user != "admin" {
+			if !bundle.GetScoreOverviewVisibleForUsers() && !b.IsAdminIdentity(user) {
 				http.Error(responseWriter, "score board is disabled", http.StatusBadRequest)
 				return
 			}
@@ -67,6 +68,7 @@ func handleIndividualScore(bundle *b.Bundle, scoringService *scoring.ScoringServ
 
 			response := IndividualScore{
 				Name:             team,
+				DisplayName:      teamScore.DisplayName,
 				Score:            teamScore.Score,
 				Position:         teamScore.Position,
 				TotalTeams:       teamCount,
@@ -49,12 +49,12 @@ func TestIndividualScoreHandler(t *testing.T) {
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		scoringService := scoring.NewScoringService(bundle)
 		scoringService.CalculateAndCacheScoreBoard(context.Background())
-		AddRoutes(server, bundle, scoringService)
+		AddRoutes(server, bundle, scoringService, nil)
 
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"solvedAt":"2024-11-01T19:55:48Z"}],"totalTeams":1}`, rr.Body.String())
+		assert.JSONEq(t, `{"name":"foobar","displayName":"foobar","score":10,"position":1,"solvedChallenges":[{"key":"scoreBoardChallenge","name":"Score Board","difficulty":1,"solvedAt":"2024-11-01T19:55:48Z"}],"totalTeams":1}`, rr.Body.String())
 	})
 
 	t.Run("returns a 404 if the scores haven't been calculated yet", func(t *testing.T) {
@@ -65,7 +65,7 @@ func TestIndividualScoreHandler(t *testing.T) {
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		scoringService := scoring.NewScoringService(bundle)
 		scoringService.CalculateAndCacheScoreBoard(context.Background())
-		AddRoutes(server, bundle, scoringService)
+		AddRoutes(server, bundle, scoringService, nil)
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusNotFound, rr.Code)
@@ -78,7 +78,7 @@ func TestIndividualScoreHandler(t *testing.T) {
 		bundle := testutil.NewTestBundle()
 		scoringService := scoring.NewScoringService(bundle)
 		scoringService.CalculateAndCacheScoreBoard(context.Background())
-		AddRoutes(server, bundle, scoringService)
+		AddRoutes(server, bundle, scoringService, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -5,21 +5,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
-	"regexp"
+	"strconv"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/intstr"
-
-	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
-	"github.com/juice-shop/multi-juicer/balancer/pkg/signutil"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/adminpassword"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/backups"
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/joincodes"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/lockout"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/reservations"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamnames"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/waitlist"
 	"github.com/prometheus/client_golang/prometheus"
-	"k8s.io/apimachinery/pkg/api/errors"
 )
 
 var loginCounter = prometheus.NewCounterVec(
@@ -42,36 +45,102 @@ func init() {
 	prometheus.MustRegister(failedLoginCounter)
 }
 
-func handleTeamJoin(bundle *bundle.Bundle) http.Handler {
+func handleTeamJoin(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		team := r.PathValue("team")
 
-		if team == "admin" {
+		if team == teamnames.AdminTeamName {
 			handleAdminLogin(bundle, w, r)
 			return
 		}
 
-		deployment, err := getDeployment(r.Context(), bundle, team)
-		if err != nil && errors.IsNotFound(err) {
-			isMaxLimitReached, err := isMaxInstanceLimitReached(r.Context(), bundle)
+		instance, err := bundle.Instances.Get(r.Context(), team)
+		if err == b.ErrInstanceNotFound {
+			if !isValidTeamName(team) {
+				http.Error(w, "invalid team name", http.StatusBadRequest)
+				return
+			}
+			if err := bundle.TeamNamePolicy.CheckTeamName(team); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if entry, _, ok := bundle.Waitlist.Get(team); ok {
+				joinWaitlistedTeam(bundle, team, entry, w, r)
+				return
+			}
+			backup, err := bundle.ProgressBackups.Get(r.Context(), team)
+			if err == nil {
+				joinBackedUpTeam(bundle, team, backup, w, r)
+				return
+			} else if err != backups.ErrBackupNotFound {
+				bundle.Log.Printf("Failed to get progress backup of team '%s': %s", team, err)
+				http.Error(w, "failed to get instance", http.StatusInternalServerError)
+				return
+			}
+			requestBody, memberName, err := readNewTeamRequestBody(r)
 			if err != nil {
-				http.Error(w, "failed to check max instance limit", http.StatusInternalServerError)
+				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
-			} else if isMaxLimitReached {
-				bundle.Log.Printf("Max instance limit reached! Cannot create any more new teams. Increase the count via the helm values or delete existing teams.")
-				http.Error(w, `{"message":"Reached Maximum Instance Count","description":"Find an admin to handle this."}`, http.StatusInternalServerError)
+			}
+			displayName := ""
+			if requestBody.DisplayName != "" {
+				var ok bool
+				displayName, ok = teamnames.NormalizeDisplayName(requestBody.DisplayName)
+				if !ok {
+					http.Error(w, teamnames.ErrInvalidDisplayName.Error(), http.StatusBadRequest)
+					return
+				}
+				if err := bundle.TeamNamePolicy.CheckDisplayName(displayName); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
+			// the join code is redeemed before reserving an instance, so that requests without a valid join code can't hold reservations
+			joinCode := ""
+			if bundle.JoinCodes.IsRequired() {
+				joinCode = joincodes.NormalizeCode(requestBody.JoinCode)
+				if !redeemJoinCode(bundle, team, joinCode, w, r) {
+					return
+				}
+			}
+
+			// teams already on the waitlist get their instances first, so new teams have to queue up behind them
+			mustWait := bundle.Config.Waitlist.Enabled && bundle.Waitlist.Len() > 0
+			if !mustWait {
+				err := bundle.InstanceReservations.Reserve(r.Context(), team, bundle.Config.MaxInstances)
+				if err == reservations.ErrMaxInstancesReached && bundle.Config.Waitlist.Enabled {
+					mustWait = true
+				} else if err == reservations.ErrMaxInstancesReached {
+					releaseJoinCode(r.Context(), bundle, joinCode, team)
+					bundle.Log.Printf("Max instance limit reached! Cannot create any more new teams. Increase the count via the helm values or delete existing teams.")
+					http.Error(w, `{"message":"Reached Maximum Instance Count","description":"Find an admin to handle this."}`, http.StatusInternalServerError)
+					return
+				} else if err != nil {
+					releaseJoinCode(r.Context(), bundle, joinCode, team)
+					bundle.Log.Printf("Failed to reserve instance for team '%s': %s", team, err)
+					http.Error(w, "failed to check max instance limit", http.StatusInternalServerError)
+					return
+				} else {
+					// once the team got created it is counted as existing instance, so the reservation isn't needed anymore
+					defer releaseInstanceReservation(bundle, team)
+				}
+			}
+
+			if mustWait {
+				joinWaitlist(r.Context(), bundle, team, displayName, memberName, joinCode, w)
 				return
 			}
-			createANewTeam(r.Context(), bundle, team, w)
+			createANewTeam(r.Context(), bundle, team, displayName, memberName, joinCode, w)
 		} else if err == nil {
-			joinExistingTeam(bundle, team, deployment, w, r)
+			joinExistingTeam(bundle, team, instance, w, r)
 		} else {
-			http.Error(w, "failed to get deployment", http.StatusInternalServerError)
+			http.Error(w, "failed to get instance", http.StatusInternalServerError)
 		}
 	})
 }
 
-func handleAdminLogin(bundle *bundle.Bundle, w http.ResponseWriter, r *http.Request) {
+func handleAdminLogin(bundle *b.Bundle, w http.ResponseWriter, r *http.Request) {
 	if r.Body == nil {
 		writeUnauthorizedResponse(w)
 		return
@@ -88,13 +157,31 @@ func handleAdminLogin(bundle *bundle.Bundle, w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if requestBody.Passcode != bundle.Config.AdminConfig.Password {
+	// admin logins are only locked by client ip. locking the admin accounts themselves would allow anybody to lock out the admins
+	lockoutKeys := []lockout.Key{lockout.ClientIPKey(bundle.ClientIPs.ClientIP(r))}
+	if retryAfter := bundle.LoginLockouts.RetryAfter(lockoutKeys...); retryAfter > 0 {
+		writeTooManyRequestsResponse(w, retryAfter)
+		return
+	}
+
+	accountName := b.DefaultAdminAccountName
+	if requestBody.Account != "" && requestBody.Account != b.DefaultAdminAccountName {
+		account, ok := bundle.Config.AdminConfig.GetAdminAccount(requestBody.Account)
+		if !ok || !adminpassword.Verify(account.Password, requestBody.Passcode) {
+			failedLoginCounter.WithLabelValues("admin").Inc()
+			bundle.LoginLockouts.RecordFailure(lockoutKeys...)
+			writeUnauthorizedResponse(w)
+			return
+		}
+		accountName = account.Name
+	} else if !adminpassword.Verify(bundle.Config.AdminConfig.Password, requestBody.Passcode) {
 		failedLoginCounter.WithLabelValues("admin").Inc()
+		bundle.LoginLockouts.RecordFailure(lockoutKeys...)
 		writeUnauthorizedResponse(w)
 		return
 	}
 
-	err = setSignedTeamCookie(bundle, "admin", w)
+	err = setSignedTeamCookie(bundle, b.AdminIdentity(accountName), 0, "", w)
 	if err != nil {
 		http.Error(w, "failed to sign team cookie", http.StatusInternalServerError)
 		return
@@ -106,70 +193,197 @@ func handleAdminLogin(bundle *bundle.Bundle, w http.ResponseWriter, r *http.Requ
 	loginCounter.WithLabelValues("login", "admin").Inc()
 }
 
-func getDeployment(context context.Context, bundle *bundle.Bundle, team string) (*appsv1.Deployment, error) {
-	return bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(
-		context,
-		fmt.Sprintf("juiceshop-%s", team),
-		metav1.GetOptions{},
-	)
+func isValidTeamName(s string) bool {
+	return teamnames.MatchesPattern(s) && len(s) <= 16
 }
 
-var teamNamePatternString = "[a-z0-9]([-a-z0-9])+[a-z0-9]"
-var validTeamnamePattern = regexp.MustCompile("^" + teamNamePatternString + "$")
+// releaseInstanceReservation is called after the team creation either succeeded or failed. Uses a fresh context, as the reservation has to be released even if the request got canceled
+func releaseInstanceReservation(bundle *b.Bundle, team string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := bundle.InstanceReservations.Release(ctx, team); err != nil {
+		bundle.Log.Printf("Failed to release instance reservation of team '%s': %s", team, err)
+	}
+}
 
-func isValidTeamName(s string) bool {
-	matched := validTeamnamePattern.MatchString(s)
-	return matched && len(s) <= 16
+// reads the optional body of a request creating a new team. The returned member name is empty if the creator doesn't want to register as member
+func readNewTeamRequestBody(r *http.Request) (joinRequestBody, string, error) {
+	var requestBody joinRequestBody
+	if r.Body == nil {
+		return requestBody, "", nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		return requestBody, "", nil
+	}
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		return requestBody, "", fmt.Errorf("invalid json")
+	}
+	if requestBody.MemberName == "" {
+		return requestBody, "", nil
+	}
+	memberName, ok := teamcookie.NormalizeMemberName(requestBody.MemberName)
+	if !ok {
+		return requestBody, "", fmt.Errorf("member name must be between 1 and %d characters long", teamcookie.MaxMemberNameLength)
+	}
+	return requestBody, memberName, nil
 }
 
-func isMaxInstanceLimitReached(context context.Context, bundle *bundle.Bundle) (bool, error) {
-	deployments, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).List(context, metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
-	})
-	if err != nil {
-		return false, fmt.Errorf("failed to list deployments: %w", err)
+// redeemJoinCode uses up one team of the quota of the join code. Writes the error response and returns false if the code is missing, unknown or used up.
+// Wrong codes are tracked like failed logins to prevent guessing valid codes
+func redeemJoinCode(bundle *b.Bundle, team string, joinCode string, w http.ResponseWriter, r *http.Request) bool {
+	lockoutKeys := []lockout.Key{lockout.ClientIPKey(bundle.ClientIPs.ClientIP(r))}
+	if retryAfter := bundle.LoginLockouts.RetryAfter(lockoutKeys...); retryAfter > 0 {
+		writeTooManyRequestsResponse(w, retryAfter)
+		return false
+	}
+	if joinCode == "" {
+		writeJoinCodeRequiredResponse(w, "A join code is required to create a new team")
+		return false
+	}
+
+	err := bundle.JoinCodes.Redeem(r.Context(), joinCode, team)
+	if err == joincodes.ErrJoinCodeNotFound {
+		failedLoginCounter.WithLabelValues("joinCode").Inc()
+		bundle.LoginLockouts.RecordFailure(lockoutKeys...)
+		writeJoinCodeRequiredResponse(w, "Invalid join code")
+		return false
+	} else if err == joincodes.ErrJoinCodeExhausted {
+		writeJoinCodeRequiredResponse(w, "The join code has been used up")
+		return false
+	} else if err == joincodes.ErrNoJoinCodes {
+		// join codes are required, but none exist yet or they couldn't be loaded. Failing closed until an admin created one
+		writeJoinCodeRequiredResponse(w, "No join codes are available yet. Ask the organizers for one")
+		return false
+	} else if err != nil {
+		bundle.Log.Printf("Failed to redeem join code for team '%s': %s", team, err)
+		http.Error(w, "failed to redeem join code", http.StatusInternalServerError)
+		return false
 	}
-	return len(deployments.Items)+1 >= bundle.Config.MaxInstances, nil
+	return true
+}
+
+func writeJoinCodeRequiredResponse(w http.ResponseWriter, message string) {
+	responseBody, _ := json.Marshal(map[string]string{"message": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write(responseBody)
 }
 
-func createANewTeam(context context.Context, bundle *bundle.Bundle, team string, w http.ResponseWriter) {
-	if !isValidTeamName(team) {
-		http.Error(w, "invalid team name", http.StatusBadRequest)
+// createANewTeam creates the team and signs the creator in. The join code used to create the team, if any, gets released again if the creation fails
+func createANewTeam(context context.Context, bundle *b.Bundle, team string, displayName string, memberName string, joinCode string, w http.ResponseWriter) {
+	initialMembers, memberID, err := newInitialMembers(memberName)
+	if err != nil {
+		http.Error(w, "failed to register member", http.StatusInternalServerError)
 		return
 	}
 
-	passcode, passcodeHash, err := generatePasscode(bundle)
+	// the cookie is signed before the team gets created, so that nothing can fail anymore once the team exists.
+	// new teams start with the initial session generation
+	cookie, err := newTeamCookie(bundle, team, 0, memberID)
 	if err != nil {
-		bundle.Log.Printf("Failed to hash passcode!: %s", err)
-		http.Error(w, "failed to generate passcode", http.StatusInternalServerError)
+		releaseJoinCode(context, bundle, joinCode, team)
+		http.Error(w, "failed to sign team cookie", http.StatusInternalServerError)
+		return
+	}
+
+	passcode, err := provisionTeam(context, bundle, team, displayName, initialMembers)
+	if err == errTeamAlreadyCreated {
+		releaseJoinCode(context, bundle, joinCode, team)
+		http.Error(w, `{"message":"Team already exists","description":"The team got created by somebody else in the meantime. Join it with its passcode instead."}`, http.StatusConflict)
+		return
+	} else if err != nil {
+		releaseJoinCode(context, bundle, joinCode, team)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = createDeploymentForTeam(context, bundle, team, passcodeHash)
+	http.SetCookie(w, cookie)
+	sendSuccessResponse(w, "Created Instance", passcode)
+	loginCounter.WithLabelValues("registration", "user").Inc()
+}
+
+// joinWaitlist queues the team up to get its instance once capacity frees up. The passcode is handed out right away, so that the team can already sign in and see its position on the waitlist
+func joinWaitlist(context context.Context, bundle *b.Bundle, team string, displayName string, memberName string, joinCode string, w http.ResponseWriter) {
+	initialMembers, memberID, err := newInitialMembers(memberName)
 	if err != nil {
-		bundle.Log.Printf("Failed to create deployment: %s", err)
-		http.Error(w, "failed to create deployment", http.StatusInternalServerError)
+		http.Error(w, "failed to register member", http.StatusInternalServerError)
+		return
+	}
+	passcode, passcodeHash, err := generatePasscode(bundle)
+	if err != nil {
+		bundle.Log.Printf("Failed to hash passcode!: %s", err)
+		releaseJoinCode(context, bundle, joinCode, team)
+		http.Error(w, "failed to generate passcode", http.StatusInternalServerError)
 		return
 	}
 
-	err = createServiceForTeam(context, bundle, team)
+	position, err := bundle.Waitlist.Enqueue(context, waitlist.Entry{
+		Team:         team,
+		DisplayName:  displayName,
+		PasscodeHash: passcodeHash,
+		Members:      initialMembers,
+		JoinCode:     joinCode,
+		JoinedAt:     time.Now(),
+	})
 	if err != nil {
-		bundle.Log.Printf("Failed to create service: %s", err)
-		http.Error(w, "failed to create service", http.StatusInternalServerError)
+		releaseJoinCode(context, bundle, joinCode, team)
+	}
+	if err == waitlist.ErrAlreadyWaiting {
+		// the team just joined the waitlist via another request, so it has to be joined with its passcode
+		writeUnauthorizedResponse(w)
+		return
+	} else if err == waitlist.ErrWaitlistFull {
+		bundle.Log.Printf("Max instance limit reached and the waitlist is full! Cannot add any more teams to the waitlist.")
+		http.Error(w, `{"message":"Reached Maximum Instance Count","description":"The waitlist is full as well. Find an admin to handle this."}`, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		bundle.Log.Printf("Failed to add team '%s' to the waitlist: %s", team, err)
+		http.Error(w, "failed to join waitlist", http.StatusInternalServerError)
 		return
 	}
+	bundle.Log.Printf("Max instance limit reached. Team '%s' joined the waitlist at position %d", team, position)
 
-	err = setSignedTeamCookie(bundle, team, w)
+	err = setSignedTeamCookie(bundle, team, 0, memberID, w)
 	if err != nil {
 		http.Error(w, "failed to sign team cookie", http.StatusInternalServerError)
 		return
 	}
 
-	sendSuccessResponse(w, "Created Instance", passcode)
+	responseBody, _ := json.Marshal(map[string]any{
+		"message":          "Joined Waitlist",
+		"passcode":         passcode,
+		"waitlistPosition": position,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(responseBody)
 	loginCounter.WithLabelValues("registration", "user").Inc()
 }
 
-func generatePasscode(bundle *bundle.Bundle) (string, string, error) {
+// newInitialMembers registers the creator of a new team as first member. Returns no members if the creator doesn't want to register as member
+func newInitialMembers(memberName string) ([]sessions.Member, string, error) {
+	if memberName == "" {
+		return []sessions.Member{}, "", nil
+	}
+	member, err := teamcookie.NewMember(memberName)
+	if err != nil {
+		return nil, "", err
+	}
+	return []sessions.Member{member}, member.ID, nil
+}
+
+// releaseJoinCode gives back the quota used by a team which couldn't be created after all
+func releaseJoinCode(context context.Context, bundle *b.Bundle, joinCode string, team string) {
+	if joinCode == "" {
+		return
+	}
+	if err := bundle.JoinCodes.Release(context, joinCode, team); err != nil {
+		bundle.Log.Printf("Failed to release join code after failed team creation: %s", err)
+	}
+}
+
+func generatePasscode(bundle *b.Bundle) (string, string, error) {
 	passcode := bundle.GeneratePasscode()
 	hashBytes, err := bcrypt.GenerateFromPassword([]byte(passcode), bundle.BcryptRounds)
 	if err != nil {
@@ -178,20 +392,29 @@ func generatePasscode(bundle *bundle.Bundle) (string, string, error) {
 	return passcode, string(hashBytes), nil
 }
 
-func setSignedTeamCookie(bundle *bundle.Bundle, team string, w http.ResponseWriter) error {
-	cookieValue, err := signutil.Sign(team, bundle.Config.CookieConfig.SigningKey)
+func setSignedTeamCookie(bundle *b.Bundle, team string, sessionGeneration int64, memberID string, w http.ResponseWriter) error {
+	cookie, err := newTeamCookie(bundle, team, sessionGeneration, memberID)
 	if err != nil {
 		return err
 	}
-	http.SetCookie(w, &http.Cookie{
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+func newTeamCookie(bundle *b.Bundle, team string, sessionGeneration int64, memberID string) (*http.Cookie, error) {
+	cookieValue, err := teamcookie.CreateCookieValue(bundle, team, sessionGeneration, memberID)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Cookie{
 		Name:     bundle.Config.CookieConfig.Name,
 		Value:    cookieValue,
 		HttpOnly: true,
 		Path:     "/",
+		MaxAge:   int(bundle.Config.CookieConfig.GetMaxAge().Seconds()),
 		SameSite: http.SameSiteStrictMode,
 		Secure:   bundle.Config.CookieConfig.Secure,
-	})
-	return nil
+	}, nil
 }
 
 func sendSuccessResponse(w http.ResponseWriter, message, passcode string) {
@@ -206,22 +429,83 @@ func sendSuccessResponse(w http.ResponseWriter, message, passcode string) {
 
 type joinRequestBody struct {
 	Passcode string `json:"passcode"`
+	// Account is only used for admin logins to select a named admin account. The default admin account is used if empty
+	Account string `json:"account"`
+	// JoinCode is required to create new teams as long as join codes are configured
+	JoinCode string `json:"joinCode"`
+	// DisplayName is an optional free-form name shown instead of the team name, only used when creating a new team
+	DisplayName string `json:"displayName"`
+	// MemberName optionally registers the joining person as member of the team with the given display name
+	MemberName string `json:"memberName"`
 }
 
-func joinExistingTeam(bundle *bundle.Bundle, team string, deployment *appsv1.Deployment, w http.ResponseWriter, r *http.Request) {
-	passCodeHashToMatch := deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"]
+func joinExistingTeam(bundle *b.Bundle, team string, instance *b.Instance, w http.ResponseWriter, r *http.Request) {
+	passCodeHashToMatch := instance.Annotations[passcodeAnnotation]
 	if passCodeHashToMatch == "" {
 		http.Error(w, "failed to get passcode", http.StatusInternalServerError)
 		return
 	}
+	addMember := func(ctx context.Context, memberName string) (*sessions.Member, int64, error) {
+		return teamcookie.AddMember(ctx, bundle, team, memberName)
+	}
+	joinTeamWithPasscode(bundle, team, passCodeHashToMatch, sessions.ParseGeneration(instance.Annotations), nil, addMember, w, r)
+}
+
+// joinWaitlistedTeam signs in to a team which is still waiting for its instance with the passcode handed out when the team joined the waitlist
+func joinWaitlistedTeam(bundle *b.Bundle, team string, entry waitlist.Entry, w http.ResponseWriter, r *http.Request) {
+	addMember := func(ctx context.Context, memberName string) (*sessions.Member, int64, error) {
+		member, err := teamcookie.NewMember(memberName)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := bundle.Waitlist.AddMember(ctx, team, member, teamcookie.MaxMembersPerTeam); err != nil {
+			return nil, 0, err
+		}
+		// waiting teams always have the initial session generation, as their passcode can't be reset yet
+		return &member, 0, nil
+	}
+	joinTeamWithPasscode(bundle, team, entry.PasscodeHash, 0, nil, addMember, w, r)
+}
+
+// joinBackedUpTeam signs in to a team whose instance got deleted by the cleaner. Once the team proved to be the same team with its passcode, its instance gets recreated with the progress from the backup
+func joinBackedUpTeam(bundle *b.Bundle, team string, backup *backups.Backup, w http.ResponseWriter, r *http.Request) {
+	restore := func(ctx context.Context) error {
+		return restoreTeam(ctx, bundle, team, backup)
+	}
+	addMember := func(ctx context.Context, memberName string) (*sessions.Member, int64, error) {
+		return teamcookie.AddMember(ctx, bundle, team, memberName)
+	}
+	joinTeamWithPasscode(bundle, team, backup.PasscodeHash, restoredSessionGeneration(backup), restore, addMember, w, r)
+}
+
+// joinTeamWithPasscode checks the passcode of the request against the hash and signs in to the team, optionally registering the joining person as member via addMember.
+// The optional beforeSignIn is called once the passcode is verified, its errors are safe to be shown to the user
+func joinTeamWithPasscode(
+	bundle *b.Bundle,
+	team string,
+	passCodeHashToMatch string,
+	sessionGeneration int64,
+	beforeSignIn func(ctx context.Context) error,
+	addMember func(ctx context.Context, memberName string) (*sessions.Member, int64, error),
+	w http.ResponseWriter,
+	r *http.Request,
+) {
 	if r.Body == nil {
 		// this not a failed login, but just a failed "team creation" for a already existing team, so we don't increment the counter
 		writeUnauthorizedResponse(w)
 		return
 	}
+
+	clientIP := bundle.ClientIPs.ClientIP(r)
+	if retryAfter := bundle.LoginLockouts.TeamRetryAfter(team, clientIP); retryAfter > 0 {
+		writeTooManyRequestsResponse(w, retryAfter)
+		return
+	}
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil || len(body) == 0 {
 		failedLoginCounter.WithLabelValues("user").Inc()
+		bundle.LoginLockouts.RecordTeamFailure(team, clientIP)
 		writeUnauthorizedResponse(w)
 		return
 	}
@@ -232,14 +516,48 @@ func joinExistingTeam(bundle *bundle.Bundle, team string, deployment *appsv1.Dep
 		return
 	}
 
+	memberName := ""
+	if requestBody.MemberName != "" {
+		var ok bool
+		memberName, ok = teamcookie.NormalizeMemberName(requestBody.MemberName)
+		if !ok {
+			http.Error(w, fmt.Sprintf("member name must be between 1 and %d characters long", teamcookie.MaxMemberNameLength), http.StatusBadRequest)
+			return
+		}
+	}
+
 	passcode := requestBody.Passcode
 	if bcrypt.CompareHashAndPassword([]byte(passCodeHashToMatch), []byte(passcode)) != nil {
 		failedLoginCounter.WithLabelValues("user").Inc()
+		bundle.LoginLockouts.RecordTeamFailure(team, clientIP)
 		writeUnauthorizedResponse(w)
 		return
 	}
+	bundle.LoginLockouts.RecordTeamSuccess(team, clientIP)
+
+	if beforeSignIn != nil {
+		if err := beforeSignIn(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	memberID := ""
+	if memberName != "" {
+		member, generation, err := addMember(r.Context(), memberName)
+		if err == teamcookie.ErrTooManyMembers || err == waitlist.ErrTooManyMembers {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			bundle.Log.Printf("Failed to register member for team '%s': %s", team, err)
+			http.Error(w, "failed to register member", http.StatusInternalServerError)
+			return
+		}
+		sessionGeneration = generation
+		memberID = member.ID
+	}
 
-	err = setSignedTeamCookie(bundle, team, w)
+	err = setSignedTeamCookie(bundle, team, sessionGeneration, memberID, w)
 	if err != nil {
 		http.Error(w, "failed to sign team cookie", http.StatusInternalServerError)
 		return
@@ -254,6 +572,19 @@ func sendJoinedResponse(w http.ResponseWriter) {
 	w.Write([]byte(`{"message": "Joined Team"}`))
 }
 
+// Helper function to write a 429 Too Many Requests response including the Retry-After header
+func writeTooManyRequestsResponse(responseWriter http.ResponseWriter, retryAfter time.Duration) {
+	retryAfterSeconds := int(math.Ceil(retryAfter.Seconds()))
+	errorResponseBody, _ := json.Marshal(map[string]any{
+		"message":    "Too many failed login attempts, try again later",
+		"retryAfter": retryAfterSeconds,
+	})
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+	responseWriter.WriteHeader(http.StatusTooManyRequests)
+	responseWriter.Write(errorResponseBody)
+}
+
 // Helper function to write a 401 Unauthorized response
 func writeUnauthorizedResponse(responseWriter http.ResponseWriter) {
 	errorResponseBody, _ := json.Marshal(map[string]string{"message": "Team requires authentication to join"})
@@ -261,218 +592,3 @@ func writeUnauthorizedResponse(responseWriter http.ResponseWriter) {
 	responseWriter.Header().Set("Content-Type", "application/json")
 	responseWriter.Write(errorResponseBody)
 }
-
-// uid of the balancer kubernetes deployment resource. used to "attach" created juice shop deployments and services to the balancer deployment so that they get deleted when the balancer gets deleted
-var deploymentUid types.UID
-
-func getOwnerReferences(context context.Context, bundle *bundle.Bundle) ([]metav1.OwnerReference, error) {
-	if deploymentUid == "" {
-		balancerDeployment, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(
-			context,
-			"balancer",
-			metav1.GetOptions{},
-		)
-		deploymentUid = balancerDeployment.ObjectMeta.UID
-		if err != nil {
-			return nil, fmt.Errorf("failed to get balancer deployment to attach correct owner reference to start juice shop: %w", err)
-		}
-	}
-
-	truePointer := true
-	ownerReferences := []metav1.OwnerReference{
-		{
-			APIVersion:         "apps/v1",
-			Kind:               "Deployment",
-			Name:               "balancer",
-			UID:                deploymentUid,
-			Controller:         &truePointer,
-			BlockOwnerDeletion: &truePointer,
-		},
-	}
-	return ownerReferences, nil
-}
-
-func createDeploymentForTeam(context context.Context, bundle *bundle.Bundle, team string, passcodeHash string) error {
-	ownerReferences, err := getOwnerReferences(context, bundle)
-	if err != nil {
-		return err
-	}
-
-	podLabels := map[string]string{}
-	if bundle.Config.JuiceShopConfig.JuiceShopPodConfig.Labels != nil {
-		podLabels = bundle.Config.JuiceShopConfig.JuiceShopPodConfig.Labels
-	}
-	podLabels["team"] = team
-	podLabels["app.kubernetes.io/version"] = bundle.Config.JuiceShopConfig.Tag
-	podLabels["app.kubernetes.io/name"] = "juice-shop"
-	podLabels["app.kubernetes.io/part-of"] = "multi-juicer"
-
-	podAnnotations := map[string]string{}
-	if bundle.Config.JuiceShopConfig.JuiceShopPodConfig.Annotations != nil {
-		podAnnotations = bundle.Config.JuiceShopConfig.JuiceShopPodConfig.Annotations
-	}
-
-	deployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("juiceshop-%s", team),
-			Labels: map[string]string{
-				"team":                        team,
-				"app.kubernetes.io/version":   bundle.Config.JuiceShopConfig.Tag,
-				"app.kubernetes.io/component": "vulnerable-app",
-				"app.kubernetes.io/name":      "juice-shop",
-				"app.kubernetes.io/instance":  fmt.Sprintf("juice-shop-%s", team),
-				"app.kubernetes.io/part-of":   "multi-juicer",
-			},
-			Annotations: map[string]string{
-				"multi-juicer.owasp-juice.shop/lastRequest":         fmt.Sprintf("%d", time.Now().UnixMilli()),
-				"multi-juicer.owasp-juice.shop/lastRequestReadable": time.Now().String(),
-				"multi-juicer.owasp-juice.shop/passcode":            passcodeHash,
-				"multi-juicer.owasp-juice.shop/challengesSolved":    "0",
-				"multi-juicer.owasp-juice.shop/challenges":          "[]",
-			},
-			OwnerReferences: ownerReferences,
-		},
-		Spec: appsv1.DeploymentSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"team":                      team,
-					"app.kubernetes.io/name":    "juice-shop",
-					"app.kubernetes.io/part-of": "multi-juicer",
-				},
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels:      podLabels,
-					Annotations: podAnnotations,
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:            "juice-shop",
-							Image:           fmt.Sprintf("%s:%s", bundle.Config.JuiceShopConfig.Image, bundle.Config.JuiceShopConfig.Tag),
-							SecurityContext: &bundle.Config.JuiceShopConfig.ContainerSecurityContext,
-							Resources:       bundle.Config.JuiceShopConfig.Resources,
-							Ports: []corev1.ContainerPort{
-								{
-									ContainerPort: 3000,
-								},
-							},
-							StartupProbe: &corev1.Probe{
-								ProbeHandler: corev1.ProbeHandler{
-									HTTPGet: &corev1.HTTPGetAction{
-										Path: "/rest/admin/application-version",
-										Port: intstr.FromInt(3000),
-									},
-								},
-								PeriodSeconds:    2,
-								FailureThreshold: 150,
-							},
-							ReadinessProbe: &corev1.Probe{
-								ProbeHandler: corev1.ProbeHandler{
-									HTTPGet: &corev1.HTTPGetAction{
-										Path: "/rest/admin/application-version",
-										Port: intstr.FromInt(3000),
-									},
-								},
-								PeriodSeconds:    5,
-								FailureThreshold: 3,
-							},
-							LivenessProbe: &corev1.Probe{
-								ProbeHandler: corev1.ProbeHandler{
-									HTTPGet: &corev1.HTTPGetAction{
-										Path: "/rest/admin/application-version",
-										Port: intstr.FromInt(3000),
-									},
-								},
-								InitialDelaySeconds: 30,
-								PeriodSeconds:       15,
-							},
-							Env: append(
-								bundle.Config.JuiceShopConfig.Env,
-								corev1.EnvVar{
-									Name:  "NODE_ENV",
-									Value: bundle.Config.JuiceShopConfig.NodeEnv,
-								},
-								corev1.EnvVar{
-									Name:  "CTF_KEY",
-									Value: bundle.Config.JuiceShopConfig.CtfKey,
-								},
-								corev1.EnvVar{
-									Name:  "SOLUTIONS_WEBHOOK",
-									Value: fmt.Sprintf("http://progress-watchdog.%s.svc/team/%s/webhook", bundle.RuntimeEnvironment.Namespace, team),
-								},
-							),
-							EnvFrom: bundle.Config.JuiceShopConfig.EnvFrom,
-							VolumeMounts: append(
-								bundle.Config.JuiceShopConfig.VolumeMounts,
-								corev1.VolumeMount{
-									Name:      "juice-shop-config",
-									MountPath: "/juice-shop/config/multi-juicer.yaml",
-									ReadOnly:  true,
-									SubPath:   "multi-juicer.yaml",
-								},
-							),
-						},
-					},
-					Volumes: append(
-						bundle.Config.JuiceShopConfig.Volumes,
-						corev1.Volume{
-							Name: "juice-shop-config",
-							VolumeSource: corev1.VolumeSource{
-								ConfigMap: &corev1.ConfigMapVolumeSource{
-									LocalObjectReference: corev1.LocalObjectReference{
-										Name: "juice-shop-config",
-									},
-								},
-							},
-						},
-					),
-					ImagePullSecrets: bundle.Config.JuiceShopConfig.ImagePullSecrets,
-					Tolerations:      bundle.Config.JuiceShopConfig.Tolerations,
-					Affinity:         &bundle.Config.JuiceShopConfig.Affinity,
-					RuntimeClassName: bundle.Config.JuiceShopConfig.RuntimeClassName,
-					SecurityContext:  &bundle.Config.JuiceShopConfig.PodSecurityContext,
-				},
-			},
-		},
-	}
-
-	_, err = bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Create(context, deployment, metav1.CreateOptions{})
-	return err
-}
-
-func createServiceForTeam(context context.Context, bundle *bundle.Bundle, team string) error {
-	ownerReferences, err := getOwnerReferences(context, bundle)
-	if err != nil {
-		return err
-	}
-
-	service := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("juiceshop-%s", team),
-			Labels: map[string]string{
-				"team":                        team,
-				"app.kubernetes.io/version":   bundle.Config.JuiceShopConfig.Tag,
-				"app.kubernetes.io/name":      "juice-shop",
-				"app.kubernetes.io/component": "vulnerable-app",
-				"app.kubernetes.io/instance":  fmt.Sprintf("juice-shop-%s", team),
-				"app.kubernetes.io/part-of":   "multi-juicer",
-			},
-			OwnerReferences: ownerReferences,
-		},
-		Spec: corev1.ServiceSpec{
-			Selector: map[string]string{
-				"team":                   team,
-				"app.kubernetes.io/name": "juice-shop",
-			},
-			Ports: []corev1.ServicePort{
-				{
-					Port: 3000,
-				},
-			},
-		},
-	}
-
-	_, err = bundle.ClientSet.CoreV1().Services(bundle.RuntimeEnvironment.Namespace).Create(context, service, metav1.CreateOptions{})
-	return err
-}
@@ -8,11 +8,20 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/juice-shop/multi-juicer/balancer/pkg/clientip"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/waitlist"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/fake"
@@ -62,7 +71,7 @@ func TestJoinHandler(t *testing.T) {
 		clientset := fake.NewSimpleClientset(balancerDeployment)
 
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -76,10 +85,21 @@ func TestJoinHandler(t *testing.T) {
 		assert.Equal(t, schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, actions[actionCounter].GetResource())
 		actionCounter++
 
-		// should then list deployments to get the current count of deployments
+		// then check if the team has a backup of its progress to restore
+		assert.Equal(t, "get", actions[actionCounter].GetVerb())
+		assert.Equal(t, schema.GroupVersionResource{Group: "", Version: "v1", Resource: "configmaps"}, actions[actionCounter].GetResource())
+		actionCounter++
+
+		// should then reserve an instance, reading the pending reservations and listing deployments to get the current count of deployments
+		assert.Equal(t, "get", actions[actionCounter].GetVerb())
+		assert.Equal(t, schema.GroupVersionResource{Group: "", Version: "v1", Resource: "configmaps"}, actions[actionCounter].GetResource())
+		actionCounter++
 		assert.Equal(t, "list", actions[actionCounter].GetVerb())
 		assert.Equal(t, schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, actions[actionCounter].GetResource())
 		actionCounter++
+		assert.Equal(t, "create", actions[actionCounter].GetVerb())
+		assert.Equal(t, schema.GroupVersionResource{Group: "", Version: "v1", Resource: "configmaps"}, actions[actionCounter].GetResource())
+		actionCounter++
 
 		// then get the deployment uid of the balancer
 		assert.Equal(t, "get", actions[actionCounter].GetVerb())
@@ -94,7 +114,15 @@ func TestJoinHandler(t *testing.T) {
 		assert.Equal(t, schema.GroupVersionResource{Group: "", Version: "v1", Resource: "services"}, actions[actionCounter].GetResource())
 		actionCounter++
 
-		assert.Regexp(t, regexp.MustCompile(`team=foobar\..*; Path=/; HttpOnly; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
+		// and finally release the reservation
+		assert.Equal(t, "get", actions[actionCounter].GetVerb())
+		assert.Equal(t, schema.GroupVersionResource{Group: "", Version: "v1", Resource: "configmaps"}, actions[actionCounter].GetResource())
+		actionCounter++
+		assert.Equal(t, "update", actions[actionCounter].GetVerb())
+		assert.Equal(t, schema.GroupVersionResource{Group: "", Version: "v1", Resource: "configmaps"}, actions[actionCounter].GetResource())
+		actionCounter++
+
+		assert.Regexp(t, regexp.MustCompile(`team=foobar\|\d+\|\d+\|0\|\|default\..*; Path=/; Max-Age=604800; HttpOnly; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
 		assert.JSONEq(t, `{"message":"Created Instance","passcode":"12345678"}`, rr.Body.String())
 
 		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
@@ -112,6 +140,20 @@ func TestJoinHandler(t *testing.T) {
 			},
 		}, deployment.OwnerReferences)
 
+		webhookSecret := deployment.Annotations["multi-juicer.owasp-juice.shop/webhookSecret"]
+		assert.Len(t, webhookSecret, 64)
+		containers := deployment.Spec.Template.Spec.Containers
+		assert.Len(t, containers, 2)
+		// the juice shop sends its webhooks to the signer sidecar and never gets to see the key
+		assert.Contains(t, containers[0].Env, corev1.EnvVar{Name: "SOLUTIONS_WEBHOOK", Value: "http://127.0.0.1:8081/"})
+		for _, env := range containers[0].Env {
+			assert.NotContains(t, env.Value, webhookSecret)
+		}
+		assert.Equal(t, "webhook-signer", containers[1].Name)
+		assert.Equal(t, []string{"/progress-watchdog", "--webhook-signer"}, append(containers[1].Command, containers[1].Args...))
+		assert.Contains(t, containers[1].Env, corev1.EnvVar{Name: "WEBHOOK_SIGNING_KEY", Value: webhookSecret})
+		assert.Contains(t, containers[1].Env, corev1.EnvVar{Name: "WEBHOOK_TARGET_URL", Value: "http://progress-watchdog.test-namespace.svc/team/foobar/webhook"})
+
 		service, err := clientset.CoreV1().Services("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
 		assert.NoError(t, err)
 
@@ -137,12 +179,12 @@ func TestJoinHandler(t *testing.T) {
 
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		bundle.Config.CookieConfig.Secure = true
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.Regexp(t, regexp.MustCompile(`team=foobar\..*; Path=/; HttpOnly; Secure; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
+		assert.Regexp(t, regexp.MustCompile(`team=foobar\|\d+\|\d+\|0\|\|default\..*; Path=/; Max-Age=604800; HttpOnly; Secure; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
 	})
 
 	t.Run("refuses to create a team if max instances limit is reached", func(t *testing.T) {
@@ -160,7 +202,7 @@ func TestJoinHandler(t *testing.T) {
 
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		bundle.Config.MaxInstances = 3
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -169,11 +211,148 @@ func TestJoinHandler(t *testing.T) {
 		assert.JSONEq(t, `{"message":"Reached Maximum Instance Count","description":"Find an admin to handle this."}`, rr.Body.String())
 	})
 
+	t.Run("counts instances currently being created by other requests towards the max instances limit", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), nil)
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		reservationsJson, _ := json.Marshal(map[string]time.Time{"team-2": time.Now()})
+		clientset := fake.NewSimpleClientset(
+			balancerDeployment,
+			createTeam("team-1"),
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "balancer-instance-reservations", Namespace: "test-namespace"},
+				Data:       map[string]string{"reservations.json": string(reservationsJson)},
+			},
+		)
+
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.MaxInstances = 2
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.JSONEq(t, `{"message":"Reached Maximum Instance Count","description":"Find an admin to handle this."}`, rr.Body.String())
+	})
+
+	t.Run("allows unlimited instances with a max instances limit of -1", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), nil)
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(
+			balancerDeployment,
+			createTeam("team-1"),
+			createTeam("team-2"),
+		)
+
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.MaxInstances = -1
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"message":"Created Instance","passcode":"12345678"}`, rr.Body.String())
+	})
+
+	t.Run("adds the team to the waitlist if the max instances limit is reached and the waitlist is enabled", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]string{"displayName": "Los Hackers", "memberName": "Alice"})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam("team-1"))
+
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.MaxInstances = 1
+		bundle.Config.Waitlist.Enabled = true
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+		assert.JSONEq(t, `{"message":"Joined Waitlist","passcode":"12345678","waitlistPosition":1}`, rr.Body.String())
+		assert.Regexp(t, regexp.MustCompile(`team=foobar\|\d+\|\d+\|0\|[0-9a-f]+\|default\..*; Path=/; Max-Age=604800; HttpOnly; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
+
+		entry, position, ok := bundle.Waitlist.Get(team)
+		assert.True(t, ok)
+		assert.Equal(t, 1, position)
+		assert.Equal(t, "Los Hackers", entry.DisplayName)
+		assert.Len(t, entry.Members, 1)
+		assert.Equal(t, "Alice", entry.Members[0].Name)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(entry.PasscodeHash), []byte("12345678")))
+
+		_, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err))
+	})
+
+	t.Run("new teams queue up behind waiting teams even if capacity is available", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), nil)
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.Waitlist.Enabled = true
+		_, err := bundle.Waitlist.Enqueue(context.Background(), waitlist.Entry{Team: "first-team", PasscodeHash: "hash"})
+		assert.NoError(t, err)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+		assert.JSONEq(t, `{"message":"Joined Waitlist","passcode":"12345678","waitlistPosition":2}`, rr.Body.String())
+	})
+
+	t.Run("teams on the waitlist can be joined with their passcode", func(t *testing.T) {
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.Waitlist.Enabled = true
+		passcodeHash, _ := bcrypt.GenerateFromPassword([]byte("87654321"), bundle.BcryptRounds)
+		_, err := bundle.Waitlist.Enqueue(context.Background(), waitlist.Entry{Team: team, PasscodeHash: string(passcodeHash)})
+		assert.NoError(t, err)
+		AddRoutes(server, bundle, nil, nil)
+
+		{
+			jsonPayload, _ := json.Marshal(map[string]string{"passcode": "12345678"})
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+			assert.Equal(t, "", rr.Header().Get("Set-Cookie"))
+		}
+		{
+			jsonPayload, _ := json.Marshal(map[string]string{"passcode": "87654321", "memberName": "Bob"})
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.JSONEq(t, `{"message":"Joined Team"}`, rr.Body.String())
+			assert.Regexp(t, regexp.MustCompile(`team=foobar\|\d+\|\d+\|0\|[0-9a-f]+\|default\..*; Path=/; Max-Age=604800; HttpOnly; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
+		}
+
+		entry, _, _ := bundle.Waitlist.Get(team)
+		assert.Len(t, entry.Members, 1)
+		assert.Equal(t, 1, bundle.Waitlist.Len())
+	})
+
 	t.Run("rejects invalid teamnames", func(t *testing.T) {
 		server := http.NewServeMux()
 
 		bundle := testutil.NewTestBundle()
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		invalidTeamnames := []string{
 			"foo bar",
@@ -197,7 +376,7 @@ func TestJoinHandler(t *testing.T) {
 		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam(team))
 
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -215,12 +394,225 @@ func TestJoinHandler(t *testing.T) {
 		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam(team))
 
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.Regexp(t, regexp.MustCompile(`team=foobar\..*; Path=/; HttpOnly; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
+		assert.Regexp(t, regexp.MustCompile(`team=foobar\|\d+\|\d+\|0\|\|default\..*; Path=/; Max-Age=604800; HttpOnly; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
+	})
+
+	t.Run("registers the joining person as member when a member name is passed", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]string{"passcode": "02101791", "memberName": "  Alice  "})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam(team))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		assert.NoError(t, err)
+		members := sessions.ParseMembers(deployment.Annotations)
+		assert.Len(t, members, 1)
+		assert.Equal(t, "Alice", members[0].Name)
+		assert.Regexp(t, regexp.MustCompile(fmt.Sprintf(`team=foobar\|\d+\|\d+\|0\|%s\|default\..*`, members[0].ID)), rr.Header().Get("Set-Cookie"))
+	})
+
+	createBackup := func(team string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-backup-%s", team),
+				Namespace: "test-namespace",
+				Labels:    map[string]string{"app.kubernetes.io/name": "juice-shop-backup"},
+			},
+			Data: map[string]string{
+				"backup.json": fmt.Sprintf(`{"team":"%s","passcodeHash":"$2a$10$wnxvqClPk/13SbdowdJtu.2thGxrZe4qrsaVdTVUsYIrVVClhPMfS","displayName":"Foo Bar","challenges":[{"key":"scoreBoardChallenge","solvedAt":"2024-10-18T13:54:27.397Z"}],"challengesSolved":1,"backedUpAt":"2024-10-19T13:54:27Z"}`, team),
+			},
+		}
+	}
+
+	t.Run("restores the progress of teams deleted by the cleaner when they rejoin with their passcode", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]string{"passcode": "02101791"})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(balancerDeployment, createBackup(team))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Regexp(t, regexp.MustCompile(`team=foobar\|\d+\|\d+\|1\|\|default\..*`), rr.Header().Get("Set-Cookie"))
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "$2a$10$wnxvqClPk/13SbdowdJtu.2thGxrZe4qrsaVdTVUsYIrVVClhPMfS", deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"])
+		assert.Equal(t, "Foo Bar", deployment.Annotations["multi-juicer.owasp-juice.shop/displayName"])
+		assert.Equal(t, "1", deployment.Annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+		assert.JSONEq(t, `[{"key":"scoreBoardChallenge","solvedAt":"2024-10-18T13:54:27.397Z"}]`, deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"])
+		_, err = clientset.CoreV1().Services("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		assert.NoError(t, err)
+		_, err = clientset.CoreV1().ConfigMaps("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-backup-%s", team), metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err), "expected the restored backup to be deleted")
+	})
+
+	t.Run("sessions revoked before the backup stay revoked after the team got restored", func(t *testing.T) {
+		backup := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-backup-%s", team),
+				Namespace: "test-namespace",
+				Labels:    map[string]string{"app.kubernetes.io/name": "juice-shop-backup"},
+			},
+			Data: map[string]string{
+				"backup.json": fmt.Sprintf(`{"team":"%s","passcodeHash":"$2a$10$wnxvqClPk/13SbdowdJtu.2thGxrZe4qrsaVdTVUsYIrVVClhPMfS","challenges":[],"challengesSolved":0,"sessionGeneration":2,"removedMembers":["removed-member"],"backedUpAt":"2024-10-19T13:54:27Z"}`, team),
+			},
+		}
+		jsonPayload, _ := json.Marshal(map[string]string{"passcode": "02101791"})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(balancerDeployment, backup)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		// cookies issued before the sessions of the team got revoked, and of a member which got removed from the team
+		revokedCookie, err := teamcookie.CreateCookieValue(bundle, team, 1, "")
+		assert.NoError(t, err)
+		removedMemberCookie, err := teamcookie.CreateCookieValue(bundle, team, 3, "removed-member")
+		assert.NoError(t, err)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Regexp(t, regexp.MustCompile(`team=foobar\|\d+\|\d+\|3\|\|default\..*`), rr.Header().Get("Set-Cookie"))
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "3", deployment.Annotations[sessions.GenerationAnnotation])
+		assert.Equal(t, []sessions.RemovedMember{{ID: "removed-member"}}, sessions.ParseRemovedMembers(deployment.Annotations))
+
+		_, err = teamcookie.ParseCookieValue(context.Background(), bundle, revokedCookie)
+		assert.Error(t, err)
+		_, err = teamcookie.ParseCookieValue(context.Background(), bundle, removedMemberCookie)
+		assert.Error(t, err)
+	})
+
+	t.Run("teams with a backup can't be taken over without their passcode", func(t *testing.T) {
+		for _, body := range []string{"", `{"passcode":"00000000"}`} {
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), strings.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			server := http.NewServeMux()
+
+			clientset := fake.NewSimpleClientset(balancerDeployment, createBackup(team))
+			bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+			AddRoutes(server, bundle, nil, nil)
+
+			server.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+			assert.Equal(t, "", rr.Header().Get("Set-Cookie"))
+			_, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+			assert.True(t, errors.IsNotFound(err), "expected no instance to be created")
+			_, err = clientset.CoreV1().ConfigMaps("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-backup-%s", team), metav1.GetOptions{})
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("stores the display name of new teams as annotation", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]string{"displayName": "  Los Hackers 🍊 "})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "Los Hackers 🍊", deployment.Annotations["multi-juicer.owasp-juice.shop/displayName"])
+	})
+
+	t.Run("rejects team names and display names violating the team name policy", func(t *testing.T) {
+		for _, testCase := range []struct {
+			team            string
+			displayName     string
+			expectedMessage string
+		}{
+			{team: "orga", expectedMessage: "team name is reserved\n"},
+			{team: "the-badword-team", expectedMessage: "name contains a blocked word\n"},
+			{team: "foobar", displayName: "Bad Word 🍊", expectedMessage: "name contains a blocked word\n"},
+			{team: "foobar", displayName: "with\ttab", expectedMessage: "display name must be between 1 and 32 characters long and must not contain control characters\n"},
+		} {
+			jsonPayload, _ := json.Marshal(map[string]string{"displayName": testCase.displayName})
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", testCase.team), bytes.NewReader(jsonPayload))
+			rr := httptest.NewRecorder()
+
+			server := http.NewServeMux()
+
+			clientset := fake.NewSimpleClientset(balancerDeployment)
+			bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+			AddRoutes(server, bundle, nil, nil)
+
+			server.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code, testCase.team)
+			assert.Equal(t, testCase.expectedMessage, rr.Body.String())
+			assert.Equal(t, "", rr.Header().Get("Set-Cookie"))
+		}
+	})
+
+	t.Run("rejects invalid member names", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]string{"passcode": "02101791", "memberName": strings.Repeat("a", 33)})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam(team))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "", rr.Header().Get("Set-Cookie"))
+	})
+
+	t.Run("registers the creator of a new team as member when a member name is passed", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]string{"memberName": "Bob"})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		assert.NoError(t, err)
+		members := sessions.ParseMembers(deployment.Annotations)
+		assert.Len(t, members, 1)
+		assert.Equal(t, "Bob", members[0].Name)
+		assert.Contains(t, rr.Header().Get("Set-Cookie"), members[0].ID)
 	})
 
 	t.Run("join is rejected when the passcode doesn't match", func(t *testing.T) {
@@ -233,7 +625,7 @@ func TestJoinHandler(t *testing.T) {
 		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam(team))
 
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -241,6 +633,76 @@ func TestJoinHandler(t *testing.T) {
 		assert.Equal(t, "", rr.Header().Get("Set-Cookie"))
 	})
 
+	t.Run("locks the team for the clients failing to join it after too many failed join attempts", func(t *testing.T) {
+		server := http.NewServeMux()
+
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam(team))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		join := func(passcode string, remoteAddr string) *httptest.ResponseRecorder {
+			jsonPayload, _ := json.Marshal(map[string]string{"passcode": passcode})
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/teams/%s/join", team), bytes.NewReader(jsonPayload))
+			req.RemoteAddr = remoteAddr
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+			return rr
+		}
+
+		for range 5 {
+			assert.Equal(t, http.StatusUnauthorized, join("00000000", "10.0.0.1:1234").Code)
+		}
+		// the client only failed once itself, but the team is locked by now
+		assert.Equal(t, http.StatusUnauthorized, join("00000000", "10.0.0.3:1234").Code)
+
+		// even the correct passcode is rejected for clients which failed to join the team before
+		rr := join("02101791", "10.0.0.3:1234")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "5", rr.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"message":"Too many failed login attempts, try again later","retryAfter":5}`, rr.Body.String())
+		assert.Equal(t, "", rr.Header().Get("Set-Cookie"))
+
+		// other clients can't lock the team out by guessing wrong passcodes
+		rr = join("02101791", "10.0.0.2:1234")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("locks admin logins per client ip after too many failed attempts", func(t *testing.T) {
+		server := http.NewServeMux()
+
+		bundle := testutil.NewTestBundle()
+		clientIPs, err := clientip.NewResolver(clientip.Config{TrustedProxies: []string{"192.0.2.0/24"}})
+		assert.NoError(t, err)
+		bundle.ClientIPs = clientIPs
+		AddRoutes(server, bundle, nil, nil)
+
+		login := func(passcode string, remoteAddr string, forwardedFor string) *httptest.ResponseRecorder {
+			jsonPayload, _ := json.Marshal(map[string]string{"passcode": passcode})
+			req, _ := http.NewRequest("POST", "/balancer/api/teams/admin/join", bytes.NewReader(jsonPayload))
+			req.RemoteAddr = remoteAddr
+			if forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+			}
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+			return rr
+		}
+
+		for range 6 {
+			assert.Equal(t, http.StatusUnauthorized, login("wrong-password", "192.0.2.1:1234", "10.0.0.1").Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, login("mock-admin-password", "192.0.2.1:1234", "10.0.0.1").Code)
+		// spoofed entries added by the client don't circumvent the lockout
+		assert.Equal(t, http.StatusTooManyRequests, login("mock-admin-password", "192.0.2.1:1234", "1.2.3.4, 10.0.0.1").Code)
+		// clients not going through a trusted proxy can't pick their ip via the header
+		for range 6 {
+			assert.Equal(t, http.StatusUnauthorized, login("wrong-password", "10.0.0.3:1234", "").Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, login("mock-admin-password", "10.0.0.3:1234", "10.0.0.4").Code)
+
+		assert.Equal(t, http.StatusOK, login("mock-admin-password", "192.0.2.1:1234", "10.0.0.2").Code)
+	})
+
 	t.Run("allows admins login with the correct passcode", func(t *testing.T) {
 		jsonPayload, _ := json.Marshal(map[string]string{"passcode": "mock-admin-password"})
 		req, _ := http.NewRequest("POST", "/balancer/api/teams/admin/join", bytes.NewReader(jsonPayload))
@@ -249,12 +711,32 @@ func TestJoinHandler(t *testing.T) {
 		server := http.NewServeMux()
 
 		bundle := testutil.NewTestBundle()
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.Regexp(t, regexp.MustCompile(`team=admin\..*; Path=/; HttpOnly; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
+		assert.Regexp(t, regexp.MustCompile(`team=admin\|\d+\|\d+\|0\|\|default\..*; Path=/; Max-Age=604800; HttpOnly; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
+	})
+
+	t.Run("allows admins login when the admin password is configured as bcrypt hash", func(t *testing.T) {
+		server := http.NewServeMux()
+
+		bundle := testutil.NewTestBundle()
+		passwordHash, _ := bcrypt.GenerateFromPassword([]byte("mock-admin-password"), bcrypt.MinCost)
+		bundle.Config.AdminConfig.Password = string(passwordHash)
+		AddRoutes(server, bundle, nil, nil)
+
+		login := func(passcode string) *httptest.ResponseRecorder {
+			jsonPayload, _ := json.Marshal(map[string]string{"passcode": passcode})
+			req, _ := http.NewRequest("POST", "/balancer/api/teams/admin/join", bytes.NewReader(jsonPayload))
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+			return rr
+		}
+
+		assert.Equal(t, http.StatusOK, login("mock-admin-password").Code)
+		assert.Equal(t, http.StatusUnauthorized, login(string(passwordHash)).Code)
 	})
 
 	t.Run("admin login returns usual 'requires auth' response when it get's no request body passed", func(t *testing.T) {
@@ -264,7 +746,7 @@ func TestJoinHandler(t *testing.T) {
 		server := http.NewServeMux()
 
 		bundle := testutil.NewTestBundle()
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -280,7 +762,39 @@ func TestJoinHandler(t *testing.T) {
 		server := http.NewServeMux()
 
 		bundle := testutil.NewTestBundle()
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, "", rr.Header().Get("Set-Cookie"))
+	})
+
+	t.Run("allows named admin accounts to login with their own password", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]string{"account": "viewer", "passcode": "mock-viewer-password"})
+		req, _ := http.NewRequest("POST", "/balancer/api/teams/admin/join", bytes.NewReader(jsonPayload))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		bundle := testutil.NewTestBundle()
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Regexp(t, regexp.MustCompile(`team=admin:viewer\|\d+\|\d+\|0\|\|default\..*; Path=/; Max-Age=604800; HttpOnly; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
+	})
+
+	t.Run("named admin accounts can't login with the password of another account", func(t *testing.T) {
+		jsonPayload, _ := json.Marshal(map[string]string{"account": "viewer", "passcode": "mock-admin-password"})
+		req, _ := http.NewRequest("POST", "/balancer/api/teams/admin/join", bytes.NewReader(jsonPayload))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+
+		bundle := testutil.NewTestBundle()
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -297,7 +811,7 @@ func TestJoinHandler(t *testing.T) {
 
 		clientset := fake.NewSimpleClientset(balancerDeployment)
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
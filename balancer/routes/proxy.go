@@ -2,7 +2,6 @@ package routes
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,11 +10,8 @@ import (
 	"sync"
 	"time"
 
-	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
-	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 )
 
 var (
@@ -37,7 +33,7 @@ func newReverseProxy(target string) *httputil.ReverseProxy {
 }
 
 // HandleProxy determines the JuiceShop instance of the Team based on the "balancer" cookie and proxies the request to the corresponding JuiceShop instance.
-func handleProxy(bundle *bundle.Bundle) http.Handler {
+func handleProxy(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 			team, err := teamcookie.GetTeamFromRequest(bundle, req)
@@ -58,6 +54,10 @@ func handleProxy(bundle *bundle.Bundle) http.Handler {
 					cacheMutex.Lock()
 					instanceUpCache[team] = time.Now().UnixMilli()
 					cacheMutex.Unlock()
+				} else if status == instanceHibernated {
+					bundle.Log.Printf("Instance for team (%s) was hibernated and is resuming. Redirecting to the status page.", team)
+					http.Redirect(responseWriter, req, fmt.Sprintf("/balancer/teams/%s/status/", team), http.StatusFound)
+					return
 				} else if status == instanceMissing {
 					bundle.Log.Printf("Instance for team (%s) is missing. Redirecting to balancer page.", team)
 					http.Redirect(responseWriter, req, fmt.Sprintf("/balancer/?msg=instance-not-found&team=%s", team), http.StatusFound)
@@ -89,63 +89,65 @@ const (
 	instanceUp      instanceStatus = "up"
 	instanceDown    instanceStatus = "down"
 	instanceMissing instanceStatus = "missing"
+	// the instance was hibernated because of inactivity and is now starting again
+	instanceHibernated instanceStatus = "hibernated"
 )
 
-func isInstanceUp(context context.Context, bundle *bundle.Bundle, team string) instanceStatus {
-	deployment, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(context, fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+func isInstanceUp(context context.Context, bundle *b.Bundle, team string) instanceStatus {
+	instance, err := bundle.Instances.Get(context, team)
 
-	if errors.IsNotFound(err) {
+	if err == b.ErrInstanceNotFound {
 		return instanceMissing
 	} else if err != nil {
-		bundle.Log.Printf("Failed to lookup if a instance is up in the kubernetes api. Assuming it's missing: %s", err)
+		bundle.Log.Printf("Failed to lookup if a instance is up. Assuming it's missing: %s", err)
 		return instanceMissing
-	} else if deployment.Status.ReadyReplicas > 0 {
+	} else if instance.Hibernated {
+		// checked before the readiness, as the pod of an instance can still be ready for a moment after it got hibernated
+		if err := resumeHibernatedInstance(context, bundle, team); err != nil {
+			bundle.Log.Printf("Failed to resume hibernated instance of team '%s': %s", team, err)
+			return instanceDown
+		}
+		return instanceHibernated
+	} else if instance.Ready {
 		err = updateLastRequestTimestamp(context, bundle, team)
 		if err != nil {
 			// we will continue here, as a working proxy is more important than a up to date timestamp.
-			bundle.Log.Printf("failed to update last request time stamp on deployment. last request timestamps shown on the admin page might be out of sync.")
+			bundle.Log.Printf("failed to update last request time stamp of the instance. last request timestamps shown on the admin page might be out of sync.")
 		}
 		return instanceUp
 	}
 	return instanceDown
 }
 
-type UpdateProgressDeploymentDiff struct {
-	Metadata UpdateProgressDeploymentMetadata `json:"metadata"`
-}
-
-// UpdateProgressDeploymentMetadata a shim of the k8s metadata object containing only annotations
-type UpdateProgressDeploymentMetadata struct {
-	Annotations UpdateProgressDeploymentDiffAnnotations `json:"annotations"`
+// resumeIfHibernated resumes the instance of the team in case it was hibernated, e.g. when a team returns to the status page instead of the juice shop
+func resumeIfHibernated(context context.Context, bundle *b.Bundle, team string) {
+	instance, err := bundle.Instances.Get(context, team)
+	if err != nil || !instance.Hibernated {
+		return
+	}
+	if err := resumeHibernatedInstance(context, bundle, team); err != nil {
+		bundle.Log.Printf("Failed to resume hibernated instance of team '%s': %s", team, err)
+	}
 }
 
-// UpdateProgressDeploymentDiffAnnotations the app specific annotations relevant to the `progress-watchdog`
-type UpdateProgressDeploymentDiffAnnotations struct {
-	LastRequest         string `json:"multi-juicer.owasp-juice.shop/lastRequest"`
-	LastRequestReadable string `json:"multi-juicer.owasp-juice.shop/lastRequestReadable"`
+// resumeHibernatedInstance starts the instance again and marks it as active, so that the cleaner doesn't hibernate it again right away
+func resumeHibernatedInstance(context context.Context, bundle *b.Bundle, team string) error {
+	if err := bundle.Instances.Resume(context, team); err != nil {
+		return err
+	}
+	bundle.Log.Printf("Resumed hibernated instance of team '%s'", team)
+	return updateLastRequestTimestamp(context, bundle, team)
 }
 
-func updateLastRequestTimestamp(context context.Context, bundle *bundle.Bundle, team string) error {
+func updateLastRequestTimestamp(context context.Context, bundle *b.Bundle, team string) error {
 	bundle.Log.Printf("Updating last request timestamp for team '%s'", team)
 
-	diff := UpdateProgressDeploymentDiff{
-		Metadata: UpdateProgressDeploymentMetadata{
-			Annotations: UpdateProgressDeploymentDiffAnnotations{
-				LastRequest:         fmt.Sprintf("%d", time.Now().UnixMilli()),
-				LastRequestReadable: time.Now().String(),
-			},
-		},
-	}
-
-	jsonBytes, err := json.Marshal(diff)
-	if err != nil {
-		return fmt.Errorf("could not encode json, to update lastRequest timestamp on deployment")
-	}
-
-	_, err = bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Patch(context, fmt.Sprintf("juiceshop-%s", team), types.MergePatchType, jsonBytes, metav1.PatchOptions{})
-
+	err := bundle.Instances.MergeAnnotations(context, team, map[string]string{
+		"multi-juicer.owasp-juice.shop/lastRequest":         fmt.Sprintf("%d", time.Now().UnixMilli()),
+		"multi-juicer.owasp-juice.shop/lastRequestReadable": time.Now().String(),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to last request timestamp for deployment. %w", err)
+		return fmt.Errorf("failed to update last request timestamp of instance. %w", err)
 	}
 	return nil
 }
@@ -50,7 +50,7 @@ func TestProxyHandler(t *testing.T) {
 		server := http.NewServeMux()
 
 		bundle := testutil.NewTestBundle()
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -71,7 +71,7 @@ func TestProxyHandler(t *testing.T) {
 		server := http.NewServeMux()
 
 		bundle := testutil.NewTestBundle()
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -100,7 +100,7 @@ func TestProxyHandler(t *testing.T) {
 		bu.GetJuiceShopUrlForTeam = func(team string, _bundle *bundle.Bundle) string {
 			return fmt.Sprintf("%s/%s/", ts.URL, team)
 		}
-		AddRoutes(server, bu, nil)
+		AddRoutes(server, bu, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -128,7 +128,7 @@ func TestProxyHandler(t *testing.T) {
 		bu.GetJuiceShopUrlForTeam = func(team string, _bundle *bundle.Bundle) string {
 			return fmt.Sprintf("%s/%s/", ts.URL, team)
 		}
-		AddRoutes(server, bu, nil)
+		AddRoutes(server, bu, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -167,7 +167,7 @@ func TestProxyHandler(t *testing.T) {
 		bu.GetJuiceShopUrlForTeam = func(team string, _bundle *bundle.Bundle) string {
 			return fmt.Sprintf("%s/%s/", ts.URL, team)
 		}
-		AddRoutes(server, bu, nil)
+		AddRoutes(server, bu, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -195,7 +195,7 @@ func TestProxyHandler(t *testing.T) {
 		bu.GetJuiceShopUrlForTeam = func(team string, _bundle *bundle.Bundle) string {
 			return fmt.Sprintf("%s/%s/", ts.URL, team)
 		}
-		AddRoutes(server, bu, nil)
+		AddRoutes(server, bu, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -203,6 +203,52 @@ func TestProxyHandler(t *testing.T) {
 		assert.Equal(t, fmt.Sprintf("/balancer/?msg=instance-not-found&team=%s", teamFoo), rr.Header().Get("Location"))
 		assert.Empty(t, rr.Body.String())
 	})
+	t.Run("resumes hibernated instances and redirects to the status page", func(t *testing.T) {
+		defer clearInstanceUpCache()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(teamFoo)))
+		rr := httptest.NewRecorder()
+
+		hibernatedDeployment := unreadyDeployment.DeepCopy()
+		replicas := int32(0)
+		hibernatedDeployment.Spec.Replicas = &replicas
+		hibernatedDeployment.Annotations["multi-juicer.owasp-juice.shop/hibernatedAt"] = "1729259667397"
+		clientset := fake.NewSimpleClientset(hibernatedDeployment)
+		server := http.NewServeMux()
+		AddRoutes(server, testutil.NewTestBundleWithCustomFakeClient(clientset), nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, fmt.Sprintf("/balancer/teams/%s/status/", teamFoo), rr.Header().Get("Location"))
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", teamFoo), metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), *deployment.Spec.Replicas)
+		assert.NotContains(t, deployment.Annotations, "multi-juicer.owasp-juice.shop/hibernatedAt")
+		assert.NotEqual(t, "1729259667397", deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"])
+	})
+	t.Run("resumes hibernated instances whose pod is still ready", func(t *testing.T) {
+		defer clearInstanceUpCache()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(teamFoo)))
+		rr := httptest.NewRecorder()
+
+		// the deployment got scaled down, but its pod hasn't been terminated yet
+		hibernatedDeployment := readyDeployment.DeepCopy()
+		replicas := int32(0)
+		hibernatedDeployment.Spec.Replicas = &replicas
+		clientset := fake.NewSimpleClientset(hibernatedDeployment)
+		server := http.NewServeMux()
+		AddRoutes(server, testutil.NewTestBundleWithCustomFakeClient(clientset), nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, fmt.Sprintf("/balancer/teams/%s/status/", teamFoo), rr.Header().Get("Location"))
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", teamFoo), metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), *deployment.Spec.Replicas)
+	})
 	t.Run("redirects to /balancer?msg=balancer-disabled when the balancer is not enabled", func(t *testing.T) {
 		defer clearInstanceUpCache()
 		req, _ := http.NewRequest("POST", "/hello-world", nil)
@@ -226,7 +272,7 @@ func TestProxyHandler(t *testing.T) {
 		bu.GetJuiceShopUrlForTeam = func(team string, _bundle *bundle.Bundle) string {
 			return fmt.Sprintf("%s/%s/", ts.URL, team)
 		}
-		AddRoutes(server, bu, nil)
+		AddRoutes(server, bu, nil, nil)
 		server.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusFound, rr.Code)
 		assert.Equal(t, fmt.Sprintf("/balancer/?msg=balancer-disabled&team=%s", teamFoo), rr.Header().Get("Location"))
@@ -4,13 +4,17 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamnames"
 )
 
-func redirectLoggedInTeamsToStatus(bundle *bundle.Bundle, next http.Handler) http.Handler {
+func redirectLoggedInTeamsToStatus(bundle *b.Bundle, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(responseWriter http.ResponseWriter, req *http.Request) {
 		team, _ := teamcookie.GetTeamFromRequest(bundle, req)
+		if b.IsAdminIdentity(team) {
+			team = teamnames.AdminTeamName
+		}
 		if team != "" {
 			http.Redirect(responseWriter, req, fmt.Sprintf("/balancer/teams/%s/status", team), http.StatusFound)
 		}
@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
+)
+
+// handleRemoveTeamMember allows members of a team to remove a member from their team. The sessions of the removed member are invalidated.
+// As the removed member could join again with the passcode, the passcode can be reset in the same request via ?resetPasscode=true. The new passcode is returned like on a passcode reset
+func handleRemoveTeamMember(bundle *b.Bundle) http.Handler {
+	return http.HandlerFunc(
+		func(responseWriter http.ResponseWriter, req *http.Request) {
+			session, err := teamcookie.GetSessionFromRequest(bundle, req)
+			if err != nil || b.IsAdminIdentity(session.Team) {
+				http.Error(responseWriter, "", http.StatusUnauthorized)
+				return
+			}
+
+			var resetPasscode func(annotations map[string]string)
+			newPasscode := ""
+			if req.URL.Query().Get("resetPasscode") == "true" {
+				passcode, passcodeHash, err := generatePasscode(bundle)
+				if err != nil {
+					bundle.Log.Printf("Failed to hash passcode!: %s", err)
+					http.Error(responseWriter, "", http.StatusInternalServerError)
+					return
+				}
+				newPasscode = passcode
+				resetPasscode = func(annotations map[string]string) {
+					annotations[passcodeAnnotation] = passcodeHash
+				}
+			}
+
+			memberID := req.PathValue("member")
+			sessionGeneration, err := teamcookie.RemoveMember(req.Context(), bundle, session.Team, memberID, resetPasscode)
+			if err == teamcookie.ErrMemberNotFound {
+				http.Error(responseWriter, "member not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Failed to remove member '%s' from team '%s': %s", memberID, session.Team, err)
+				http.Error(responseWriter, "", http.StatusInternalServerError)
+				return
+			}
+			bundle.Log.Printf("Removed member '%s' from team '%s'", memberID, session.Team)
+
+			if memberID == session.MemberID {
+				http.SetCookie(responseWriter, &http.Cookie{Name: bundle.Config.CookieConfig.Name, Path: "/", MaxAge: -1})
+			} else if sessionGeneration > session.Generation {
+				// all sessions got revoked, the member removing the other one stays logged in with a cookie of the new session generation
+				if err := setSignedTeamCookie(bundle, session.Team, sessionGeneration, session.MemberID, responseWriter); err != nil {
+					http.Error(responseWriter, "failed to sign team cookie", http.StatusInternalServerError)
+					return
+				}
+			}
+
+			if newPasscode == "" {
+				responseWriter.WriteHeader(http.StatusOK)
+				responseWriter.Write([]byte{})
+				return
+			}
+			responseBody, _ := json.Marshal(ResetPasscodeResponse{
+				Message:  "Member removed and passcode reset successfully",
+				Passcode: newPasscode,
+			})
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+			responseWriter.Write(responseBody)
+		},
+	)
+}
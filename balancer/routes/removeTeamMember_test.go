@@ -0,0 +1,206 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRemoveTeamMemberHandler(t *testing.T) {
+	createTeamWithMembers := func(team string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					"multi-juicer.owasp-juice.shop/passcode": "$2a$10$wnxvqClPk/13SbdowdJtu.2thGxrZe4qrsaVdTVUsYIrVVClhPMfS",
+					"multi-juicer.owasp-juice.shop/members":  `[{"id":"alice","name":"Alice","joinedAt":1729259000000},{"id":"bob","name":"Bob","joinedAt":1729259100000}]`,
+				},
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+		}
+	}
+
+	t.Run("requires a team cookie", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/balancer/api/teams/members/bob", nil)
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(createTeamWithMembers("foobar"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Empty(t, clientset.Actions())
+	})
+
+	t.Run("removes the member and invalidates its sessions", func(t *testing.T) {
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(createTeamWithMembers("foobar"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		req, _ := http.NewRequest("DELETE", "/balancer/api/teams/members/bob", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamMember("foobar", "alice")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rr.Header().Get("Set-Cookie"))
+
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, []sessions.Member{{ID: "alice", Name: "Alice", JoinedAt: 1729259000000}}, sessions.ParseMembers(deployment.Annotations))
+		removedMembers := sessions.ParseRemovedMembers(deployment.Annotations)
+		assert.Len(t, removedMembers, 1)
+		assert.Equal(t, "bob", removedMembers[0].ID)
+		assert.WithinDuration(t, time.Now(), time.UnixMilli(removedMembers[0].RemovedAt), time.Minute)
+		assert.Equal(t, []sessions.Member{{ID: "alice", Name: "Alice", JoinedAt: 1729259000000}}, bundle.TeamSessions.GetMembers("foobar"))
+
+		getSetting := func(cookie string) int {
+			settingReq, _ := http.NewRequest("GET", "/balancer/api/settings/balancerEnabled", nil)
+			settingReq.Header.Set("Cookie", fmt.Sprintf("team=%s", cookie))
+			settingRR := httptest.NewRecorder()
+			server.ServeHTTP(settingRR, settingReq)
+			return settingRR.Code
+		}
+		assert.Equal(t, http.StatusUnauthorized, getSetting(testutil.SignTestTeamMember("foobar", "bob")))
+		assert.Equal(t, http.StatusOK, getSetting(testutil.SignTestTeamMember("foobar", "alice")))
+		assert.Equal(t, http.StatusOK, getSetting(testutil.SignTestTeamname("foobar")))
+	})
+
+	t.Run("resets the passcode together with the removal, so that the removed member can't join again", func(t *testing.T) {
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(createTeamWithMembers("foobar"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		req, _ := http.NewRequest("DELETE", "/balancer/api/teams/members/bob?resetPasscode=true", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamMember("foobar", "alice")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"message":"Member removed and passcode reset successfully","passcode":"12345678"}`, rr.Body.String())
+		// the member removing bob stays logged in with a cookie of the new session generation
+		assert.Regexp(t, regexp.MustCompile(`team=foobar\|\d+\|\d+\|1\|alice\|default\..*`), rr.Header().Get("Set-Cookie"))
+
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"]), []byte("12345678")))
+		assert.Equal(t, "1", deployment.Annotations[sessions.GenerationAnnotation])
+		assert.Equal(t, []sessions.Member{{ID: "alice", Name: "Alice", JoinedAt: 1729259000000}}, sessions.ParseMembers(deployment.Annotations))
+		assert.Empty(t, sessions.ParseRemovedMembers(deployment.Annotations))
+
+		assert.True(t, bundle.TeamSessions.IsRevoked("foobar", 0, "bob"))
+		assert.True(t, bundle.TeamSessions.IsRevoked("foobar", 0, "alice"))
+		assert.False(t, bundle.TeamSessions.IsRevoked("foobar", 1, "alice"))
+	})
+
+	t.Run("forgets removed members once all their cookies have expired", func(t *testing.T) {
+		deployment := createTeamWithMembers("foobar")
+		deployment.Annotations[sessions.RemovedMembersAnnotation] = fmt.Sprintf(
+			`[{"id":"carol","removedAt":%d},{"id":"dave","removedAt":%d}]`,
+			time.Now().Add(-8*24*time.Hour).UnixMilli(),
+			time.Now().Add(-time.Hour).UnixMilli(),
+		)
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(deployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		req, _ := http.NewRequest("DELETE", "/balancer/api/teams/members/bob", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamMember("foobar", "alice")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+		removedMemberIDs := []string{}
+		for _, removedMember := range sessions.ParseRemovedMembers(deployment.Annotations) {
+			removedMemberIDs = append(removedMemberIDs, removedMember.ID)
+		}
+		assert.Equal(t, []string{"dave", "bob"}, removedMemberIDs)
+	})
+
+	t.Run("revokes all sessions instead of forgetting removed members whose cookies are still valid", func(t *testing.T) {
+		removedMembers := []sessions.RemovedMember{}
+		for i := range sessions.MaxRemovedMembers {
+			removedMembers = append(removedMembers, sessions.RemovedMember{ID: fmt.Sprintf("member-%d", i), RemovedAt: time.Now().Add(-time.Hour).UnixMilli()})
+		}
+		encodedRemovedMembers, _ := json.Marshal(removedMembers)
+		deployment := createTeamWithMembers("foobar")
+		deployment.Annotations[sessions.RemovedMembersAnnotation] = string(encodedRemovedMembers)
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(deployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		req, _ := http.NewRequest("DELETE", "/balancer/api/teams/members/bob", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamMember("foobar", "alice")))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Regexp(t, regexp.MustCompile(`team=foobar\|\d+\|\d+\|1\|alice\|default\..*`), rr.Header().Get("Set-Cookie"))
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "1", deployment.Annotations[sessions.GenerationAnnotation])
+		assert.Empty(t, sessions.ParseRemovedMembers(deployment.Annotations))
+		assert.True(t, bundle.TeamSessions.IsRevoked("foobar", 0, "member-0"))
+		assert.True(t, bundle.TeamSessions.IsRevoked("foobar", 0, "bob"))
+	})
+
+	t.Run("clears the cookie when members remove themselves", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/balancer/api/teams/members/alice", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamMember("foobar", "alice")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(createTeamWithMembers("foobar"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "team=; Path=/; Max-Age=0", rr.Header().Get("Set-Cookie"))
+	})
+
+	t.Run("returns 404 for unknown members", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/balancer/api/teams/members/mallory", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamMember("foobar", "alice")))
+		rr := httptest.NewRecorder()
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(createTeamWithMembers("foobar"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
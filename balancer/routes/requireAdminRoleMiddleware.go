@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
+)
+
+// requireAdminRole only passes requests of admins with at least the required role through to the next handler.
+// Requests without a admin login are rejected with 401, admins with a insufficient role with 403
+func requireAdminRole(bundle *bundle.Bundle, requiredRole bundle.AdminRole, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, req *http.Request) {
+		admin, err := teamcookie.GetAdminFromRequest(bundle, req)
+		if err != nil {
+			http.Error(responseWriter, "", http.StatusUnauthorized)
+			return
+		}
+		if !admin.Role.Includes(requiredRole) {
+			bundle.Log.Printf("Admin '%s' with role '%s' tried to access '%s %s' which requires role '%s'", admin.Name, admin.Role, req.Method, req.URL.Path, requiredRole)
+			http.Error(responseWriter, "", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(responseWriter, req)
+	})
+}
@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRequireAdminRoleMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		cookieValue    string
+		expectedStatus int
+	}{
+		{"teams can't list instances", "GET", "/balancer/api/admin/all", "foobar", http.StatusUnauthorized},
+		{"viewers can list instances", "GET", "/balancer/api/admin/all", "admin:viewer", http.StatusOK},
+		{"viewers can't restart instances", "POST", "/balancer/api/admin/teams/foobar/restart", "admin:viewer", http.StatusForbidden},
+		{"operators can restart instances", "POST", "/balancer/api/admin/teams/foobar/restart", "admin:operator", http.StatusNotFound},
+		{"operators can't delete instances", "DELETE", "/balancer/api/admin/teams/foobar/delete", "admin:operator", http.StatusForbidden},
+		{"operators can't change settings", "POST", "/balancer/api/settings", "admin:operator", http.StatusForbidden},
+		{"default admin account is a owner", "DELETE", "/balancer/api/admin/teams/foobar/delete", "admin", http.StatusOK},
+		{"unknown admin accounts are rejected", "GET", "/balancer/api/admin/all", "admin:removed-account", http.StatusUnauthorized},
+		{"oidc admins are rejected when oidc is disabled", "GET", "/balancer/api/admin/all", "admin:oidc:instructor@example.com", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(tt.cookieValue)))
+			rr := httptest.NewRecorder()
+
+			server := http.NewServeMux()
+			clientset := fake.NewSimpleClientset()
+			bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+			AddRoutes(server, bundle, nil, nil)
+
+			server.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -2,14 +2,11 @@ package routes
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 
-	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
 	"golang.org/x/crypto/bcrypt"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 )
 
 type ResetPasscodeResponse struct {
@@ -17,24 +14,19 @@ type ResetPasscodeResponse struct {
 	Passcode string `json:"passcode"`
 }
 
-func handleResetPasscode(bundle *bundle.Bundle) http.Handler {
+func handleResetPasscode(bundle *b.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 
-			team, err := teamcookie.GetTeamFromRequest(bundle, req)
+			session, err := teamcookie.GetSessionFromRequest(bundle, req)
 			if err != nil {
 				http.Error(responseWriter, "", http.StatusUnauthorized)
 				return
 			}
+			team := session.Team
 
 			newPasscode := bundle.GeneratePasscode()
 
-			deployment, err := bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Get(req.Context(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
-			if err != nil {
-				http.NotFound(responseWriter, req)
-				return
-			}
-
 			passcodeHashBytes, err := bcrypt.GenerateFromPassword([]byte(newPasscode), bundle.BcryptRounds)
 			if err != nil {
 				bundle.Log.Printf("Failed to hash passcode!: %s", err)
@@ -43,26 +35,25 @@ func handleResetPasscode(bundle *bundle.Bundle) http.Handler {
 			}
 			passcodeHash := string(passcodeHashBytes)
 
-			patch, err := json.Marshal(map[string]interface{}{
-				"metadata": map[string]interface{}{
-					"annotations": map[string]interface{}{
-						"multi-juicer.owasp-juice.shop/passcode": passcodeHash,
-					},
-				},
+			// the passcode is updated together with the session generation, so that everyone who joined with the old passcode gets logged out
+			sessionGeneration, err := teamcookie.RevokeSessions(req.Context(), bundle, team, func(annotations map[string]string) {
+				annotations[passcodeAnnotation] = passcodeHash
 			})
-
-			if err != nil {
-				bundle.Log.Printf("Failed to convert passcode update patch to json: %v", err)
+			if err == b.ErrInstanceNotFound {
+				http.NotFound(responseWriter, req)
+				return
+			} else if err != nil {
+				bundle.Log.Printf("Failed to update passcode of team '%s': %v", team, err)
 				http.Error(responseWriter, "Failed to update passcode", http.StatusInternalServerError)
 				return
 			}
 
-			bundle.ClientSet.AppsV1().Deployments(bundle.RuntimeEnvironment.Namespace).Patch(
-				req.Context(),
-				deployment.Name, types.StrategicMergePatchType,
-				patch,
-				metav1.PatchOptions{},
-			)
+			// the member resetting the passcode stays logged in with a cookie of the new session generation
+			err = setSignedTeamCookie(bundle, team, sessionGeneration, session.MemberID, responseWriter)
+			if err != nil {
+				http.Error(responseWriter, "failed to sign team cookie", http.StatusInternalServerError)
+				return
+			}
 
 			responseBody := ResetPasscodeResponse{
 				Message:  "Passcode reset successfully",
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
 
 	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
@@ -49,7 +50,7 @@ func TestResetPasscodeHandler(t *testing.T) {
 			},
 		})
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -69,6 +70,48 @@ func TestResetPasscodeHandler(t *testing.T) {
 		updatedHash := updatedDeployment.Annotations["multi-juicer.owasp-juice.shop/passcode"]
 		assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(updatedHash), []byte(response.Passcode)), "Returned passcode should match the updated hash")
 	})
+	t.Run("reset passcode logs out other sessions of the team but keeps the resetting member logged in", func(t *testing.T) {
+		leakedCookie := testutil.SignTestTeamname(team)
+
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					"multi-juicer.owasp-juice.shop/passcode": "$2a$10$wnxvqClPk/13SbdowdJtu.2thGxrZe4qrsaVdTVUsYIrVVClhPMfS",
+				},
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+		})
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		AddRoutes(server, bundle, nil, nil)
+
+		req, _ := http.NewRequest("POST", "/balancer/api/teams/reset-passcode", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		updatedDeployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, "1", updatedDeployment.Annotations["multi-juicer.owasp-juice.shop/sessionGeneration"])
+		assert.Regexp(t, regexp.MustCompile(`team=foobar\|\d+\|\d+\|1\|\|default\..*; Path=/; Max-Age=604800; HttpOnly; SameSite=Strict`), rr.Header().Get("Set-Cookie"))
+
+		getSetting := func(cookie string) int {
+			settingReq, _ := http.NewRequest("GET", "/balancer/api/settings/balancerEnabled", nil)
+			settingReq.Header.Set("Cookie", fmt.Sprintf("team=%s", cookie))
+			settingRR := httptest.NewRecorder()
+			server.ServeHTTP(settingRR, settingReq)
+			return settingRR.Code
+		}
+		assert.Equal(t, http.StatusUnauthorized, getSetting(leakedCookie))
+		assert.Equal(t, http.StatusOK, getSetting(rr.Result().Cookies()[0].Value))
+	})
 	t.Run("reset passcode requries a signed team cookie", func(t *testing.T) {
 		req, _ := http.NewRequest("POST", "/balancer/api/teams/reset-passcode", nil)
 		rr := httptest.NewRecorder()
@@ -77,7 +120,7 @@ func TestResetPasscodeHandler(t *testing.T) {
 
 		clientset := fake.NewSimpleClientset()
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
-		AddRoutes(server, bundle, nil)
+		AddRoutes(server, bundle, nil, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -3,7 +3,9 @@ package routes
 import (
 	"net/http"
 
-	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/announcements"
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/lockout"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/scoring"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -23,8 +25,9 @@ func init() {
 
 func AddRoutes(
 	router *http.ServeMux,
-	bundle *bundle.Bundle,
+	bundle *b.Bundle,
 	scoringService *scoring.ScoringService,
+	announcementService *announcements.AnnouncementService,
 ) {
 	router.Handle("/", trackRequestMetrics(handleProxy(bundle)))
 	router.Handle("GET /balancer", redirectLoggedInTeamsToStatus(bundle, handleStaticFiles(bundle)))
@@ -32,15 +35,37 @@ func AddRoutes(
 	router.Handle("POST /balancer/api/teams/{team}/join", handleTeamJoin(bundle))
 	router.Handle("POST /balancer/api/teams/logout", handleLogout(bundle))
 	router.Handle("POST /balancer/api/teams/reset-passcode", handleResetPasscode(bundle))
+	router.Handle("DELETE /balancer/api/teams/members/{member}", handleRemoveTeamMember(bundle))
 	router.Handle("GET /balancer/api/score-board/top", handleScoreBoard(bundle, scoringService))
 	router.Handle("GET /balancer/api/score-board/teams/{team}/score", handleIndividualScore(bundle, scoringService))
 	router.Handle("GET /balancer/api/teams/status", handleTeamStatus(bundle, scoringService))
+	router.Handle("GET /balancer/api/announcements", handleAnnouncementsGet(bundle, announcementService))
+	router.Handle("POST /balancer/api/announcements", requireAdminRole(bundle, b.AdminRoleOperator, handleAnnouncementsPost(bundle, announcementService)))
+	router.Handle("DELETE /balancer/api/announcements/{id}", requireAdminRole(bundle, b.AdminRoleOperator, handleAnnouncementsDelete(bundle, announcementService)))
 
-	router.Handle("GET /balancer/api/admin/all", handleAdminListInstances(bundle))
-	router.Handle("DELETE /balancer/api/admin/teams/{team}/delete", handleAdminDeleteInstance(bundle))
-	router.Handle("POST /balancer/api/admin/teams/{team}/restart", handleAdminRestartInstance(bundle))
+	adminOIDCAuthenticator := &adminOIDCAuthenticatorProvider{bundle: bundle}
+	router.Handle("GET /balancer/api/admin/oidc/login", handleAdminOIDCLogin(bundle, adminOIDCAuthenticator))
+	router.Handle("GET /balancer/api/admin/oidc/callback", handleAdminOIDCCallback(bundle, adminOIDCAuthenticator))
+	router.Handle("GET /balancer/api/admin/all", requireAdminRole(bundle, b.AdminRoleViewer, handleAdminListInstances(bundle)))
+	router.Handle("POST /balancer/api/admin/teams", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminBulkCreateTeams(bundle)))
+	router.Handle("DELETE /balancer/api/admin/teams/{team}/delete", requireAdminRole(bundle, b.AdminRoleOwner, handleAdminDeleteInstance(bundle)))
+	router.Handle("POST /balancer/api/admin/teams/{team}/restart", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminRestartInstance(bundle)))
+	router.Handle("POST /balancer/api/admin/teams/{team}/repair", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminRepairInstance(bundle)))
+	router.Handle("PUT /balancer/api/admin/teams/{team}/protected", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminSetInstanceProtection(bundle, true)))
+	router.Handle("DELETE /balancer/api/admin/teams/{team}/protected", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminSetInstanceProtection(bundle, false)))
+	router.Handle("POST /balancer/api/admin/teams/{team}/revoke-sessions", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminRevokeSessions(bundle)))
+	router.Handle("GET /balancer/api/admin/lockouts", requireAdminRole(bundle, b.AdminRoleViewer, handleAdminListLockouts(bundle)))
+	router.Handle("DELETE /balancer/api/admin/lockouts", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminClearLockouts(bundle)))
+	router.Handle("DELETE /balancer/api/admin/lockouts/teams/{value}", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminClearLockout(bundle, lockout.KeyTypeTeam)))
+	router.Handle("DELETE /balancer/api/admin/lockouts/ips/{value}", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminClearLockout(bundle, lockout.KeyTypeClientIP)))
+	router.Handle("GET /balancer/api/admin/join-codes", requireAdminRole(bundle, b.AdminRoleViewer, handleAdminListJoinCodes(bundle)))
+	router.Handle("POST /balancer/api/admin/join-codes", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminCreateJoinCode(bundle)))
+	router.Handle("DELETE /balancer/api/admin/join-codes/{code}", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminDeleteJoinCode(bundle)))
+	router.Handle("GET /balancer/api/admin/waitlist", requireAdminRole(bundle, b.AdminRoleViewer, handleAdminListWaitlist(bundle)))
+	router.Handle("POST /balancer/api/admin/waitlist/{team}/move", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminMoveWaitlistEntry(bundle)))
+	router.Handle("DELETE /balancer/api/admin/waitlist/{team}", requireAdminRole(bundle, b.AdminRoleOperator, handleAdminRemoveWaitlistEntry(bundle)))
 	router.Handle("GET /balancer/api/settings/{setting}", handleSettingsGet(bundle))
-	router.Handle("POST /balancer/api/settings", handleSettingsPost(bundle))
+	router.Handle("POST /balancer/api/settings", requireAdminRole(bundle, b.AdminRoleOwner, handleSettingsPost(bundle)))
 
 	router.HandleFunc("GET /balancer/api/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -17,6 +17,7 @@ type ScoreBoardResponse struct {
 
 type TeamScore struct {
 	Name                 string `json:"name"`
+	DisplayName          string `json:"displayName"`
 	Score                int    `json:"score"`
 	Position             int    `json:"position"`
 	SolvedChallengeCount int    `json:"solvedChallengeCount"`
@@ -26,7 +27,7 @@ func handleScoreBoard(bundle *b.Bundle, scoringService *scoring.ScoringService)
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
 			user, _ := teamcookie.GetTeamFromRequest(bundle, req)
-			if !bundle.GetScoreOverviewVisibleForUsers() && user != "admin" {
+			if !bundle.GetScoreOverviewVisibleForUsers() && !b.IsAdminIdentity(user) {
 				responseWriter.WriteHeader(http.StatusNoContent)
 				responseWriter.Write([]byte{})
 				return
@@ -61,6 +62,7 @@ func handleScoreBoard(bundle *b.Bundle, scoringService *scoring.ScoringService)
 			for i, topTeam := range topTeams {
 				convertedTopScores[i] = &TeamScore{
 					Name:                 topTeam.Name,
+					DisplayName:          topTeam.DisplayName,
 					Score:                topTeam.Score,
 					Position:             topTeam.Position,
 					SolvedChallengeCount: len(topTeam.Challenges),
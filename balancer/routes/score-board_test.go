@@ -43,14 +43,16 @@ func TestScoreBoardHandler(t *testing.T) {
 		rr := httptest.NewRecorder()
 
 		server := http.NewServeMux()
+		teamWithDisplayName := createTeam("barfoo", `[]`, "0")
+		teamWithDisplayName.Annotations["multi-juicer.owasp-juice.shop/displayName"] = "Los Hackers 🍊"
 		clientset := fake.NewSimpleClientset(
 			createTeam("foobar", `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"},{"key":"nullByteChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, "2"),
-			createTeam("barfoo", `[]`, "0"),
+			teamWithDisplayName,
 		)
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		scoringService := scoring.NewScoringService(bundle)
 		scoringService.CalculateAndCacheScoreBoard(context.Background())
-		AddRoutes(server, bundle, scoringService)
+		AddRoutes(server, bundle, scoringService, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -63,12 +65,14 @@ func TestScoreBoardHandler(t *testing.T) {
 		assert.Equal(t, []*TeamScore{
 			{
 				Name:                 "foobar",
+				DisplayName:          "foobar",
 				Score:                50,
 				Position:             1,
 				SolvedChallengeCount: 2,
 			},
 			{
 				Name:                 "barfoo",
+				DisplayName:          "Los Hackers 🍊",
 				Score:                0,
 				Position:             2,
 				SolvedChallengeCount: 0,
@@ -92,7 +96,7 @@ func TestScoreBoardHandler(t *testing.T) {
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		scoringService := scoring.NewScoringService(bundle)
 		scoringService.CalculateAndCacheScoreBoard(context.Background())
-		AddRoutes(server, bundle, scoringService)
+		AddRoutes(server, bundle, scoringService, nil)
 
 		server.ServeHTTP(rr, req)
 
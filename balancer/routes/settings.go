@@ -57,12 +57,6 @@ func handleSettingsGet(bundle *bundle.Bundle) http.Handler {
 func handleSettingsPost(bundle *bundle.Bundle) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
-			team, err := teamcookie.GetTeamFromRequest(bundle, req)
-			if err != nil || team != "admin" {
-				http.Error(responseWriter, "", http.StatusUnauthorized)
-				return
-			}
-
 			var data settings
 
 			if req.Body == nil {
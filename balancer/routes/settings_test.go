@@ -84,7 +84,7 @@ func TestHandleSettingsGet(t *testing.T) {
 			w := httptest.NewRecorder()
 			server := http.NewServeMux()
 
-			AddRoutes(server, b, nil)
+			AddRoutes(server, b, nil, nil)
 			server.ServeHTTP(w, req)
 
 			if w.Code != tt.expectedStatus {
@@ -179,7 +179,7 @@ func TestHandleSettingsPost(t *testing.T) {
 			w := httptest.NewRecorder()
 			server := http.NewServeMux()
 
-			AddRoutes(server, b, nil)
+			AddRoutes(server, b, nil, nil)
 
 			server.ServeHTTP(w, req)
 
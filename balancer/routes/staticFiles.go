@@ -5,16 +5,17 @@ import (
 	"regexp"
 
 	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamnames"
 )
 
 func handleStaticFiles(bundle *bundle.Bundle) http.Handler {
 	// these routes should serve the index.html file and let the frontend handle the routing
 	frontendRoutePatterns := []*regexp.Regexp{
 		regexp.MustCompile("/balancer/admin"),
-		regexp.MustCompile("/balancer/teams/" + teamNamePatternString + "/status"),
-		regexp.MustCompile("/balancer/teams/" + teamNamePatternString + "/joining"),
+		regexp.MustCompile("/balancer/teams/" + teamnames.PatternString + "/status"),
+		regexp.MustCompile("/balancer/teams/" + teamnames.PatternString + "/joining"),
 		regexp.MustCompile("/balancer/score-overview"),
-		regexp.MustCompile("/balancer/score-overview/teams/" + teamNamePatternString + "/score"),
+		regexp.MustCompile("/balancer/score-overview/teams/" + teamnames.PatternString + "/score"),
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
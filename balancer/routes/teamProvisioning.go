@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/backups"
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/reservations"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamnames"
+)
+
+// errTeamAlreadyCreated is returned if the team got created concurrently by somebody else, e.g. by another request for the same team name
+var errTeamAlreadyCreated = errors.New("team already exists")
+
+const passcodeAnnotation = "multi-juicer.owasp-juice.shop/passcode"
+
+// provisionTeam creates the instance of a new team with a freshly generated passcode and returns the passcode. The display name is optional.
+// The returned errors are safe to be shown to the user, the details get logged
+func provisionTeam(context context.Context, bundle *b.Bundle, team string, displayName string, initialMembers []sessions.Member) (string, error) {
+	passcode, passcodeHash, err := generatePasscode(bundle)
+	if err != nil {
+		bundle.Log.Printf("Failed to hash passcode!: %s", err)
+		return "", fmt.Errorf("failed to generate passcode")
+	}
+	if err := createTeamResources(context, bundle, team, displayName, passcodeHash, initialMembers); err != nil {
+		return "", err
+	}
+	return passcode, nil
+}
+
+// createTeamResources creates the instance of a team with an already hashed passcode.
+// The creation is idempotent: calling it again for a team created with the same passcode hash completes a partially created team instead of failing.
+// The returned errors are safe to be shown to the user, the details get logged
+func createTeamResources(context context.Context, bundle *b.Bundle, team string, displayName string, passcodeHash string, initialMembers []sessions.Member) error {
+	annotations, err := newTeamAnnotations(displayName, passcodeHash, initialMembers)
+	if err != nil {
+		bundle.Log.Printf("Failed to create annotations of team '%s': %s", team, err)
+		return fmt.Errorf("failed to create instance")
+	}
+
+	err = bundle.Instances.Create(context, team, annotations)
+	if err == b.ErrInstanceAlreadyExists {
+		return completeExistingTeam(context, bundle, team, passcodeHash)
+	} else if err != nil {
+		bundle.Log.Printf("Failed to create instance of team '%s': %s", team, err)
+		return fmt.Errorf("failed to create instance")
+	}
+	return nil
+}
+
+// completeExistingTeam repairs the instance if it was created by a previous attempt to create the same team, recognized by the same passcode hash
+func completeExistingTeam(context context.Context, bundle *b.Bundle, team string, passcodeHash string) error {
+	instance, err := bundle.Instances.Get(context, team)
+	if err != nil {
+		bundle.Log.Printf("Failed to get existing instance of team '%s': %s", team, err)
+		return fmt.Errorf("failed to create instance")
+	}
+	if instance.Annotations[passcodeAnnotation] != passcodeHash {
+		return errTeamAlreadyCreated
+	}
+	if _, err := bundle.Instances.Repair(context, team); err != nil {
+		bundle.Log.Printf("Failed to complete existing instance of team '%s': %s", team, err)
+		return fmt.Errorf("failed to create instance")
+	}
+	return nil
+}
+
+// restoreTeam recreates the instance of a team deleted by the cleaner with the progress from its backup. The progress watchdog applies the restored challenges to the new instance once it's ready.
+// The returned errors are safe to be shown to the user, the details get logged
+func restoreTeam(context context.Context, bundle *b.Bundle, team string, backup *backups.Backup) error {
+	err := bundle.InstanceReservations.Reserve(context, team, bundle.Config.MaxInstances)
+	if err == reservations.ErrMaxInstancesReached {
+		bundle.Log.Printf("Max instance limit reached! Cannot restore team '%s'. Increase the count via the helm values or delete existing teams.", team)
+		return errors.New(`{"message":"Reached Maximum Instance Count","description":"Find an admin to handle this."}`)
+	} else if err != nil {
+		bundle.Log.Printf("Failed to reserve instance for team '%s': %s", team, err)
+		return fmt.Errorf("failed to check max instance limit")
+	}
+	defer releaseInstanceReservation(bundle, team)
+
+	annotations, err := newTeamAnnotations(backup.DisplayName, backup.PasscodeHash, nil)
+	if err != nil {
+		bundle.Log.Printf("Failed to create annotations of team '%s': %s", team, err)
+		return fmt.Errorf("failed to create instance")
+	}
+	annotations["multi-juicer.owasp-juice.shop/challenges"] = string(backup.Challenges)
+	annotations["multi-juicer.owasp-juice.shop/challengesSolved"] = strconv.Itoa(backup.ChallengesSolved)
+	// sessions revoked before the instance got deleted have to stay revoked. The generation is incremented on top, which also invalidates all cookies issued to the deleted instance
+	annotations[sessions.GenerationAnnotation] = strconv.FormatInt(restoredSessionGeneration(backup), 10)
+	if len(backup.RemovedMembers) > 0 {
+		annotations[sessions.RemovedMembersAnnotation] = string(backup.RemovedMembers)
+	}
+
+	err = bundle.Instances.Create(context, team, annotations)
+	if err == b.ErrInstanceAlreadyExists {
+		// restored concurrently by another member of the team
+		if err := completeExistingTeam(context, bundle, team, backup.PasscodeHash); err != nil {
+			return err
+		}
+	} else if err != nil {
+		bundle.Log.Printf("Failed to restore instance of team '%s': %s", team, err)
+		return fmt.Errorf("failed to create instance")
+	}
+
+	// apply the restored sessions immediately and not only once the watcher picked up the new instance
+	bundle.TeamSessions.Update(team, annotations)
+
+	if err := bundle.ProgressBackups.Delete(context, team); err != nil {
+		bundle.Log.Printf("Failed to delete the restored backup of team '%s': %s", team, err)
+	}
+	bundle.Log.Printf("Restored team '%s' with %d solved challenge(s) from its backup", team, backup.ChallengesSolved)
+	return nil
+}
+
+// restoredSessionGeneration is the session generation a team restored from the backup continues with
+func restoredSessionGeneration(backup *backups.Backup) int64 {
+	return backup.SessionGeneration + 1
+}
+
+func newTeamAnnotations(displayName string, passcodeHash string, initialMembers []sessions.Member) (map[string]string, error) {
+	annotations := map[string]string{
+		"multi-juicer.owasp-juice.shop/lastRequest":         fmt.Sprintf("%d", time.Now().UnixMilli()),
+		"multi-juicer.owasp-juice.shop/lastRequestReadable": time.Now().String(),
+		"multi-juicer.owasp-juice.shop/challengesSolved":    "0",
+		"multi-juicer.owasp-juice.shop/challenges":          "[]",
+	}
+	annotations[passcodeAnnotation] = passcodeHash
+	webhookSecret, err := b.GenerateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	annotations[b.WebhookSecretAnnotation] = webhookSecret
+	if displayName != "" {
+		annotations[teamnames.DisplayNameAnnotation] = displayName
+	}
+	if len(initialMembers) > 0 {
+		encodedMembers, err := teamcookie.EncodeMembers(initialMembers)
+		if err != nil {
+			return nil, err
+		}
+		annotations[sessions.MembersAnnotation] = encodedMembers
+	}
+	return annotations, nil
+}
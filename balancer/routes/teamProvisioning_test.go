@@ -0,0 +1,227 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	testcore "k8s.io/client-go/testing"
+)
+
+func TestTeamProvisioning(t *testing.T) {
+	balancerDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "balancer",
+			Namespace: "test-namespace",
+			UID:       "34c0bb8a-240b-4f2a-84ae-2eb2258298f9",
+		},
+	}
+	createTeam := func(team string, passcodeHash string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					"multi-juicer.owasp-juice.shop/passcode": passcodeHash,
+				},
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+		}
+	}
+	createService := func(team string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: "test-namespace",
+			},
+		}
+	}
+	failServiceCreation := func(clientset *fake.Clientset, err error) {
+		clientset.PrependReactor("create", "services", func(action testcore.Action) (bool, runtime.Object, error) {
+			return true, nil, err
+		})
+	}
+	serviceGroupResource := schema.GroupResource{Group: "", Resource: "services"}
+
+	t.Run("deletes the created deployment again if the service can't be created", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		failServiceCreation(clientset, errors.NewForbidden(serviceGroupResource, "juiceshop-foobar", fmt.Errorf("quota exceeded")))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		err := createTeamResources(context.Background(), bundle, "foobar", "", "hash", []sessions.Member{})
+
+		assert.EqualError(t, err, "failed to create instance")
+		_, err = clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err))
+	})
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		attempts := 0
+		clientset.PrependReactor("create", "services", func(action testcore.Action) (bool, runtime.Object, error) {
+			attempts++
+			if attempts == 1 {
+				return true, nil, errors.NewServiceUnavailable("etcd is unavailable")
+			}
+			return false, nil, nil
+		})
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		err := createTeamResources(context.Background(), bundle, "foobar", "", "hash", []sessions.Member{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		_, err = clientset.CoreV1().Services("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("completes a partially created team when retried with the same passcode", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam("foobar", "hash"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		err := createTeamResources(context.Background(), bundle, "foobar", "", "hash", []sessions.Member{})
+
+		assert.NoError(t, err)
+		_, err = clientset.CoreV1().Services("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("never touches a team which got created concurrently by somebody else", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam("foobar", "other-hash"), createService("foobar"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+
+		err := createTeamResources(context.Background(), bundle, "foobar", "", "hash", []sessions.Member{})
+
+		assert.Equal(t, errTeamAlreadyCreated, err)
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "other-hash", deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"])
+		_, err = clientset.CoreV1().Services("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("join returns a conflict if the team got created concurrently", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		// simulates another request creating the team between the existence check and the creation
+		clientset.PrependReactor("create", "deployments", func(action testcore.Action) (bool, runtime.Object, error) {
+			assert.NoError(t, clientset.Tracker().Add(createTeam("foobar", "other-hash")))
+			return true, nil, errors.NewAlreadyExists(schema.GroupResource{Group: "apps", Resource: "deployments"}, "juiceshop-foobar")
+		})
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		server := http.NewServeMux()
+		AddRoutes(server, bundle, nil, nil)
+
+		req, _ := http.NewRequest("POST", "/balancer/api/teams/foobar/join", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Empty(t, rr.Header().Get("Set-Cookie"))
+		deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "other-hash", deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"])
+	})
+
+	t.Run("join can recreate a team after a failed creation got rolled back", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		server := http.NewServeMux()
+		AddRoutes(server, bundle, nil, nil)
+		attempts := 0
+		clientset.PrependReactor("create", "services", func(action testcore.Action) (bool, runtime.Object, error) {
+			attempts++
+			if attempts == 1 {
+				return true, nil, errors.NewForbidden(serviceGroupResource, "juiceshop-foobar", fmt.Errorf("quota exceeded"))
+			}
+			return false, nil, nil
+		})
+
+		req, _ := http.NewRequest("POST", "/balancer/api/teams/foobar/join", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Empty(t, rr.Header().Get("Set-Cookie"))
+
+		req, _ = http.NewRequest("POST", "/balancer/api/teams/foobar/join", nil)
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"message":"Created Instance","passcode":"12345678"}`, rr.Body.String())
+	})
+}
+
+func TestAdminRepairInstanceHandler(t *testing.T) {
+	balancerDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "balancer",
+			Namespace: "test-namespace",
+			UID:       "34c0bb8a-240b-4f2a-84ae-2eb2258298f9",
+		},
+	}
+	teamDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "juiceshop-foobar",
+			Namespace: "test-namespace",
+		},
+	}
+	repair := func(clientset *fake.Clientset, team string, cookie string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/balancer/api/admin/teams/%s/repair", team), nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(cookie)))
+		rr := httptest.NewRecorder()
+		server := http.NewServeMux()
+		AddRoutes(server, testutil.NewTestBundleWithCustomFakeClient(clientset), nil, nil)
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("requires the operator role", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment, teamDeployment)
+		rr := repair(clientset, "foobar", "admin:viewer")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("recreates the missing service of a team", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment, teamDeployment)
+		rr := repair(clientset, "foobar", "admin:operator")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response AdminRepairInstanceResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.True(t, response.Repaired)
+		_, err := clientset.CoreV1().Services("test-namespace").Get(context.Background(), "juiceshop-foobar", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("leaves complete teams untouched", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment, teamDeployment, &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "juiceshop-foobar", Namespace: "test-namespace"},
+		})
+		rr := repair(clientset, "foobar", "admin")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"message":"Instance is complete","repaired":false}`, rr.Body.String())
+	})
+
+	t.Run("returns a 404 for teams without a deployment", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment)
+		rr := repair(clientset, "foobar", "admin")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
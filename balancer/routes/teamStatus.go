@@ -5,35 +5,54 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/scoring"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/sessions"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/teamcookie"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/teamnames"
 )
 
 type TeamStatus struct {
 	Name             string `json:"name"`
+	DisplayName      string `json:"displayName"`
 	Score            int    `json:"score"`
 	SolvedChallenges int    `json:"solvedChallenges"`
 	Position         int    `json:"position"`
 	TotalTeams       int    `json:"totalTeams"`
 	Readiness        bool   `json:"readiness"`
+	// Members registered for the team
+	Members []sessions.Member `json:"members"`
+	// MemberID of the member the request belongs to. Empty if joined without registering as member
+	MemberID string `json:"memberId,omitempty"`
+	// WaitlistPosition of the team, starting at 1. Omitted once the instance of the team has been created
+	WaitlistPosition int `json:"waitlistPosition,omitempty"`
+	// SecondsUntilCleanup until the instance gets deleted because of inactivity, unless the team uses it again. Omitted if the instance never gets deleted
+	SecondsUntilCleanup *int64 `json:"secondsUntilCleanup,omitempty"`
 }
 
 type AdminTeamStatus struct {
-	Name string `json:"name"`
+	Name    string      `json:"name"`
+	Account string      `json:"account"`
+	Role    b.AdminRole `json:"role"`
 }
 
-func handleTeamStatus(bundle *bundle.Bundle, scoringService *scoring.ScoringService) http.Handler {
+func handleTeamStatus(bundle *b.Bundle, scoringService *scoring.ScoringService) http.Handler {
 	return http.HandlerFunc(
 		func(responseWriter http.ResponseWriter, req *http.Request) {
-			team, err := teamcookie.GetTeamFromRequest(bundle, req)
+			session, err := teamcookie.GetSessionFromRequest(bundle, req)
 			if err != nil {
 				http.Error(responseWriter, "", http.StatusUnauthorized)
 				return
 			}
+			team := session.Team
 
-			if team == "admin" {
-				responseBytes, err := json.Marshal(AdminTeamStatus{Name: "admin"})
+			if b.IsAdminIdentity(team) {
+				admin, err := teamcookie.GetAdminFromRequest(bundle, req)
+				if err != nil {
+					http.Error(responseWriter, "", http.StatusUnauthorized)
+					return
+				}
+				responseBytes, err := json.Marshal(AdminTeamStatus{Name: teamnames.AdminTeamName, Account: admin.Name, Role: admin.Role})
 				if err != nil {
 					bundle.Log.Printf("Failed to marshal response: %s", err)
 					http.Error(responseWriter, "", http.StatusInternalServerError)
@@ -47,6 +66,7 @@ func handleTeamStatus(bundle *bundle.Bundle, scoringService *scoring.ScoringServ
 			}
 
 			var teamScore *scoring.TeamScore
+			waitlistEntry, waitlistPosition, waiting := bundle.Waitlist.Get(team)
 
 			if req.URL.Query().Get("wait-for-update-after") != "" {
 				lastSeenUpdate, err := time.Parse(time.RFC3339, req.URL.Query().Get("wait-for-update-after"))
@@ -55,32 +75,63 @@ func handleTeamStatus(bundle *bundle.Bundle, scoringService *scoring.ScoringServ
 					return
 				}
 				teamScore = scoringService.WaitForTeamUpdatesNewerThan(req.Context(), team, lastSeenUpdate)
-				if teamScore == nil {
+				// waiting teams don't have a score yet, but still get their current waitlist position after each wait
+				if teamScore == nil && !waiting {
 					responseWriter.WriteHeader(http.StatusNoContent)
 					responseWriter.Write([]byte{})
 					return
 				}
 			} else {
-				var ok bool
-				teamScore, ok = scoringService.GetScoreForTeam(team)
-				if !ok {
-					teamScore = &scoring.TeamScore{
-						Name:              team,
-						Score:             -1,
-						Position:          -1,
-						Challenges:        []scoring.ChallengeProgress{},
-						InstanceReadiness: false,
+				teamScore, _ = scoringService.GetScoreForTeam(team)
+			}
+			if teamScore != nil && !teamScore.InstanceReadiness {
+				// the status page waits for the instance to become ready, which hibernated instances only do once resumed
+				resumeIfHibernated(req.Context(), bundle, team)
+			}
+			if teamScore == nil {
+				displayName := team
+				if waitlistEntry.DisplayName != "" {
+					displayName = waitlistEntry.DisplayName
+				}
+				teamScore = &scoring.TeamScore{
+					Name:              team,
+					DisplayName:       displayName,
+					Score:             -1,
+					Position:          -1,
+					Challenges:        []scoring.ChallengeProgress{},
+					InstanceReadiness: false,
+				}
+			}
+
+			members := bundle.TeamSessions.GetMembers(team)
+			if waiting && len(waitlistEntry.Members) > 0 {
+				// members of waiting teams are only tracked on the waitlist until the instance got created
+				members = waitlistEntry.Members
+			}
+
+			var secondsUntilCleanup *int64
+			if !waiting && bundle.CleanupSchedule.Enabled() {
+				// only the cleaner knows when exactly it runs, so this is the earliest time the instance can get deleted
+				if instance, err := bundle.Instances.Get(req.Context(), team); err == nil {
+					if timeUntilDeletion, ok := bundle.CleanupSchedule.TimeUntilDeletion(instance.Annotations, instance.CreatedAt, time.Now()); ok {
+						seconds := int64(timeUntilDeletion.Seconds())
+						secondsUntilCleanup = &seconds
 					}
 				}
 			}
 
 			response := TeamStatus{
-				Name:             team,
-				Score:            teamScore.Score,
-				Position:         teamScore.Position,
-				TotalTeams:       len(scoringService.GetScores()),
-				SolvedChallenges: len(teamScore.Challenges),
-				Readiness:        teamScore.InstanceReadiness,
+				Name:                team,
+				DisplayName:         teamScore.DisplayName,
+				Score:               teamScore.Score,
+				Position:            teamScore.Position,
+				TotalTeams:          len(scoringService.GetScores()),
+				SolvedChallenges:    len(teamScore.Challenges),
+				Readiness:           teamScore.InstanceReadiness,
+				Members:             members,
+				MemberID:            session.MemberID,
+				WaitlistPosition:    waitlistPosition,
+				SecondsUntilCleanup: secondsUntilCleanup,
 			}
 
 			responseBytes, err := json.Marshal(response)
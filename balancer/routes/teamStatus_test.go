@@ -2,6 +2,7 @@ package routes
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,8 +10,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/juice-shop/multi-juicer/balancer/pkg/cleanup"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/scoring"
 	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/waitlist"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -58,12 +61,57 @@ func TestTeamStatusHandler(t *testing.T) {
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		scoringService := scoring.NewScoringService(bundle)
 		scoringService.CalculateAndCacheScoreBoard(context.Background())
-		AddRoutes(server, bundle, scoringService)
+		AddRoutes(server, bundle, scoringService, nil)
 
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"solvedChallenges":1,"totalTeams":2,"readiness":true}`, rr.Body.String())
+		assert.JSONEq(t, `{"name":"foobar","displayName":"foobar","score":10,"position":1,"solvedChallenges":1,"totalTeams":2,"readiness":true,"members":[]}`, rr.Body.String())
+	})
+
+	t.Run("returns the time until the instance gets deleted because of inactivity", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/teams/status", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
+		rr := httptest.NewRecorder()
+		server := http.NewServeMux()
+		deployment := createTeam("foobar", `[]`, "0")
+		deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"] = fmt.Sprintf("%d", time.Now().Add(-23*time.Hour).UnixMilli())
+		clientset := fake.NewSimpleClientset(deployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.CleanupSchedule, _ = cleanup.NewSchedule(cleanup.Config{Enabled: true, MaxInactive: "24h"})
+		scoringService := scoring.NewScoringService(bundle)
+		scoringService.CalculateAndCacheScoreBoard(context.Background())
+		AddRoutes(server, bundle, scoringService, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var status TeamStatus
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+		if assert.NotNil(t, status.SecondsUntilCleanup) {
+			assert.InDelta(t, 3600, *status.SecondsUntilCleanup, 5)
+		}
+	})
+
+	t.Run("omits the time until cleanup for protected instances", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/teams/status", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
+		rr := httptest.NewRecorder()
+		server := http.NewServeMux()
+		deployment := createTeam("foobar", `[]`, "0")
+		deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"] = fmt.Sprintf("%d", time.Now().Add(-23*time.Hour).UnixMilli())
+		deployment.Annotations["multi-juicer.owasp-juice.shop/protected"] = "true"
+		clientset := fake.NewSimpleClientset(deployment)
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.CleanupSchedule, _ = cleanup.NewSchedule(cleanup.Config{Enabled: true, MaxInactive: "24h"})
+		scoringService := scoring.NewScoringService(bundle)
+		scoringService.CalculateAndCacheScoreBoard(context.Background())
+		AddRoutes(server, bundle, scoringService, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "secondsUntilCleanup")
 	})
 
 	t.Run("returns -1 for position and score if it hasn't been calculated yet", func(t *testing.T) {
@@ -75,12 +123,33 @@ func TestTeamStatusHandler(t *testing.T) {
 		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
 		scoringService := scoring.NewScoringService(bundle)
 		scoringService.CalculateAndCacheScoreBoard(context.Background())
-		AddRoutes(server, bundle, scoringService)
+		AddRoutes(server, bundle, scoringService, nil)
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"name":"foobar","displayName":"foobar","score":-1,"position":-1,"solvedChallenges":0,"totalTeams":1,"readiness":false,"members":[]}`, rr.Body.String())
+	})
+
+	t.Run("returns the waitlist position of teams waiting for their instance", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/balancer/api/teams/status", nil)
+		req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
+		rr := httptest.NewRecorder()
+		server := http.NewServeMux()
+		clientset := fake.NewSimpleClientset(createTeam("other-team", `[]`, "0"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		_, err := bundle.Waitlist.Enqueue(context.Background(), waitlist.Entry{Team: "first-team", PasscodeHash: "hash"})
+		assert.NoError(t, err)
+		_, err = bundle.Waitlist.Enqueue(context.Background(), waitlist.Entry{Team: team, DisplayName: "Los Hackers", PasscodeHash: "hash"})
+		assert.NoError(t, err)
+		scoringService := scoring.NewScoringService(bundle)
+		scoringService.CalculateAndCacheScoreBoard(context.Background())
+		AddRoutes(server, bundle, scoringService, nil)
 
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.JSONEq(t, `{"name":"foobar","score":-1,"position":-1,"solvedChallenges":0,"totalTeams":1,"readiness":false}`, rr.Body.String())
+		assert.JSONEq(t, `{"name":"foobar","displayName":"Los Hackers","score":-1,"position":-1,"solvedChallenges":0,"totalTeams":1,"readiness":false,"members":[],"waitlistPosition":2}`, rr.Body.String())
 	})
 
 	t.Run("returns ready when instance gets update by the scoring watcher", func(t *testing.T) {
@@ -95,7 +164,7 @@ func TestTeamStatusHandler(t *testing.T) {
 		scoringService.CalculateAndCacheScoreBoard(ctx)
 		go scoringService.StartingScoringWorker(ctx)
 
-		AddRoutes(server, bundle, scoringService)
+		AddRoutes(server, bundle, scoringService, nil)
 
 		{
 			req, _ := http.NewRequest("GET", "/balancer/api/teams/status", nil)
@@ -103,7 +172,7 @@ func TestTeamStatusHandler(t *testing.T) {
 			req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
 			server.ServeHTTP(rr, req)
 			assert.Equal(t, http.StatusOK, rr.Code)
-			assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"solvedChallenges":1,"totalTeams":1,"readiness":false}`, rr.Body.String())
+			assert.JSONEq(t, `{"name":"foobar","displayName":"foobar","score":10,"position":1,"solvedChallenges":1,"totalTeams":1,"readiness":false,"members":[]}`, rr.Body.String())
 		}
 
 		watcher.Modify(createTeamNumberOfReadyReplicas(team, `[{"key":"scoreBoardChallenge","solvedAt":"2024-11-01T19:55:48.211Z"}]`, "1", 1))
@@ -115,7 +184,7 @@ func TestTeamStatusHandler(t *testing.T) {
 			req.Header.Set("Cookie", fmt.Sprintf("team=%s", testutil.SignTestTeamname(team)))
 			server.ServeHTTP(rr, req)
 			assert.Equal(t, http.StatusOK, rr.Code)
-			return assert.JSONEq(t, `{"name":"foobar","score":10,"position":1,"solvedChallenges":1,"totalTeams":1,"readiness":true}`, rr.Body.String())
+			return assert.JSONEq(t, `{"name":"foobar","displayName":"foobar","score":10,"position":1,"solvedChallenges":1,"totalTeams":1,"readiness":true,"members":[]}`, rr.Body.String())
 		}, 1*time.Second, 10*time.Millisecond)
 	})
 
@@ -127,7 +196,7 @@ func TestTeamStatusHandler(t *testing.T) {
 
 		bundle := testutil.NewTestBundle()
 		scoringService := scoring.NewScoringService(bundle)
-		AddRoutes(server, bundle, scoringService)
+		AddRoutes(server, bundle, scoringService, nil)
 
 		server.ServeHTTP(rr, req)
 
@@ -143,11 +212,11 @@ func TestTeamStatusHandler(t *testing.T) {
 
 		bundle := testutil.NewTestBundle()
 		scoringService := scoring.NewScoringService(bundle)
-		AddRoutes(server, bundle, scoringService)
+		AddRoutes(server, bundle, scoringService, nil)
 
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.JSONEq(t, `{"name":"admin"}`, rr.Body.String())
+		assert.JSONEq(t, `{"name":"admin","account":"admin","role":"owner"}`, rr.Body.String())
 	})
 }
@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"context"
+	"time"
+
+	b "github.com/juice-shop/multi-juicer/balancer/pkg/bundle"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/reservations"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/waitlist"
+)
+
+const waitlistProvisioningInterval = 5 * time.Second
+
+// StartWaitlistProvisioner regularly creates the instances of waiting teams, once instances got deleted by the cleaner or an admin and capacity is available again
+func StartWaitlistProvisioner(ctx context.Context, bundle *b.Bundle) {
+	ticker := time.NewTicker(waitlistProvisioningInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			bundle.Log.Printf("MultiJuicer context canceled. Exiting the waitlist provisioner.")
+			return
+		case <-ticker.C:
+			provisionWaitingTeams(ctx, bundle)
+		}
+	}
+}
+
+// provisionWaitingTeams creates the instances of the teams at the front of the waitlist until the max instance count is reached again
+func provisionWaitingTeams(ctx context.Context, bundle *b.Bundle) {
+	for {
+		entries := bundle.Waitlist.GetEntries()
+		if len(entries) == 0 {
+			return
+		}
+		if !provisionWaitingTeam(ctx, bundle, entries[0]) {
+			return
+		}
+	}
+}
+
+// provisionWaitingTeam creates the instance of the team and removes it from the waitlist. Returns false if the instance couldn't be created (yet)
+func provisionWaitingTeam(ctx context.Context, bundle *b.Bundle, entry waitlist.Entry) bool {
+	_, err := bundle.Instances.Get(ctx, entry.Team)
+	if err == nil {
+		// already created by another balancer replica
+		return removeProvisionedTeamFromWaitlist(ctx, bundle, entry.Team)
+	} else if err != b.ErrInstanceNotFound {
+		bundle.Log.Printf("Failed to check if the instance of waiting team '%s' exists: %s", entry.Team, err)
+		return false
+	}
+
+	err = bundle.InstanceReservations.Reserve(ctx, entry.Team, bundle.Config.MaxInstances)
+	if err == reservations.ErrMaxInstancesReached {
+		return false
+	} else if err != nil {
+		bundle.Log.Printf("Failed to reserve instance for waiting team '%s': %s", entry.Team, err)
+		return false
+	}
+	defer releaseInstanceReservation(bundle, entry.Team)
+
+	err = createTeamResources(ctx, bundle, entry.Team, entry.DisplayName, entry.PasscodeHash, entry.Members)
+	if err == errTeamAlreadyCreated {
+		// the team name got taken by another team in the meantime, the waiting team can't get its instance anymore
+		bundle.Log.Printf("Team '%s' got created by somebody else while it was waiting. Removing it from the waitlist", entry.Team)
+		return removeProvisionedTeamFromWaitlist(ctx, bundle, entry.Team)
+	} else if err != nil {
+		bundle.Log.Printf("Failed to create instance for waiting team '%s': %s", entry.Team, err)
+		return false
+	}
+	bundle.Log.Printf("Created instance for team '%s' from the waitlist", entry.Team)
+	return removeProvisionedTeamFromWaitlist(ctx, bundle, entry.Team)
+}
+
+// removeProvisionedTeamFromWaitlist returns false if the team is still on the waitlist, so that the provisioning stops until the next run instead of retrying right away
+func removeProvisionedTeamFromWaitlist(ctx context.Context, bundle *b.Bundle, team string) bool {
+	if _, err := bundle.Waitlist.Remove(ctx, team); err != nil && err != waitlist.ErrNotWaiting {
+		bundle.Log.Printf("Failed to remove team '%s' from the waitlist after its instance got created: %s", team, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/juice-shop/multi-juicer/balancer/pkg/testutil"
+	"github.com/juice-shop/multi-juicer/balancer/pkg/waitlist"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWaitlistProvisioner(t *testing.T) {
+	balancerDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "balancer",
+			Namespace: "test-namespace",
+			UID:       "34c0bb8a-240b-4f2a-84ae-2eb2258298f9",
+		},
+	}
+	createTeam := func(team string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+					"team":                      team,
+				},
+			},
+		}
+	}
+
+	t.Run("creates the instances of waiting teams in order until the max instance count is reached", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam("team-1"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.MaxInstances = 3
+		passcodeHash, _ := bcrypt.GenerateFromPassword([]byte("87654321"), bundle.BcryptRounds)
+		for _, team := range []string{"team-a", "team-b", "team-c"} {
+			_, err := bundle.Waitlist.Enqueue(context.Background(), waitlist.Entry{Team: team, DisplayName: "Waiting " + team, PasscodeHash: string(passcodeHash)})
+			assert.NoError(t, err)
+		}
+
+		provisionWaitingTeams(context.Background(), bundle)
+
+		for _, team := range []string{"team-a", "team-b"} {
+			deployment, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, string(passcodeHash), deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"])
+			assert.Equal(t, "Waiting "+team, deployment.Annotations["multi-juicer.owasp-juice.shop/displayName"])
+			_, err = clientset.CoreV1().Services("test-namespace").Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
+			assert.NoError(t, err)
+		}
+		_, err := clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-team-c", metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err))
+
+		entries := bundle.Waitlist.GetEntries()
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "team-c", entries[0].Team)
+	})
+
+	t.Run("creates the instance of the next waiting team once an instance got deleted", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam("team-1"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		bundle.Config.MaxInstances = 1
+		_, err := bundle.Waitlist.Enqueue(context.Background(), waitlist.Entry{Team: "team-a", PasscodeHash: "hash"})
+		assert.NoError(t, err)
+
+		provisionWaitingTeams(context.Background(), bundle)
+		assert.Equal(t, 1, bundle.Waitlist.Len())
+
+		assert.NoError(t, clientset.AppsV1().Deployments("test-namespace").Delete(context.Background(), "juiceshop-team-1", metav1.DeleteOptions{}))
+		provisionWaitingTeams(context.Background(), bundle)
+
+		assert.Equal(t, 0, bundle.Waitlist.Len())
+		_, err = clientset.AppsV1().Deployments("test-namespace").Get(context.Background(), "juiceshop-team-a", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("removes waiting teams whose instance has already been created by another balancer", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(balancerDeployment, createTeam("team-a"))
+		bundle := testutil.NewTestBundleWithCustomFakeClient(clientset)
+		_, err := bundle.Waitlist.Enqueue(context.Background(), waitlist.Entry{Team: "team-a", PasscodeHash: "hash"})
+		assert.NoError(t, err)
+
+		provisionWaitingTeams(context.Background(), bundle)
+
+		assert.Equal(t, 0, bundle.Waitlist.Len())
+	})
+}
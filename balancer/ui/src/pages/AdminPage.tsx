@@ -114,15 +114,21 @@ function DeleteInstanceButton({ team }: { team: string }) {
 interface Team {
   team: string;
   ready: boolean;
+  hibernated: boolean;
+  protected: boolean;
   createdAt: Date;
   lastConnect: Date;
+  cleanupDue: boolean;
 }
 
 interface TeamRaw {
   team: string;
   ready: boolean;
+  hibernated: boolean;
+  protected: boolean;
   createdAt: string;
   lastConnect: string;
+  cleanupDue: boolean;
 }
 
 async function fetchAdminData(): Promise<Team[]> {
@@ -210,8 +216,28 @@ export default function AdminPage() {
             </div>
             <div>
               <p className="text-sm text-gray-800 dark:text-gray-200">
-                {team.ready ? "up and running 🟢" : "down ⚠️"}
+                {team.ready
+                  ? "up and running 🟢"
+                  : team.hibernated
+                    ? "hibernated 💤"
+                    : "down ⚠️"}
               </p>
+              {team.protected && (
+                <p className="text-sm text-gray-800 dark:text-gray-200">
+                  <FormattedMessage
+                    id="admin_table.protected"
+                    defaultMessage="protected from cleanup 🛡️"
+                  />
+                </p>
+              )}
+              {team.cleanupDue && (
+                <p className="text-sm text-gray-800 dark:text-gray-200">
+                  <FormattedMessage
+                    id="admin_table.cleanupDue"
+                    defaultMessage="deleted by next cleanup ⏳"
+                  />
+                </p>
+              )}
               <p className="text-sm text-gray-800 dark:text-gray-200">
                 {" "}
                 <FormattedMessage
@@ -7,6 +7,12 @@ import { Button } from "../components/Button";
 import toast from "react-hot-toast";
 import { PositionDisplay } from "../components/PositionDisplay";
 
+interface TeamMember {
+  id: string;
+  name: string;
+  joinedAt: number;
+}
+
 interface TeamStatusResponse {
   name: string;
   score: string;
@@ -14,8 +20,13 @@ interface TeamStatusResponse {
   totalTeams: number;
   solvedChallenges: number;
   readiness: boolean;
+  members?: TeamMember[];
+  secondsUntilCleanup?: number;
 }
 
+// teams get warned once their instance gets deleted within the next hour because it wasn't used
+const CLEANUP_WARNING_THRESHOLD_MS = 60 * 60 * 1000;
+
 async function fetchSettings(callback: (data: Record<string, any>)=> void) {
   try {
     const response = await fetch('/balancer/api/settings/all');
@@ -118,6 +129,109 @@ const PasscodeResetButton = ({ team }: { team: string }) => {
   );
 };
 
+function TeamMembers({ team, members }: { team: string; members: TeamMember[] }) {
+  const navigate = useNavigate();
+  const intl = useIntl();
+  const [removingMember, setRemovingMember] = useState<string | null>(null);
+  // the status only gets sent again once the score changes, so removed members are hidden right away
+  const [removedMembers, setRemovedMembers] = useState<string[]>([]);
+
+  const remainingMembers = members.filter(
+    (member) => !removedMembers.includes(member.id)
+  );
+  if (remainingMembers.length === 0) {
+    return null;
+  }
+
+  async function removeMember(member: TeamMember) {
+    // removing a member only ends its current session, the passcode has to be reset as well to keep it from joining again
+    const confirmed = confirm(
+      intl.formatMessage(
+        {
+          id: "remove_member_confirmation",
+          defaultMessage:
+            "Remove {name} from the team? This only ends their current session, they can join again as long as they know the passcode.",
+        },
+        { name: member.name }
+      )
+    );
+    if (!confirmed) {
+      return;
+    }
+    const resetPasscode = confirm(
+      intl.formatMessage(
+        {
+          id: "remove_member_reset_passcode",
+          defaultMessage:
+            "Also reset the passcode, so that {name} can't join again? Everyone else has to join again with the new passcode.",
+        },
+        { name: member.name }
+      )
+    );
+
+    setRemovingMember(member.id);
+    try {
+      const response = await fetch(
+        `/balancer/api/teams/members/${encodeURIComponent(member.id)}${
+          resetPasscode ? "?resetPasscode=true" : ""
+        }`,
+        { method: "DELETE" }
+      );
+      if (!response.ok) {
+        throw new Error(`Failed to remove member, status: ${response.status}`);
+      }
+      setRemovedMembers((removed) => [...removed, member.id]);
+      toast.success(
+        intl.formatMessage(
+          {
+            id: "remove_member_success",
+            defaultMessage: "Removed {name} from the team",
+          },
+          { name: member.name }
+        )
+      );
+      if (resetPasscode) {
+        const data = await response.json();
+        navigate(`/teams/${team}/status/`, {
+          state: { passcode: data.passcode, reset: true },
+        });
+      }
+    } catch (error) {
+      console.error("Failed to remove member", error);
+    } finally {
+      setRemovingMember(null);
+    }
+  }
+
+  return (
+    <>
+      <div className="p-4 text-sm">
+        <p className="font-medium mb-2">
+          <FormattedMessage id="team_members" defaultMessage="Team Members" />
+        </p>
+        <ul className="flex flex-col gap-2">
+          {remainingMembers.map((member) => (
+            <li
+              key={member.id}
+              className="flex flex-row justify-between items-center"
+            >
+              <span>{member.name}</span>
+              <button
+                onClick={() => removeMember(member)}
+                disabled={removingMember !== null}
+                className="bg-gray-300 text-gray-800 font-semibold py-1 px-3 rounded-sm"
+              >
+                <FormattedMessage id="remove_member" defaultMessage="Remove" />
+              </button>
+            </li>
+          ))}
+        </ul>
+      </div>
+      <hr className="border-gray-500" />
+    </>
+  );
+}
+
 async function fetchTeamStatusData(
   lastSeen: Date | null
 ): Promise<TeamStatusResponse | null> {
@@ -139,6 +253,36 @@ async function fetchTeamStatusData(
   return status;
 }
 
+function CleanupWarning({ cleanupAt }: { cleanupAt: Date | null }) {
+  const [now, setNow] = useState(Date.now());
+
+  useEffect(() => {
+    const interval = window.setInterval(() => setNow(Date.now()), 30000);
+    return () => clearInterval(interval);
+  }, []);
+
+  if (cleanupAt === null) {
+    return null;
+  }
+  const remainingMs = cleanupAt.getTime() - now;
+  if (remainingMs > CLEANUP_WARNING_THRESHOLD_MS) {
+    return null;
+  }
+
+  return (
+    <>
+      <div className="p-4 text-sm text-orange-800 dark:text-orange-200">
+        <FormattedMessage
+          id="cleanup_warning"
+          defaultMessage="Your JuiceShop instance will be deleted in about {minutes} minutes, together with your progress, because it hasn't been used for a while, reached its maximum lifetime or the event is ending."
+          values={{ minutes: Math.max(Math.ceil(remainingMs / 60000), 0) }}
+        />
+      </div>
+      <hr className="border-gray-500" />
+    </>
+  );
+}
+
 export const TeamStatusPage = ({
   setActiveTeam,
 }: {
@@ -153,6 +297,7 @@ export const TeamStatusPage = ({
 
   const [scoreOverviewEnabled, setScoreOverviewEnabled] = useState(false);
   const [balancerEnabled, setBalancerEnabled] = useState(false);
+  const [cleanupAt, setCleanupAt] = useState<Date | null>(null);
 
   function setData(data: Record<string, any>) {
     setScoreOverviewEnabled(data.scoreOverviewVisibleForUsers);
@@ -178,6 +323,12 @@ export const TeamStatusPage = ({
         return;
       }
       setInstanceStatus(status);
+      // the status only gets sent again once the score changes, so the remaining time is tracked as deadline
+      setCleanupAt(
+        status.secondsUntilCleanup !== undefined
+          ? new Date(Date.now() + status.secondsUntilCleanup * 1000)
+          : null
+      );
       setActiveTeam(status.name);
       const waitTime = status.readiness ? 5000 : 1000; // poll faster when not ready, as the instance is starting and we want to show the user the status as soon as possible
       timeout = window.setTimeout(() => updateStatusData(new Date()), waitTime);
@@ -237,6 +388,10 @@ export const TeamStatusPage = ({
         <ScoreDisplay instanceStatus={instanceStatus} scoreOverviewEnabled={scoreOverviewEnabled} />
         <hr className="border-gray-500" />
 
+        <CleanupWarning cleanupAt={cleanupAt} />
+
+        <TeamMembers team={team} members={instanceStatus?.members ?? []} />
+
         {passcode && (
           <>
             <div className="flex flex-col justify-start p-4">
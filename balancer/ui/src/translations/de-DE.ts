@@ -24,6 +24,15 @@ export default {
   instance_status_ready: "Juice Shop-Instanz bereit",
   instance_status_start_hacking: "Anfangen zu hacken",
   instance_status_starting: "Juice Shop-Instanz startet",
+  team_members: "Teammitglieder",
+  remove_member: "Entfernen",
+  remove_member_confirmation:
+    "{name} aus dem Team entfernen? Dadurch wird nur die aktuelle Sitzung beendet, solange {name} das Passwort kennt, kann {name} dem Team wieder beitreten.",
+  remove_member_reset_passcode:
+    "Auch das Passwort zurücksetzen, damit {name} nicht wieder beitreten kann? Alle anderen müssen dem Team dann mit dem neuen Passwort erneut beitreten.",
+  remove_member_success: "{name} wurde aus dem Team entfernt",
+  cleanup_warning:
+    "Deine Juice Shop-Instanz wird in etwa {minutes} Minuten mitsamt deinem Fortschritt gelöscht, da sie eine Weile nicht genutzt wurde, ihre maximale Laufzeit erreicht hat oder die Veranstaltung endet.",
   "admin_table.table_header": "Aktive Teams",
   "admin_table.teamname": "Teamname",
   "admin_table.created": "Erstellt",
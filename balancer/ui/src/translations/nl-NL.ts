@@ -20,6 +20,15 @@ export default {
   instance_status_ready: 'Juice Shop is beschikbaar',
   instance_status_start_hacking: 'Start Hacking',
   instance_status_starting: 'Juice Shop bezig met starten',
+  team_members: 'Teamleden',
+  remove_member: 'Verwijderen',
+  remove_member_confirmation:
+    '{name} uit het team verwijderen? Dit beëindigt alleen de huidige sessie, zolang {name} de passcode kent kan {name} opnieuw aansluiten.',
+  remove_member_reset_passcode:
+    'Ook de passcode resetten, zodat {name} niet opnieuw kan aansluiten? Alle anderen moeten dan opnieuw aansluiten met de nieuwe passcode.',
+  remove_member_success: '{name} is uit het team verwijderd',
+  cleanup_warning:
+    'Je Juice Shop wordt over ongeveer {minutes} minuten verwijderd, samen met je voortgang, omdat hij een tijdje niet is gebruikt, zijn maximale levensduur heeft bereikt of het evenement eindigt.',
   'admin_table.table_header': 'Active Teams',
   'admin_table.teamname': 'Teamnaam',
   'admin_table.created': 'Aangemaakt',
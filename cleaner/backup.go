@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the backups are read by the balancer to restore the progress of teams rejoining after their instance got deleted
+const backupConfigMapPrefix = "juiceshop-backup-"
+const backupConfigMapDataKey = "backup.json"
+const backupLabelSelector = "app.kubernetes.io/name=juice-shop-backup,app.kubernetes.io/part-of=multi-juicer"
+
+// defaultBackupRetention after which backups get deleted if BACKUP_RETENTION_DURATION isn't set
+const defaultBackupRetention = 7 * 24 * time.Hour
+
+// ProgressBackup of a team, written before its instance gets deleted
+type ProgressBackup struct {
+	Team         string `json:"team"`
+	PasscodeHash string `json:"passcodeHash"`
+	DisplayName  string `json:"displayName,omitempty"`
+	// Challenges solved by the team, in the format of the challenges annotation
+	Challenges       json.RawMessage `json:"challenges"`
+	ChallengesSolved int             `json:"challengesSolved"`
+	// SessionGeneration of the team. Restored teams continue with a higher generation, so that sessions revoked before the deletion stay revoked
+	SessionGeneration int64 `json:"sessionGeneration"`
+	// RemovedMembers of the team, in the format of the removed members annotation, so that removed members can't sign back in with their old cookie
+	RemovedMembers json.RawMessage `json:"removedMembers,omitempty"`
+	// CreatedAt of the deleted instance
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastRequest *time.Time `json:"lastRequest,omitempty"`
+	BackedUpAt  time.Time  `json:"backedUpAt"`
+}
+
+func getTeam(deployment appsv1.Deployment) string {
+	if team := deployment.Labels["team"]; team != "" {
+		return team
+	}
+	return strings.TrimPrefix(deployment.Name, "juiceshop-")
+}
+
+// backupProgress persists the progress of the team in a ConfigMap, replacing older backups of the same team.
+// Returns false without error for instances without passcode, as the team couldn't prove to be the same team when rejoining
+func backupProgress(clientset kubernetes.Interface, deployment appsv1.Deployment, currentTime time.Time) (bool, error) {
+	passcodeHash := deployment.Annotations["multi-juicer.owasp-juice.shop/passcode"]
+	if passcodeHash == "" {
+		return false, nil
+	}
+	team := getTeam(deployment)
+
+	challenges := json.RawMessage("[]")
+	if challengesAnnotation := deployment.Annotations["multi-juicer.owasp-juice.shop/challenges"]; challengesAnnotation != "" {
+		if !json.Valid([]byte(challengesAnnotation)) {
+			return false, fmt.Errorf("the challenges annotation of team '%s' is not valid json", team)
+		}
+		challenges = json.RawMessage(challengesAnnotation)
+	}
+	challengesSolved, _ := strconv.Atoi(deployment.Annotations["multi-juicer.owasp-juice.shop/challengesSolved"])
+	sessionGeneration, _ := strconv.ParseInt(deployment.Annotations["multi-juicer.owasp-juice.shop/sessionGeneration"], 10, 64)
+	var removedMembers json.RawMessage
+	if removedMembersAnnotation := deployment.Annotations["multi-juicer.owasp-juice.shop/removedMembers"]; removedMembersAnnotation != "" {
+		if !json.Valid([]byte(removedMembersAnnotation)) {
+			return false, fmt.Errorf("the removed members annotation of team '%s' is not valid json", team)
+		}
+		removedMembers = json.RawMessage(removedMembersAnnotation)
+	}
+
+	backup := ProgressBackup{
+		Team:              team,
+		PasscodeHash:      passcodeHash,
+		DisplayName:       deployment.Annotations["multi-juicer.owasp-juice.shop/displayName"],
+		Challenges:        challenges,
+		ChallengesSolved:  challengesSolved,
+		SessionGeneration: max(sessionGeneration, 0),
+		RemovedMembers:    removedMembers,
+		CreatedAt:         deployment.CreationTimestamp.Time,
+		BackedUpAt:        currentTime,
+	}
+	if lastRequest, err := strconv.ParseInt(deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"], 10, 64); err == nil {
+		lastRequestTime := time.UnixMilli(lastRequest)
+		backup.LastRequest = &lastRequestTime
+	}
+	encodedBackup, err := json.Marshal(backup)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode backup: %w", err)
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupConfigMapPrefix + team,
+			Namespace: deployment.Namespace,
+			Labels: map[string]string{
+				"team":                      team,
+				"app.kubernetes.io/name":    "juice-shop-backup",
+				"app.kubernetes.io/part-of": "multi-juicer",
+			},
+		},
+		Data: map[string]string{backupConfigMapDataKey: string(encodedBackup)},
+	}
+	configMaps := clientset.CoreV1().ConfigMaps(deployment.Namespace)
+	_, err = configMaps.Create(context.Background(), configMap, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		_, err = configMaps.Update(context.Background(), configMap, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to persist backup: %w", err)
+	}
+	return true, nil
+}
+
+// pruneBackups deletes backups older than the retention, so that the teams names can be used by new teams again and the passcode hashes don't stay around.
+// Backups of teams whose instance got created after the backup have already been restored by the balancer and are deleted as well, in case the balancer failed to delete them
+func pruneBackups(clientset kubernetes.Interface, deployments []appsv1.Deployment, currentTime time.Time, config CleanupConfig, summary *CleanupSummary) {
+	instanceCreatedAt := map[string]time.Time{}
+	for _, deployment := range deployments {
+		instanceCreatedAt[getTeam(deployment)] = deployment.CreationTimestamp.Time
+	}
+
+	var configMaps *corev1.ConfigMapList
+	err := retryOnTransientError(func() (err error) {
+		configMaps, err = clientset.CoreV1().ConfigMaps(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: backupLabelSelector})
+		return err
+	})
+	if err != nil {
+		logger.Printf("Failed to list progress backups to prune: %v", err)
+		return
+	}
+
+	for _, configMap := range configMaps.Items {
+		var backup ProgressBackup
+		if err := json.Unmarshal([]byte(configMap.Data[backupConfigMapDataKey]), &backup); err != nil {
+			logger.Printf("Skipping invalid progress backup %s: %v", configMap.Name, err)
+			continue
+		}
+		createdAt, hasInstance := instanceCreatedAt[backup.Team]
+		restored := hasInstance && createdAt.After(backup.BackedUpAt)
+		if !restored && currentTime.Sub(backup.BackedUpAt) <= config.BackupRetention {
+			continue
+		}
+		if config.DryRun {
+			logger.Printf("Would delete progress backup %s from %s", configMap.Name, backup.BackedUpAt.Format(time.RFC3339))
+			continue
+		}
+		err := retryOnTransientError(func() error {
+			return clientset.CoreV1().ConfigMaps(configMap.Namespace).Delete(context.Background(), configMap.Name, metav1.DeleteOptions{})
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			logger.Printf("Failed to delete progress backup %s: %v", configMap.Name, err)
+			continue
+		}
+		logger.Printf("Deleted progress backup %s from %s", configMap.Name, backup.BackedUpAt.Format(time.RFC3339))
+		summary.PrunedBackups++
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+)
+
+func TestProgressBackup(t *testing.T) {
+	createDeployment := func(team string, annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: testNamespace,
+				Labels: map[string]string{
+					"team":                      team,
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+				},
+				Annotations: annotations,
+			},
+		}
+	}
+	inactiveSince := time.UnixMilli(time.Now().Add(-48 * time.Hour).UnixMilli())
+	getBackup := func(t *testing.T, clientset *fake.Clientset, team string) ProgressBackup {
+		configMap, err := clientset.CoreV1().ConfigMaps(testNamespace).Get(context.Background(), "juiceshop-backup-"+team, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Expected a backup of team %s, got: %v", team, err)
+		}
+		var backup ProgressBackup
+		if err := json.Unmarshal([]byte(configMap.Data["backup.json"]), &backup); err != nil {
+			t.Fatal(err)
+		}
+		return backup
+	}
+	createBackup := func(team string, backedUpAt time.Time) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "juiceshop-backup-" + team,
+				Namespace: testNamespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop-backup",
+					"app.kubernetes.io/part-of": "multi-juicer",
+				},
+			},
+			Data: map[string]string{"backup.json": fmt.Sprintf(`{"team":"%s","backedUpAt":"%s"}`, team, backedUpAt.Format(time.RFC3339))},
+		}
+	}
+
+	t.Run("Backs Up The Progress Before Deleting The Instance", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createDeployment("team1", map[string]string{
+			"multi-juicer.owasp-juice.shop/lastRequest":      strconv.FormatInt(inactiveSince.UnixMilli(), 10),
+			"multi-juicer.owasp-juice.shop/passcode":         "$2a$10$passcodehash",
+			"multi-juicer.owasp-juice.shop/displayName":      "Team One",
+			"multi-juicer.owasp-juice.shop/challengesSolved": "1",
+			"multi-juicer.owasp-juice.shop/challenges":       `[{"key":"scoreBoardChallenge","solvedAt":"2024-10-18T13:54:27.397Z"}]`,
+		}))
+		currentTime := time.Now()
+
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: 24 * time.Hour, BackupProgress: true})
+
+		if summary.SuccessfulDeploymentDeletions != 1 || summary.SuccessfulBackups != 1 || !summary.Instances[0].BackedUp {
+			t.Errorf("Expected the instance to be backed up and deleted, got: %v", summary)
+		}
+		backup := getBackup(t, clientset, "team1")
+		if backup.Team != "team1" || backup.PasscodeHash != "$2a$10$passcodehash" || backup.DisplayName != "Team One" || backup.ChallengesSolved != 1 {
+			t.Errorf("Unexpected backup: %v", backup)
+		}
+		if string(backup.Challenges) != `[{"key":"scoreBoardChallenge","solvedAt":"2024-10-18T13:54:27.397Z"}]` {
+			t.Errorf("Unexpected challenges in backup: %s", backup.Challenges)
+		}
+		if !backup.LastRequest.Equal(inactiveSince) || !backup.BackedUpAt.Equal(currentTime) {
+			t.Errorf("Unexpected timestamps in backup: %v", backup)
+		}
+	})
+
+	t.Run("Backs Up The Sessions Of The Team", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createDeployment("team1", map[string]string{
+			"multi-juicer.owasp-juice.shop/lastRequest":       strconv.FormatInt(inactiveSince.UnixMilli(), 10),
+			"multi-juicer.owasp-juice.shop/passcode":          "$2a$10$passcodehash",
+			"multi-juicer.owasp-juice.shop/sessionGeneration": "2",
+			"multi-juicer.owasp-juice.shop/removedMembers":    `["removed-member"]`,
+		}))
+
+		mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, BackupProgress: true})
+
+		backup := getBackup(t, clientset, "team1")
+		if backup.SessionGeneration != 2 {
+			t.Errorf("Expected the session generation 2 to be backed up, got: %d", backup.SessionGeneration)
+		}
+		if string(backup.RemovedMembers) != `["removed-member"]` {
+			t.Errorf("Unexpected removed members in backup: %s", backup.RemovedMembers)
+		}
+	})
+
+	t.Run("Replaces Older Backups Of The Same Team", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(
+			createDeployment("team1", map[string]string{
+				"multi-juicer.owasp-juice.shop/lastRequest":      strconv.FormatInt(inactiveSince.UnixMilli(), 10),
+				"multi-juicer.owasp-juice.shop/passcode":         "$2a$10$newhash",
+				"multi-juicer.owasp-juice.shop/challengesSolved": "0",
+			}),
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "juiceshop-backup-team1", Namespace: testNamespace},
+				Data:       map[string]string{"backup.json": `{"team":"team1","passcodeHash":"$2a$10$oldhash"}`},
+			},
+		)
+
+		mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, BackupProgress: true})
+
+		if backup := getBackup(t, clientset, "team1"); backup.PasscodeHash != "$2a$10$newhash" || string(backup.Challenges) != "[]" {
+			t.Errorf("Expected the backup to be replaced, got: %v", backup)
+		}
+	})
+
+	t.Run("Keeps The Instance If The Backup Fails", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createDeployment("team1", map[string]string{
+			"multi-juicer.owasp-juice.shop/lastRequest": strconv.FormatInt(inactiveSince.UnixMilli(), 10),
+			"multi-juicer.owasp-juice.shop/passcode":    "$2a$10$passcodehash",
+		}))
+		clientset.PrependReactor("create", "configmaps", func(action clientgotesting.Action) (handled bool, ret runtime.Object, err error) {
+			return true, nil, fmt.Errorf("failed to create config map")
+		})
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, BackupProgress: true})
+
+		if summary.SuccessfulDeploymentDeletions != 0 || summary.FailedBackups != 1 || summary.Instances[0].Error == "" {
+			t.Errorf("Expected the instance to be kept, got: %v", summary)
+		}
+		if _, err := clientset.AppsV1().Deployments(testNamespace).Get(context.Background(), "juiceshop-team1", metav1.GetOptions{}); err != nil {
+			t.Errorf("Expected the deployment to still exist, got: %v", err)
+		}
+	})
+
+	t.Run("Prunes Backups Older Than The Retention", func(t *testing.T) {
+		currentTime := time.Now()
+		clientset := fake.NewSimpleClientset(
+			createBackup("team1", currentTime.Add(-8*24*time.Hour)),
+			createBackup("team2", currentTime.Add(-24*time.Hour)),
+		)
+
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: 24 * time.Hour, BackupProgress: true, BackupRetention: 7 * 24 * time.Hour})
+
+		if summary.PrunedBackups != 1 {
+			t.Errorf("Expected 1 pruned backup, got: %v", summary)
+		}
+		configMaps, _ := clientset.CoreV1().ConfigMaps(testNamespace).List(context.Background(), metav1.ListOptions{})
+		if len(configMaps.Items) != 1 || configMaps.Items[0].Name != "juiceshop-backup-team2" {
+			t.Errorf("Expected only the backup of team2 to be kept, got: %v", configMaps.Items)
+		}
+	})
+
+	t.Run("Prunes Backups Of Restored Teams Even If Backups Are Disabled", func(t *testing.T) {
+		currentTime := time.Now()
+		restoredInstance := createDeployment("team1", map[string]string{
+			"multi-juicer.owasp-juice.shop/lastRequest": strconv.FormatInt(currentTime.UnixMilli(), 10),
+		})
+		restoredInstance.CreationTimestamp = metav1.NewTime(currentTime.Add(-time.Hour))
+		olderInstance := createDeployment("team2", map[string]string{
+			"multi-juicer.owasp-juice.shop/lastRequest": strconv.FormatInt(currentTime.UnixMilli(), 10),
+		})
+		olderInstance.CreationTimestamp = metav1.NewTime(currentTime.Add(-4 * time.Hour))
+		clientset := fake.NewSimpleClientset(
+			restoredInstance,
+			olderInstance,
+			createBackup("team1", currentTime.Add(-2*time.Hour)),
+			createBackup("team2", currentTime.Add(-2*time.Hour)),
+		)
+
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: 24 * time.Hour, BackupRetention: 7 * 24 * time.Hour})
+
+		if summary.PrunedBackups != 1 {
+			t.Errorf("Expected 1 pruned backup, got: %v", summary)
+		}
+		configMaps, _ := clientset.CoreV1().ConfigMaps(testNamespace).List(context.Background(), metav1.ListOptions{})
+		if len(configMaps.Items) != 1 || configMaps.Items[0].Name != "juiceshop-backup-team2" {
+			t.Errorf("Expected only the backup of team2 to be kept, got: %v", configMaps.Items)
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/kubernetes"
+)
+
+var cleanupRunsCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "multijuicer_cleaner_runs",
+		Help: `Number of cleanup runs (see label "result").`,
+	},
+	[]string{"result"},
+)
+var deletionsCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "multijuicer_cleaner_deletions",
+		Help: `Number of deleted JuiceShop instances (see label "policy" for the reason).`,
+	},
+	[]string{"policy"},
+)
+var failedDeletionsCounter = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "multijuicer_cleaner_failed_deletions",
+		Help: "Number of JuiceShop instances which couldn't be deleted.",
+	},
+)
+var hibernationsCounter = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "multijuicer_cleaner_hibernations",
+		Help: "Number of hibernated JuiceShop instances.",
+	},
+)
+var failedHibernationsCounter = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "multijuicer_cleaner_failed_hibernations",
+		Help: "Number of JuiceShop instances which couldn't be hibernated.",
+	},
+)
+var skippedInstancesGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "multijuicer_cleaner_skipped_instances",
+		Help: `Number of JuiceShop instances skipped by the last cleanup run (see label "reason").`,
+	},
+	[]string{"reason"},
+)
+var garbageGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "multijuicer_cleaner_garbage",
+		Help: `Number of orphaned or broken JuiceShop resources found by the last cleanup run (see label "kind").`,
+	},
+	[]string{"kind"},
+)
+var instancesNearExpiryGauge = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "multijuicer_cleaner_instances_near_expiry",
+		Help: "Number of JuiceShop instances which will be deleted within the next cleanup interval, unless their team becomes active again.",
+	},
+)
+var lastSuccessfulRunGauge = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "multijuicer_cleaner_last_successful_run_timestamp_seconds",
+		Help: "Unix timestamp of the last successful cleanup run.",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(cleanupRunsCounter)
+	prometheus.MustRegister(deletionsCounter)
+	prometheus.MustRegister(failedDeletionsCounter)
+	prometheus.MustRegister(hibernationsCounter)
+	prometheus.MustRegister(failedHibernationsCounter)
+	prometheus.MustRegister(skippedInstancesGauge)
+	prometheus.MustRegister(garbageGauge)
+	prometheus.MustRegister(instancesNearExpiryGauge)
+	prometheus.MustRegister(lastSuccessfulRunGauge)
+}
+
+type DaemonConfig struct {
+	// Interval between two cleanup runs
+	Interval time.Duration
+	// Address the metrics and health endpoints are served on
+	Address string
+}
+
+// failedRunRetryDelay is the delay before the first retry of a failed cleanup run. It doubles with every consecutive failure, up to the interval
+const failedRunRetryDelay = 10 * time.Second
+
+func loadDaemonConfig() DaemonConfig {
+	config := DaemonConfig{Interval: 5 * time.Minute, Address: ":8080"}
+	if intervalString := os.Getenv("CLEANUP_INTERVAL"); intervalString != "" {
+		interval, err := time.ParseDuration(intervalString)
+		if err != nil || interval <= 0 {
+			logger.Fatalf("Could not parse configured CLEANUP_INTERVAL: '%s'. Duration has to formatted like the following examples: \"1h\" for 1 hour, \"5m\" for 5 minutes.", intervalString)
+		}
+		config.Interval = interval
+	}
+	if address := os.Getenv("DAEMON_ADDRESS"); address != "" {
+		config.Address = address
+	}
+	return config
+}
+
+// runDaemon runs the cleanup in the configured interval until the context is canceled. Failed runs are retried with an exponential backoff
+func runDaemon(ctx context.Context, clientset kubernetes.Interface, cleanupConfig CleanupConfig, daemonConfig DaemonConfig) {
+	health := newDaemonHealth(time.Now(), 3*daemonConfig.Interval)
+
+	router := http.NewServeMux()
+	router.Handle("GET /metrics", promhttp.Handler())
+	router.Handle("GET /healthz", health)
+	server := &http.Server{
+		Addr:    daemonConfig.Address,
+		Handler: router,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatalf("Failed to start metrics server: %v", err)
+		}
+	}()
+
+	logger.Printf("Running cleanup every %s, serving metrics and health checks on %s", daemonConfig.Interval, daemonConfig.Address)
+	consecutiveFailures := 0
+	for {
+		delay := daemonConfig.Interval
+		if runDaemonCleanup(clientset, cleanupConfig, daemonConfig, health) {
+			consecutiveFailures = 0
+		} else {
+			consecutiveFailures++
+			delay = getRetryDelay(consecutiveFailures, daemonConfig.Interval)
+			logger.Printf("Retrying cleanup in %s", delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Println("Stopping cleaner")
+			server.Shutdown(context.Background())
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func runDaemonCleanup(clientset kubernetes.Interface, cleanupConfig CleanupConfig, daemonConfig DaemonConfig, health *daemonHealth) bool {
+	currentTime := time.Now()
+	summary, err := runCleanup(clientset, currentTime, cleanupConfig)
+	if err != nil {
+		cleanupRunsCounter.WithLabelValues("failed").Inc()
+		logger.Printf("Cleanup failed: %v", err)
+		return false
+	}
+	cleanupRunsCounter.WithLabelValues("successful").Inc()
+	recordMetrics(summary, daemonConfig.Interval)
+	lastSuccessfulRunGauge.Set(float64(currentTime.Unix()))
+	health.recordSuccessfulRun(currentTime)
+	logSummary(summary, cleanupConfig)
+	return true
+}
+
+func getRetryDelay(consecutiveFailures int, interval time.Duration) time.Duration {
+	delay := failedRunRetryDelay
+	for i := 1; i < consecutiveFailures && delay < interval; i++ {
+		delay *= 2
+	}
+	return min(delay, interval)
+}
+
+// recordMetrics updates the metrics with the results of a cleanup run. Instances which will expire within the nearExpiryWindow are counted as near expiry
+func recordMetrics(summary CleanupSummary, nearExpiryWindow time.Duration) {
+	skipped := map[SkipReason]int{}
+	nearExpiry := 0
+	for _, report := range summary.Instances {
+		switch report.Action {
+		case ActionSkip:
+			skipped[report.SkipReason]++
+			if report.expires && report.timeUntilDeletion <= nearExpiryWindow {
+				nearExpiry++
+			}
+		case ActionDelete:
+			if summary.DryRun {
+				continue
+			}
+			if report.Error != "" {
+				failedDeletionsCounter.Inc()
+			} else {
+				deletionsCounter.WithLabelValues(string(report.Policy)).Inc()
+			}
+		case ActionHibernate:
+			if summary.DryRun {
+				continue
+			}
+			if report.Error != "" {
+				failedHibernationsCounter.Inc()
+			} else {
+				hibernationsCounter.Inc()
+			}
+		}
+	}
+
+	skippedInstancesGauge.Reset()
+	for reason, count := range skipped {
+		skippedInstancesGauge.WithLabelValues(string(reason)).Set(float64(count))
+	}
+	garbageGauge.Reset()
+	for kind, count := range summary.Garbage {
+		garbageGauge.WithLabelValues(string(kind)).Set(float64(count))
+	}
+	instancesNearExpiryGauge.Set(float64(nearExpiry))
+}
+
+// daemonHealth reports the daemon as unhealthy once it failed to clean up for longer than maxAge, e.g. because it can't reach the kubernetes api anymore
+type daemonHealth struct {
+	mutex             sync.Mutex
+	lastSuccessfulRun time.Time
+	maxAge            time.Duration
+}
+
+func newDaemonHealth(startedAt time.Time, maxAge time.Duration) *daemonHealth {
+	// the start counts as successful run, to give the first cleanup time to succeed
+	return &daemonHealth{lastSuccessfulRun: startedAt, maxAge: maxAge}
+}
+
+func (health *daemonHealth) recordSuccessfulRun(runAt time.Time) {
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+	health.lastSuccessfulRun = runAt
+}
+
+func (health *daemonHealth) ServeHTTP(responseWriter http.ResponseWriter, req *http.Request) {
+	health.mutex.Lock()
+	sinceLastSuccessfulRun := time.Since(health.lastSuccessfulRun)
+	health.mutex.Unlock()
+
+	if sinceLastSuccessfulRun > health.maxAge {
+		http.Error(responseWriter, fmt.Sprintf("no successful cleanup for %s", sinceLastSuccessfulRun.Round(time.Second)), http.StatusServiceUnavailable)
+		return
+	}
+	responseWriter.Write([]byte("ok"))
+}
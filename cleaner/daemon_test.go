@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+)
+
+func TestDaemon(t *testing.T) {
+	createDeployment := func(team string, lastRequest time.Time) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s", team),
+				Namespace: testNamespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+				},
+				Annotations: map[string]string{
+					"multi-juicer.owasp-juice.shop/lastRequest": strconv.FormatInt(lastRequest.UnixMilli(), 10),
+				},
+			},
+		}
+	}
+	withFastRetries := func(t *testing.T) {
+		originalBackoff := transientErrorBackoff
+		transientErrorBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+		t.Cleanup(func() { transientErrorBackoff = originalBackoff })
+	}
+
+	t.Run("Transient Errors - Listing Deployments Is Retried", func(t *testing.T) {
+		withFastRetries(t)
+		clientset := fake.NewSimpleClientset(createDeployment("team1", time.Now().Add(-48*time.Hour)))
+		failures := 0
+		clientset.PrependReactor("list", "deployments", func(action clientgotesting.Action) (handled bool, ret runtime.Object, err error) {
+			if failures < 2 {
+				failures++
+				return true, nil, errors.NewServiceUnavailable("api server is restarting")
+			}
+			return false, nil, nil
+		})
+
+		summary, err := runCleanup(clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour})
+
+		if err != nil {
+			t.Fatalf("Expected the cleanup to succeed after retrying, got: %v", err)
+		}
+		if summary.SuccessfulDeploymentDeletions != 1 {
+			t.Errorf("Expected 1 successful deployment deletion, got: %v", summary)
+		}
+	})
+
+	t.Run("Persistent Errors - Cleanup Returns An Error Instead Of Exiting", func(t *testing.T) {
+		withFastRetries(t)
+		clientset := fake.NewSimpleClientset()
+		clientset.PrependReactor("list", "deployments", func(action clientgotesting.Action) (handled bool, ret runtime.Object, err error) {
+			return true, nil, errors.NewServiceUnavailable("api server is down")
+		})
+
+		_, err := runCleanup(clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour})
+
+		if err == nil {
+			t.Errorf("Expected the cleanup to fail")
+		}
+	})
+
+	t.Run("Metrics - Counts Skipped Instances By Reason And Instances Near Expiry", func(t *testing.T) {
+		currentTime := time.Now()
+		protectedDeployment := createDeployment("team3", currentTime.Add(-23*time.Hour))
+		protectedDeployment.Annotations["multi-juicer.owasp-juice.shop/protected"] = "true"
+		clientset := fake.NewSimpleClientset(
+			createDeployment("team1", currentTime.Add(-23*time.Hour)),
+			createDeployment("team2", currentTime.Add(-time.Hour)),
+			protectedDeployment,
+			createDeployment("team4", currentTime.Add(-48*time.Hour)),
+		)
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: 24 * time.Hour})
+		deletionsBefore := testutil.ToFloat64(deletionsCounter.WithLabelValues(string(PolicyInactivity)))
+
+		recordMetrics(summary, 2*time.Hour)
+
+		if value := testutil.ToFloat64(skippedInstancesGauge.WithLabelValues(string(SkipActive))); value != 2 {
+			t.Errorf("Expected 2 instances skipped as active, got: %f", value)
+		}
+		if value := testutil.ToFloat64(skippedInstancesGauge.WithLabelValues(string(SkipProtected))); value != 1 {
+			t.Errorf("Expected 1 instance skipped as protected, got: %f", value)
+		}
+		if value := testutil.ToFloat64(instancesNearExpiryGauge); value != 1 {
+			t.Errorf("Expected 1 instance near expiry, got: %f", value)
+		}
+		if value := testutil.ToFloat64(deletionsCounter.WithLabelValues(string(PolicyInactivity))); value != deletionsBefore+1 {
+			t.Errorf("Expected the deletion to be counted, got: %f", value)
+		}
+	})
+
+	t.Run("Health - Unhealthy Once No Cleanup Succeeded For Too Long", func(t *testing.T) {
+		health := newDaemonHealth(time.Now().Add(-time.Hour), 30*time.Minute)
+
+		rr := httptest.NewRecorder()
+		health.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status %d, got: %d", http.StatusServiceUnavailable, rr.Code)
+		}
+
+		health.recordSuccessfulRun(time.Now())
+		rr = httptest.NewRecorder()
+		health.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got: %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("Backoff - Retry Delay Doubles Up To The Interval", func(t *testing.T) {
+		expectedDelays := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+		for i, expectedDelay := range expectedDelays {
+			if delay := getRetryDelay(i+1, time.Minute); delay != expectedDelay {
+				t.Errorf("Expected delay %s after %d failures, got: %s", expectedDelay, i+1, delay)
+			}
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GarbageKind categorizes leftover or broken resources of JuiceShop instances
+type GarbageKind string
+
+const (
+	// GarbageOrphanedService is a service without the deployment of its instance, e.g. after the deployment got deleted manually
+	GarbageOrphanedService GarbageKind = "orphanedService"
+	// GarbageMissingService is a deployment without the service of its instance, making the instance unreachable
+	GarbageMissingService GarbageKind = "missingService"
+	// GarbageMissingAnnotation is a deployment without a valid lastRequest annotation, which would otherwise be skipped forever
+	GarbageMissingAnnotation GarbageKind = "missingAnnotation"
+	// GarbageCrashLooping is a deployment with pods stuck in CrashLoopBackOff for more restarts than the configured threshold
+	GarbageCrashLooping GarbageKind = "crashLooping"
+)
+
+// garbageGracePeriod protects resources of instances which are just being created or deleted by the balancer from being considered garbage
+const garbageGracePeriod = 5 * time.Minute
+
+// instanceResources are the other resources belonging to the deployment of an instance
+type instanceResources struct {
+	hasService bool
+	// crashLoopRestarts is the highest restart count of the containers of the instance waiting in CrashLoopBackOff
+	crashLoopRestarts int32
+}
+
+// ServiceReport explains what the cleanup did (or would have done in a dry run) with an orphaned JuiceShop service
+type ServiceReport struct {
+	Service string        `json:"service"`
+	Action  CleanupAction `json:"action"`
+	// Error of the deletion, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+func existsLongerThan(meta metav1.ObjectMeta, currentTime time.Time, duration time.Duration) bool {
+	return !meta.CreationTimestamp.IsZero() && currentTime.Sub(meta.CreationTimestamp.Time) > duration
+}
+
+// listInstanceResources looks up the services and pods of the deployments. Services without a deployment are returned as orphaned
+func listInstanceResources(clientset kubernetes.Interface, deployments []appsv1.Deployment, currentTime time.Time, config CleanupConfig) (map[string]instanceResources, []corev1.Service, error) {
+	listOptions := metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
+	}
+
+	resources := map[string]instanceResources{}
+	for _, deployment := range deployments {
+		resources[deployment.Name] = instanceResources{}
+	}
+
+	var services *corev1.ServiceList
+	err := retryOnTransientError(func() (err error) {
+		services, err = clientset.CoreV1().Services(namespace).List(context.Background(), listOptions)
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list services of the JuiceShop instances: %w", err)
+	}
+	orphanedServices := []corev1.Service{}
+	for _, service := range services.Items {
+		instance, ok := resources[service.Name]
+		if !ok {
+			if existsLongerThan(service.ObjectMeta, currentTime, garbageGracePeriod) {
+				orphanedServices = append(orphanedServices, service)
+			}
+			continue
+		}
+		instance.hasService = true
+		resources[service.Name] = instance
+	}
+
+	if config.CrashLoopRestartThreshold <= 0 {
+		return resources, orphanedServices, nil
+	}
+	var pods *corev1.PodList
+	err = retryOnTransientError(func() (err error) {
+		pods, err = clientset.CoreV1().Pods(namespace).List(context.Background(), listOptions)
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list pods of the JuiceShop instances: %w", err)
+	}
+	for _, pod := range pods.Items {
+		deploymentName := fmt.Sprintf("juiceshop-%s", pod.Labels["team"])
+		instance, ok := resources[deploymentName]
+		if !ok {
+			continue
+		}
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason == "CrashLoopBackOff" {
+				instance.crashLoopRestarts = max(instance.crashLoopRestarts, containerStatus.RestartCount)
+			}
+		}
+		resources[deploymentName] = instance
+	}
+	return resources, orphanedServices, nil
+}
+
+// cleanupOrphanedService deletes the service if garbage collection is enabled, otherwise it's only reported
+func cleanupOrphanedService(clientset kubernetes.Interface, service corev1.Service, config CleanupConfig, summary *CleanupSummary) ServiceReport {
+	report := ServiceReport{Service: service.Name, Action: ActionSkip}
+	if !config.DeleteGarbage {
+		logger.Printf("Found orphaned service %s without deployment", service.Name)
+		return report
+	}
+
+	report.Action = ActionDelete
+	if config.DryRun {
+		logger.Printf("Would delete orphaned service %s without deployment", service.Name)
+		return report
+	}
+	logger.Printf("Deleting orphaned service %s without deployment", service.Name)
+	err := retryOnTransientError(func() error {
+		return clientset.CoreV1().Services(service.Namespace).Delete(context.Background(), service.Name, metav1.DeleteOptions{})
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		logger.Printf("Failed to delete service %s: %v", service.Name, err)
+		report.Error = fmt.Sprintf("failed to delete service: %v", err)
+		summary.FailedServiceDeletions++
+		return report
+	}
+	summary.SuccessfulServiceDeletions++
+	return report
+}
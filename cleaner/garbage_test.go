@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGarbageCollection(t *testing.T) {
+	labels := map[string]string{
+		"app.kubernetes.io/name":    "juice-shop",
+		"app.kubernetes.io/part-of": "multi-juicer",
+	}
+	createDeployment := func(team string, annotations map[string]string, createdAt time.Time) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("juiceshop-%s", team),
+				Namespace:         testNamespace,
+				Labels:            labels,
+				Annotations:       annotations,
+				CreationTimestamp: metav1.NewTime(createdAt),
+			},
+		}
+	}
+	createActiveDeployment := func(team string, createdAt time.Time) *appsv1.Deployment {
+		return createDeployment(team, map[string]string{
+			"multi-juicer.owasp-juice.shop/lastRequest": strconv.FormatInt(time.Now().UnixMilli(), 10),
+		}, createdAt)
+	}
+	createService := func(team string, createdAt time.Time) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("juiceshop-%s", team),
+				Namespace:         testNamespace,
+				Labels:            labels,
+				CreationTimestamp: metav1.NewTime(createdAt),
+			},
+		}
+	}
+	createCrashLoopingPod := func(team string, restarts int32) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("juiceshop-%s-5d8f7c4b9-abcde", team),
+				Namespace: testNamespace,
+				Labels: map[string]string{
+					"team":                      team,
+					"app.kubernetes.io/name":    "juice-shop",
+					"app.kubernetes.io/part-of": "multi-juicer",
+				},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:         "juice-shop",
+					RestartCount: restarts,
+					State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				}},
+			},
+		}
+	}
+	hourAgo := time.Now().Add(-time.Hour)
+
+	t.Run("Orphaned Services - Are Only Reported By Default", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createService("team1", hourAgo))
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour})
+
+		if summary.Garbage[GarbageOrphanedService] != 1 || len(summary.OrphanedServices) != 1 || summary.OrphanedServices[0].Action != ActionSkip {
+			t.Errorf("Expected the orphaned service to be reported, got: %v", summary)
+		}
+		if _, err := clientset.CoreV1().Services(testNamespace).Get(context.Background(), "juiceshop-team1", metav1.GetOptions{}); err != nil {
+			t.Errorf("Expected the service to still exist, got: %v", err)
+		}
+	})
+
+	t.Run("Orphaned Services - Are Deleted With Garbage Collection Enabled", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(
+			createActiveDeployment("team1", hourAgo),
+			createService("team1", hourAgo),
+			createService("team2", hourAgo),
+			// just created by the balancer, the deployment could still be in the making
+			createService("team3", time.Now()),
+		)
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, DeleteGarbage: true})
+
+		if summary.SuccessfulServiceDeletions != 1 || len(summary.OrphanedServices) != 1 || summary.OrphanedServices[0].Service != "juiceshop-team2" {
+			t.Errorf("Expected only the orphaned service of team2 to be deleted, got: %v", summary)
+		}
+		services, _ := clientset.CoreV1().Services(testNamespace).List(context.Background(), metav1.ListOptions{})
+		if len(services.Items) != 2 {
+			t.Errorf("Expected 2 remaining services, got: %d", len(services.Items))
+		}
+	})
+
+	t.Run("Missing Service - Deployment Is Reported Or Deleted", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createActiveDeployment("team1", hourAgo))
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour})
+		if summary.Instances[0].Action != ActionSkip || summary.Garbage[GarbageMissingService] != 1 {
+			t.Errorf("Expected the deployment to be reported as missing its service, got: %v", summary)
+		}
+
+		summary = mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, DeleteGarbage: true})
+		if summary.Instances[0].Action != ActionDelete || summary.DeletionsByPolicy[PolicyMissingService] != 1 {
+			t.Errorf("Expected the deployment to be deleted, got: %v", summary)
+		}
+	})
+
+	t.Run("Missing Annotation - Creation Is Used As Last Activity With Garbage Collection Enabled", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(
+			createDeployment("team1", map[string]string{}, time.Now().Add(-25*time.Hour)),
+			createService("team1", time.Now().Add(-25*time.Hour)),
+			createDeployment("team2", map[string]string{"multi-juicer.owasp-juice.shop/lastRequest": "invalid"}, hourAgo),
+			createService("team2", hourAgo),
+		)
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, DeleteGarbage: true})
+
+		if summary.Garbage[GarbageMissingAnnotation] != 2 {
+			t.Errorf("Expected both deployments to be reported as missing the annotation, got: %v", summary.Garbage)
+		}
+		if summary.Instances[0].Action != ActionDelete || summary.Instances[0].Policy != PolicyMissingAnnotation || summary.Instances[0].Reason != "it has no lastRequest annotation and exists for 25h0m0s, longer than 24h0m0s" {
+			t.Errorf("Expected the old deployment to be deleted, got: %v", summary.Instances[0])
+		}
+		if summary.Instances[1].Action != ActionSkip || summary.Instances[1].SkipReason != SkipInvalidLastRequest {
+			t.Errorf("Expected the new deployment to be skipped, got: %v", summary.Instances[1])
+		}
+	})
+
+	t.Run("Crash Loop - Instances Beyond The Restart Threshold Are Deleted", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(
+			createActiveDeployment("team1", hourAgo),
+			createService("team1", hourAgo),
+			createCrashLoopingPod("team1", 12),
+			createActiveDeployment("team2", hourAgo),
+			createService("team2", hourAgo),
+			createCrashLoopingPod("team2", 3),
+		)
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, DeleteGarbage: true, CrashLoopRestartThreshold: 10})
+
+		if summary.Instances[0].Action != ActionDelete || summary.Instances[0].Reason != "it is stuck in CrashLoopBackOff after 12 restarts" {
+			t.Errorf("Expected the crash looping instance to be deleted, got: %v", summary.Instances[0])
+		}
+		if summary.Instances[1].Action != ActionSkip || len(summary.Instances[1].Garbage) != 0 {
+			t.Errorf("Expected the instance below the threshold to be skipped, got: %v", summary.Instances[1])
+		}
+	})
+
+	t.Run("Protected Deployment - Is Never Garbage", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createDeployment("team1", map[string]string{"multi-juicer.owasp-juice.shop/protected": "true"}, time.Now().Add(-48*time.Hour)))
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, DeleteGarbage: true})
+
+		if summary.Instances[0].Action != ActionSkip || len(summary.Garbage) != 0 {
+			t.Errorf("Expected the protected deployment to be skipped, got: %v", summary)
+		}
+	})
+}
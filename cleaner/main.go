@@ -2,29 +2,40 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags)
+// logs are written to stderr, so that stdout only contains the json summary of the cleanup
+var logger = log.New(os.Stderr, "", log.LstdFlags)
 var namespace = os.Getenv("NAMESPACE")
 
 func main() {
+	dryRun := flag.Bool("dry-run", os.Getenv("DRY_RUN") == "true", "only report which instances would be deleted or hibernated without changing anything. Can also be enabled by setting DRY_RUN=true")
+	daemon := flag.Bool("daemon", os.Getenv("DAEMON_MODE") == "true", "keep running and clean up in the interval configured by CLEANUP_INTERVAL instead of exiting after a single cleanup. Exposes prometheus metrics and a health endpoint. Can also be enabled by setting DAEMON_MODE=true")
+	flag.Parse()
+
 	logger.Println("Starting cleaner")
 
-	maxInactiveTimeString := os.Getenv("MAX_INACTIVE_DURATION")
-	maxInactiveTime, err := time.ParseDuration(maxInactiveTimeString)
-	if err != nil {
-		logger.Fatalf("Could not parse configured MAX_INACTIVE_DURATION: '%s'. Duration has to formatted like the following examples: \"12h\" for 12 hours, \"30m\" for 30 minutes.", maxInactiveTimeString)
-	}
+	cleanupConfig := loadCleanupConfig()
+	cleanupConfig.DryRun = *dryRun
 
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -35,79 +46,508 @@ func main() {
 		panic(err.Error())
 	}
 
-	currentTime := time.Now()
+	if *daemon {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		runDaemon(ctx, clientset, cleanupConfig, loadDaemonConfig())
+		return
+	}
+
+	cleanupSummary, err := runCleanup(clientset, time.Now(), cleanupConfig)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logSummary(cleanupSummary, cleanupConfig)
+
+	if err := json.NewEncoder(os.Stdout).Encode(cleanupSummary); err != nil {
+		logger.Fatalf("Failed to write cleanup summary: %v", err)
+	}
+}
+
+func loadCleanupConfig() CleanupConfig {
+	maxInactiveTimeString := os.Getenv("MAX_INACTIVE_DURATION")
+	maxInactiveTime, err := time.ParseDuration(maxInactiveTimeString)
+	if err != nil {
+		logger.Fatalf("Could not parse configured MAX_INACTIVE_DURATION: '%s'. Duration has to formatted like the following examples: \"12h\" for 12 hours, \"30m\" for 30 minutes.", maxInactiveTimeString)
+	}
+
+	// hibernation is optional. Without it inactive instances get deleted right away
+	var hibernateInactiveTime time.Duration
+	if hibernateInactiveTimeString := os.Getenv("HIBERNATE_INACTIVE_DURATION"); hibernateInactiveTimeString != "" {
+		hibernateInactiveTime, err = time.ParseDuration(hibernateInactiveTimeString)
+		if err != nil {
+			logger.Fatalf("Could not parse configured HIBERNATE_INACTIVE_DURATION: '%s'. Duration has to formatted like the following examples: \"12h\" for 12 hours, \"30m\" for 30 minutes.", hibernateInactiveTimeString)
+		}
+		if hibernateInactiveTime >= maxInactiveTime {
+			logger.Fatalf("HIBERNATE_INACTIVE_DURATION (%s) has to be shorter than MAX_INACTIVE_DURATION (%s)", hibernateInactiveTime, maxInactiveTime)
+		}
+	}
+
+	// the max lifetime and the end of the event are optional, both delete instances regardless of their activity
+	var maxLifetime time.Duration
+	if maxLifetimeString := os.Getenv("MAX_LIFETIME_DURATION"); maxLifetimeString != "" {
+		maxLifetime, err = time.ParseDuration(maxLifetimeString)
+		if err != nil {
+			logger.Fatalf("Could not parse configured MAX_LIFETIME_DURATION: '%s'. Duration has to formatted like the following examples: \"12h\" for 12 hours, \"30m\" for 30 minutes.", maxLifetimeString)
+		}
+	}
+	var eventEnd time.Time
+	if eventEndString := os.Getenv("EVENT_END_TIME"); eventEndString != "" {
+		eventEnd, err = time.Parse(time.RFC3339, eventEndString)
+		if err != nil {
+			logger.Fatalf("Could not parse configured EVENT_END_TIME: '%s'. Time has to be formatted according to RFC 3339, e.g. \"2024-10-18T18:00:00+02:00\".", eventEndString)
+		}
+	}
+
+	// garbage is only reported by default, to not delete anything unexpected
+	var deleteGarbage bool
+	switch garbageCollectionPolicy := os.Getenv("GARBAGE_COLLECTION_POLICY"); garbageCollectionPolicy {
+	case "", "report":
+		deleteGarbage = false
+	case "delete":
+		deleteGarbage = true
+	default:
+		logger.Fatalf("Unknown GARBAGE_COLLECTION_POLICY: '%s'. Has to be either \"report\" or \"delete\".", garbageCollectionPolicy)
+	}
+	var crashLoopRestartThreshold int32
+	if crashLoopRestartThresholdString := os.Getenv("CRASH_LOOP_RESTART_THRESHOLD"); crashLoopRestartThresholdString != "" {
+		threshold, err := strconv.ParseInt(crashLoopRestartThresholdString, 10, 32)
+		if err != nil || threshold < 0 {
+			logger.Fatalf("Could not parse configured CRASH_LOOP_RESTART_THRESHOLD: '%s'. Has to be a positive number of restarts.", crashLoopRestartThresholdString)
+		}
+		crashLoopRestartThreshold = int32(threshold)
+	}
 
-	cleanupSummary := runCleanup(clientset, currentTime, maxInactiveTime)
+	backupProgress := os.Getenv("BACKUP_PROGRESS") == "true"
+	// backups contain the passcode hash of the team, so they must not be kept forever
+	backupRetention := defaultBackupRetention
+	if backupRetentionString := os.Getenv("BACKUP_RETENTION_DURATION"); backupRetentionString != "" {
+		backupRetention, err = time.ParseDuration(backupRetentionString)
+		if err != nil || backupRetention <= 0 {
+			logger.Fatalf("Could not parse configured BACKUP_RETENTION_DURATION: '%s'. Duration has to be positive and formatted like the following examples: \"168h\" for 7 days, \"24h\" for 1 day.", backupRetentionString)
+		}
+	}
 
+	return CleanupConfig{
+		MaxInactive:               maxInactiveTime,
+		HibernateInactive:         hibernateInactiveTime,
+		MaxLifetime:               maxLifetime,
+		EventEnd:                  eventEnd,
+		DeleteGarbage:             deleteGarbage,
+		CrashLoopRestartThreshold: crashLoopRestartThreshold,
+		BackupProgress:            backupProgress,
+		BackupRetention:           backupRetention,
+	}
+}
+
+func logSummary(cleanupSummary CleanupSummary, config CleanupConfig) {
+	if cleanupSummary.DryRun {
+		logger.Println("Finished dry run. No JuiceShop deployments have been changed.")
+		logger.Printf("Would delete %d and hibernate %d deployment(s)", cleanupSummary.PlannedDeletions, cleanupSummary.PlannedHibernations)
+		return
+	}
 	logger.Println("Finished cleaning up JuiceShop deployments.")
 	logger.Printf("Deleted %d deployment(s) and %d service(s) successfully", cleanupSummary.SuccessfulDeploymentDeletions, cleanupSummary.SuccessfulServiceDeletions)
+	if config.BackupProgress {
+		logger.Printf("Backed up the progress of %d team(s) and failed to backup %d", cleanupSummary.SuccessfulBackups, cleanupSummary.FailedBackups)
+	}
+	if cleanupSummary.PrunedBackups > 0 {
+		logger.Printf("Pruned %d expired or already restored backup(s)", cleanupSummary.PrunedBackups)
+	}
 	if (cleanupSummary.FailedDeploymentDeletions + cleanupSummary.FailedServiceDeletions) > 0 {
 		logger.Printf("Failed to delete %d deployment(s) and %d service(s)", cleanupSummary.FailedDeploymentDeletions, cleanupSummary.FailedServiceDeletions)
 	}
+	if config.HibernateInactive > 0 {
+		logger.Printf("Hibernated %d deployment(s) successfully", cleanupSummary.SuccessfulHibernations)
+		if cleanupSummary.FailedHibernations > 0 {
+			logger.Printf("Failed to hibernate %d deployment(s)", cleanupSummary.FailedHibernations)
+		}
+	}
+}
+
+// transientErrorBackoff is used to retry kubernetes api calls which failed because of temporary issues, like an overloaded or restarting api server
+var transientErrorBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+}
+
+func isTransientError(err error) bool {
+	return errors.IsServerTimeout(err) ||
+		errors.IsTimeout(err) ||
+		errors.IsTooManyRequests(err) ||
+		errors.IsServiceUnavailable(err) ||
+		errors.IsInternalError(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
+func retryOnTransientError(fn func() error) error {
+	return retry.OnError(transientErrorBackoff, func(err error) bool {
+		if !isTransientError(err) {
+			return false
+		}
+		logger.Printf("Retrying after transient error: %v", err)
+		return true
+	}, fn)
+}
+
+type CleanupConfig struct {
+	// MaxInactive after which instances get deleted
+	MaxInactive time.Duration
+	// HibernateInactive after which instances get scaled down to zero replicas, keeping their progress until they get resumed by the balancer or deleted. Disabled if zero
+	HibernateInactive time.Duration
+	// MaxLifetime after which instances get deleted, counted from the creation of their deployment and regardless of their activity. Disabled if zero
+	MaxLifetime time.Duration
+	// EventEnd after which all instances get deleted, regardless of their activity. Disabled if zero
+	EventEnd time.Time
+	// DeleteGarbage deletes orphaned services and broken deployments instead of only reporting them
+	DeleteGarbage bool
+	// CrashLoopRestartThreshold of restarts after which instances stuck in CrashLoopBackOff are considered broken. Disabled if zero
+	CrashLoopRestartThreshold int32
+	// BackupProgress of teams before their instances get deleted, so that the balancer can restore it once the team returns
+	BackupProgress bool
+	// BackupRetention after which backups get deleted, even if backups are disabled by now. Always set outside of tests, see defaultBackupRetention
+	BackupRetention time.Duration
+	// DryRun only reports what would be done without changing any deployments
+	DryRun bool
+}
+
+type CleanupAction string
+
+const (
+	ActionDelete    CleanupAction = "delete"
+	ActionHibernate CleanupAction = "hibernate"
+	ActionSkip      CleanupAction = "skip"
+)
+
+// SkipReason categorizes why an instance has been skipped
+type SkipReason string
+
+const (
+	SkipProtected          SkipReason = "protected"
+	SkipMissingLastRequest SkipReason = "missingLastRequest"
+	SkipInvalidLastRequest SkipReason = "invalidLastRequest"
+	SkipAlreadyHibernated  SkipReason = "alreadyHibernated"
+	SkipActive             SkipReason = "active"
+)
+
+// CleanupPolicy names the rule which caused an instance to be deleted or hibernated
+type CleanupPolicy string
+
+const (
+	PolicyInactivity  CleanupPolicy = "inactivity"
+	PolicyMaxLifetime CleanupPolicy = "maxLifetime"
+	PolicyEventEnd    CleanupPolicy = "eventEnd"
+	// the garbage collection policies are only applied if garbage collection is enabled
+	PolicyMissingAnnotation CleanupPolicy = "missingAnnotation"
+	PolicyMissingService    CleanupPolicy = "missingService"
+	PolicyCrashLoop         CleanupPolicy = "crashLoop"
+)
+
+// InstanceReport explains what the cleanup did (or would have done in a dry run) with a JuiceShop deployment and why
+type InstanceReport struct {
+	Deployment string        `json:"deployment"`
+	Action     CleanupAction `json:"action"`
+	Reason     string        `json:"reason"`
+	// Policy which caused the deletion or hibernation, omitted for skipped instances
+	Policy CleanupPolicy `json:"policy,omitempty"`
+	// SkipReason categorizes the reason of skipped instances
+	SkipReason SkipReason `json:"skipReason,omitempty"`
+	// Garbage lists the ways the instance is broken, if any
+	Garbage []GarbageKind `json:"garbage,omitempty"`
+	// Age of the deployment since its creation
+	Age string `json:"age,omitempty"`
+	// LastRequest is omitted for deployments without a valid lastRequest annotation
+	LastRequest *time.Time `json:"lastRequest,omitempty"`
+	InactiveFor string     `json:"inactiveFor,omitempty"`
+	// BackedUp is true if the progress of the team got backed up before the deletion
+	BackedUp bool `json:"backedUp,omitempty"`
+	// Error of the deletion or hibernation, if it failed
+	Error string `json:"error,omitempty"`
+
+	// timeUntilDeletion by the earliest applying policy, only set if expires is true. Protected instances never expire
+	timeUntilDeletion time.Duration
+	expires           bool
+}
+
+// expireIn keeps the earliest time until the instance gets deleted by one of the policies
+func (report *InstanceReport) expireIn(duration time.Duration) {
+	if !report.expires || duration < report.timeUntilDeletion {
+		report.timeUntilDeletion = duration
+		report.expires = true
+	}
 }
 
 type CleanupSummary struct {
-	SuccessfulDeploymentDeletions int
-	SuccessfulServiceDeletions    int
-	FailedDeploymentDeletions     int
-	FailedServiceDeletions        int
+	DryRun bool `json:"dryRun"`
+	// PlannedDeletions and PlannedHibernations count the instances selected for the action, also in dry runs
+	PlannedDeletions              int `json:"plannedDeletions"`
+	PlannedHibernations           int `json:"plannedHibernations"`
+	SuccessfulDeploymentDeletions int `json:"successfulDeploymentDeletions"`
+	SuccessfulServiceDeletions    int `json:"successfulServiceDeletions"`
+	FailedDeploymentDeletions     int `json:"failedDeploymentDeletions"`
+	FailedServiceDeletions        int `json:"failedServiceDeletions"`
+	SuccessfulHibernations        int `json:"successfulHibernations"`
+	FailedHibernations            int `json:"failedHibernations"`
+	SuccessfulBackups             int `json:"successfulBackups"`
+	FailedBackups                 int `json:"failedBackups"`
+	PrunedBackups                 int `json:"prunedBackups"`
+	// DeletionsByPolicy counts the instances selected for deletion by the policy which caused it
+	DeletionsByPolicy map[CleanupPolicy]int `json:"deletionsByPolicy"`
+	// Garbage counts the orphaned and broken resources found, regardless of whether they got deleted
+	Garbage          map[GarbageKind]int `json:"garbage"`
+	Instances        []InstanceReport    `json:"instances"`
+	OrphanedServices []ServiceReport     `json:"orphanedServices"`
 }
 
-func runCleanup(clientset kubernetes.Interface, currentTime time.Time, maxInactive time.Duration) CleanupSummary {
-	deployments, err := clientset.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
+// runCleanup deletes instances which have been inactive for longer than the configured max inactive duration and hibernates the ones inactive for longer than the hibernate duration.
+// Instances older than the max lifetime and all instances after the end of the event get deleted regardless of their activity.
+// Orphaned services and broken deployments are reported, or deleted if garbage collection is enabled.
+// In dry runs only the report of what would have been done is created
+func runCleanup(clientset kubernetes.Interface, currentTime time.Time, config CleanupConfig) (CleanupSummary, error) {
+	var deployments *appsv1.DeploymentList
+	err := retryOnTransientError(func() (err error) {
+		deployments, err = clientset.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{
+			LabelSelector: "app.kubernetes.io/name=juice-shop,app.kubernetes.io/part-of=multi-juicer",
+		})
+		return err
 	})
+	if err != nil {
+		return CleanupSummary{}, fmt.Errorf("failed to list deployments to find JuiceShop instances to cleanup: %w", err)
+	}
 
+	resources, orphanedServices, err := listInstanceResources(clientset, deployments.Items, currentTime, config)
 	if err != nil {
-		logger.Fatal(fmt.Errorf("failed to list deployments to find JuiceShop instances to cleanup: %w", err))
+		return CleanupSummary{}, err
 	}
 
-	if len(deployments.Items) == 0 {
+	if len(deployments.Items) == 0 && len(orphanedServices) == 0 {
 		logger.Println("No JuiceShop deployments found. Nothing to do.")
 	}
 
 	summary := CleanupSummary{
-		SuccessfulDeploymentDeletions: 0,
-		SuccessfulServiceDeletions:    0,
-		FailedDeploymentDeletions:     0,
-		FailedServiceDeletions:        0,
+		DryRun:            config.DryRun,
+		DeletionsByPolicy: map[CleanupPolicy]int{},
+		Garbage:           map[GarbageKind]int{},
+		Instances:         []InstanceReport{},
+		OrphanedServices:  []ServiceReport{},
 	}
 
 	for _, deployment := range deployments.Items {
-		lastConnectedTimestampString, hasAnnotation := deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"]
-		if !hasAnnotation || lastConnectedTimestampString == "" {
-			logger.Printf("Skipping deployment %s as it has no lastRequest annotation", deployment.Name)
-			continue
-		}
-		lastConnectedTimestamp, err := strconv.ParseInt(lastConnectedTimestampString, 10, 64)
-		if err != nil {
-			logger.Printf("Skipping deployment %s as it has an invalid lastRequest annotation: %v", deployment.Name, err)
-			continue
+		report := evaluateDeployment(deployment, resources[deployment.Name], currentTime, config)
+		name := deployment.Name
+		for _, kind := range report.Garbage {
+			summary.Garbage[kind]++
 		}
 
-		name := deployment.Name
-		if currentTime.Sub(time.UnixMilli(lastConnectedTimestamp)) > maxInactive {
-			logger.Printf("Deleting instance '%s' as it has been inactive for longer than %s", name, maxInactive.String())
-			err = clientset.AppsV1().Deployments(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+		switch report.Action {
+		case ActionSkip:
+			logger.Printf("Skipping deployment %s as %s", name, report.Reason)
+			if len(report.Garbage) > 0 {
+				logger.Printf("Found broken deployment %s: %v", name, report.Garbage)
+			}
+		case ActionDelete:
+			summary.PlannedDeletions++
+			summary.DeletionsByPolicy[report.Policy]++
+			if config.DryRun {
+				logger.Printf("Would delete instance '%s' as %s", name, report.Reason)
+				break
+			}
+			logger.Printf("Deleting instance '%s' as %s", name, report.Reason)
+			if config.BackupProgress {
+				err = retryOnTransientError(func() (err error) {
+					report.BackedUp, err = backupProgress(clientset, deployment, currentTime)
+					return err
+				})
+				if err != nil {
+					// without backup the progress of the team would be lost, so the instance is kept until the next run
+					logger.Printf("Failed to backup progress of %s, not deleting it: %v", name, err)
+					report.Error = fmt.Sprintf("failed to backup progress: %v", err)
+					summary.FailedBackups++
+					summary.FailedDeploymentDeletions++
+					break
+				}
+				if report.BackedUp {
+					summary.SuccessfulBackups++
+				}
+			}
+			err = retryOnTransientError(func() error {
+				return clientset.AppsV1().Deployments(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+			})
 			if err != nil && !errors.IsNotFound(err) {
 				logger.Printf("Failed to delete deployment %s: %v", name, err)
+				report.Error = fmt.Sprintf("failed to delete deployment: %v", err)
 				summary.FailedDeploymentDeletions++
-				continue
+				break
 			}
 			summary.SuccessfulDeploymentDeletions++
-			err = clientset.CoreV1().Services(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+			err = retryOnTransientError(func() error {
+				return clientset.CoreV1().Services(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+			})
 			if err != nil && !errors.IsNotFound(err) {
 				logger.Printf("Failed to delete service %s: %v", name, err)
+				report.Error = fmt.Sprintf("failed to delete service: %v", err)
 				summary.FailedServiceDeletions++
-				continue
+				break
 			}
 			summary.SuccessfulServiceDeletions++
 
 			logger.Printf("Successfully deleted instance %s", name)
-		} else {
-			logger.Printf("Skipping deployment %s as it has been active recently", name)
+		case ActionHibernate:
+			summary.PlannedHibernations++
+			if config.DryRun {
+				logger.Printf("Would hibernate instance '%s' as %s", name, report.Reason)
+				break
+			}
+			logger.Printf("Hibernating instance '%s' as %s", name, report.Reason)
+			err = retryOnTransientError(func() error {
+				return hibernateDeployment(clientset, deployment.Namespace, name, currentTime)
+			})
+			if err != nil {
+				logger.Printf("Failed to hibernate deployment %s: %v", name, err)
+				report.Error = fmt.Sprintf("failed to hibernate deployment: %v", err)
+				summary.FailedHibernations++
+				break
+			}
+			summary.SuccessfulHibernations++
+		}
+		summary.Instances = append(summary.Instances, report)
+	}
+
+	for _, service := range orphanedServices {
+		summary.Garbage[GarbageOrphanedService]++
+		summary.OrphanedServices = append(summary.OrphanedServices, cleanupOrphanedService(clientset, service, config, &summary))
+	}
+
+	if config.BackupRetention > 0 {
+		pruneBackups(clientset, deployments.Items, currentTime, config, &summary)
+	}
+
+	return summary, nil
+}
+
+// evaluateDeployment decides what to do with the deployment, without changing it
+func evaluateDeployment(deployment appsv1.Deployment, resources instanceResources, currentTime time.Time, config CleanupConfig) InstanceReport {
+	report := InstanceReport{Deployment: deployment.Name, Action: ActionSkip}
+
+	if deployment.Annotations["multi-juicer.owasp-juice.shop/protected"] == "true" {
+		report.Reason = "it is protected from the automatic cleanup"
+		report.SkipReason = SkipProtected
+		return report
+	}
+
+	if !deployment.CreationTimestamp.IsZero() {
+		report.Age = currentTime.Sub(deployment.CreationTimestamp.Time).Round(time.Second).String()
+	}
+	if !config.EventEnd.IsZero() {
+		report.expireIn(config.EventEnd.Sub(currentTime))
+	}
+	if config.MaxLifetime > 0 && !deployment.CreationTimestamp.IsZero() {
+		report.expireIn(config.MaxLifetime - currentTime.Sub(deployment.CreationTimestamp.Time))
+	}
+	if !config.EventEnd.IsZero() && !currentTime.Before(config.EventEnd) {
+		report.Action = ActionDelete
+		report.Policy = PolicyEventEnd
+		report.Reason = fmt.Sprintf("the event ended at %s", config.EventEnd.Format(time.RFC3339))
+		return report
+	}
+	if config.MaxLifetime > 0 && !deployment.CreationTimestamp.IsZero() && currentTime.Sub(deployment.CreationTimestamp.Time) > config.MaxLifetime {
+		report.Action = ActionDelete
+		report.Policy = PolicyMaxLifetime
+		report.Reason = fmt.Sprintf("it exists for %s, longer than the max lifetime of %s", report.Age, config.MaxLifetime.String())
+		return report
+	}
+
+	if config.CrashLoopRestartThreshold > 0 && resources.crashLoopRestarts >= config.CrashLoopRestartThreshold {
+		report.Garbage = append(report.Garbage, GarbageCrashLooping)
+		if config.DeleteGarbage {
+			report.Action = ActionDelete
+			report.Policy = PolicyCrashLoop
+			report.Reason = fmt.Sprintf("it is stuck in CrashLoopBackOff after %d restarts", resources.crashLoopRestarts)
+			return report
+		}
+	}
+	if !resources.hasService && existsLongerThan(deployment.ObjectMeta, currentTime, garbageGracePeriod) {
+		report.Garbage = append(report.Garbage, GarbageMissingService)
+		if config.DeleteGarbage {
+			report.Action = ActionDelete
+			report.Policy = PolicyMissingService
+			report.Reason = "its service is missing"
+			return report
 		}
 	}
 
-	return summary
+	lastConnectedTimestampString, hasAnnotation := deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"]
+	if !hasAnnotation || lastConnectedTimestampString == "" {
+		return evaluateDeploymentWithoutLastRequest(report, deployment, currentTime, config, "it has no lastRequest annotation", SkipMissingLastRequest)
+	}
+	lastConnectedTimestamp, err := strconv.ParseInt(lastConnectedTimestampString, 10, 64)
+	if err != nil {
+		return evaluateDeploymentWithoutLastRequest(report, deployment, currentTime, config, fmt.Sprintf("it has an invalid lastRequest annotation: %v", err), SkipInvalidLastRequest)
+	}
+
+	lastRequest := time.UnixMilli(lastConnectedTimestamp)
+	inactiveFor := currentTime.Sub(lastRequest)
+	report.LastRequest = &lastRequest
+	report.InactiveFor = inactiveFor.Round(time.Second).String()
+	report.expireIn(config.MaxInactive - inactiveFor)
+
+	if inactiveFor > config.MaxInactive {
+		report.Action = ActionDelete
+		report.Policy = PolicyInactivity
+		report.Reason = fmt.Sprintf("it has been inactive for %s, longer than %s", report.InactiveFor, config.MaxInactive.String())
+	} else if config.HibernateInactive > 0 && inactiveFor > config.HibernateInactive {
+		if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 {
+			report.Reason = "it is already hibernated"
+			report.SkipReason = SkipAlreadyHibernated
+			return report
+		}
+		report.Action = ActionHibernate
+		report.Policy = PolicyInactivity
+		report.Reason = fmt.Sprintf("it has been inactive for %s, longer than %s", report.InactiveFor, config.HibernateInactive.String())
+	} else {
+		report.Reason = "it has been active recently"
+		report.SkipReason = SkipActive
+	}
+	return report
+}
+
+// evaluateDeploymentWithoutLastRequest skips deployments without a valid lastRequest annotation.
+// With garbage collection enabled their creation is used as last activity instead, so that they don't get skipped forever
+func evaluateDeploymentWithoutLastRequest(report InstanceReport, deployment appsv1.Deployment, currentTime time.Time, config CleanupConfig, reason string, skipReason SkipReason) InstanceReport {
+	report.Garbage = append(report.Garbage, GarbageMissingAnnotation)
+	if config.DeleteGarbage && !deployment.CreationTimestamp.IsZero() {
+		age := currentTime.Sub(deployment.CreationTimestamp.Time)
+		report.expireIn(config.MaxInactive - age)
+		if age > config.MaxInactive {
+			report.Action = ActionDelete
+			report.Policy = PolicyMissingAnnotation
+			report.Reason = fmt.Sprintf("%s and exists for %s, longer than %s", reason, report.Age, config.MaxInactive.String())
+			return report
+		}
+	}
+	report.Reason = reason
+	report.SkipReason = skipReason
+	return report
+}
+
+// hibernateDeployment scales the deployment down to zero replicas. The annotations with the progress of the team stay on the deployment, the balancer scales it back up once the team returns
+func hibernateDeployment(clientset kubernetes.Interface, namespace string, name string, currentTime time.Time) error {
+	patch := map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{
+				"multi-juicer.owasp-juice.shop/hibernatedAt": strconv.FormatInt(currentTime.UnixMilli(), 10),
+			},
+		},
+		"spec": map[string]any{"replicas": 0},
+	}
+	jsonBytes, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to encode patch: %w", err)
+	}
+	_, err = clientset.AppsV1().Deployments(namespace).Patch(context.Background(), name, types.MergePatchType, jsonBytes, metav1.PatchOptions{})
+	return err
 }
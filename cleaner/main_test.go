@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -51,7 +54,7 @@ func TestRunCleanup(t *testing.T) {
 		currentTime := time.Now()
 		maxInactive := time.Duration(30 * time.Minute)
 
-		summary := runCleanup(clientset, currentTime, maxInactive)
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: maxInactive})
 
 		if summary.SuccessfulDeploymentDeletions != 0 || summary.SuccessfulServiceDeletions != 0 {
 			t.Errorf("Expected no deletions, got: %v", summary)
@@ -74,7 +77,7 @@ func TestRunCleanup(t *testing.T) {
 		currentTime := time.Now()
 		maxInactive := time.Duration(30 * time.Minute)
 
-		summary := runCleanup(clientset, currentTime, maxInactive)
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: maxInactive})
 
 		if summary.SuccessfulDeploymentDeletions != 0 || summary.SuccessfulServiceDeletions != 0 {
 			t.Errorf("Expected no deletions, got: %v", summary)
@@ -87,7 +90,7 @@ func TestRunCleanup(t *testing.T) {
 		currentTime := time.Now()
 		maxInactive := time.Duration(30 * time.Minute)
 
-		summary := runCleanup(clientset, currentTime, maxInactive)
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: maxInactive})
 
 		if summary.SuccessfulDeploymentDeletions != 0 || summary.SuccessfulServiceDeletions != 0 {
 			t.Errorf("Expected no deletions, got: %v", summary)
@@ -101,7 +104,7 @@ func TestRunCleanup(t *testing.T) {
 		currentTime := time.Now()
 		maxInactive := time.Duration(30 * time.Minute)
 
-		summary := runCleanup(clientset, currentTime, maxInactive)
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: maxInactive})
 
 		if summary.SuccessfulDeploymentDeletions != 0 || summary.SuccessfulServiceDeletions != 0 {
 			t.Errorf("Expected no deletions, got: %v", summary)
@@ -118,7 +121,7 @@ func TestRunCleanup(t *testing.T) {
 		currentTime := time.Now()
 		maxInactive := time.Duration(30 * time.Minute)
 
-		summary := runCleanup(clientset, currentTime, maxInactive)
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: maxInactive})
 
 		if summary.SuccessfulDeploymentDeletions != 1 || summary.SuccessfulServiceDeletions != 1 {
 			t.Errorf("Expected 1 deployment and 1 service deletion, got: %v", summary)
@@ -135,7 +138,7 @@ func TestRunCleanup(t *testing.T) {
 		currentTime := time.Now()
 		maxInactive := time.Duration(30 * time.Minute)
 
-		summary := runCleanup(clientset, currentTime, maxInactive)
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: maxInactive})
 
 		if summary.FailedDeploymentDeletions != 1 {
 			t.Errorf("Expected 1 failed deployment deletion, got: %v", summary)
@@ -156,10 +159,220 @@ func TestRunCleanup(t *testing.T) {
 		currentTime := time.Now()
 		maxInactive := time.Duration(30 * time.Minute)
 
-		summary := runCleanup(clientset, currentTime, maxInactive)
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: maxInactive})
 
 		if summary.FailedServiceDeletions != 1 {
 			t.Errorf("Expected 1 failed service deletion, got: %v", summary)
 		}
 	})
+
+	t.Run("Inactive Deployment - Should Be Hibernated Before The Max Inactive Duration", func(t *testing.T) {
+		lastRequestTime := strconv.FormatInt(time.Now().Add(-2*time.Hour).UnixMilli(), 10)
+		clientset := fake.NewSimpleClientset(
+			createDeployment("team1", lastRequestTime),
+			createService("team1"),
+		)
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, HibernateInactive: time.Hour})
+
+		if summary.SuccessfulHibernations != 1 || summary.SuccessfulDeploymentDeletions != 0 {
+			t.Errorf("Expected 1 hibernation and no deletions, got: %v", summary)
+		}
+		deployment, err := clientset.AppsV1().Deployments(testNamespace).Get(context.Background(), "juiceshop-team1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Expected the deployment to still exist, got: %v", err)
+		}
+		if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 0 {
+			t.Errorf("Expected the deployment to be scaled to 0 replicas, got: %v", deployment.Spec.Replicas)
+		}
+		if deployment.Annotations["multi-juicer.owasp-juice.shop/hibernatedAt"] == "" {
+			t.Errorf("Expected the hibernatedAt annotation to be set")
+		}
+		if deployment.Annotations["multi-juicer.owasp-juice.shop/lastRequest"] != lastRequestTime {
+			t.Errorf("Expected the lastRequest annotation to be kept")
+		}
+	})
+
+	t.Run("Hibernated Deployment - Should Not Be Hibernated Again", func(t *testing.T) {
+		deployment := createDeployment("team1", strconv.FormatInt(time.Now().Add(-2*time.Hour).UnixMilli(), 10))
+		replicas := int32(0)
+		deployment.Spec.Replicas = &replicas
+		clientset := fake.NewSimpleClientset(deployment, createService("team1"))
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, HibernateInactive: time.Hour})
+
+		if summary.SuccessfulHibernations != 0 || summary.FailedHibernations != 0 {
+			t.Errorf("Expected no hibernations, got: %v", summary)
+		}
+	})
+
+	t.Run("Hibernated Deployment - Should Be Deleted After The Max Inactive Duration", func(t *testing.T) {
+		deployment := createDeployment("team1", strconv.FormatInt(time.Now().Add(-48*time.Hour).UnixMilli(), 10))
+		replicas := int32(0)
+		deployment.Spec.Replicas = &replicas
+		clientset := fake.NewSimpleClientset(deployment, createService("team1"))
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, HibernateInactive: time.Hour})
+
+		if summary.SuccessfulDeploymentDeletions != 1 || summary.SuccessfulServiceDeletions != 1 {
+			t.Errorf("Expected 1 deployment and 1 service deletion, got: %v", summary)
+		}
+	})
+
+	t.Run("Failure to Hibernate Deployment", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset(createDeployment("team1", strconv.FormatInt(time.Now().Add(-2*time.Hour).UnixMilli(), 10)), createService("team1"))
+
+		clientset.PrependReactor("patch", "deployments", func(action clientgotesting.Action) (handled bool, ret runtime.Object, err error) {
+			return true, nil, fmt.Errorf("failed to patch deployment")
+		})
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, HibernateInactive: time.Hour})
+
+		if summary.FailedHibernations != 1 {
+			t.Errorf("Expected 1 failed hibernation, got: %v", summary)
+		}
+	})
+
+	t.Run("Dry Run - Should Only Report The Planned Actions", func(t *testing.T) {
+		currentTime := time.Now()
+		clientset := fake.NewSimpleClientset(
+			createDeployment("team1", strconv.FormatInt(currentTime.Add(-48*time.Hour).UnixMilli(), 10)),
+			createService("team1"),
+			createDeployment("team2", strconv.FormatInt(currentTime.Add(-2*time.Hour).UnixMilli(), 10)),
+			createDeployment("team3", strconv.FormatInt(currentTime.Add(-10*time.Minute).UnixMilli(), 10)),
+		)
+
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: 24 * time.Hour, HibernateInactive: time.Hour, DryRun: true})
+
+		if !summary.DryRun || summary.PlannedDeletions != 1 || summary.PlannedHibernations != 1 {
+			t.Errorf("Expected 1 planned deletion and 1 planned hibernation, got: %v", summary)
+		}
+		if summary.SuccessfulDeploymentDeletions != 0 || summary.SuccessfulHibernations != 0 {
+			t.Errorf("Expected no changes in a dry run, got: %v", summary)
+		}
+		for _, action := range clientset.Actions() {
+			if action.GetVerb() != "list" {
+				t.Errorf("Expected the dry run to only list deployments, got a %s action", action.GetVerb())
+			}
+		}
+
+		expectedActions := map[string]CleanupAction{
+			"juiceshop-team1": ActionDelete,
+			"juiceshop-team2": ActionHibernate,
+			"juiceshop-team3": ActionSkip,
+		}
+		if len(summary.Instances) != len(expectedActions) {
+			t.Fatalf("Expected a report for every deployment, got: %v", summary.Instances)
+		}
+		for _, report := range summary.Instances {
+			if report.Action != expectedActions[report.Deployment] {
+				t.Errorf("Expected action %s for %s, got: %s", expectedActions[report.Deployment], report.Deployment, report.Action)
+			}
+		}
+	})
+
+	t.Run("Report - Explains Why An Instance Gets Deleted", func(t *testing.T) {
+		currentTime := time.Now()
+		lastRequest := time.UnixMilli(currentTime.Add(-25 * time.Hour).UnixMilli())
+		clientset := fake.NewSimpleClientset(createDeployment("team1", strconv.FormatInt(lastRequest.UnixMilli(), 10)))
+
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: 24 * time.Hour, DryRun: true})
+
+		encoded, err := json.Marshal(summary.Instances[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := fmt.Sprintf(`{"deployment":"juiceshop-team1","action":"delete","reason":"it has been inactive for %s, longer than 24h0m0s","policy":"inactivity","lastRequest":%s,"inactiveFor":"%s"}`,
+			summary.Instances[0].InactiveFor, mustMarshal(t, lastRequest), summary.Instances[0].InactiveFor)
+		if string(encoded) != expected {
+			t.Errorf("Expected report %s, got: %s", expected, encoded)
+		}
+		if !strings.HasPrefix(summary.Instances[0].InactiveFor, "25h0m") {
+			t.Errorf("Expected the instance to be inactive for 25h, got: %s", summary.Instances[0].InactiveFor)
+		}
+	})
+
+	t.Run("Protected Deployment - Should Neither Be Deleted Nor Hibernated", func(t *testing.T) {
+		protectedDeployment := createDeployment("team1", strconv.FormatInt(time.Now().Add(-48*time.Hour).UnixMilli(), 10))
+		protectedDeployment.Annotations["multi-juicer.owasp-juice.shop/protected"] = "true"
+		clientset := fake.NewSimpleClientset(protectedDeployment, createService("team1"))
+
+		summary := mustRunCleanup(t, clientset, time.Now(), CleanupConfig{MaxInactive: 24 * time.Hour, HibernateInactive: time.Hour})
+
+		if summary.SuccessfulDeploymentDeletions != 0 || summary.SuccessfulHibernations != 0 || summary.PlannedDeletions != 0 {
+			t.Errorf("Expected no deletions or hibernations, got: %v", summary)
+		}
+		if summary.Instances[0].Action != ActionSkip || summary.Instances[0].Reason != "it is protected from the automatic cleanup" {
+			t.Errorf("Expected the protected instance to be skipped, got: %v", summary.Instances[0])
+		}
+	})
+
+	t.Run("Max Lifetime - Deletes Old Instances Even If They Are Active", func(t *testing.T) {
+		currentTime := time.Now()
+		oldDeployment := createDeployment("team1", strconv.FormatInt(currentTime.Add(-time.Minute).UnixMilli(), 10))
+		oldDeployment.CreationTimestamp = metav1.NewTime(currentTime.Add(-9 * time.Hour))
+		newDeployment := createDeployment("team2", strconv.FormatInt(currentTime.Add(-time.Minute).UnixMilli(), 10))
+		newDeployment.CreationTimestamp = metav1.NewTime(currentTime.Add(-time.Hour))
+		clientset := fake.NewSimpleClientset(oldDeployment, newDeployment)
+
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: 24 * time.Hour, MaxLifetime: 8 * time.Hour})
+
+		if summary.SuccessfulDeploymentDeletions != 1 || summary.DeletionsByPolicy[PolicyMaxLifetime] != 1 {
+			t.Errorf("Expected one deletion because of the max lifetime, got: %v", summary)
+		}
+		if summary.Instances[0].Reason != "it exists for 9h0m0s, longer than the max lifetime of 8h0m0s" {
+			t.Errorf("Expected the max lifetime as reason, got: %s", summary.Instances[0].Reason)
+		}
+		if summary.Instances[1].Action != ActionSkip {
+			t.Errorf("Expected the newer instance to be skipped, got: %v", summary.Instances[1])
+		}
+	})
+
+	t.Run("Event End - Deletes All Instances After The End Of The Event", func(t *testing.T) {
+		currentTime := time.Now()
+		eventEnd := currentTime.Add(-time.Minute)
+		clientset := fake.NewSimpleClientset(
+			createDeployment("team1", strconv.FormatInt(currentTime.Add(-time.Second).UnixMilli(), 10)),
+			createDeployment("team2", ""),
+		)
+
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: 24 * time.Hour, EventEnd: eventEnd})
+
+		if summary.SuccessfulDeploymentDeletions != 2 || summary.DeletionsByPolicy[PolicyEventEnd] != 2 {
+			t.Errorf("Expected all instances to be deleted because of the end of the event, got: %v", summary)
+		}
+		expectedReason := fmt.Sprintf("the event ended at %s", eventEnd.Format(time.RFC3339))
+		for _, report := range summary.Instances {
+			if report.Reason != expectedReason {
+				t.Errorf("Expected reason '%s', got: '%s'", expectedReason, report.Reason)
+			}
+		}
+	})
+
+	t.Run("Event End - Keeps Instances Before The End Of The Event", func(t *testing.T) {
+		currentTime := time.Now()
+		clientset := fake.NewSimpleClientset(createDeployment("team1", strconv.FormatInt(currentTime.Add(-time.Second).UnixMilli(), 10)))
+
+		summary := mustRunCleanup(t, clientset, currentTime, CleanupConfig{MaxInactive: 24 * time.Hour, EventEnd: currentTime.Add(time.Hour)})
+
+		if summary.PlannedDeletions != 0 {
+			t.Errorf("Expected no deletions before the end of the event, got: %v", summary)
+		}
+	})
+}
+
+func mustMarshal(t *testing.T, value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(encoded)
+}
+
+func mustRunCleanup(t *testing.T, clientset *fake.Clientset, currentTime time.Time, config CleanupConfig) CleanupSummary {
+	summary, err := runCleanup(clientset, currentTime, config)
+	if err != nil {
+		t.Fatalf("Expected cleanup to succeed, got: %v", err)
+	}
+	return summary
 }
@@ -69,6 +69,46 @@ app.kubernetes.io/instance: cleaner-{{ .Release.Name }}
 app.kubernetes.io/part-of: multi-juicer
 {{- end -}}
 
+{{/*
+cleaner environment, shared by the cron job and the daemon deployment
+*/}}
+{{- define "multi-juicer.cleaner.env" -}}
+- name: NAMESPACE
+  value: {{ .Release.Namespace | quote }}
+- name: MAX_INACTIVE_DURATION
+  value: {{ .Values.juiceShopCleanup.gracePeriod }}
+{{- if .Values.juiceShopCleanup.dryRun }}
+- name: DRY_RUN
+  value: "true"
+{{- end }}
+{{- with .Values.juiceShopCleanup.hibernatePeriod }}
+- name: HIBERNATE_INACTIVE_DURATION
+  value: {{ . | quote }}
+{{- end }}
+{{- with .Values.juiceShopCleanup.maxLifetime }}
+- name: MAX_LIFETIME_DURATION
+  value: {{ . | quote }}
+{{- end }}
+{{- with .Values.juiceShopCleanup.eventEnd }}
+- name: EVENT_END_TIME
+  value: {{ . | quote }}
+{{- end }}
+- name: GARBAGE_COLLECTION_POLICY
+  value: {{ .Values.juiceShopCleanup.garbageCollection.policy | quote }}
+{{- with .Values.juiceShopCleanup.garbageCollection.crashLoopRestartThreshold }}
+- name: CRASH_LOOP_RESTART_THRESHOLD
+  value: {{ . | quote }}
+{{- end }}
+{{- if .Values.juiceShopCleanup.backup.enabled }}
+- name: BACKUP_PROGRESS
+  value: "true"
+{{- end }}
+{{- with .Values.juiceShopCleanup.backup.retention }}
+- name: BACKUP_RETENTION_DURATION
+  value: {{ . | quote }}
+{{- end }}
+{{- end -}}
+
 {{/*
 juice-shop labels
 */}}
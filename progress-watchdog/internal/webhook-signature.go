@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the balancer generates a webhook signing key for every team when it gets created.
+// The webhook signer sidecar in the pod of the instance signs the solution webhooks with it, see webhook-signer.go
+const webhookKeyAnnotation = "multi-juicer.owasp-juice.shop/webhookSecret"
+
+const (
+	// SignatureHeader contains the hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the webhook key of the team
+	SignatureHeader = "X-MultiJuicer-Signature"
+	// TimestampHeader contains the unix time in seconds at which the webhook got signed
+	TimestampHeader = "X-MultiJuicer-Timestamp"
+)
+
+// MaxSignatureAge limits how long a signed webhook can be replayed. Also tolerates the clock skew between the nodes
+const MaxSignatureAge = 5 * time.Minute
+
+// WebhookVerificationReason explains why a solution webhook was rejected, or why it was accepted without a signature
+type WebhookVerificationReason string
+
+const (
+	RejectedUnknownTeam      WebhookVerificationReason = "unknownTeam"
+	RejectedMissingSignature WebhookVerificationReason = "missingSignature"
+	RejectedInvalidSignature WebhookVerificationReason = "invalidSignature"
+	RejectedExpiredSignature WebhookVerificationReason = "expiredSignature"
+	RejectedLegacyNoSecret   WebhookVerificationReason = "legacyNoSecretExpired"
+	AcceptedLegacyNoSecret   WebhookVerificationReason = "legacyNoSecret"
+)
+
+var rejectedWebhooksCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "multijuicer_progress_watchdog_rejected_webhooks",
+		Help: "Number of solution webhooks rejected because of an unknown team or a missing, invalid or expired signature",
+	},
+	[]string{"reason"},
+)
+
+var unverifiedWebhooksCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "multijuicer_progress_watchdog_unverified_webhooks",
+		Help: "Number of solution webhooks accepted without verifying a signature, because the team was created before the webhook keys were introduced",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	prometheus.MustRegister(rejectedWebhooksCounter)
+	prometheus.MustRegister(unverifiedWebhooksCounter)
+}
+
+// SignWebhook returns the signature of the webhook body signed at the given time
+func SignWebhook(key string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(formatTimestamp(timestamp)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func formatTimestamp(timestamp time.Time) string {
+	return strconv.FormatInt(timestamp.Unix(), 10)
+}
+
+// WebhookVerifier checks the signatures of solution webhooks against the webhook keys of the teams
+type WebhookVerifier struct {
+	// LegacyAcceptedUntil ends the transition window in which webhooks of teams created before the webhook keys were introduced are still accepted. Zero rejects them
+	LegacyAcceptedUntil time.Time
+}
+
+// Verify checks the signature of the webhook body with the webhook key from the annotations of the team.
+// Webhooks of teams without key are accepted with AcceptedLegacyNoSecret until LegacyAcceptedUntil, so that the caller can log them
+func (v *WebhookVerifier) Verify(annotations map[string]string, signature string, timestamp string, body []byte, now time.Time) (WebhookVerificationReason, bool) {
+	key := annotations[webhookKeyAnnotation]
+	if key == "" {
+		if now.Before(v.LegacyAcceptedUntil) {
+			return AcceptedLegacyNoSecret, true
+		}
+		return RejectedLegacyNoSecret, false
+	}
+	if signature == "" || timestamp == "" {
+		return RejectedMissingSignature, false
+	}
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return RejectedInvalidSignature, false
+	}
+	// the timestamp is part of the signature, so it is only trusted after the signature has been verified
+	expected := SignWebhook(key, time.Unix(signedAt, 0), body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return RejectedInvalidSignature, false
+	}
+	if age := now.Sub(time.Unix(signedAt, 0)); age > MaxSignatureAge || age < -MaxSignatureAge {
+		return RejectedExpiredSignature, false
+	}
+	return "", true
+}
+
+// RecordRejectedWebhook logs and counts a rejected solution webhook
+func RecordRejectedWebhook(team string, remoteAddr string, reason WebhookVerificationReason) {
+	logger.Printf("Rejected solution webhook for team '%s' from '%s': %s", team, remoteAddr, reason)
+	rejectedWebhooksCounter.WithLabelValues(string(reason)).Inc()
+}
+
+// RecordUnverifiedWebhook logs and counts a solution webhook accepted without verifying a signature
+func RecordUnverifiedWebhook(team string, remoteAddr string, reason WebhookVerificationReason) {
+	logger.Printf("Accepted solution webhook for team '%s' from '%s' without signature: %s", team, remoteAddr, reason)
+	unverifiedWebhooksCounter.WithLabelValues(string(reason)).Inc()
+}
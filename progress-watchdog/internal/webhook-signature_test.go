@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookVerifier(t *testing.T) {
+	now := time.Unix(1_729_259_666, 0)
+	annotations := map[string]string{webhookKeyAnnotation: "team-key"}
+	body := []byte(`{"solution":{"challenge":"scoreBoardChallenge"}}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	verifier := WebhookVerifier{}
+
+	reason, ok := verifier.Verify(annotations, SignWebhook("team-key", now, body), timestamp, body, now)
+	assert.True(t, ok, "Should accept webhooks signed with the key of the team")
+	assert.Equal(t, WebhookVerificationReason(""), reason)
+
+	reason, ok = verifier.Verify(annotations, "", "", body, now)
+	assert.False(t, ok)
+	assert.Equal(t, RejectedMissingSignature, reason, "Should reject unsigned webhooks")
+
+	reason, ok = verifier.Verify(annotations, SignWebhook("other-key", now, body), timestamp, body, now)
+	assert.False(t, ok)
+	assert.Equal(t, RejectedInvalidSignature, reason, "Should reject webhooks signed with the key of another team")
+
+	reason, ok = verifier.Verify(annotations, SignWebhook("team-key", now, body), timestamp, []byte(`{"solution":{"challenge":"nullByteChallenge"}}`), now)
+	assert.False(t, ok)
+	assert.Equal(t, RejectedInvalidSignature, reason, "Should reject webhooks with a modified body")
+
+	reason, ok = verifier.Verify(annotations, SignWebhook("team-key", now, body), strconv.FormatInt(now.Unix()+1, 10), body, now)
+	assert.False(t, ok)
+	assert.Equal(t, RejectedInvalidSignature, reason, "Should reject webhooks with a modified timestamp")
+
+	reason, ok = verifier.Verify(annotations, SignWebhook("team-key", now, body), timestamp, body, now.Add(MaxSignatureAge+time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, RejectedExpiredSignature, reason, "Should reject replayed webhooks")
+}
+
+func TestWebhookVerifierLegacyTeams(t *testing.T) {
+	now := time.Unix(1_729_259_666, 0)
+
+	reason, ok := (&WebhookVerifier{}).Verify(map[string]string{}, "", "", []byte("{}"), now)
+	assert.False(t, ok, "Should reject webhooks of teams without key if no transition window is configured")
+	assert.Equal(t, RejectedLegacyNoSecret, reason)
+
+	transition := WebhookVerifier{LegacyAcceptedUntil: now.Add(time.Hour)}
+	reason, ok = transition.Verify(map[string]string{}, "", "", []byte("{}"), now)
+	assert.True(t, ok, "Should accept webhooks of teams created before the webhook keys were introduced during the transition window")
+	assert.Equal(t, AcceptedLegacyNoSecret, reason)
+
+	reason, ok = transition.Verify(map[string]string{}, "", "", []byte("{}"), now.Add(2*time.Hour))
+	assert.False(t, ok)
+	assert.Equal(t, RejectedLegacyNoSecret, reason)
+}
+
+func TestWebhookSigner(t *testing.T) {
+	var received *http.Request
+	var receivedBody []byte
+	watchdog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	defer watchdog.Close()
+
+	signer := httptest.NewServer(NewWebhookSigner("team-key", watchdog.URL+"/team/foobar/webhook", watchdog.Client()))
+	defer signer.Close()
+
+	body := []byte(`{"solution":{"challenge":"scoreBoardChallenge"}}`)
+	res, err := http.Post(signer.URL, "application/json", bytes.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.Equal(t, "/team/foobar/webhook", received.URL.Path)
+	assert.Equal(t, body, receivedBody)
+	reason, ok := (&WebhookVerifier{}).Verify(
+		map[string]string{webhookKeyAnnotation: "team-key"},
+		received.Header.Get(SignatureHeader),
+		received.Header.Get(TimestampHeader),
+		receivedBody,
+		time.Now(),
+	)
+	assert.True(t, ok, "Should forward webhooks the watchdog accepts, got %s", reason)
+}
@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxWebhookBodySize limits the solution webhooks, they only contain the solved challenge and some metadata of the instance
+const maxWebhookBodySize = 64 * 1024
+
+// NewWebhookSigner returns the handler of the webhook signer sidecar running next to every juice shop instance.
+// The juice shop can't sign its solution webhooks or send custom headers, so it sends them to the sidecar which signs them with the webhook key of the team and forwards them to the progress watchdog.
+// The key is only passed to the sidecar container, so it neither ends up in the environment of the juice shop nor in any url or access log
+func NewWebhookSigner(key string, targetUrl string, client *http.Client) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(responseWriter, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(responseWriter, req.Body, maxWebhookBodySize))
+		if err != nil {
+			http.Error(responseWriter, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		forward, err := http.NewRequestWithContext(req.Context(), http.MethodPost, targetUrl, bytes.NewReader(body))
+		if err != nil {
+			http.Error(responseWriter, "failed to forward webhook", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		forward.Header.Set("Content-Type", "application/json")
+		forward.Header.Set(TimestampHeader, formatTimestamp(now))
+		forward.Header.Set(SignatureHeader, SignWebhook(key, now, body))
+
+		res, err := client.Do(forward)
+		if err != nil {
+			logger.Printf("Failed to forward solution webhook to '%s': %s", targetUrl, err)
+			http.Error(responseWriter, "failed to forward webhook", http.StatusBadGateway)
+			return
+		}
+		defer res.Body.Close()
+		responseWriter.WriteHeader(res.StatusCode)
+		io.Copy(responseWriter, res.Body)
+	})
+}
@@ -3,14 +3,19 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"sort"
+	"time"
 
 	"github.com/juice-shop/multi-juicer/progress-watchdog/internal"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -50,8 +55,24 @@ var logger = log.New(os.Stdout, "", log.LstdFlags)
 var namespace = os.Getenv("NAMESPACE")
 
 func main() {
+	webhookSigner := flag.Bool("webhook-signer", false, "run as webhook signer sidecar next to a juice shop instance instead of as progress watchdog. Signs the solution webhooks with WEBHOOK_SIGNING_KEY and forwards them to WEBHOOK_TARGET_URL")
+	flag.Parse()
+	if *webhookSigner {
+		runWebhookSigner()
+		return
+	}
+
 	logger.Println("Starting ProgressWatchdog")
 
+	var verifier internal.WebhookVerifier
+	if legacyAcceptedUntil := os.Getenv("LEGACY_WEBHOOKS_ACCEPTED_UNTIL"); legacyAcceptedUntil != "" {
+		parsed, err := time.Parse(time.RFC3339, legacyAcceptedUntil)
+		if err != nil {
+			logger.Fatalf("Could not parse configured LEGACY_WEBHOOKS_ACCEPTED_UNTIL: '%s'. Time has to be formatted according to RFC 3339, e.g. \"2024-10-18T18:00:00+02:00\".", legacyAcceptedUntil)
+		}
+		verifier.LegacyAcceptedUntil = parsed
+	}
+
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		panic(err.Error())
@@ -69,17 +90,38 @@ func main() {
 	router := http.NewServeMux()
 	router.HandleFunc("POST /team/{team}/webhook", func(responseWriter http.ResponseWriter, req *http.Request) {
 		team := req.PathValue("team")
-		var webhook JuiceShopWebhook
-
-		err := json.NewDecoder(req.Body).Decode(&webhook)
+		body, err := io.ReadAll(http.MaxBytesReader(responseWriter, req.Body, 64*1024))
 		if err != nil {
-			http.Error(responseWriter, "invalid json", http.StatusBadRequest)
+			http.Error(responseWriter, "invalid body", http.StatusBadRequest)
 			return
 		}
 
 		deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.Background(), fmt.Sprintf("juiceshop-%s", team), metav1.GetOptions{})
-		if err != nil {
+		if errors.IsNotFound(err) {
+			internal.RecordRejectedWebhook(team, req.RemoteAddr, internal.RejectedUnknownTeam)
+			http.Error(responseWriter, "team not found", http.StatusNotFound)
+			return
+		} else if err != nil {
 			logger.Print(fmt.Errorf("failed to get deployment for team: '%s' received via in webhook: %w", team, err))
+			http.Error(responseWriter, "failed to get team", http.StatusInternalServerError)
+			return
+		}
+
+		// signed by the webhook signer sidecar in the pod of the instance
+		reason, ok := verifier.Verify(deployment.Annotations, req.Header.Get(internal.SignatureHeader), req.Header.Get(internal.TimestampHeader), body, time.Now())
+		if !ok {
+			internal.RecordRejectedWebhook(team, req.RemoteAddr, reason)
+			http.Error(responseWriter, "invalid webhook signature", http.StatusForbidden)
+			return
+		} else if reason == internal.AcceptedLegacyNoSecret {
+			internal.RecordUnverifiedWebhook(team, req.RemoteAddr, reason)
+		}
+
+		var webhook JuiceShopWebhook
+		err = json.Unmarshal(body, &webhook)
+		if err != nil {
+			http.Error(responseWriter, "invalid json", http.StatusBadRequest)
+			return
 		}
 
 		challengeStatusJson := "[]"
@@ -114,6 +156,8 @@ func main() {
 		responseWriter.Write([]byte("ok"))
 	})
 
+	router.Handle("GET /metrics", promhttp.Handler())
+
 	router.HandleFunc("GET /ready", func(responseWriter http.ResponseWriter, req *http.Request) {
 		responseWriter.WriteHeader(http.StatusOK)
 		responseWriter.Write([]byte("ok"))
@@ -126,3 +170,25 @@ func main() {
 	logger.Println("Starting web server listening for Solution Webhooks on :8080")
 	server.ListenAndServe()
 }
+
+// runWebhookSigner signs the solution webhooks of the juice shop instance it runs next to, see internal.NewWebhookSigner
+func runWebhookSigner() {
+	key := os.Getenv("WEBHOOK_SIGNING_KEY")
+	targetUrl := os.Getenv("WEBHOOK_TARGET_URL")
+	if key == "" || targetUrl == "" {
+		logger.Fatal("The webhook signer requires WEBHOOK_SIGNING_KEY and WEBHOOK_TARGET_URL to be set")
+	}
+	listenAddress := os.Getenv("WEBHOOK_SIGNER_ADDRESS")
+	if listenAddress == "" {
+		listenAddress = "127.0.0.1:8081"
+	}
+
+	server := &http.Server{
+		Addr:    listenAddress,
+		Handler: internal.NewWebhookSigner(key, targetUrl, &http.Client{Timeout: 10 * time.Second}),
+	}
+	logger.Printf("Starting webhook signer listening on %s", listenAddress)
+	if err := server.ListenAndServe(); err != nil {
+		logger.Fatal(err)
+	}
+}